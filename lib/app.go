@@ -42,6 +42,16 @@ type App struct {
 	HideHelp bool
 	// Boolean to hide built-in version flag and the VERSION section of help
 	HideVersion bool
+	// Default path of a JSON file with values for the global flags, see
+	// ConfigFlag. Config files are disabled if empty.
+	ConfigFile string
+	// Keys of the config file holding other settings than flags, read with
+	// Context.ConfigSection
+	ConfigSections []string
+	// The loaded config file, its path and the selected profile
+	config     ConfigFile
+	configPath string
+	profile    string
 	// Populate on app startup, only gettable through method Categories()
 	categories CommandCategories
 	// An action to execute when the bash-completion flag is set
@@ -83,6 +93,11 @@ type App struct {
 	Metadata map[string]interface{}
 	// Carries a function which returns app specific info.
 	ExtraInfo func() map[string]string
+	// HelpFuncs are added to the functions of the help templates, e.g. to
+	// color headings. They replace default functions of the same name.
+	HelpFuncs map[string]interface{}
+	// HelpWriter receives the help output, Writer if nil
+	HelpWriter io.Writer
 	// CustomAppHelpTemplate the text template for app help topic.
 	// cli.go uses text/template to render templates. You can
 	// render custom help text by setting this variable.
@@ -91,6 +106,10 @@ type App struct {
 	// single-character bool arguments into one
 	// i.e. foobar -o -v -> foobar -ov
 	UseShortOptionHandling bool
+	// PersistentFlags lets the app flags also be given after the command
+	// name, up to a --, e.g. app run --port 3000. Flags of the command take
+	// precedence over app flags of the same name.
+	PersistentFlags bool
 
 	didSetup bool
 }
@@ -158,6 +177,11 @@ func (a *App) setup() {
 		a.appendFlag(VersionFlag)
 	}
 
+	if a.ConfigFile != "" {
+		a.appendFlag(ConfigFlag)
+		a.appendFlag(ProfileFlag)
+	}
+
 	a.categories = CommandCategories{}
 	for _, command := range a.Commands {
 		a.categories = a.categories.AddCommand(command.Category, command)
@@ -196,16 +220,30 @@ func (a *App) Run(arguments []string) (err error) {
 
 	set, err := a.newFlagSet()
 	if err != nil {
+		_, _ = fmt.Fprintln(a.Writer, err)
 		return err
 	}
 
 	err = parseIter(set, a, arguments[1:], shellComplete)
+	if err == nil {
+		if lifted, ok := a.liftFlags(arguments, set); ok {
+			if set, err = a.newFlagSet(); err == nil {
+				err = parseIter(set, a, lifted[1:], shellComplete)
+			}
+		}
+	}
+	if err == nil {
+		err = a.applyConfigFile(set)
+	}
 	nerr := normalizeFlags(a.Flags, set)
 	context := NewContext(a, set, nil)
 	if nerr != nil {
 		_, _ = fmt.Fprintln(a.Writer, nerr)
 		return nerr
 	}
+	if err == nil {
+		err = validateFlags(a.Flags, set)
+	}
 	context.shellComplete = shellComplete
 
 	if checkCompletions(context) {
@@ -222,6 +260,16 @@ func (a *App) Run(arguments []string) (err error) {
 		return err
 	}
 
+	if !a.HideHelp && checkHelp(context) {
+		_ = ShowAppHelp(context)
+		return nil
+	}
+
+	if !a.HideVersion && checkVersion(context) {
+		ShowVersion(context)
+		return nil
+	}
+
 	cerr := checkRequiredFlags(a.Flags, context)
 	if cerr != nil {
 		return cerr
@@ -323,6 +371,10 @@ func (a *App) runAsSubcommand(ctx *Context) (err error) {
 		if checkSubcommandHelp(context) {
 			return nil
 		}
+	} else {
+		if checkCommandHelp(ctx, context.Args().First()) {
+			return nil
+		}
 	}
 
 	cerr := checkRequiredFlags(a.Flags, context)
@@ -383,9 +435,9 @@ func (a *App) Categories() CommandCategories {
 	return a.categories
 }
 
-// visibleCategories returns a slice of categories and commands that are
+// VisibleCategories returns a slice of categories and commands that are
 // Hidden=false
-func (a *App) visibleCategories() []*CommandCategory {
+func (a *App) VisibleCategories() []*CommandCategory {
 	var ret []*CommandCategory
 	for _, category := range a.categories {
 		if visible := func() *CommandCategory {
@@ -419,8 +471,9 @@ func (a *App) VisibleFlags() []Flag {
 }
 
 func (a *App) hasFlag(flag Flag) bool {
+	// flags with a Validator can't be compared
 	for _, f := range a.Flags {
-		if flag == f {
+		if flag.GetName() == f.GetName() {
 			return true
 		}
 	}
@@ -0,0 +1,184 @@
+package gin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BenchSample is one rebuild cycle of gin bench
+type BenchSample struct {
+	// Build is how long the build took
+	Build float64 `json:"build_ms"`
+	// Ready is how long it took from the change until the app accepted
+	// connections, including the build
+	Ready float64 `json:"ready_ms"`
+}
+
+// BenchStats summarizes the durations of the cycles
+type BenchStats struct {
+	Min     float64 `json:"min_ms"`
+	Average float64 `json:"average_ms"`
+	P95     float64 `json:"p95_ms"`
+	Max     float64 `json:"max_ms"`
+}
+
+// BenchResult is the result of gin bench, which can be saved as a baseline
+type BenchResult struct {
+	Time time.Time `json:"time"`
+	// Flags describe the build, e.g. "-tags dev -race", to tell baselines
+	// apart
+	Flags   string        `json:"flags,omitempty"`
+	Build   BenchStats    `json:"build"`
+	Ready   BenchStats    `json:"ready"`
+	Samples []BenchSample `json:"samples"`
+}
+
+// NewBenchResult summarizes samples
+func NewBenchResult(flags string, samples []BenchSample) BenchResult {
+	builds := make([]float64, len(samples))
+	ready := make([]float64, len(samples))
+	for i, sample := range samples {
+		builds[i], ready[i] = sample.Build, sample.Ready
+	}
+	return BenchResult{
+		Time:    time.Now(),
+		Flags:   flags,
+		Build:   benchStats(builds),
+		Ready:   benchStats(ready),
+		Samples: samples,
+	}
+}
+
+// benchStats summarizes durations, the 95th percentile by nearest rank
+func benchStats(durations []float64) BenchStats {
+	if len(durations) == 0 {
+		return BenchStats{}
+	}
+	sorted := append([]float64{}, durations...)
+	sort.Float64s(sorted)
+
+	total := 0.0
+	for _, d := range sorted {
+		total += d
+	}
+	rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+	return BenchStats{
+		Min:     sorted[0],
+		Average: total / float64(len(sorted)),
+		P95:     sorted[rank],
+		Max:     sorted[len(sorted)-1],
+	}
+}
+
+// ReadBenchResult reads a result saved with Save
+func ReadBenchResult(file string) (BenchResult, error) {
+	var result BenchResult
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return result, err
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, fmt.Errorf("%s: %v", file, err)
+	}
+	return result, nil
+}
+
+// Save writes the result to file as JSON
+func (r BenchResult) Save(file string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(data, '\n'), 0644)
+}
+
+// Change returns the relative change from baseline to current, e.g. -0.25
+// if current is 25% faster, 0 without a baseline
+func Change(baseline, current float64) float64 {
+	if baseline == 0 {
+		return 0
+	}
+	return (current - baseline) / baseline
+}
+
+// WaitServing waits until addr accepts TCP connections and returns when it
+// did, or an error after timeout
+func WaitServing(addr string, timeout time.Duration) (time.Time, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+			return time.Now(), nil
+		}
+		if time.Now().After(deadline) {
+			return time.Time{}, fmt.Errorf("the app didn't accept connections on %s within %s: %v", addr, timeout, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Sentinel is a file gin bench changes before every cycle, so the build
+// can't reuse its last result. Restore puts back its original content.
+type Sentinel struct {
+	path     string
+	original []byte
+	mode     os.FileMode
+
+	mu      sync.Mutex
+	changes int
+}
+
+// NewSentinel remembers the content of the file at path
+func NewSentinel(path string) (*Sentinel, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+	original, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Sentinel{path: path, original: original, mode: info.Mode().Perm()}, nil
+}
+
+// Touch changes the file by appending another empty line, which the go
+// build cache sees as a new version of the file. A nil Sentinel does
+// nothing.
+func (s *Sentinel) Touch() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changes++
+	content := append(append([]byte{}, s.original...), make([]byte, s.changes)...)
+	for i := len(s.original); i < len(content); i++ {
+		content[i] = '\n'
+	}
+	return ioutil.WriteFile(s.path, content, s.mode)
+}
+
+// Restore writes back the original content of the file if it was changed
+func (s *Sentinel) Restore() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.changes == 0 {
+		return nil
+	}
+	s.changes = 0
+	return ioutil.WriteFile(s.path, s.original, s.mode)
+}
@@ -0,0 +1,155 @@
+package gin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// buildHistoryMax is the number of recent builds kept in memory, the
+// summary covers all of them
+const buildHistoryMax = 1000
+
+// BuildRecord describes a finished build
+type BuildRecord struct {
+	Time time.Time `json:"time"`
+	// Cause summarizes the changes built, empty for the first build
+	Cause string `json:"cause,omitempty"`
+	// Files are the changed files and the triggers which requested the
+	// build, relative to the working directory
+	Files    []string `json:"files,omitempty"`
+	Duration float64  `json:"duration_ms"`
+	Failed   bool     `json:"failed"`
+}
+
+// BuildSummary aggregates builds
+type BuildSummary struct {
+	Since       time.Time `json:"since"`
+	Builds      int       `json:"builds"`
+	Failures    int       `json:"failures"`
+	FailureRate float64   `json:"failure_rate"`
+	Average     float64   `json:"average_ms"`
+	Fastest     float64   `json:"fastest_ms"`
+	Slowest     float64   `json:"slowest_ms"`
+}
+
+// String summarizes the builds in a line, e.g. "12 builds, 2 failed (17%),
+// 1.24s on average"
+func (s BuildSummary) String() string {
+	plural := "s"
+	if s.Builds == 1 {
+		plural = ""
+	}
+	return fmt.Sprintf("%d build%s, %d failed (%.0f%%), %s on average", s.Builds, plural, s.Failures, s.FailureRate*100,
+		time.Duration(s.Average*float64(time.Millisecond)).Round(10*time.Millisecond))
+}
+
+// BuildStats are the summary and the recent builds, most recent last
+type BuildStats struct {
+	Summary BuildSummary  `json:"summary"`
+	Builds  []BuildRecord `json:"builds"`
+}
+
+// BuildHistory records the builds of a session in memory, and appends them
+// to a file as lines of JSON if it has one
+type BuildHistory struct {
+	file string
+
+	mu      sync.Mutex
+	records []BuildRecord
+	summary BuildSummary
+	total   float64
+}
+
+// NewBuildHistory creates a BuildHistory appending builds to file, if not
+// empty
+func NewBuildHistory(file string) *BuildHistory {
+	return &BuildHistory{file: file, summary: BuildSummary{Since: time.Now()}}
+}
+
+// ReadBuildHistory returns the stats of the builds appended to file
+func ReadBuildHistory(file string) (BuildStats, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return BuildStats{}, err
+	}
+	defer f.Close()
+
+	h := NewBuildHistory("")
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var record BuildRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return BuildStats{}, fmt.Errorf("%s:%d: %v", file, line, err)
+		}
+		if h.summary.Builds == 0 {
+			h.summary.Since = record.Time
+		}
+		h.add(record)
+	}
+	return h.Stats(), scanner.Err()
+}
+
+// Add records a build, appending it to the file of the history
+func (h *BuildHistory) Add(record BuildRecord) error {
+	h.mu.Lock()
+	h.add(record)
+	h.mu.Unlock()
+
+	if h.file == "" {
+		return nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// add records a build in memory and updates the summary
+func (h *BuildHistory) add(record BuildRecord) {
+	h.records = append(h.records, record)
+	if len(h.records) > buildHistoryMax {
+		h.records = h.records[len(h.records)-buildHistoryMax:]
+	}
+
+	s := &h.summary
+	s.Builds++
+	if record.Failed {
+		s.Failures++
+	}
+	s.FailureRate = float64(s.Failures) / float64(s.Builds)
+	h.total += record.Duration
+	s.Average = h.total / float64(s.Builds)
+	if s.Builds == 1 || record.Duration < s.Fastest {
+		s.Fastest = record.Duration
+	}
+	if record.Duration > s.Slowest {
+		s.Slowest = record.Duration
+	}
+}
+
+// Summary returns the summary of the builds
+func (h *BuildHistory) Summary() BuildSummary {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.summary
+}
+
+// Stats returns the summary and the recent builds
+func (h *BuildHistory) Stats() BuildStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return BuildStats{Summary: h.summary, Builds: append([]BuildRecord{}, h.records...)}
+}
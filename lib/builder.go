@@ -1,17 +1,109 @@
 package gin
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 )
 
+// transientBuildErrors match go command errors caused by the network or the
+// module proxy rather than the code, e.g. module download timeouts
+var transientBuildErrors = regexp.MustCompile(`(?i)dial tcp|i/o timeout|TLS handshake timeout|connection (refused|reset|timed out)|no such host|temporary failure in name resolution|unexpected EOF|server response: 5\d\d|(502|503|504) (Bad Gateway|Service Unavailable|Gateway Time-?out)`)
+
+// TransientBuildError reports whether the errors of a build look caused by
+// the network, so building again later may succeed
+func TransientBuildError(errors string) bool {
+	return transientBuildErrors.MatchString(errors)
+}
+
+// MainPackage returns an error unless dir contains a main package, e.g. the
+// target of a build
+func MainPackage(dir string) error {
+	command := exec.Command("go", "list", "-f", "{{.Name}}", ".")
+	command.Dir = dir
+	out, err := command.CombinedOutput()
+	name := strings.TrimSpace(string(out))
+	switch {
+	case err != nil:
+		if name == "" {
+			return err
+		}
+		return errors.New(name)
+	case name != "main":
+		return fmt.Errorf("%s contains package %s, expected a main package", dir, name)
+	}
+	return nil
+}
+
+// TargetBinary returns the default name of the binary built from the main
+// package in target, e.g. gin-server for ./cmd/server
+func TargetBinary(target string) string {
+	if abs, err := filepath.Abs(target); err == nil {
+		target = abs
+	}
+	return "gin-" + filepath.Base(target)
+}
+
+// ErrBuildCanceled is returned by BuildContext when its context is done
+// before the build finished
+var ErrBuildCanceled = errors.New("build canceled")
+
 type Builder interface {
 	Build() error
+	BuildContext(ctx context.Context) error
 	Binary() string
 	Errors() string
+	Diagnostics() []Diagnostic
+	SetFlags(BuildFlags)
+	Warm() error
+}
+
+// BuildFlags are go build options composed by the Builder in front of the
+// additional build arguments
+type BuildFlags struct {
+	Tags    string
+	LDFlags string
+	GCFlags string
+	Race    bool
+	// GOOS and GOARCH cross-compile the binary for another platform
+	GOOS   string
+	GOARCH string
+	// VersionVar, e.g. "main.version", is set to "dev-<timestamp>" through
+	// -ldflags -X on every build when not empty
+	VersionVar string
+}
+
+// args returns the go build arguments for the flags
+func (f BuildFlags) args(now time.Time) []string {
+	var args []string
+	if f.Tags != "" {
+		args = append(args, "-tags", f.Tags)
+	}
+	if f.Race {
+		args = append(args, "-race")
+	}
+	if f.GCFlags != "" {
+		args = append(args, "-gcflags", f.GCFlags)
+	}
+
+	ldflags := f.LDFlags
+	if f.VersionVar != "" {
+		ldflags = strings.TrimSpace(ldflags + " -X " + f.VersionVar + "=dev-" + now.Format("20060102150405"))
+	}
+	if ldflags != "" {
+		args = append(args, "-ldflags", ldflags)
+	}
+
+	return args
 }
 
 type builder struct {
@@ -21,6 +113,7 @@ type builder struct {
 	useGodep  bool
 	wd        string
 	buildArgs []string
+	flags     BuildFlags
 }
 
 func NewBuilder(dir string, bin string, useGodep bool, wd string, buildArgs []string) Builder {
@@ -46,23 +139,58 @@ func (b *builder) Errors() string {
 	return b.errors
 }
 
+// Diagnostics returns the located errors of the last build
+func (b *builder) Diagnostics() []Diagnostic {
+	dir := b.dir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(b.wd, dir)
+	}
+	return ParseDiagnostics(b.errors, dir)
+}
+
+func (b *builder) SetFlags(flags BuildFlags) {
+	b.flags = flags
+}
+
 func (b *builder) Build() error {
-	args := append([]string{"go", "build", "-o", filepath.Join(b.wd, b.binary)}, b.buildArgs...)
+	return b.BuildContext(context.Background())
+}
 
-	var command *exec.Cmd
-	if b.useGodep {
-		args = append([]string{"godep"}, args...)
-	}
-	command = exec.Command(args[0], args[1:]...)
+// BuildContext builds like Build, but kills the go command and the compilers
+// and linkers it started once ctx is done. The output of a canceled build is
+// discarded, so the errors of the last finished build are kept.
+func (b *builder) BuildContext(ctx context.Context) error {
+	args := append([]string{"build", "-o", filepath.Join(b.wd, b.binary)}, b.flags.args(time.Now())...)
+	args = append(args, b.buildArgs...)
 
-	command.Dir = b.dir
+	command := b.goCommand(args...)
+	setProcessGroup(command)
+	var output bytes.Buffer
+	command.Stdout = &output
+	command.Stderr = &output
 
-	output, err := command.CombinedOutput()
+	if err := command.Start(); err != nil {
+		b.errors = err.Error()
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- command.Wait()
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		killProcess(command.Process, true)
+		<-done
+		return ErrBuildCanceled
+	}
 
 	if command.ProcessState.Success() {
 		b.errors = ""
 	} else {
-		b.errors = string(output)
+		b.errors = output.String()
 	}
 
 	if len(b.errors) > 0 {
@@ -71,3 +199,96 @@ func (b *builder) Build() error {
 
 	return err
 }
+
+// Warm compiles all packages below the build directory with the same flags
+// as Build, discarding the results, so later builds hit a hot build cache.
+func (b *builder) Warm() error {
+	args := append([]string{"build"}, b.flags.args(time.Now())...)
+	args = append(args, "./...")
+
+	output, err := b.goCommand(args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("warming build cache: %v\n%s", err, output)
+	}
+	return nil
+}
+
+// goCommand returns a go command run in the build directory for the target
+// platform
+func (b *builder) goCommand(args ...string) *exec.Cmd {
+	args = append([]string{"go"}, args...)
+	if b.useGodep {
+		args = append([]string{"godep"}, args...)
+	}
+	command := exec.Command(args[0], args[1:]...)
+	traceCommand(args[0], args[1:]...)
+
+	command.Dir = b.dir
+	if b.flags.GOOS != "" || b.flags.GOARCH != "" {
+		env := Env{}
+		if b.flags.GOOS != "" {
+			env["GOOS"] = b.flags.GOOS
+		}
+		if b.flags.GOARCH != "" {
+			env["GOARCH"] = b.flags.GOARCH
+		}
+		command.Env = env.Environ(os.Environ())
+	}
+
+	return command
+}
+
+// BuildQueue runs one build at a time. Starting a build while another runs
+// cancels that one, and the new build waits in the background for it to
+// stop first, so the latest sources are built as soon as possible.
+type BuildQueue struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Running reports whether a build is running
+func (q *BuildQueue) Running() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.done == nil {
+		return false
+	}
+	select {
+	case <-q.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Run cancels the running build, if any, and runs fn in the background once
+// it returned, with a context canceled by the next call. It reports whether
+// a build was interrupted.
+func (q *BuildQueue) Run(fn func(ctx context.Context)) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	interrupted := false
+	previous := q.done
+	if q.cancel != nil {
+		select {
+		case <-q.done:
+		default:
+			interrupted = true
+		}
+		q.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	q.cancel, q.done = cancel, done
+	go func() {
+		defer close(done)
+		if previous != nil {
+			<-previous
+		}
+		fn(ctx)
+	}()
+	return interrupted
+}
@@ -0,0 +1,71 @@
+package gin
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBuildQueueCancelsRunningBuild(t *testing.T) {
+	q := &BuildQueue{}
+
+	started := make(chan struct{})
+	stale := make(chan struct{})
+	if q.Run(func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		// keeps running for a while after being canceled, like go build
+		time.Sleep(50 * time.Millisecond)
+		close(stale)
+	}) {
+		t.Error("the first build interrupted another one")
+	}
+	<-started
+
+	latest := make(chan struct{})
+	returned := make(chan bool)
+	go func() {
+		returned <- q.Run(func(ctx context.Context) {
+			select {
+			case <-stale:
+			default:
+				t.Error("the next build started before the canceled one stopped")
+			}
+			close(latest)
+		})
+	}()
+
+	select {
+	case interrupted := <-returned:
+		if !interrupted {
+			t.Error("Run didn't report the interrupted build")
+		}
+	case <-time.After(20 * time.Millisecond):
+		t.Fatal("Run waited for the canceled build to stop")
+	}
+	if !q.Running() {
+		t.Error("the queue isn't running while builds are pending")
+	}
+
+	select {
+	case <-latest:
+	case <-time.After(time.Second):
+		t.Fatal("the latest build didn't run")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if q.Running() {
+		t.Error("the queue is still running after the latest build")
+	}
+}
+
+func TestBuildQueueFinishedBuild(t *testing.T) {
+	q := &BuildQueue{}
+	done := make(chan struct{})
+	q.Run(func(ctx context.Context) { close(done) })
+	<-done
+	time.Sleep(10 * time.Millisecond)
+
+	if q.Run(func(ctx context.Context) {}) {
+		t.Error("Run reported a finished build as interrupted")
+	}
+}
@@ -0,0 +1,161 @@
+package gin
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetainedBuild is a previous binary kept by a BuildStore
+type RetainedBuild struct {
+	ID   int       `json:"id"`
+	Time time.Time `json:"time"`
+	Size int64     `json:"size"`
+}
+
+// BuildStore keeps copies of the last successful builds in the build
+// directory below StateDir, so they can be swapped in without rebuilding
+type BuildStore struct {
+	dir    string
+	binary string
+	keep   int
+	mu     sync.Mutex
+	next   int
+}
+
+// NewBuildStore opens the retained builds of binary, the path of the binary
+// built for the project in wd, keeping at most keep builds
+func NewBuildStore(wd string, binary string, keep int) (*BuildStore, error) {
+	s := &BuildStore{
+		dir:    filepath.Join(wd, StateDir, "build"),
+		binary: binary,
+		keep:   keep,
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, err
+	}
+
+	builds, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(builds) > 0 {
+		s.next = builds[len(builds)-1].ID
+	}
+	s.next++
+
+	return s, nil
+}
+
+// Retain keeps the current binary, removing the oldest builds beyond the
+// limit
+func (s *BuildStore) Retain() (*RetainedBuild, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.next
+	// the go tool replaces the binary instead of writing into it, so a hard
+	// link keeps this build intact
+	if err := os.Link(s.binary, s.path(id)); err != nil {
+		if err := copyFile(s.binary, s.path(id)); err != nil {
+			return nil, err
+		}
+	}
+	s.next++
+
+	builds, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for len(builds) > s.keep {
+		os.Remove(s.path(builds[0].ID))
+		builds = builds[1:]
+	}
+
+	return s.Get(id)
+}
+
+// List returns the retained builds, oldest first
+func (s *BuildStore) List() ([]RetainedBuild, error) {
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var builds []RetainedBuild
+	for _, entry := range entries {
+		id, err := strconv.Atoi(entry.Name())
+		if err == nil {
+			builds = append(builds, RetainedBuild{ID: id, Time: entry.ModTime(), Size: entry.Size()})
+		}
+	}
+	sort.Slice(builds, func(i, j int) bool { return builds[i].ID < builds[j].ID })
+	return builds, nil
+}
+
+// Get returns the retained build with the given id
+func (s *BuildStore) Get(id int) (*RetainedBuild, error) {
+	info, err := os.Stat(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no retained build #%d", id)
+	} else if err != nil {
+		return nil, err
+	}
+	return &RetainedBuild{ID: id, Time: info.ModTime(), Size: info.Size()}, nil
+}
+
+// Latest returns the most recent retained build
+func (s *BuildStore) Latest() (*RetainedBuild, error) {
+	builds, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(builds) == 0 {
+		return nil, fmt.Errorf("no retained builds")
+	}
+	return &builds[len(builds)-1], nil
+}
+
+// Restore replaces the binary with the retained build id. The copy gets a
+// new modification time, so runners pick it up like a fresh build.
+func (s *BuildStore) Restore(id int) error {
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+
+	tmp := s.binary + ".swap"
+	if err := copyFile(s.path(id), tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, s.binary)
+}
+
+func (s *BuildStore) path(id int) string {
+	return filepath.Join(s.dir, strconv.Itoa(id))
+}
+
+// copyFile copies the executable src to dst
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
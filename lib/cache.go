@@ -0,0 +1,118 @@
+package gin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheStats summarizes the files in a cache directory
+type CacheStats struct {
+	Dir      string
+	Files    int
+	Size     int64
+	Oldest   time.Time
+	LastUsed time.Time
+}
+
+// GoCacheDir returns the location of the go build cache
+func GoCacheDir() string {
+	return goEnv("GOCACHE")
+}
+
+// DirStats walks dir and sums up the size of its files. The go command
+// refreshes the modification time of build cache entries when using them,
+// so LastUsed is the most recent modification.
+func DirStats(dir string) (CacheStats, error) {
+	stats := CacheStats{Dir: dir}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		stats.Files++
+		stats.Size += info.Size()
+		if stats.Oldest.IsZero() || info.ModTime().Before(stats.Oldest) {
+			stats.Oldest = info.ModTime()
+		}
+		if info.ModTime().After(stats.LastUsed) {
+			stats.LastUsed = info.ModTime()
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// TrimGoCache removes go build cache entries not used within olderThan, the
+// same way the go command trims entries unused for five days.
+func TrimGoCache(dir string, olderThan time.Duration) (removed int, freed int64, err error) {
+	cutoff := time.Now().Add(-olderThan)
+	subdirs, err := filepath.Glob(filepath.Join(dir, "[0-9a-f][0-9a-f]"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, subdir := range subdirs {
+		entries, err := os.ReadDir(subdir)
+		if err != nil {
+			return removed, freed, err
+		}
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || info.IsDir() || !info.ModTime().Before(cutoff) {
+				continue
+			}
+			if err := os.Remove(filepath.Join(subdir, entry.Name())); err == nil {
+				removed++
+				freed += info.Size()
+			}
+		}
+	}
+	return removed, freed, nil
+}
+
+// FormatBytes formats a size in bytes for humans, e.g. 1.5 GB
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// ParseBytes parses a size such as "512M", "2G" or "1048576" into bytes.
+// Units are powers of 1024, an optional trailing "B" or "iB" is ignored.
+func ParseBytes(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "B"), "I")
+
+	mult := int64(1)
+	if value != "" {
+		if i := strings.IndexByte("KMGTPE", value[len(value)-1]); i >= 0 {
+			for ; i >= 0; i-- {
+				mult *= 1024
+			}
+			value = value[:len(value)-1]
+		}
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(mult)), nil
+}
@@ -0,0 +1,112 @@
+package gin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// StateDir is the directory, relative to the working directory, where gin
+// keeps its caches and generated files
+const StateDir = ".gin"
+
+// stateEntries are the files and directories gin may generate inside StateDir
+var stateEntries = []string{
+	"build",
+	"livereload",
+	"certs",
+	"history",
+	"watcher.json",
+	"daemon.pid",
+	"daemon.log",
+	"control.sock",
+	"diagnostics.json",
+	"diagnostics.xml",
+	"diagnostics.lsp.json",
+}
+
+// keptEntries returns the entries of StateDir cleaning the project in wd
+// must keep, the pidfile, log and control socket of a daemon while it runs
+func keptEntries(wd string) map[string]bool {
+	kept := make(map[string]bool)
+	if NewDaemon(wd).PID() != 0 {
+		for _, entry := range []string{daemonPIDFile, daemonLogFile, daemonSocket} {
+			kept[entry] = true
+		}
+	}
+	return kept
+}
+
+// Artifacts returns the existing files and directories generated by gin for
+// the project in wd, including the built binary. The files of a daemon are
+// left out while it runs.
+func Artifacts(wd string, binary string) []string {
+	kept := keptEntries(wd)
+	candidates := []string{filepath.Join(wd, binary)}
+	for _, entry := range stateEntries {
+		if !kept[entry] {
+			candidates = append(candidates, filepath.Join(wd, StateDir, entry))
+		}
+	}
+
+	var found []string
+	for _, path := range candidates {
+		if _, err := os.Lstat(path); err == nil {
+			found = append(found, path)
+		}
+	}
+	return found
+}
+
+// Clean removes the given artifacts and the state directory if it is left
+// empty. The files of a running daemon are never removed.
+func Clean(wd string, artifacts []string) error {
+	kept := keptEntries(wd)
+	stateDir := filepath.Join(wd, StateDir)
+	for _, path := range artifacts {
+		if filepath.Dir(path) == stateDir && kept[filepath.Base(path)] {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+
+	// only succeeds when nothing else lives in the state directory
+	os.Remove(stateDir)
+	return nil
+}
+
+// CleanState removes everything inside the state directory of the project
+// in wd but the files of a running daemon, and the directory itself if it
+// is left empty. It returns the number of bytes freed.
+func CleanState(wd string) (int64, error) {
+	stateDir := filepath.Join(wd, StateDir)
+	entries, err := ioutil.ReadDir(stateDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	kept := keptEntries(wd)
+	var freed int64
+	for _, entry := range entries {
+		if kept[entry.Name()] {
+			continue
+		}
+		path := filepath.Join(stateDir, entry.Name())
+		stats, err := DirStats(path)
+		if err != nil {
+			return freed, err
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return freed, err
+		}
+		freed += stats.Size
+	}
+
+	os.Remove(stateDir)
+	return freed, nil
+}
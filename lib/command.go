@@ -127,6 +127,9 @@ func (c Command) run(ctx *Context) (err error) {
 	}
 
 	set, err := c.parseFlags(ctx.Args().Tail(), ctx.shellComplete)
+	if err == nil {
+		err = validateFlags(c.Flags, set)
+	}
 
 	context := NewContext(ctx.App, set, ctx)
 	context.Command = c
@@ -145,6 +148,10 @@ func (c Command) run(ctx *Context) (err error) {
 		return err
 	}
 
+	if checkCommandHelp(context, c.Name) {
+		return nil
+	}
+
 	checkErr := checkRequiredFlags(c.Flags, context)
 	if checkErr != nil {
 		return checkErr
@@ -344,6 +351,8 @@ func (c Command) startApp(ctx *Context) error {
 	app.Email = ctx.App.Email
 	app.Writer = ctx.App.Writer
 	app.ErrWriter = ctx.App.ErrWriter
+	app.HelpWriter = ctx.App.HelpWriter
+	app.HelpFuncs = ctx.App.HelpFuncs
 	app.UseShortOptionHandling = ctx.App.UseShortOptionHandling
 
 	app.categories = CommandCategories{}
@@ -376,6 +385,7 @@ func (c Command) startApp(ctx *Context) error {
 	return app.runAsSubcommand(ctx)
 }
 
-func (c Command) visibleFlags() []Flag {
+// VisibleFlags returns a slice of the Flags with Hidden=false
+func (c Command) VisibleFlags() []Flag {
 	return visibleFlags(c.Flags)
 }
@@ -0,0 +1,241 @@
+package gin
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// The scripts ask the app for the commands and flags to complete through
+// the --generate-bash-completion flag and complete the values of flags
+// naming files or directories themselves.
+var completionTemplates = map[string]string{
+	"bash": `# bash completion for {{.Name}}, e.g. source <({{.Name}} completion bash)
+_{{.Func}}_complete() {
+  local cur prev opts
+  COMPREPLY=()
+  cur="${COMP_WORDS[COMP_CWORD]}"
+  prev="${COMP_WORDS[COMP_CWORD-1]}"
+
+  case "$prev" in
+{{- if .Dirs}}
+    {{join .Dirs "|"}})
+      COMPREPLY=( $(compgen -d -- "$cur") )
+      return 0
+      ;;
+{{- end}}
+{{- if .Files}}
+    {{join .Files "|"}})
+      COMPREPLY=( $(compgen -f -- "$cur") )
+      return 0
+      ;;
+{{- end}}
+  esac
+
+  if [[ "$cur" == "-"* ]]; then
+    opts=$( "${COMP_WORDS[@]:0:$COMP_CWORD}" "$cur" --generate-bash-completion 2>/dev/null )
+  else
+    opts=$( "${COMP_WORDS[@]:0:$COMP_CWORD}" --generate-bash-completion 2>/dev/null )
+  fi
+  COMPREPLY=( $(compgen -W "$opts" -- "$cur") )
+  return 0
+}
+complete -o bashdefault -o default -F _{{.Func}}_complete {{.Name}}
+`,
+	"zsh": `#compdef {{.Name}}
+# zsh completion for {{.Name}}, e.g. source <({{.Name}} completion zsh)
+_{{.Func}}_complete() {
+  local -a opts
+  local cur prev
+  cur=${words[-1]}
+  prev=${words[-2]}
+
+  case "$prev" in
+{{- if .Dirs}}
+    {{join .Dirs "|"}})
+      _files -/
+      return
+      ;;
+{{- end}}
+{{- if .Files}}
+    {{join .Files "|"}})
+      _files
+      return
+      ;;
+{{- end}}
+  esac
+
+  if [[ "$cur" == "-"* ]]; then
+    opts=("${(@f)$(_CLI_ZSH_AUTOCOMPLETE_HACK=1 ${words[@]:0:#words[@]-1} ${cur} --generate-bash-completion 2>/dev/null)}")
+  else
+    opts=("${(@f)$(_CLI_ZSH_AUTOCOMPLETE_HACK=1 ${words[@]:0:#words[@]-1} --generate-bash-completion 2>/dev/null)}")
+  fi
+
+  if [[ "${opts[1]}" != "" ]]; then
+    _describe 'values' opts
+  else
+    _files
+  fi
+}
+compdef _{{.Func}}_complete {{.Name}}
+`,
+	"fish": `# fish completion for {{.Name}}, e.g. {{.Name}} completion fish | source
+function __{{.Func}}_complete
+    set -l args (commandline -opc)
+    set -l cur (commandline -ct)
+
+    switch $args[-1]
+{{- if .Dirs}}
+        case {{join .Dirs " "}}
+            __fish_complete_directories $cur
+            return
+{{- end}}
+{{- if .Files}}
+        case {{join .Files " "}}
+            __fish_complete_path $cur
+            return
+{{- end}}
+    end
+
+    if string match -q -- '-*' $cur
+        command $args[1] $args[2..-1] $cur --generate-bash-completion 2>/dev/null
+    else
+        command $args[1] $args[2..-1] --generate-bash-completion 2>/dev/null
+    end
+end
+complete -c {{.Name}} -f -a '(__{{.Func}}_complete)'
+`,
+	"powershell": `# PowerShell completion for {{.Name}}, e.g. {{.Name}} completion powershell | Out-String | Invoke-Expression
+Register-ArgumentCompleter -Native -CommandName '{{.Name}}' -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+
+    $words = @($commandAst.CommandElements | ForEach-Object { $_.ToString() })
+    if ($wordToComplete -ne '') {
+        $words = @($words | Select-Object -First ($words.Count - 1))
+    }
+
+    $dirFlags = @({{quote .Dirs}})
+    $fileFlags = @({{quote .Files}})
+    $prev = $words[-1]
+    if ($dirFlags -contains $prev) {
+        [System.Management.Automation.CompletionCompleters]::CompleteFilename($wordToComplete) |
+            Where-Object { $_.ResultType -eq 'ProviderContainer' }
+        return
+    }
+    if ($fileFlags -contains $prev) {
+        [System.Management.Automation.CompletionCompleters]::CompleteFilename($wordToComplete)
+        return
+    }
+
+    $arguments = @($words | Select-Object -Skip 1)
+    if ($wordToComplete -like '-*') {
+        $arguments += $wordToComplete
+    }
+    & $words[0] @arguments --generate-bash-completion 2>$null |
+        Where-Object { $_ -like "$wordToComplete*" } |
+        ForEach-Object { [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_) }
+}
+`,
+}
+
+// CompletionShells returns the shells CompletionScript supports
+func CompletionShells() []string {
+	var shells []string
+	for shell := range completionTemplates {
+		shells = append(shells, shell)
+	}
+	sort.Strings(shells)
+	return shells
+}
+
+// CompletionScript returns a script for shell completing the commands and
+// flags of the app, as well as the values of PathFlags and flags with
+// TakesFile. Its completions require EnableBashCompletion.
+func (a *App) CompletionScript(shell string) (string, error) {
+	text, ok := completionTemplates[shell]
+	if !ok {
+		return "", fmt.Errorf("unknown shell %q, expected one of %s", shell, strings.Join(CompletionShells(), ", "))
+	}
+
+	var dirs, files []string
+	collect := func(flags []Flag) {
+		for _, f := range flags {
+			var names *[]string
+			switch f := f.(type) {
+			case PathFlag:
+				names = &files
+				if f.Directory {
+					names = &dirs
+				}
+			case StringFlag:
+				if f.TakesFile {
+					names = &files
+				}
+			case StringSliceFlag:
+				if f.TakesFile {
+					names = &files
+				}
+			case GenericFlag:
+				if f.TakesFile {
+					names = &files
+				}
+			}
+			if names == nil {
+				continue
+			}
+			eachName(f.GetName(), func(name string) {
+				*names = appendUnique(*names, prefixFor(name)+name)
+			})
+		}
+	}
+
+	collect(a.Flags)
+	var walk func(commands []Command)
+	walk = func(commands []Command) {
+		for _, c := range commands {
+			collect(c.Flags)
+			walk(c.Subcommands)
+		}
+	}
+	walk(a.Commands)
+
+	funcs := template.FuncMap{
+		"join": strings.Join,
+		"quote": func(names []string) string {
+			quoted := make([]string, len(names))
+			for i, name := range names {
+				quoted[i] = "'" + name + "'"
+			}
+			return strings.Join(quoted, ", ")
+		},
+	}
+	t, err := template.New(shell).Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = t.Execute(&buf, struct {
+		Name  string
+		Func  string
+		Dirs  []string
+		Files []string
+	}{
+		Name:  a.Name,
+		Func:  strings.NewReplacer("-", "_", ".", "_", " ", "_").Replace(a.Name),
+		Dirs:  dirs,
+		Files: files,
+	})
+	return buf.String(), err
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
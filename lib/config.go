@@ -1,9 +1,236 @@
 package gin
 
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
 type Config struct {
-	Laddr    string `json:"laddr"`
-	Port     int    `json:"port"`
-	ProxyTo  string `json:"proxy_to"`
-	KeyFile  string `json:"key_file"`
-	CertFile string `json:"cert_file"`
+	Laddr     string     `json:"laddr"`
+	Port      int        `json:"port"`
+	ProxyTo   string     `json:"proxy_to"`
+	KeyFile   string     `json:"key_file"`
+	CertFile  string     `json:"cert_file"`
+	Listeners []Listener `json:"listeners"`
+	Routes    []Route    `json:"routes"`
+	Hosts     []Host     `json:"hosts"`
+	// Transport tunes the connections to the app and other upstreams
+	Transport TransportOptions `json:"transport"`
+	// Sockets are served instead of Listeners, e.g. the ones passed by
+	// systemd socket activation
+	Sockets []net.Listener `json:"-"`
+}
+
+// Listener is an address served by the proxy
+type Listener struct {
+	Addr string `json:"addr"`
+	TLS  bool   `json:"tls"`
+}
+
+// URL returns the address of the listener including its scheme
+func (l Listener) URL() string {
+	if l.TLS {
+		return "https://" + l.Addr
+	}
+	return "http://" + l.Addr
+}
+
+// ParseListener parses a listening address such as "127.0.0.1",
+// "127.0.0.1:3000", "https://192.168.1.5:3443", an IPv6 address like "::1"
+// or "[::1]:3000", or a host name like "dev.local". The port defaults to
+// port and the scheme to https when useTLS is set.
+func ParseListener(value string, port int, useTLS bool) Listener {
+	l := Listener{TLS: useTLS}
+	if strings.HasPrefix(value, "https://") {
+		l.TLS = true
+		value = strings.TrimPrefix(value, "https://")
+	} else if strings.HasPrefix(value, "http://") {
+		l.TLS = false
+		value = strings.TrimPrefix(value, "http://")
+	}
+	value = strings.TrimSuffix(value, "/")
+
+	if _, _, err := net.SplitHostPort(value); err == nil {
+		l.Addr = value
+	} else {
+		// IPv6 addresses may be given in brackets without a port
+		host := strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+		l.Addr = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	return l
+}
+
+// listen opens the sockets of the listener, one per address its host name
+// resolves to, e.g. 127.0.0.1 and ::1 for localhost. Addresses this machine
+// can't bind, like ::1 with IPv6 disabled, are skipped as long as another
+// one works. Port 0 picks the same free port for all addresses and replaces
+// the one of Addr.
+func (l *Listener) listen(config *tls.Config) ([]net.Listener, error) {
+	host, port, err := net.SplitHostPort(l.Addr)
+	if err != nil {
+		return nil, err
+	}
+	addrs := []string{l.Addr}
+	if host != "" && net.ParseIP(host) == nil {
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			return nil, fmt.Errorf("can't listen on %s: %v, pass an IP address or add the name to the hosts file", l.Addr, err)
+		}
+		addrs = nil
+		for _, ip := range ips {
+			addrs = append(addrs, net.JoinHostPort(ip.String(), port))
+		}
+	}
+
+	var listeners []net.Listener
+	var unavailable error
+	for _, addr := range addrs {
+		if len(listeners) > 0 && port == "0" {
+			ip, _, _ := net.SplitHostPort(addr)
+			addr = net.JoinHostPort(ip, strconv.Itoa(listeners[0].Addr().(*net.TCPAddr).Port))
+		}
+		listener, err := net.Listen("tcp", addr)
+		if errors.Is(err, syscall.EADDRNOTAVAIL) {
+			unavailable = err
+			continue
+		}
+		if err != nil {
+			for _, listener := range listeners {
+				listener.Close()
+			}
+			return nil, portInUse(addr, err)
+		}
+		if config != nil {
+			listener = tls.NewListener(listener, config)
+		}
+		listeners = append(listeners, listener)
+	}
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("can't listen on %s: %v", l.Addr, unavailable)
+	}
+
+	// report the port picked by the system for port 0
+	if port == "0" {
+		l.Addr = net.JoinHostPort(host, strconv.Itoa(listeners[0].Addr().(*net.TCPAddr).Port))
+	}
+	return listeners, nil
+}
+
+// tlsConfig loads the certificate of the proxy
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	cer, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cer}}, nil
+}
+
+// listeners returns the configured listeners, falling back to Laddr and Port
+func (c *Config) listeners() []Listener {
+	if len(c.Listeners) > 0 {
+		return c.Listeners
+	}
+	return []Listener{ParseListener(c.Laddr, c.Port, c.CertFile != "" && c.KeyFile != "")}
+}
+
+// Route sends the requests whose path starts with Prefix to Upstream instead
+// of the app, e.g. a frontend dev server
+type Route struct {
+	Prefix string `json:"prefix"`
+	// Upstream is the address requests are proxied to, the app if empty
+	Upstream string `json:"upstream"`
+}
+
+// ParseRoute parses a route such as "/=5173", "/assets=http://localhost:8080"
+// or "/api=app", where a bare port stands for localhost
+func ParseRoute(value string) (Route, error) {
+	i := strings.Index(value, "=")
+	if i < 0 {
+		return Route{}, fmt.Errorf("expected prefix=upstream, got %q", value)
+	}
+	route := Route{Prefix: strings.TrimSpace(value[:i]), Upstream: strings.TrimSpace(value[i+1:])}
+	if !strings.HasPrefix(route.Prefix, "/") {
+		return route, fmt.Errorf("route %q: the prefix must start with /", value)
+	}
+
+	upstream, err := parseUpstream(route.Upstream)
+	if err != nil {
+		return route, fmt.Errorf("route %q: %v", value, err)
+	}
+	route.Upstream = upstream
+	return route, nil
+}
+
+// parseUpstream returns the URL of an upstream such as "5173",
+// "localhost:8080" or "http://10.0.0.2:8080", and "" for "app"
+func parseUpstream(value string) (string, error) {
+	switch value {
+	case "app":
+		return "", nil
+	case "":
+		return "", fmt.Errorf("missing the upstream")
+	}
+
+	if _, err := strconv.Atoi(value); err == nil {
+		value = "localhost:" + value
+	}
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid upstream %q", value)
+	}
+	return value, nil
+}
+
+// matches reports whether the route applies to path, which must equal the
+// prefix or continue it with a new segment
+func (r Route) matches(path string) bool {
+	prefix := strings.TrimSuffix(r.Prefix, "/")
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// routes returns the routes, the longest prefix first
+func (c *Config) routes() []Route {
+	routes := append([]Route(nil), c.Routes...)
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].Prefix) > len(routes[j].Prefix)
+	})
+	return routes
+}
+
+// Host sends the requests for a host name, e.g. api.local.test, to Upstream.
+// Hosts take precedence over Routes.
+type Host struct {
+	Name string `json:"name"`
+	// Upstream is the address requests are proxied to, the app if empty
+	Upstream string `json:"upstream"`
+}
+
+// ParseHost parses a virtual host such as "web.local.test=5173" or
+// "api.local.test=app", with upstreams like ParseRoute
+func ParseHost(value string) (Host, error) {
+	i := strings.Index(value, "=")
+	if i < 0 {
+		return Host{}, fmt.Errorf("expected name=upstream, got %q", value)
+	}
+	host := Host{Name: strings.ToLower(strings.TrimSpace(value[:i]))}
+	if host.Name == "" || strings.ContainsAny(host.Name, "/: ") {
+		return host, fmt.Errorf("host %q: invalid name %q", value, host.Name)
+	}
+
+	upstream, err := parseUpstream(strings.TrimSpace(value[i+1:]))
+	if err != nil {
+		return host, fmt.Errorf("host %q: %v", value, err)
+	}
+	host.Upstream = upstream
+	return host, nil
 }
@@ -0,0 +1,312 @@
+package gin
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfigFlag selects the config file of an App with a ConfigFile. It is
+// added to the global flags.
+var ConfigFlag = PathFlag{
+	Name:      "config",
+	Usage:     "JSON file with default values for the global options",
+	MustExist: true,
+}
+
+// ProfileFlag selects a profile of the config file of an App with a
+// ConfigFile. It is added to the global flags and may also follow the
+// command, e.g. gin run --profile race.
+var ProfileFlag = StringFlag{
+	Name:   "profile",
+	EnvVar: "GIN_PROFILE",
+	Usage:  "profile of the config file whose values override the others, on top of the default profile",
+}
+
+// ProfilesKey is the key of the config file holding the named profiles, each
+// an object of option values like the config file itself
+const ProfilesKey = "profiles"
+
+// DefaultProfile is applied below the selected profile, and alone if none is
+// selected
+const DefaultProfile = "default"
+
+// ConfigFile holds option values loaded from a JSON file, keyed by the long
+// name of the flag, e.g. {"port": 3000, "excludeDir": ["vendor"]}
+type ConfigFile map[string]interface{}
+
+// LoadConfigFile reads the config file at path
+func LoadConfigFile(path string) (ConfigFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config ConfigFile
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return config, nil
+}
+
+// applyConfigFile sets the global flags given neither on the command line nor
+// in the environment to the values in the config file. A missing default
+// config file is ignored.
+func (a *App) applyConfigFile(set *flag.FlagSet) error {
+	if a.ConfigFile == "" {
+		return nil
+	}
+
+	path := lookupString(ConfigFlag.Name, set)
+	explicit := path != ""
+	if !explicit {
+		path = a.ConfigFile
+	}
+	profile := lookupString(ProfileFlag.Name, set)
+
+	config, err := LoadConfigFile(path)
+	if os.IsNotExist(err) && !explicit {
+		if profile != "" {
+			return fmt.Errorf("can't select the profile %q without a config file, %s doesn't exist", profile, path)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if config, err = config.withProfile(profile); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	a.config, a.configPath, a.profile = config, path, profile
+	return config.apply(path, a.Flags, a.ConfigSections, set)
+}
+
+// withProfile returns the values of the config file with the ones of the
+// default profile and then of the profile name applied on top. Objects,
+// e.g. a section of environment variables, are merged.
+func (c ConfigFile) withProfile(name string) (ConfigFile, error) {
+	raw, ok := c[ProfilesKey]
+	if !ok {
+		if name != "" {
+			return nil, fmt.Errorf("profile %q not found, the config file has no %s", name, ProfilesKey)
+		}
+		return c, nil
+	}
+	profiles, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: expected an object of profiles", ProfilesKey)
+	}
+
+	names := []string{DefaultProfile}
+	if name != "" && name != DefaultProfile {
+		if _, ok := profiles[name]; !ok {
+			var available []string
+			for n := range profiles {
+				available = append(available, n)
+			}
+			sort.Strings(available)
+			return nil, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(available, ", "))
+		}
+		names = append(names, name)
+	}
+
+	merged := make(ConfigFile)
+	for key, value := range c {
+		if key != ProfilesKey {
+			merged[key] = value
+		}
+	}
+	for _, n := range names {
+		raw, ok := profiles[n]
+		if !ok {
+			continue
+		}
+		profile, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: %s: expected an object of option values", ProfilesKey, n)
+		}
+		for key, value := range profile {
+			if key == ProfilesKey {
+				return nil, fmt.Errorf("%s: %s: profiles can't be nested", ProfilesKey, n)
+			}
+			override, isObject := value.(map[string]interface{})
+			base, baseIsObject := merged[key].(map[string]interface{})
+			if !isObject || !baseIsObject {
+				merged[key] = value
+				continue
+			}
+			object := make(map[string]interface{})
+			for k, v := range base {
+				object[k] = v
+			}
+			for k, v := range override {
+				object[k] = v
+			}
+			merged[key] = object
+		}
+	}
+	return merged, nil
+}
+
+// liftProfileFlag moves --profile given right after the command, e.g. in
+// gin run --profile race, in front of the command, since it is a global flag
+// and the command may not parse flags. args are the arguments left after
+// the global flags, starting with the command.
+func liftProfileFlag(arguments []string, args []string) ([]string, bool) {
+	if len(args) < 2 {
+		return nil, false
+	}
+
+	var value string
+	var rest []string
+	flag := strings.TrimLeft(args[1], "-")
+	switch {
+	case !strings.HasPrefix(args[1], "-"):
+		return nil, false
+	case flag == ProfileFlag.Name && len(args) > 2:
+		value, rest = args[2], args[3:]
+	case strings.HasPrefix(flag, ProfileFlag.Name+"="):
+		value, rest = strings.TrimPrefix(flag, ProfileFlag.Name+"="), args[2:]
+	default:
+		return nil, false
+	}
+
+	head := arguments[:len(arguments)-len(args)]
+	lifted := append(append([]string{}, head...), "--"+ProfileFlag.Name+"="+value, args[0])
+	return append(lifted, rest...), true
+}
+
+// ConfigSection decodes the value of key in the config file of the app,
+// one of its ConfigSections, into v. It returns false if the key isn't set.
+func (c *Context) ConfigSection(key string, v interface{}) (bool, error) {
+	app := c.rootApp()
+	value, ok := app.config[key]
+	if !ok {
+		return false, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err == nil {
+		err = json.Unmarshal(data, v)
+	}
+	if err != nil {
+		return true, fmt.Errorf("%s: %s: %v", app.configPath, key, err)
+	}
+	return true, nil
+}
+
+// ConfigProfile returns the selected profile of the config file, or "" if
+// only the default profile applies
+func (c *Context) ConfigProfile() string {
+	return c.rootApp().profile
+}
+
+// ConfigFilePath returns the path of the loaded config file, or "" if the
+// app has none
+func (c *Context) ConfigFilePath() string {
+	return c.rootApp().configPath
+}
+
+// rootApp returns the app of the outermost context, since subcommands run
+// as apps of their own
+func (c *Context) rootApp() *App {
+	for c.parentContext != nil {
+		c = c.parentContext
+	}
+	return c.App
+}
+
+// apply sets the flags which weren't given on the command line or through
+// their environment variables to the values in the config file
+func (c ConfigFile) apply(path string, flags []Flag, sections []string, set *flag.FlagSet) error {
+	visited := make(map[string]bool)
+	set.Visit(func(f *flag.Flag) {
+		visited[f.Name] = true
+	})
+
+	known := make(map[string]bool)
+	for _, f := range flags {
+		var names []string
+		eachName(f.GetName(), func(name string) {
+			names = append(names, name)
+		})
+
+		name := names[0]
+		value, ok := c[name]
+		known[name] = true
+		if !ok {
+			continue
+		}
+
+		given := false
+		for _, n := range names {
+			given = given || visited[n]
+		}
+		fv := flagValue(f)
+		if envVar := fv.FieldByName("EnvVar"); envVar.IsValid() {
+			if _, ok := flagFromFileEnv(fv.FieldByName("FilePath").String(), envVar.String()); ok {
+				given = true
+			}
+		}
+		if given {
+			continue
+		}
+
+		values, err := configValues(value)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %v", path, name, err)
+		}
+		for _, v := range values {
+			if err := set.Set(name, v); err != nil {
+				return fmt.Errorf("%s: invalid value %q for %s: %v", path, v, name, err)
+			}
+		}
+	}
+
+	for _, section := range sections {
+		known[section] = true
+	}
+
+	var unknown []string
+	for name := range c {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%s: unknown option %q", path, unknown[0])
+	}
+	return nil
+}
+
+// configValues converts a JSON value to the values passed to a flag, one per
+// element for arrays, which set repeatable flags several times
+func configValues(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case []interface{}:
+		var values []string
+		for _, element := range v {
+			converted, err := configValues(element)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, converted...)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("expected a string, number, boolean or array")
+}
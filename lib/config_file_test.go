@@ -0,0 +1,52 @@
+package gin
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runWithConfig runs an App with the config file config, decoding its
+// plugins section like gin does before running a command
+func runWithConfig(t *testing.T, config string) (map[string]string, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gin.json")
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var plugins map[string]string
+	app := NewApp()
+	app.ConfigFile = path
+	app.ConfigSections = []string{"plugins"}
+	app.Writer = ioutil.Discard
+	app.Before = func(c *Context) error {
+		_, err := c.ConfigSection("plugins", &plugins)
+		return err
+	}
+	app.Action = func(c *Context) {}
+	return plugins, app.Run([]string{"gin"})
+}
+
+func TestConfigSection(t *testing.T) {
+	plugins, err := runWithConfig(t, `{"plugins": {"assets": "./tools/assets --minify"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plugins["assets"] != "./tools/assets --minify" {
+		t.Errorf("plugins = %v", plugins)
+	}
+}
+
+func TestConfigSectionMalformed(t *testing.T) {
+	_, err := runWithConfig(t, `{"plugins": ["assets"]}`)
+	if err == nil {
+		t.Fatal("a list of plugins was accepted")
+	}
+	// the error is all gin shows before exiting, so it has to point at the
+	// section
+	if msg := err.Error(); !strings.Contains(msg, "gin.json") || !strings.Contains(msg, "plugins") {
+		t.Errorf("error %q names neither the file nor the section", msg)
+	}
+}
@@ -0,0 +1,26 @@
+package gin
+
+import "testing"
+
+func TestParseListener(t *testing.T) {
+	tests := []struct {
+		value  string
+		useTLS bool
+		want   Listener
+	}{
+		{"127.0.0.1", false, Listener{Addr: "127.0.0.1:3000"}},
+		{"127.0.0.1:4000", false, Listener{Addr: "127.0.0.1:4000"}},
+		{"0.0.0.0", true, Listener{Addr: "0.0.0.0:3000", TLS: true}},
+		{"https://192.168.1.5:3443/", false, Listener{Addr: "192.168.1.5:3443", TLS: true}},
+		{"http://localhost", true, Listener{Addr: "localhost:3000"}},
+		{"::1", false, Listener{Addr: "[::1]:3000"}},
+		{"[::1]", false, Listener{Addr: "[::1]:3000"}},
+		{"[::]:4000", false, Listener{Addr: "[::]:4000"}},
+		{"dev.local", false, Listener{Addr: "dev.local:3000"}},
+	}
+	for _, test := range tests {
+		if got := ParseListener(test.value, 3000, test.useTLS); got != test.want {
+			t.Errorf("ParseListener(%q, 3000, %v) = %+v, want %+v", test.value, test.useTLS, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package gin
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+)
+
+// ControlPrefix is the path prefix of the control API endpoints
+const ControlPrefix = "/_gin/"
+
+// ControlStatus is the response of the status endpoint
+type ControlStatus struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	// Stale is true while the app of an older build runs since the last
+	// build failed
+	Stale   bool     `json:"stale,omitempty"`
+	Errors  string   `json:"errors,omitempty"`
+	URLs    []string `json:"urls"`
+	AppPort string   `json:"app_port"`
+	// Panic is the stack trace of the last crash since the last build
+	Panic string `json:"panic,omitempty"`
+	// CrashLoop is set while the app keeps exiting right after it starts
+	CrashLoop *CrashLoopStatus `json:"crash_loop,omitempty"`
+	// Changed are the files which caused the last rebuild or restart, or
+	// the trigger which requested it, e.g. /_gin/rebuild
+	Changed []string `json:"changed,omitempty"`
+}
+
+// RebuildNotification is pushed to the clients of the events endpoint when
+// changed files cause a rebuild or restart, as a gin/rebuild notification
+// next to the diagnostics
+type RebuildNotification struct {
+	JSONRPC string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  RebuildParams `json:"params"`
+}
+
+// RebuildParams describe the cause of a rebuild
+type RebuildParams struct {
+	// Restart is true if the app is only restarted, e.g. for changed env files
+	Restart bool     `json:"restart"`
+	Changed []string `json:"changed"`
+	// Requested lists the triggers which requested the rebuild, e.g. the
+	// rebuild endpoint
+	Requested []string `json:"requested,omitempty"`
+}
+
+// NewRebuildNotification returns the notification of a rebuild, or restart,
+// caused by the changed files and requested by triggers
+func NewRebuildNotification(changed, requested []string, restart bool) RebuildNotification {
+	if changed == nil {
+		changed = []string{}
+	}
+	return RebuildNotification{
+		JSONRPC: "2.0",
+		Method:  "gin/rebuild",
+		Params:  RebuildParams{Restart: restart, Changed: changed, Requested: requested},
+	}
+}
+
+// ReloadNotification is pushed to the clients of the events endpoint when
+// changed files only require a reload of the page, as a gin/reload
+// notification, e.g. templates the app parses on every request
+type ReloadNotification struct {
+	JSONRPC string       `json:"jsonrpc"`
+	Method  string       `json:"method"`
+	Params  ReloadParams `json:"params"`
+}
+
+// ReloadParams list the changed files of a reload
+type ReloadParams struct {
+	Changed []string `json:"changed"`
+}
+
+// NewReloadNotification returns the notification of a reload caused by the
+// changed files
+func NewReloadNotification(changed []string) ReloadNotification {
+	return ReloadNotification{
+		JSONRPC: "2.0",
+		Method:  "gin/reload",
+		Params:  ReloadParams{Changed: changed},
+	}
+}
+
+// ControlServer exposes the state of a running gin over HTTP on a separate
+// address from the proxy
+type ControlServer struct {
+	// Token, if set, must be sent by clients as a bearer token
+	Token string
+
+	mux      *http.ServeMux
+	listener net.Listener
+	socket   net.Listener
+	events   *websocketHub
+}
+
+func NewControlServer() *ControlServer {
+	s := &ControlServer{mux: http.NewServeMux(), events: newWebsocketHub()}
+	s.Handle("events", s.events)
+	return s
+}
+
+// Broadcast sends the JSON encoding of message to the WebSocket clients
+// connected to the events endpoint
+func (s *ControlServer) Broadcast(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	s.events.Broadcast(data)
+	return nil
+}
+
+// Handle registers handler for the endpoint name, served at ControlPrefix+name
+func (s *ControlServer) Handle(name string, handler http.Handler) {
+	s.mux.Handle(ControlPrefix+name, handler)
+}
+
+// HandleJSON registers an endpoint responding with the JSON encoding of the
+// value returned by fn
+func (s *ControlServer) HandleJSON(name string, fn func() interface{}) {
+	s.Handle(name, http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		writeJSON(res, fn())
+	}))
+}
+
+// HandleAction registers an endpoint which changes the state of gin. It only
+// accepts POST requests and responds with the JSON encoding of the value
+// returned by fn, or with its error as a bad request.
+func (s *ControlServer) HandleAction(name string, fn func(req *http.Request) (interface{}, error)) {
+	s.Handle(name, http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" {
+			res.Header().Set("Allow", "POST")
+			http.Error(res, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		v, err := fn(req)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(res, v)
+	}))
+}
+
+// Listen starts serving the control API on addr
+func (s *ControlServer) Listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return portInUse(addr, err)
+	}
+	s.listener = listener
+
+	go http.Serve(listener, http.HandlerFunc(s.serveHTTP))
+	return nil
+}
+
+// ListenUnix starts serving the control API on a Unix socket at path. Only
+// the user running gin may connect, so clients don't need the Token.
+func (s *ControlServer) ListenUnix(path string) error {
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return err
+	}
+	s.socket = listener
+
+	go http.Serve(listener, s.mux)
+	return nil
+}
+
+// ListenTLS starts serving the control API over TLS on addr
+func (s *ControlServer) ListenTLS(addr string, certFile string, keyFile string) error {
+	cer, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	listener, err := tls.Listen("tcp", addr, &tls.Config{Certificates: []tls.Certificate{cer}})
+	if err != nil {
+		return portInUse(addr, err)
+	}
+	s.listener = listener
+
+	go http.Serve(listener, http.HandlerFunc(s.serveHTTP))
+	return nil
+}
+
+func (s *ControlServer) serveHTTP(res http.ResponseWriter, req *http.Request) {
+	if s.Token != "" {
+		token := req.Header.Get("Authorization")
+		// WebSocket clients in browsers can't set headers
+		if token == "" && req.URL.Query().Get("token") != "" {
+			token = "Bearer " + req.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte("Bearer "+s.Token)) != 1 {
+			res.Header().Set("WWW-Authenticate", `Bearer realm="gin"`)
+			http.Error(res, "invalid or missing token", http.StatusUnauthorized)
+			return
+		}
+	}
+	s.mux.ServeHTTP(res, req)
+}
+
+// Addr returns the address the control API listens on
+func (s *ControlServer) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+func (s *ControlServer) Close() error {
+	s.events.Close()
+	if s.socket != nil {
+		s.socket.Close()
+	}
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+func writeJSON(res http.ResponseWriter, v interface{}) {
+	res.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(res)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
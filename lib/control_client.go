@@ -0,0 +1,103 @@
+package gin
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ControlClient talks to the control API of a gin running elsewhere
+type ControlClient struct {
+	// URL of the control API, e.g. https://staging:3030
+	URL   string
+	Token string
+
+	client *http.Client
+}
+
+// NewControlClient creates a client for the control API at addr. Addresses
+// without a scheme use plain HTTP. caFile, if set, is a PEM certificate
+// trusted in addition to the system roots, e.g. a self-signed certificate.
+func NewControlClient(addr string, token string, caFile string) (*ControlClient, error) {
+	if !strings.HasPrefix(addr, "http://") && !strings.HasPrefix(addr, "https://") {
+		addr = "http://" + addr
+	}
+
+	transport := &http.Transport{}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &ControlClient{
+		URL:    strings.TrimSuffix(addr, "/"),
+		Token:  token,
+		client: &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}, nil
+}
+
+// NewSocketControlClient creates a client for the control API on the Unix
+// socket at path
+func NewSocketControlClient(path string) *ControlClient {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", path)
+		},
+	}
+	return &ControlClient{
+		URL:    "http://gin",
+		client: &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}
+}
+
+// Get decodes the JSON response of the endpoint name into v
+func (c *ControlClient) Get(name string, v interface{}) error {
+	return c.do("GET", name, v)
+}
+
+// Post invokes the endpoint name and decodes its JSON response into v
+func (c *ControlClient) Post(name string, v interface{}) error {
+	return c.do("POST", name, v)
+}
+
+func (c *ControlClient) do(method string, name string, v interface{}) error {
+	req, err := http.NewRequest(method, c.URL+ControlPrefix+name, nil)
+	if err != nil {
+		return err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return errors.New(res.Status + ": " + strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
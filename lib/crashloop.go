@@ -0,0 +1,118 @@
+package gin
+
+import (
+	"sync"
+	"time"
+)
+
+// CrashLoop detects an app which keeps exiting right after it starts, e.g.
+// because of a bad config or a port taken by another process. In a crash
+// loop the app is started again with an exponential backoff, and not at all
+// once it reaches MaxBackoff, until Reset.
+type CrashLoop struct {
+	// Crashes is the number of quick exits in a row making a crash loop
+	Crashes int
+	// Uptime is how long the app must run for an exit not to count
+	Uptime time.Duration
+	// Backoff is how long to wait before starting the app again after the
+	// crash detecting the loop, doubled with every further crash
+	Backoff time.Duration
+	// MaxBackoff is the longest wait, beyond which the app isn't started
+	// again until Reset
+	MaxBackoff time.Duration
+
+	mu      sync.Mutex
+	count   int
+	looping bool
+	exited  time.Time
+}
+
+// NewCrashLoop detects 3 exits in a row within 5 seconds of the start, and
+// then waits 1s, 2s, 4s, 8s and 16s before giving up
+func NewCrashLoop() *CrashLoop {
+	return &CrashLoop{Crashes: 3, Uptime: 5 * time.Second, Backoff: time.Second, MaxBackoff: 30 * time.Second}
+}
+
+// Exited records an exit of the app which wasn't stopped by gin after
+// running for uptime, and reports whether it started a crash loop
+func (c *CrashLoop) Exited(uptime time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if uptime >= c.Uptime {
+		c.count = 0
+		c.looping = false
+		return false
+	}
+	c.count++
+	c.exited = time.Now()
+	if c.count < c.Crashes || c.looping {
+		return false
+	}
+	c.looping = true
+	return true
+}
+
+// Count returns the number of quick exits in a row
+func (c *CrashLoop) Count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.count
+}
+
+// Looping reports whether the app is in a crash loop
+func (c *CrashLoop) Looping() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.looping
+}
+
+// Wait returns how long to wait before starting the app again, 0 outside
+// of a crash loop or once the backoff passed, and false if the app
+// shouldn't be started again until Reset
+func (c *CrashLoop) Wait() (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.looping {
+		return 0, true
+	}
+	backoff := c.Backoff
+	for i := c.Crashes; i < c.count && backoff <= c.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > c.MaxBackoff {
+		return 0, false
+	}
+	if wait := time.Until(c.exited.Add(backoff)); wait > 0 {
+		return wait, true
+	}
+	return 0, true
+}
+
+// CrashLoopStatus describes a crash loop in the status of the control API
+type CrashLoopStatus struct {
+	// Crashes is the number of quick exits in a row
+	Crashes int `json:"crashes"`
+	// RetryIn is how many seconds are left before the app may start again
+	RetryIn float64 `json:"retry_in_s,omitempty"`
+	// Stopped is true once the app isn't started again until a change
+	Stopped bool `json:"stopped,omitempty"`
+}
+
+// Status describes the crash loop, nil outside of one
+func (c *CrashLoop) Status() *CrashLoopStatus {
+	if !c.Looping() {
+		return nil
+	}
+	wait, retry := c.Wait()
+	return &CrashLoopStatus{Crashes: c.Count(), RetryIn: wait.Seconds(), Stopped: !retry}
+}
+
+// Reset forgets the exits, e.g. after a new build
+func (c *CrashLoop) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count = 0
+	c.looping = false
+}
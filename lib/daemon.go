@@ -0,0 +1,221 @@
+package gin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DaemonEnv is set in the environment of the gin started by Daemon.Start, so
+// it runs in the foreground instead of starting another one
+const DaemonEnv = "GIN_DAEMONIZED"
+
+// files of the daemon inside StateDir
+const (
+	daemonPIDFile = "daemon.pid"
+	daemonLogFile = "daemon.log"
+	daemonSocket  = "control.sock"
+)
+
+// ErrDaemonNotRunning is returned when no gin runs in the background
+var ErrDaemonNotRunning = errors.New("gin isn't running in the background, start it with gin start --daemon")
+
+// Daemon is a gin running in the background for the project in Dir. It
+// records its pid in a pidfile, writes its output to a log file and serves
+// the control API on a Unix socket, all inside StateDir.
+type Daemon struct {
+	Dir string
+}
+
+// NewDaemon returns the daemon of the project in wd
+func NewDaemon(wd string) *Daemon {
+	return &Daemon{Dir: wd}
+}
+
+// PIDFile returns the path of the pidfile
+func (d *Daemon) PIDFile() string {
+	return filepath.Join(d.Dir, StateDir, daemonPIDFile)
+}
+
+// LogFile returns the path of the file the output of the daemon goes to
+func (d *Daemon) LogFile() string {
+	return filepath.Join(d.Dir, StateDir, daemonLogFile)
+}
+
+// Socket returns the path of the Unix socket serving the control API
+func (d *Daemon) Socket() string {
+	return filepath.Join(d.Dir, StateDir, daemonSocket)
+}
+
+// PID returns the pid of the running daemon, 0 if none runs. A pidfile left
+// behind by a daemon which died is removed.
+func (d *Daemon) PID() int {
+	data, err := ioutil.ReadFile(d.PIDFile())
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 || !processAlive(pid) {
+		d.Remove()
+		return 0
+	}
+	return pid
+}
+
+// Client returns a client of the control API on the socket of the daemon
+func (d *Daemon) Client() *ControlClient {
+	return NewSocketControlClient(d.Socket())
+}
+
+// Start runs gin again with args, detached from the terminal and with its
+// output written to the log file, and waits up to timeout for it to answer
+// on the control socket. It returns the pid of the daemon.
+func (d *Daemon) Start(args []string, timeout time.Duration) (int, error) {
+	if pid := d.PID(); pid != 0 {
+		return 0, fmt.Errorf("gin is already running in the background with pid %d, stop it with gin stop", pid)
+	}
+	if err := os.MkdirAll(filepath.Join(d.Dir, StateDir), 0755); err != nil {
+		return 0, err
+	}
+	os.Remove(d.Socket())
+
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	out, err := os.OpenFile(d.LogFile(), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	command := exec.Command(exe, args...)
+	command.Dir = d.Dir
+	command.Env = append(os.Environ(), DaemonEnv+"=1")
+	command.Stdout = out
+	command.Stderr = out
+	detachProcess(command)
+	traceCommand(exe, args...)
+	if err := command.Start(); err != nil {
+		return 0, err
+	}
+	pid := command.Process.Pid
+	if err := ioutil.WriteFile(d.PIDFile(), []byte(strconv.Itoa(pid)+"\n"), 0644); err != nil {
+		command.Process.Kill()
+		return 0, err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- command.Wait()
+	}()
+
+	client := d.Client()
+	deadline := time.Now().Add(timeout)
+	for {
+		var status ControlStatus
+		if err := client.Get("status", &status); err == nil {
+			return pid, nil
+		}
+		select {
+		case err := <-exited:
+			d.Remove()
+			if err == nil {
+				err = errors.New("exited")
+			}
+			return 0, fmt.Errorf("gin stopped right after starting (%v), see %s", err, d.LogFile())
+		case <-time.After(100 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			return pid, fmt.Errorf("gin doesn't answer on %s after %s, see %s", d.Socket(), timeout, d.LogFile())
+		}
+	}
+}
+
+// Stop asks the daemon to stop through the control socket, or with an
+// interrupt if it doesn't answer, and kills it if it is still running after
+// timeout. It returns the pid of the stopped daemon.
+func (d *Daemon) Stop(timeout time.Duration) (int, error) {
+	pid := d.PID()
+	if pid == 0 {
+		return 0, ErrDaemonNotRunning
+	}
+
+	var res map[string]bool
+	if err := d.Client().Post("stop", &res); err != nil {
+		Verbosef("Interrupting gin, the control socket doesn't answer: %v", err)
+		process, err := os.FindProcess(pid)
+		if err != nil {
+			return pid, err
+		}
+		if err := interruptProcess(process, false); err != nil {
+			return pid, err
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			log.Printf("gin is still running after %s, killing it", timeout)
+			process, err := os.FindProcess(pid)
+			if err == nil {
+				err = process.Kill()
+			}
+			if err != nil {
+				return pid, err
+			}
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	d.Remove()
+	return pid, nil
+}
+
+// Remove removes the pidfile and the socket, e.g. when the daemon exits
+func (d *Daemon) Remove() {
+	os.Remove(d.PIDFile())
+	os.Remove(d.Socket())
+}
+
+// CopyLog writes the log of the daemon to w. With follow it keeps writing
+// what is appended, starting over when a new daemon truncates the log.
+func (d *Daemon) CopyLog(w io.Writer, follow bool) error {
+	f, err := os.Open(d.LogFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no log found, gin writes it to %s when started with gin start --daemon", d.LogFile())
+		}
+		return err
+	}
+	defer func() { f.Close() }()
+
+	var offset int64
+	for {
+		n, err := io.Copy(w, f)
+		if err != nil {
+			return err
+		}
+		offset += n
+		if !follow {
+			return nil
+		}
+
+		time.Sleep(200 * time.Millisecond)
+		if info, err := os.Stat(d.LogFile()); err == nil && info.Size() < offset {
+			f.Close()
+			if f, err = os.Open(d.LogFile()); err != nil {
+				return err
+			}
+			offset = 0
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package gin
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dashboardLogLines is the number of log lines kept for scrolling
+const dashboardLogLines = 1000
+
+// dashboardChanges is the number of recently changed files shown
+const dashboardChanges = 5
+
+// KeyBinding is an action triggered by a single key press
+type KeyBinding struct {
+	Key         byte
+	Description string
+	Action      func()
+}
+
+// Dashboard renders a persistent terminal view of the build state, restarts,
+// recent changes, request rate and the latest log lines of gin and the app
+type Dashboard struct {
+	out      io.Writer
+	name     string
+	requests func() int
+	bindings []KeyBinding
+
+	mu          sync.Mutex
+	state       string
+	buildTime   time.Duration
+	restarts    int
+	changes     []string
+	qrCodes     []string
+	showQRCodes bool
+	logs        []string
+	partial     []byte
+	lastCount   int
+	lastSample  time.Time
+	rate        float64
+	done        chan struct{}
+	once        sync.Once
+	restoreTerm func()
+}
+
+// NewDashboard creates a Dashboard drawing to out
+func NewDashboard(out io.Writer, name string) *Dashboard {
+	return &Dashboard{
+		out:  out,
+		name: name,
+		done: make(chan struct{}),
+	}
+}
+
+// CountRequests sets the function returning the number of proxied requests
+// so far, which is sampled for the request rate
+func (d *Dashboard) CountRequests(requests func() int) {
+	d.mu.Lock()
+	d.requests = requests
+	d.mu.Unlock()
+}
+
+// Bind registers an action for key, listed at the bottom of the dashboard
+func (d *Dashboard) Bind(key byte, description string, action func()) {
+	d.bindings = append(d.bindings, KeyBinding{Key: key, Description: description, Action: action})
+}
+
+// SetState shows the build state, one of StatusBuilding, StatusOK or
+// StatusFailed
+func (d *Dashboard) SetState(state string) {
+	d.mu.Lock()
+	d.state = state
+	d.mu.Unlock()
+}
+
+// BuildFinished records the duration of the last build
+func (d *Dashboard) BuildFinished(duration time.Duration) {
+	d.mu.Lock()
+	d.buildTime = duration
+	d.mu.Unlock()
+}
+
+// Restarted counts a restart of the app and the files which caused it
+func (d *Dashboard) Restarted(files []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.restarts++
+	for _, file := range files {
+		d.changes = append(d.changes, time.Now().Format("15:04:05")+" "+file)
+	}
+	if len(d.changes) > dashboardChanges {
+		d.changes = d.changes[len(d.changes)-dashboardChanges:]
+	}
+}
+
+// ShowQRCodes shows QR codes of urls, e.g. the proxy URLs on the local
+// network, below the recent changes, replacing the ones shown before
+func (d *Dashboard) ShowQRCodes(urls []string) error {
+	lines, err := QRCodeLines(urls)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.qrCodes = lines
+	d.showQRCodes = true
+	d.mu.Unlock()
+	return nil
+}
+
+// ToggleQRCodes hides or shows the QR codes
+func (d *Dashboard) ToggleQRCodes() {
+	d.mu.Lock()
+	d.showQRCodes = !d.showQRCodes
+	d.mu.Unlock()
+}
+
+// ClearLogs empties the log pane
+func (d *Dashboard) ClearLogs() {
+	d.mu.Lock()
+	d.logs = nil
+	d.mu.Unlock()
+}
+
+// Write adds output to the log pane, so the Dashboard can be used as the
+// writer of gin's logger and the app
+func (d *Dashboard) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.partial = append(d.partial, p...)
+	for {
+		i := bytes.IndexByte(d.partial, '\n')
+		if i < 0 {
+			break
+		}
+		d.logs = append(d.logs, strings.TrimRight(string(d.partial[:i]), "\r"))
+		d.partial = d.partial[i+1:]
+	}
+	if len(d.logs) > dashboardLogLines {
+		d.logs = d.logs[len(d.logs)-dashboardLogLines:]
+	}
+	return len(p), nil
+}
+
+// Run switches to the alternate screen and redraws the dashboard every
+// interval until Close is called. Keys are read from stdin if it is a
+// terminal.
+func (d *Dashboard) Run(interval time.Duration) {
+	fmt.Fprint(d.out, "\033[?1049h\033[?25l")
+	d.restoreTerm = rawTerminal()
+	if d.restoreTerm != nil {
+		go d.readKeys(os.Stdin)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			d.draw()
+			select {
+			case <-ticker.C:
+			case <-d.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close restores the terminal
+func (d *Dashboard) Close() {
+	d.once.Do(func() {
+		close(d.done)
+		if d.restoreTerm != nil {
+			d.restoreTerm()
+		}
+		fmt.Fprint(d.out, "\033[?25h\033[?1049l")
+	})
+}
+
+func (d *Dashboard) readKeys(in io.Reader) {
+	r := bufio.NewReader(in)
+	for {
+		key, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		for _, binding := range d.bindings {
+			if binding.Key == key {
+				binding.Action()
+			}
+		}
+	}
+}
+
+func (d *Dashboard) draw() {
+	rows, cols := terminalSize()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.requests != nil {
+		now := time.Now()
+		count := d.requests()
+		if !d.lastSample.IsZero() {
+			d.rate = float64(count-d.lastCount) / now.Sub(d.lastSample).Seconds()
+		}
+		d.lastCount, d.lastSample = count, now
+	}
+
+	var lines []string
+	lines = append(lines,
+		fmt.Sprintf("\033[1m%s %s\033[0m", d.state, d.name),
+		fmt.Sprintf("last build %s   restarts %d   requests %.1f/s", d.buildTime.Round(time.Millisecond), d.restarts, d.rate),
+	)
+	for _, change := range d.changes {
+		lines = append(lines, "\033[2m"+change+"\033[0m")
+	}
+	if d.showQRCodes {
+		lines = append(lines, d.qrCodes...)
+	}
+	lines = append(lines, strings.Repeat("─", cols))
+
+	var keys []string
+	for _, binding := range d.bindings {
+		keys = append(keys, "\033[1m"+string(binding.Key)+"\033[0m "+binding.Description)
+	}
+	footer := strings.Join(keys, "  ")
+
+	logRows := rows - len(lines) - 1
+	if logRows < 0 {
+		logRows = 0
+	}
+	logs := d.logs
+	if len(logs) > logRows {
+		logs = logs[len(logs)-logRows:]
+	}
+	lines = append(lines, logs...)
+	for len(lines) < rows-1 {
+		lines = append(lines, "")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("\033[H")
+	for _, line := range lines {
+		buf.WriteString(truncateVisible(line, cols))
+		buf.WriteString("\033[0m\033[K\r\n")
+	}
+	buf.WriteString(truncateVisible(footer, cols) + "\033[0m\033[K")
+	d.out.Write(buf.Bytes())
+}
+
+// truncateVisible shortens s to width visible characters, not counting
+// escape sequences
+func truncateVisible(s string, width int) string {
+	visible := 0
+	inEscape := false
+	for i, r := range s {
+		switch {
+		case inEscape:
+			if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '\\' || r == '\a' {
+				inEscape = false
+			}
+		case r == '\033':
+			inEscape = true
+		case r == '\t':
+			visible += 8 - visible%8
+		default:
+			visible++
+		}
+		if visible > width {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// visibleWidth counts the visible characters of s like truncateVisible
+func visibleWidth(s string) int {
+	visible := 0
+	inEscape := false
+	for _, r := range s {
+		switch {
+		case inEscape:
+			if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '\\' || r == '\a' {
+				inEscape = false
+			}
+		case r == '\033':
+			inEscape = true
+		case r == '\t':
+			visible += 8 - visible%8
+		default:
+			visible++
+		}
+	}
+	return visible
+}
+
+// terminalSize returns the rows and columns of the terminal, falling back to
+// LINES and COLUMNS or 24x80
+func terminalSize() (int, int) {
+	command := exec.Command("stty", "size")
+	command.Stdin = os.Stdin
+	if out, err := command.Output(); err == nil {
+		fields := strings.Fields(string(out))
+		if len(fields) == 2 {
+			rows, rerr := strconv.Atoi(fields[0])
+			cols, cerr := strconv.Atoi(fields[1])
+			if rerr == nil && cerr == nil && rows > 0 && cols > 0 {
+				return rows, cols
+			}
+		}
+	}
+
+	rows, _ := strconv.Atoi(os.Getenv("LINES"))
+	cols, _ := strconv.Atoi(os.Getenv("COLUMNS"))
+	if rows <= 0 {
+		rows = 24
+	}
+	if cols <= 0 {
+		cols = 80
+	}
+	return rows, cols
+}
+
+// rawTerminal makes stdin deliver single key presses without echo, returning
+// a function restoring the previous mode, or nil if stdin is no terminal
+func rawTerminal() func() {
+	if _, err := exec.LookPath("stty"); err != nil {
+		return nil
+	}
+
+	save := exec.Command("stty", "-g")
+	save.Stdin = os.Stdin
+	state, err := save.Output()
+	if err != nil {
+		return nil
+	}
+
+	raw := exec.Command("stty", "-icanon", "-echo", "min", "1")
+	raw.Stdin = os.Stdin
+	if err := raw.Run(); err != nil {
+		return nil
+	}
+
+	return func() {
+		restore := exec.Command("stty", strings.TrimSpace(string(state)))
+		restore.Stdin = os.Stdin
+		restore.Run()
+	}
+}
@@ -0,0 +1,56 @@
+package gin
+
+import (
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Deployer copies a built binary to the machine it is run on
+type Deployer interface {
+	// Deploy copies the binary and returns its path on the target
+	Deploy(binary string) (string, error)
+	// Host returns the ssh destination the binary is deployed to
+	Host() string
+}
+
+// SSHDeployer copies binaries to Dir on Destination with scp, or rsync when
+// Rsync is set. Destination is anything accepted by ssh, e.g. "pi@raspberrypi".
+type SSHDeployer struct {
+	Destination string
+	Dir         string
+	Rsync       bool
+}
+
+func (d *SSHDeployer) Host() string {
+	return d.Destination
+}
+
+func (d *SSHDeployer) Deploy(binary string) (string, error) {
+	mkdir := "mkdir -p " + shellQuote(d.Dir)
+	traceCommand("ssh", d.Destination, mkdir)
+	if output, err := exec.Command("ssh", d.Destination, mkdir).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("creating %s on %s: %v: %s", d.Dir, d.Destination, err, output)
+	}
+
+	target := path.Join(d.Dir, filepath.Base(binary))
+	var command *exec.Cmd
+	if d.Rsync {
+		command = exec.Command("rsync", "-az", binary, d.Destination+":"+target)
+	} else {
+		command = exec.Command("scp", "-q", binary, d.Destination+":"+target)
+	}
+
+	traceCommand(command.Path, command.Args[1:]...)
+	if output, err := command.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("copying %s to %s: %v: %s", binary, d.Destination, err, output)
+	}
+	return target, nil
+}
+
+// shellQuote quotes s for use in a POSIX shell command line
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
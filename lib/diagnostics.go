@@ -0,0 +1,242 @@
+package gin
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is a compiler error located in a source file
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// DiagnosticsFormatter writes diagnostics in a machine readable format
+type DiagnosticsFormatter func(w io.Writer, diagnostics []Diagnostic) error
+
+// diagnosticsFormatters maps format names to their formatter and the file
+// extension conventionally used for them
+var diagnosticsFormatters = map[string]struct {
+	format DiagnosticsFormatter
+	ext    string
+}{
+	"json":       {formatDiagnosticsJSON, ".json"},
+	"checkstyle": {formatCheckstyle, ".xml"},
+	"lsp":        {formatLSP, ".lsp.json"},
+}
+
+// compilerError matches the errors printed by go build, e.g.
+// "./main.go:12:5: undefined: foo"
+var compilerError = regexp.MustCompile(`^(.+\.go):(\d+)(?::(\d+))?: (.*)$`)
+
+// ParseDiagnostics extracts the located errors from the output of go build.
+// Relative paths are resolved against dir, the directory go build ran in.
+func ParseDiagnostics(output string, dir string) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, line := range strings.Split(output, "\n") {
+		// details such as "have (int)" / "want (string)" are indented
+		if strings.HasPrefix(line, "\t") && len(diagnostics) > 0 {
+			last := &diagnostics[len(diagnostics)-1]
+			last.Message += "\n" + strings.TrimSpace(line)
+			continue
+		}
+
+		m := compilerError.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+
+		file := m[1]
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		lineNo, _ := strconv.Atoi(m[2])
+		column, _ := strconv.Atoi(m[3])
+		diagnostics = append(diagnostics, Diagnostic{File: file, Line: lineNo, Column: column, Message: m[4]})
+	}
+	return diagnostics
+}
+
+// DiagnosticsFormats returns the names of the supported formats
+func DiagnosticsFormats() []string {
+	var names []string
+	for name := range diagnosticsFormatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DiagnosticsExt returns the file extension of format, e.g. ".json"
+func DiagnosticsExt(format string) string {
+	return diagnosticsFormatters[format].ext
+}
+
+// WriteDiagnostics writes diagnostics to w in the named format
+func WriteDiagnostics(w io.Writer, format string, diagnostics []Diagnostic) error {
+	f, ok := diagnosticsFormatters[format]
+	if !ok {
+		return fmt.Errorf("unknown diagnostics format %q (available: %s)", format, strings.Join(DiagnosticsFormats(), ", "))
+	}
+	return f.format(w, diagnostics)
+}
+
+func formatDiagnosticsJSON(w io.Writer, diagnostics []Diagnostic) error {
+	return formatJSON(w, diagnostics)
+}
+
+func formatJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+type checkstyleResult struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr,omitempty"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// formatCheckstyle writes the checkstyle XML understood by CI report viewers
+func formatCheckstyle(w io.Writer, diagnostics []Diagnostic) error {
+	result := checkstyleResult{Version: "4.3"}
+	index := make(map[string]int)
+	for _, d := range diagnostics {
+		i, ok := index[d.File]
+		if !ok {
+			i = len(result.Files)
+			index[d.File] = i
+			result.Files = append(result.Files, checkstyleFile{Name: d.File})
+		}
+		result.Files[i].Errors = append(result.Files[i].Errors, checkstyleError{
+			Line:     d.Line,
+			Column:   d.Column,
+			Severity: "error",
+			Message:  d.Message,
+			Source:   "go build",
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// LSPNotification is a textDocument/publishDiagnostics notification of the
+// Language Server Protocol
+type LSPNotification struct {
+	JSONRPC string                   `json:"jsonrpc"`
+	Method  string                   `json:"method"`
+	Params  PublishDiagnosticsParams `json:"params"`
+}
+
+// PublishDiagnosticsParams are the diagnostics of one document
+type PublishDiagnosticsParams struct {
+	URI         string          `json:"uri"`
+	Diagnostics []LSPDiagnostic `json:"diagnostics"`
+}
+
+// LSPDiagnostic is a diagnostic as defined by the Language Server Protocol.
+// Lines and characters count from 0.
+type LSPDiagnostic struct {
+	Range    LSPRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type LSPRange struct {
+	Start LSPPosition `json:"start"`
+	End   LSPPosition `json:"end"`
+}
+
+type LSPPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// lspSeverityError is the DiagnosticSeverity of errors
+const lspSeverityError = 1
+
+// PublishDiagnostics returns a publishDiagnostics notification for every file
+// with diagnostics, plus empty ones for the files in cleared, so clients drop
+// errors fixed since they were last published
+func PublishDiagnostics(diagnostics []Diagnostic, cleared []string) []LSPNotification {
+	var notifications []LSPNotification
+	index := make(map[string]int)
+	for _, d := range diagnostics {
+		i, ok := index[d.File]
+		if !ok {
+			i = len(notifications)
+			index[d.File] = i
+			notifications = append(notifications, newLSPNotification(d.File))
+		}
+
+		pos := LSPPosition{Line: d.Line - 1}
+		if d.Column > 0 {
+			pos.Character = d.Column - 1
+		}
+		params := &notifications[i].Params
+		params.Diagnostics = append(params.Diagnostics, LSPDiagnostic{
+			Range:    LSPRange{Start: pos, End: pos},
+			Severity: lspSeverityError,
+			Source:   "gin",
+			Message:  d.Message,
+		})
+	}
+
+	for _, file := range cleared {
+		if _, ok := index[file]; !ok {
+			notifications = append(notifications, newLSPNotification(file))
+		}
+	}
+	return notifications
+}
+
+func newLSPNotification(file string) LSPNotification {
+	uri := url.URL{Scheme: "file", Path: filepath.ToSlash(file)}
+	return LSPNotification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params:  PublishDiagnosticsParams{URI: uri.String(), Diagnostics: []LSPDiagnostic{}},
+	}
+}
+
+// formatLSP writes the publishDiagnostics notifications as a JSON array
+func formatLSP(w io.Writer, diagnostics []Diagnostic) error {
+	notifications := PublishDiagnostics(diagnostics, nil)
+	if notifications == nil {
+		notifications = []LSPNotification{}
+	}
+	return formatJSON(w, notifications)
+}
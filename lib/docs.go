@@ -0,0 +1,276 @@
+package gin
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// docPage describes the documentation of the app or one of its commands
+type docPage struct {
+	name        string
+	usage       string
+	usageText   string
+	argsUsage   string
+	description string
+	aliases     []string
+	flags       []Flag
+	commands    []*docPage
+	parent      *docPage
+	hasCommands bool
+}
+
+// file returns the base name of the files documenting the page, e.g.
+// gin-control-status for "gin control status"
+func (p *docPage) file() string {
+	return strings.Replace(p.name, " ", "-", -1)
+}
+
+// synopsis returns the usage line of the page, like the help output
+func (p *docPage) synopsis() string {
+	if p.usageText != "" {
+		return p.usageText
+	}
+
+	line := p.name
+	if p.parent == nil {
+		if len(p.flags) > 0 {
+			line += " [global options]"
+		}
+		if p.hasCommands {
+			line += " command [command options]"
+		}
+	} else {
+		if p.hasCommands {
+			line += " command"
+		}
+		if len(p.flags) > 0 {
+			line += " [command options]"
+		}
+	}
+
+	if p.argsUsage != "" {
+		return line + " " + p.argsUsage
+	}
+	return line + " [arguments...]"
+}
+
+// docPages returns the pages documenting the app and all visible commands,
+// parents before their commands
+func (a *App) docPages() []*docPage {
+	a.setup()
+
+	root := &docPage{
+		name:        a.Name,
+		usage:       a.Usage,
+		usageText:   a.UsageText,
+		argsUsage:   a.ArgsUsage,
+		description: a.Description,
+		flags:       docFlags(a.Flags),
+	}
+	pages := []*docPage{root}
+
+	var walk func(parent *docPage, commands []Command)
+	walk = func(parent *docPage, commands []Command) {
+		for _, c := range commands {
+			if c.Hidden || c.Name == helpCommand.Name {
+				continue
+			}
+			parent.hasCommands = true
+
+			page := &docPage{
+				name:        parent.name + " " + c.Name,
+				usage:       c.Usage,
+				usageText:   c.UsageText,
+				argsUsage:   c.ArgsUsage,
+				description: c.Description,
+				aliases:     c.Names()[1:],
+				flags:       docFlags(c.Flags),
+				parent:      parent,
+			}
+			parent.commands = append(parent.commands, page)
+			pages = append(pages, page)
+			walk(page, c.Subcommands)
+		}
+	}
+	walk(root, a.Commands)
+
+	return pages
+}
+
+// docFlags returns the visible flags except the built-in ones
+func docFlags(flags []Flag) []Flag {
+	var documented []Flag
+	for _, f := range visibleFlags(flags) {
+		if f.GetName() == HelpFlag.GetName() || f.GetName() == VersionFlag.GetName() {
+			continue
+		}
+		documented = append(documented, f)
+	}
+	return documented
+}
+
+// splitFlag splits the help line of a flag into its names and description
+func splitFlag(f Flag) (names, description string) {
+	line := FlagStringer(f)
+	if i := strings.Index(line, "\t"); i >= 0 {
+		return line[:i], line[i+1:]
+	}
+	return line, ""
+}
+
+// WriteManPages writes a troff man page for the app and one for each of its
+// commands, e.g. gin.1 and gin-run.1, to dir and returns their paths
+func (a *App) WriteManPages(dir string) ([]string, error) {
+	return a.writeDocs(dir, ".1", a.writeManPage)
+}
+
+// WriteMarkdownDocs writes a markdown page for the app and one for each of
+// its commands, e.g. gin.md and gin-run.md, to dir and returns their paths
+func (a *App) WriteMarkdownDocs(dir string) ([]string, error) {
+	return a.writeDocs(dir, ".md", writeMarkdownPage)
+}
+
+func (a *App) writeDocs(dir, ext string, write func(w io.Writer, page *docPage) error) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, page := range a.docPages() {
+		path := filepath.Join(dir, page.file()+ext)
+		file, err := os.Create(path)
+		if err != nil {
+			return paths, err
+		}
+		err = write(file, page)
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func (a *App) writeManPage(w io.Writer, page *docPage) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, ".TH %q 1 %q %q %q\n", strings.ToUpper(page.file()), a.Compiled.Format("January 2006"), a.Name, "User Commands")
+	fmt.Fprintf(&b, ".SH NAME\n%s \\- %s\n", manEscape(page.file()), manEscape(page.usage))
+	fmt.Fprintf(&b, ".SH SYNOPSIS\n\\fB%s\\fR\n", manEscape(page.synopsis()))
+	if len(page.aliases) > 0 {
+		fmt.Fprintf(&b, ".PP\nAliases: %s\n", manEscape(strings.Join(page.aliases, ", ")))
+	}
+	if page.description != "" {
+		fmt.Fprintf(&b, ".SH DESCRIPTION\n%s\n", manEscape(page.description))
+	}
+
+	if len(page.commands) > 0 {
+		b.WriteString(".SH COMMANDS\n")
+		for _, c := range page.commands {
+			fmt.Fprintf(&b, ".TP\n\\fB%s\\fR\n%s\n", manEscape(c.name[len(page.name)+1:]), manEscape(c.usage))
+		}
+	}
+
+	if len(page.flags) > 0 {
+		if page.parent == nil {
+			b.WriteString(".SH GLOBAL OPTIONS\n")
+		} else {
+			b.WriteString(".SH OPTIONS\n")
+		}
+		for _, f := range page.flags {
+			names, description := splitFlag(f)
+			fmt.Fprintf(&b, ".TP\n\\fB%s\\fR\n%s\n", manEscape(names), manEscape(description))
+		}
+	}
+
+	var related []string
+	if page.parent != nil {
+		related = append(related, page.parent.file())
+	}
+	for _, c := range page.commands {
+		related = append(related, c.file())
+	}
+	if len(related) > 0 {
+		b.WriteString(".SH SEE ALSO\n")
+		for i, name := range related {
+			if i > 0 {
+				b.WriteString(",\n")
+			}
+			fmt.Fprintf(&b, "\\fB%s\\fR(1)", manEscape(name))
+		}
+		b.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// manEscape escapes text for troff, so e.g. backslashes and leading dots
+// are printed literally
+func manEscape(text string) string {
+	text = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(text)
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func writeMarkdownPage(w io.Writer, page *docPage) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", page.name)
+	if page.usage != "" {
+		fmt.Fprintf(&b, "%s\n\n", page.usage)
+	}
+	fmt.Fprintf(&b, "```\n%s\n```\n\n", page.synopsis())
+	if len(page.aliases) > 0 {
+		fmt.Fprintf(&b, "Aliases: `%s`\n\n", strings.Join(page.aliases, "`, `"))
+	}
+	if page.description != "" {
+		fmt.Fprintf(&b, "%s\n\n", page.description)
+	}
+
+	if len(page.commands) > 0 {
+		b.WriteString("## Commands\n\n| Command | Description |\n| --- | --- |\n")
+		for _, c := range page.commands {
+			fmt.Fprintf(&b, "| [`%s`](%s.md) | %s |\n", c.name, c.file(), markdownCell(c.usage))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(page.flags) > 0 {
+		if page.parent == nil {
+			b.WriteString("## Global options\n\n")
+		} else {
+			b.WriteString("## Options\n\n")
+		}
+		b.WriteString("| Option | Description |\n| --- | --- |\n")
+		for _, f := range page.flags {
+			names, description := splitFlag(f)
+			fmt.Fprintf(&b, "| `%s` | %s |\n", strings.Replace(names, "|", `\|`, -1), markdownCell(description))
+		}
+		b.WriteString("\n")
+	}
+
+	if page.parent != nil {
+		fmt.Fprintf(&b, "See also [%s](%s.md).\n", page.parent.name, page.parent.file())
+	}
+
+	_, err := io.WriteString(w, strings.TrimRight(b.String(), "\n")+"\n")
+	return err
+}
+
+// markdownCell escapes text for a cell of a markdown table
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", `\|`, "<", "&lt;", ">", "&gt;", "\n", " ").Replace(text)
+}
@@ -0,0 +1,218 @@
+package gin
+
+import (
+	"bufio"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Outcomes of a Checkup
+const (
+	CheckOK   = "ok"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+// Checkup is the result of one of gin doctor's environment checks
+type Checkup struct {
+	Name   string
+	Status string
+	Detail string
+	// Fix suggests how to resolve a warning or failure
+	Fix string
+}
+
+func checkup(name string, status string, detail string, fix string) Checkup {
+	return Checkup{Name: name, Status: status, Detail: detail, Fix: fix}
+}
+
+var (
+	goVersion   = regexp.MustCompile(`go(\d+)\.(\d+)`)
+	goDirective = regexp.MustCompile(`(?m)^go (\d+)\.(\d+)`)
+)
+
+// CheckGo checks that the go tool is installed and at least as new as the go
+// directive of the go.mod in dir
+func CheckGo(dir string) Checkup {
+	out, err := exec.Command("go", "version").Output()
+	if err != nil {
+		return checkup("Go toolchain", CheckFail, err.Error(), "install Go from https://go.dev/dl/ and add it to PATH")
+	}
+	version := strings.TrimSpace(strings.TrimPrefix(string(out), "go version "))
+
+	have := goVersion.FindStringSubmatch(version)
+	mod, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	if have == nil || err != nil {
+		return checkup("Go toolchain", CheckOK, version, "")
+	}
+	want := goDirective.FindStringSubmatch(string(mod))
+	if want != nil && versionLess(have[1:], want[1:]) {
+		return checkup("Go toolchain", CheckFail, fmt.Sprintf("%s is older than go %s.%s required by go.mod", version, want[1], want[2]), "update Go from https://go.dev/dl/")
+	}
+	return checkup("Go toolchain", CheckOK, version, "")
+}
+
+func versionLess(a []string, b []string) bool {
+	for i := range a {
+		x, _ := strconv.Atoi(a[i])
+		y, _ := strconv.Atoi(b[i])
+		if x != y {
+			return x < y
+		}
+	}
+	return false
+}
+
+// CheckModules reports whether the build path is part of a module or GOPATH
+func CheckModules(dir string) Checkup {
+	command := exec.Command("go", "env", "GOMOD", "GO111MODULE", "GOPATH")
+	command.Dir = dir
+	out, err := command.Output()
+	if err != nil {
+		return checkup("Modules", CheckFail, err.Error(), "")
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	for len(lines) < 3 {
+		lines = append(lines, "")
+	}
+	gomod, mode, gopath := lines[0], lines[1], lines[2]
+
+	switch {
+	case gomod != "" && gomod != os.DevNull:
+		return checkup("Modules", CheckOK, "using "+gomod, "")
+	case mode == "off":
+		return checkup("Modules", CheckWarn, "GOPATH mode with GOPATH="+gopath, "migrate to modules with go mod init")
+	}
+	return checkup("Modules", CheckFail, "no go.mod found in "+dir+" or its parents", "run go mod init <module path>")
+}
+
+// CheckMainPackage checks that the build path or target contains a main
+// package
+func CheckMainPackage(dir string) Checkup {
+	if err := MainPackage(dir); err != nil {
+		return checkup("Main package", CheckFail, err.Error(), "point --target at the directory of your main package")
+	}
+	return checkup("Main package", CheckOK, "found in "+dir, "")
+}
+
+// watchLimitFix explains how to raise the inotify watch limit
+const watchLimitFix = "raise the limit with sudo sysctl fs.inotify.max_user_watches=524288 (add it to /etc/sysctl.conf to keep it) or exclude directories with --excludeDir"
+
+// inotifyLimit reads one of the inotify limits of Linux, e.g.
+// max_user_watches
+func inotifyLimit(name string) (int, error) {
+	data, err := ioutil.ReadFile("/proc/sys/fs/inotify/" + name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// CheckWatchLimit compares the number of directories watched with the
+// inotify watch limit on Linux
+func CheckWatchLimit(opts WatchOptions) Checkup {
+	dirs := 0
+	opts.walk(opts.Path, func(path string, info os.FileInfo) error {
+		if info.IsDir() {
+			dirs++
+		}
+		return nil
+	})
+
+	if runtime.GOOS != "linux" {
+		return checkup("Watch limit", CheckOK, fmt.Sprintf("%d directories to watch", dirs), "")
+	}
+
+	limit, err := inotifyLimit("max_user_watches")
+	if err != nil {
+		return checkup("Watch limit", CheckWarn, err.Error(), "")
+	}
+	detail := fmt.Sprintf("%d directories to watch, limit %d", dirs, limit)
+	fix := watchLimitFix
+
+	switch {
+	case dirs > limit:
+		return checkup("Watch limit", CheckFail, detail, fix)
+	case dirs > limit*8/10:
+		// other programs, e.g. editors, use watches too
+		return checkup("Watch limit", CheckWarn, detail, fix)
+	}
+	return checkup("Watch limit", CheckOK, detail, "")
+}
+
+// CheckPortFree checks that nothing listens on addr
+func CheckPortFree(name string, addr string, flag string) Checkup {
+	if err := CheckPort(addr); err != nil {
+		return checkup(name, CheckFail, err.Error(), "stop the other process, pass another "+flag+" or 0 to pick a free port")
+	}
+	return checkup(name, CheckOK, addr+" is free", "")
+}
+
+// CheckEnvFiles parses the given env files, reporting malformed lines. Missing
+// files are fine when optional is set.
+func CheckEnvFiles(files []string, optional bool) Checkup {
+	var loaded, problems []string
+	for _, name := range files {
+		file, err := os.Open(name)
+		if os.IsNotExist(err) && optional {
+			continue
+		} else if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+
+		scanner := bufio.NewScanner(file)
+		for n := 1; scanner.Scan(); n++ {
+			if _, _, err := parseln(scanner.Text(), os.Getenv); err != nil {
+				problems = append(problems, fmt.Sprintf("%s:%d: %s", name, n, err))
+			}
+		}
+		file.Close()
+		loaded = append(loaded, name)
+	}
+
+	switch {
+	case len(problems) > 0:
+		return checkup("Env files", CheckFail, strings.Join(problems, "\n"), "use KEY=value lines, comments start with #")
+	case len(loaded) == 0:
+		return checkup("Env files", CheckOK, "none found", "")
+	}
+	return checkup("Env files", CheckOK, strings.Join(loaded, ", ")+" valid", "")
+}
+
+// CheckCert checks that the TLS certificate in certFile has not expired and
+// won't within 30 days
+func CheckCert(certFile string, now time.Time) Checkup {
+	data, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return checkup("TLS certificate", CheckFail, err.Error(), "pass an existing --certFile")
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return checkup("TLS certificate", CheckFail, "no PEM data in "+certFile, "pass a PEM encoded --certFile")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return checkup("TLS certificate", CheckFail, err.Error(), "")
+	}
+
+	fix := "create a new certificate, e.g. with mkcert"
+	expiry := cert.NotAfter.Format("2006-01-02")
+	switch {
+	case now.After(cert.NotAfter):
+		return checkup("TLS certificate", CheckFail, "expired on "+expiry, fix)
+	case now.Add(30 * 24 * time.Hour).After(cert.NotAfter):
+		return checkup("TLS certificate", CheckWarn, "expires on "+expiry, fix)
+	}
+	return checkup("TLS certificate", CheckOK, "valid until "+expiry, "")
+}
@@ -0,0 +1,147 @@
+package gin
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// embedDirective starts the lines embedding files into the binary
+const embedDirective = "//go:embed"
+
+// Embeds tracks the files embedded into the binary with //go:embed
+// directives in the watched sources. Changes to them require a rebuild, even
+// though they aren't Go files.
+type Embeds struct {
+	mu sync.Mutex
+	// patterns of the directives per Go file, relative to its directory
+	patterns map[string][]string
+}
+
+// ScanEmbeds reads the directives of the Go files below the watched path
+func ScanEmbeds(opts WatchOptions) *Embeds {
+	e := &Embeds{patterns: make(map[string][]string)}
+	var files []string
+	opts.walk(opts.Path, func(path string, info os.FileInfo) error {
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	e.Update(files)
+	return e
+}
+
+// Update reads the directives of the changed Go files among files again,
+// e.g. after a change, forgetting those of removed files
+func (e *Embeds) Update(files []string) {
+	if e == nil {
+		return
+	}
+	for _, file := range files {
+		if filepath.Ext(file) != ".go" || strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		patterns := embedPatterns(file)
+		e.mu.Lock()
+		if len(patterns) > 0 {
+			e.patterns[file] = patterns
+		} else {
+			delete(e.patterns, file)
+		}
+		e.mu.Unlock()
+	}
+}
+
+// Contains reports whether the file at path is embedded, by a pattern
+// naming it or a directory containing it
+func (e *Embeds) Contains(file string) bool {
+	if e == nil {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for source, patterns := range e.patterns {
+		rel, err := filepath.Rel(filepath.Dir(source), file)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, pattern := range patterns {
+			for name := rel; name != "."; name = path.Dir(name) {
+				if ok, _ := path.Match(pattern, name); ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Len returns the number of Go files with directives
+func (e *Embeds) Len() int {
+	if e == nil {
+		return 0
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.patterns)
+}
+
+// embedPatterns returns the patterns of the directives in the Go file at
+// path. Patterns are separated by spaces and may be quoted, and the all:
+// prefix including hidden files is dropped.
+func embedPatterns(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, embedDirective+" ") && !strings.HasPrefix(line, embedDirective+"\t") {
+			continue
+		}
+		for _, pattern := range splitEmbedPatterns(line[len(embedDirective):]) {
+			patterns = append(patterns, strings.TrimPrefix(pattern, "all:"))
+		}
+	}
+	return patterns
+}
+
+// splitEmbedPatterns splits the arguments of a directive, which may be
+// quoted with " or `
+func splitEmbedPatterns(args string) []string {
+	var patterns []string
+	for {
+		args = strings.TrimLeft(args, " \t")
+		if args == "" {
+			return patterns
+		}
+
+		end := strings.IndexAny(args, " \t")
+		if quote := args[0]; quote == '"' || quote == '`' {
+			end = strings.IndexByte(args[1:], quote) + 2
+			if end == 1 {
+				return patterns
+			}
+		} else if end < 0 {
+			end = len(args)
+		}
+
+		pattern := args[:end]
+		if unquoted, err := strconv.Unquote(pattern); err == nil {
+			pattern = unquoted
+		}
+		patterns = append(patterns, pattern)
+		args = args[end:]
+	}
+}
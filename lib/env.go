@@ -5,26 +5,80 @@ import (
 	"errors"
 	"io"
 	"os"
+	"sort"
 	"strings"
 )
 
+// DefaultEnvFiles are loaded by Bootstrap when no files are given. Values in
+// .env.local override the ones in .env.
+var DefaultEnvFiles = []string{".env", ".env.local"}
+
 // Env represents the values parsed from the .env file
 type Env map[string]string
 
-// Bootstrap loads a .env file into the current environment using envy.Load
-func Bootstrap() (Env, error) {
-	file, err := os.Open(".env")
-	if err != nil {
-		return nil, err
+// Bootstrap loads the given .env files, later files overriding earlier ones.
+// Without arguments DefaultEnvFiles are loaded, skipping the ones that do
+// not exist. The current environment is left untouched, the values are
+// meant to be merged with Environ.
+func Bootstrap(files ...string) (Env, error) {
+	return BootstrapEnv(nil, files...)
+}
+
+// BootstrapEnv loads the .env files like Bootstrap on top of seed, the
+// variables gin sets for the app, e.g. PORT, so the files can reference them
+// as ${PORT}. The returned Env includes seed.
+func BootstrapEnv(seed Env, files ...string) (Env, error) {
+	optional := len(files) == 0
+	if optional {
+		files = DefaultEnvFiles
+	}
+
+	env := make(Env)
+	for k, v := range seed {
+		env[k] = v
+	}
+	var firstErr error
+	loaded := 0
+	for _, name := range files {
+		file, err := os.Open(name)
+		if err != nil {
+			if optional && os.IsNotExist(err) {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			return env, err
+		}
+
+		err = env.load(file)
+		file.Close()
+		if err != nil {
+			return env, err
+		}
+		loaded++
 	}
 
-	return Load(file)
+	if loaded == 0 && firstErr != nil {
+		return env, firstErr
+	}
+
+	return env, nil
 }
 
-// Load parses lines of a reader in the .env format.
+// Load parses lines of a reader in the .env format. Values may reference
+// previously defined or existing environment variables as $VAR or ${VAR},
+// unless they are single quoted.
 func Load(reader io.Reader) (Env, error) {
+	env := make(Env)
+	err := env.load(reader)
+	return env, err
+}
+
+// load adds the lines of reader to e, expanding references against the
+// values of e before the ones of the environment
+func (e Env) load(reader io.Reader) error {
 	r := bufio.NewReader(reader)
-	env := make(map[string]string)
 
 	for {
 		line, _, err := r.ReadLine()
@@ -32,16 +86,53 @@ func Load(reader io.Reader) (Env, error) {
 			break
 		}
 
-		key, val, err := parseln(string(line))
+		key, val, err := parseln(string(line), e.lookup)
 		if err != nil {
-			return env, err
+			return err
+		}
+		if key == "" {
+			continue
 		}
 
-		env[key] = val
-		os.Setenv(key, val)
+		e[key] = val
 	}
 
-	return env, nil
+	return nil
+}
+
+// lookup returns the value of the variable name, from e if it is defined
+// there, otherwise from the environment
+func (e Env) lookup(name string) string {
+	if val, ok := e[name]; ok {
+		return val
+	}
+	return os.Getenv(name)
+}
+
+// Environ returns base with the values of e added, replacing variables
+// of the same name, in the "key=value" form used by exec.Cmd.
+func (e Env) Environ(base []string) []string {
+	environ := make([]string, 0, len(base)+len(e))
+	for _, kv := range base {
+		key := kv
+		if i := strings.Index(kv, "="); i >= 0 {
+			key = kv[:i]
+		}
+		if _, ok := e[key]; !ok {
+			environ = append(environ, kv)
+		}
+	}
+
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		environ = append(environ, key+"="+e[key])
+	}
+
+	return environ
 }
 
 func removeComments(s string) string {
@@ -56,7 +147,7 @@ func removeComments(s string) string {
 	return s
 }
 
-func parseln(line string) (key string, val string, err error) {
+func parseln(line string, lookup func(string) string) (key string, val string, err error) {
 	line = removeComments(line)
 	if len(line) == 0 {
 		return "", "", nil
@@ -70,5 +161,9 @@ func parseln(line string) (key string, val string, err error) {
 	key = strings.Trim(splits[0], " ")
 	val = strings.Trim(splits[1], ` "'`)
 
+	if !strings.HasPrefix(strings.TrimSpace(splits[1]), "'") {
+		val = os.Expand(val, lookup)
+	}
+
 	return key, val, nil
 }
@@ -0,0 +1,99 @@
+package gin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadLeavesEnvironmentUntouched(t *testing.T) {
+	os.Unsetenv("GIN_TEST_LOADED")
+	os.Setenv("GIN_TEST_HOME", "/home/gin")
+	defer os.Unsetenv("GIN_TEST_HOME")
+
+	env, err := Load(strings.NewReader(strings.Join([]string{
+		"# comment",
+		"GIN_TEST_LOADED=1",
+		`GIN_TEST_URL="http://localhost:${GIN_TEST_LOADED}"`,
+		"GIN_TEST_DIR=$GIN_TEST_HOME/src # trailing comment",
+		"GIN_TEST_LITERAL='$GIN_TEST_LOADED'",
+	}, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Env{
+		"GIN_TEST_LOADED":  "1",
+		"GIN_TEST_URL":     "http://localhost:1",
+		"GIN_TEST_DIR":     "/home/gin/src",
+		"GIN_TEST_LITERAL": "$GIN_TEST_LOADED",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("Load = %v, want %v", env, want)
+	}
+	if value, ok := os.LookupEnv("GIN_TEST_LOADED"); ok {
+		t.Errorf("Load set GIN_TEST_LOADED=%s in the environment", value)
+	}
+}
+
+func TestLoadMissingDelimiter(t *testing.T) {
+	if _, err := Load(strings.NewReader("GIN_TEST_BROKEN\n")); err == nil {
+		t.Error("Load accepted a line without =")
+	}
+}
+
+func TestBootstrapLaterFilesOverride(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gin-env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env := filepath.Join(dir, ".env")
+	local := filepath.Join(dir, ".env.local")
+	ioutil.WriteFile(env, []byte("GIN_TEST_A=a\nGIN_TEST_B=b\n"), 0644)
+	ioutil.WriteFile(local, []byte("GIN_TEST_B=${GIN_TEST_A}2\n"), 0644)
+
+	got, err := Bootstrap(env, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Env{"GIN_TEST_A": "a", "GIN_TEST_B": "a2"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Bootstrap = %v, want %v", got, want)
+	}
+	if _, ok := os.LookupEnv("GIN_TEST_A"); ok {
+		t.Error("Bootstrap set GIN_TEST_A in the environment")
+	}
+}
+
+func TestEnvironReplacesBase(t *testing.T) {
+	env := Env{"PORT": "3001", "B": "2"}
+	got := env.Environ([]string{"PATH=/bin", "PORT=3000"})
+	want := []string{"PATH=/bin", "B=2", "PORT=3001"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Environ = %q, want %q", got, want)
+	}
+}
+
+func TestBootstrapEnvExpandsSeed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gin-env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Unsetenv("PORT")
+
+	env := filepath.Join(dir, ".env")
+	ioutil.WriteFile(env, []byte("API_ROOT=http://localhost:${PORT}/api\n"), 0644)
+
+	got, err := BootstrapEnv(Env{"PORT": "3001"}, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Env{"PORT": "3001", "API_ROOT": "http://localhost:3001/api"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("BootstrapEnv = %v, want %v", got, want)
+	}
+}
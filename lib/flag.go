@@ -122,6 +122,64 @@ func flagSet(name string, flags []Flag) (*flag.FlagSet, error) {
 	return set, nil
 }
 
+// validatableFlag is implemented by flags with a Validator, which rejects
+// bad values at parse time instead of deep inside the command
+type validatableFlag interface {
+	validate(set *flag.FlagSet) error
+}
+
+// validateFlags runs the validators of the flags against the parsed set
+func validateFlags(flags []Flag, set *flag.FlagSet) error {
+	for _, f := range flags {
+		if vf, ok := f.(validatableFlag); ok {
+			if err := vf.validate(set); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateFlag calls validate with the first name of the flag and reports
+// its error the way the flag package reports values it can't parse
+func validateFlag(set *flag.FlagSet, fullName string, validate func(name string) error) error {
+	name := strings.TrimSpace(strings.Split(fullName, ",")[0])
+	if err := validate(name); err != nil {
+		value := ""
+		if f := set.Lookup(name); f != nil {
+			value = f.Value.String()
+		}
+		return fmt.Errorf("invalid value %q for flag -%s: %v", value, name, err)
+	}
+	return nil
+}
+
+// OneOf returns a Validator accepting the given values. The empty value of
+// an unset flag is always accepted.
+func OneOf(values ...string) func(string) error {
+	return func(value string) error {
+		if value == "" {
+			return nil
+		}
+		for _, v := range values {
+			if v == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected one of %s", strings.Join(values, ", "))
+	}
+}
+
+// IntRange returns a Validator accepting values between min and max inclusive
+func IntRange(min, max int) func(int) error {
+	return func(value int) error {
+		if value < min || value > max {
+			return fmt.Errorf("expected a value between %d and %d", min, max)
+		}
+		return nil
+	}
+}
+
 func eachName(longName string, fn func(string)) {
 	parts := strings.Split(longName, ",")
 	for _, name := range parts {
@@ -195,7 +253,11 @@ func withEnvHint(envVar, str string) string {
 			suffix = "%"
 			sep = "%, %"
 		}
-		envText = " [" + prefix + strings.Join(strings.Split(envVar, ","), sep) + suffix + "]"
+		names := strings.Split(envVar, ",")
+		for i, name := range names {
+			names[i] = strings.TrimSpace(name)
+		}
+		envText = " [" + prefix + strings.Join(names, sep) + suffix + "]"
 	}
 	return str + envText
 }
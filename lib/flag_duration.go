@@ -16,6 +16,7 @@ type DurationFlag struct {
 	Hidden      bool
 	Value       time.Duration
 	Destination *time.Duration
+	Validator   func(time.Duration) error
 }
 
 // String returns a readable representation of this value
@@ -93,6 +94,16 @@ func (f DurationFlag) ApplyWithError(set *flag.FlagSet) error {
 	return nil
 }
 
+// validate calls the Validator, if any, with the parsed value
+func (f DurationFlag) validate(set *flag.FlagSet) error {
+	if f.Validator == nil {
+		return nil
+	}
+	return validateFlag(set, f.Name, func(name string) error {
+		return f.Validator(lookupDuration(name, set))
+	})
+}
+
 func lookupDuration(name string, set *flag.FlagSet) time.Duration {
 	f := set.Lookup(name)
 	if f != nil {
@@ -16,6 +16,7 @@ type Float64Flag struct {
 	Hidden      bool
 	Value       float64
 	Destination *float64
+	Validator   func(float64) error
 }
 
 // String returns a readable representation of this value
@@ -93,6 +94,16 @@ func (f Float64Flag) ApplyWithError(set *flag.FlagSet) error {
 	return nil
 }
 
+// validate calls the Validator, if any, with the parsed value
+func (f Float64Flag) validate(set *flag.FlagSet) error {
+	if f.Validator == nil {
+		return nil
+	}
+	return validateFlag(set, f.Name, func(name string) error {
+		return f.Validator(lookupFloat64(name, set))
+	})
+}
+
 func lookupFloat64(name string, set *flag.FlagSet) float64 {
 	f := set.Lookup(name)
 	if f != nil {
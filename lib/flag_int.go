@@ -16,6 +16,7 @@ type IntFlag struct {
 	Hidden      bool
 	Value       int
 	Destination *int
+	Validator   func(int) error
 }
 
 // String returns a readable representation of this value
@@ -77,6 +78,16 @@ func (f IntFlag) ApplyWithError(set *flag.FlagSet) error {
 	return nil
 }
 
+// validate calls the Validator, if any, with the parsed value
+func (f IntFlag) validate(set *flag.FlagSet) error {
+	if f.Validator == nil {
+		return nil
+	}
+	return validateFlag(set, f.Name, func(name string) error {
+		return f.Validator(lookupInt(name, set))
+	})
+}
+
 // Int looks up the value of a local IntFlag, returns
 // 0 if not found
 func (c *Context) Int(name string) int {
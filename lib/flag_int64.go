@@ -16,6 +16,7 @@ type Int64Flag struct {
 	Hidden      bool
 	Value       int64
 	Destination *int64
+	Validator   func(int64) error
 }
 
 // String returns a readable representation of this value
@@ -78,6 +79,16 @@ func (f Int64Flag) ApplyWithError(set *flag.FlagSet) error {
 	return nil
 }
 
+// validate calls the Validator, if any, with the parsed value
+func (f Int64Flag) validate(set *flag.FlagSet) error {
+	if f.Validator == nil {
+		return nil
+	}
+	return validateFlag(set, f.Name, func(name string) error {
+		return f.Validator(lookupInt64(name, set))
+	})
+}
+
 // Int64 looks up the value of a local Int64Flag, returns
 // 0 if not found
 func (c *Context) Int64(name string) int64 {
@@ -42,13 +42,14 @@ func (f *Int64Slice) Get() interface{} {
 
 // Int64SliceFlag is a flag with type *Int64Slice
 type Int64SliceFlag struct {
-	Name     string
-	Usage    string
-	EnvVar   string
-	FilePath string
-	Required bool
-	Hidden   bool
-	Value    *Int64Slice
+	Name      string
+	Usage     string
+	EnvVar    string
+	FilePath  string
+	Required  bool
+	Hidden    bool
+	Value     *Int64Slice
+	Validator func([]int64) error
 }
 
 // String returns a readable representation of this value
@@ -119,6 +120,16 @@ func (f Int64SliceFlag) ApplyWithError(set *flag.FlagSet) error {
 	return nil
 }
 
+// validate calls the Validator, if any, with the parsed value
+func (f Int64SliceFlag) validate(set *flag.FlagSet) error {
+	if f.Validator == nil {
+		return nil
+	}
+	return validateFlag(set, f.Name, func(name string) error {
+		return f.Validator(lookupInt64Slice(name, set))
+	})
+}
+
 // Int64Slice looks up the value of a local Int64SliceFlag, returns
 // nil if not found
 func (c *Context) Int64Slice(name string) []int64 {
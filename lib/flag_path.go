@@ -0,0 +1,156 @@
+package gin
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PathFlag is a flag with type string naming a file or directory. A leading
+// ~ is expanded to the home directory of the user.
+type PathFlag struct {
+	Name        string
+	Usage       string
+	EnvVar      string
+	FilePath    string
+	Required    bool
+	Hidden      bool
+	TakesFile   bool
+	Value       string
+	Destination *string
+	Validator   func(string) error
+	// MustExist makes parsing fail if the given path does not exist
+	MustExist bool
+	// Directory marks paths naming a directory, e.g. for shell completion
+	Directory bool
+}
+
+// String returns a readable representation of this value
+// (for usage defaults)
+func (f PathFlag) String() string {
+	return FlagStringer(f)
+}
+
+// GetName returns the name of the flag
+func (f PathFlag) GetName() string {
+	return f.Name
+}
+
+// IsRequired returns whether the flag is required
+func (f PathFlag) IsRequired() bool {
+	return f.Required
+}
+
+// TakesValue returns true of the flag takes a value, otherwise false
+func (f PathFlag) TakesValue() bool {
+	return true
+}
+
+// GetUsage returns the usage string for the flag
+func (f PathFlag) GetUsage() string {
+	return f.Usage
+}
+
+// GetValue returns the flags value as string representation and an empty
+// string if the flag takes no value at all.
+func (f PathFlag) GetValue() string {
+	return f.Value
+}
+
+// Apply populates the flag given the flag set and environment
+// Ignores errors
+func (f PathFlag) Apply(set *flag.FlagSet) {
+	_ = f.ApplyWithError(set)
+}
+
+// ApplyWithError populates the flag given the flag set and environment
+func (f PathFlag) ApplyWithError(set *flag.FlagSet) error {
+	value := &pathValue{mustExist: f.MustExist}
+	if f.Destination != nil {
+		value.path = f.Destination
+	} else {
+		value.path = new(string)
+	}
+	// the default is not required to exist, e.g. a file created later
+	*value.path = ExpandPath(f.Value)
+
+	if envVal, ok := flagFromFileEnv(f.FilePath, f.EnvVar); ok {
+		if err := value.Set(envVal); err != nil {
+			return fmt.Errorf("could not parse %s as path for flag %s: %s", envVal, f.Name, err)
+		}
+	}
+
+	eachName(f.Name, func(name string) {
+		set.Var(value, name, f.Usage)
+	})
+
+	return nil
+}
+
+// validate calls the Validator, if any, with the parsed value
+func (f PathFlag) validate(set *flag.FlagSet) error {
+	if f.Validator == nil {
+		return nil
+	}
+	return validateFlag(set, f.Name, func(name string) error {
+		return f.Validator(lookupString(name, set))
+	})
+}
+
+// Path looks up the value of a local PathFlag, returns
+// "" if not found
+func (c *Context) Path(name string) string {
+	return lookupString(name, c.flagSet)
+}
+
+// GlobalPath looks up the value of a global PathFlag, returns
+// "" if not found
+func (c *Context) GlobalPath(name string) string {
+	if fs := lookupGlobalFlagSet(name, c); fs != nil {
+		return lookupString(name, fs)
+	}
+	return ""
+}
+
+// ExpandPath replaces a leading ~ in path with the home directory of the
+// user. Paths of other users, e.g. ~bob/src, are returned unchanged.
+func ExpandPath(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// pathValue is the flag.Value of a PathFlag
+type pathValue struct {
+	path      *string
+	mustExist bool
+}
+
+func (v *pathValue) Set(value string) error {
+	value = ExpandPath(value)
+	if v.mustExist {
+		if _, err := os.Stat(value); err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("%s does not exist", value)
+			}
+			return err
+		}
+	}
+	*v.path = value
+	return nil
+}
+
+func (v *pathValue) String() string {
+	if v.path == nil {
+		return ""
+	}
+	return *v.path
+}
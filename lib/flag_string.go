@@ -13,6 +13,7 @@ type StringFlag struct {
 	TakesFile   bool
 	Value       string
 	Destination *string
+	Validator   func(string) error
 }
 
 // String returns a readable representation of this value
@@ -70,6 +71,16 @@ func (f StringFlag) ApplyWithError(set *flag.FlagSet) error {
 	return nil
 }
 
+// validate calls the Validator, if any, with the parsed value
+func (f StringFlag) validate(set *flag.FlagSet) error {
+	if f.Validator == nil {
+		return nil
+	}
+	return validateFlag(set, f.Name, func(name string) error {
+		return f.Validator(lookupString(name, set))
+	})
+}
+
 // String looks up the value of a local StringFlag, returns
 // "" if not found
 func (c *Context) String(name string) string {
@@ -40,6 +40,7 @@ type StringSliceFlag struct {
 	Hidden    bool
 	TakesFile bool
 	Value     *StringSlice
+	Validator func([]string) error
 }
 
 // String returns a readable representation of this value
@@ -110,6 +111,16 @@ func (f StringSliceFlag) ApplyWithError(set *flag.FlagSet) error {
 	return nil
 }
 
+// validate calls the Validator, if any, with the parsed value
+func (f StringSliceFlag) validate(set *flag.FlagSet) error {
+	if f.Validator == nil {
+		return nil
+	}
+	return validateFlag(set, f.Name, func(name string) error {
+		return f.Validator(lookupStringSlice(name, set))
+	})
+}
+
 // StringSlice looks up the value of a local StringSliceFlag, returns
 // nil if not found
 func (c *Context) StringSlice(name string) []string {
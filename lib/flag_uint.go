@@ -16,6 +16,7 @@ type UintFlag struct {
 	Hidden      bool
 	Value       uint
 	Destination *uint
+	Validator   func(uint) error
 }
 
 // String returns a readable representation of this value
@@ -72,6 +73,16 @@ func (f UintFlag) ApplyWithError(set *flag.FlagSet) error {
 	return nil
 }
 
+// validate calls the Validator, if any, with the parsed value
+func (f UintFlag) validate(set *flag.FlagSet) error {
+	if f.Validator == nil {
+		return nil
+	}
+	return validateFlag(set, f.Name, func(name string) error {
+		return f.Validator(lookupUint(name, set))
+	})
+}
+
 // GetValue returns the flags value as string representation and an empty
 // string if the flag takes no value at all.
 func (f UintFlag) GetValue() string {
@@ -16,6 +16,7 @@ type Uint64Flag struct {
 	Hidden      bool
 	Value       uint64
 	Destination *uint64
+	Validator   func(uint64) error
 }
 
 // String returns a readable representation of this value
@@ -78,6 +79,16 @@ func (f Uint64Flag) ApplyWithError(set *flag.FlagSet) error {
 	return nil
 }
 
+// validate calls the Validator, if any, with the parsed value
+func (f Uint64Flag) validate(set *flag.FlagSet) error {
+	if f.Validator == nil {
+		return nil
+	}
+	return validateFlag(set, f.Name, func(name string) error {
+		return f.Validator(lookupUint64(name, set))
+	})
+}
+
 // Uint64 looks up the value of a local Uint64Flag, returns
 // 0 if not found
 func (c *Context) Uint64(name string) uint64 {
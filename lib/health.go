@@ -0,0 +1,201 @@
+package gin
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthPath is where the proxy serves the Health of gin
+const HealthPath = ControlPrefix + "health"
+
+// Health keeps the build, restart and watcher stats reported at HealthPath
+type Health struct {
+	mu        sync.Mutex
+	started   time.Time
+	path      string
+	watcher   string
+	builds    int
+	failures  int
+	duration  time.Duration
+	built     time.Time
+	errors    string
+	changes   int
+	changed   time.Time
+	files     []string
+	appStarts int
+}
+
+// HealthReport is the JSON document served at HealthPath
+type HealthReport struct {
+	Name    string      `json:"name"`
+	Version string      `json:"version"`
+	State   string      `json:"state"`
+	Uptime  float64     `json:"uptime_s"`
+	Build   BuildHealth `json:"build"`
+	App     AppHealth   `json:"app"`
+	Watch   WatchHealth `json:"watch"`
+}
+
+// BuildHealth describes the builds since gin started
+type BuildHealth struct {
+	Count        int        `json:"count"`
+	Failures     int        `json:"failures"`
+	LastDuration float64    `json:"last_duration_ms"`
+	LastFinished *time.Time `json:"last_finished,omitempty"`
+	Errors       string     `json:"errors,omitempty"`
+}
+
+// AppHealth describes the app process
+type AppHealth struct {
+	Pid      int        `json:"pid,omitempty"`
+	Running  bool       `json:"running"`
+	Started  *time.Time `json:"started,omitempty"`
+	Uptime   float64    `json:"uptime_s"`
+	Restarts int        `json:"restarts"`
+}
+
+// WatchHealth describes the watched path and the changes seen
+type WatchHealth struct {
+	Path        string     `json:"path"`
+	Watcher     string     `json:"watcher"`
+	Changes     int        `json:"changes"`
+	LastChange  *time.Time `json:"last_change,omitempty"`
+	LastChanged []string   `json:"last_changed,omitempty"`
+}
+
+// ProcessInfo describes the process started by a Runner
+type ProcessInfo struct {
+	Pid     int
+	Running bool
+	Started time.Time
+	// Starts counts the processes started so far
+	Starts int
+}
+
+// NewHealth creates a Health, reporting the time since it was created as
+// the uptime of gin
+func NewHealth() *Health {
+	return &Health{started: time.Now()}
+}
+
+// Watching records the watched path and the spec of the watcher
+func (h *Health) Watching(path, watcher string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.path = path
+	h.watcher = watcher
+}
+
+// BuildFinished records a build which took duration and failed with errors,
+// if any
+func (h *Health) BuildFinished(duration time.Duration, errors string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.builds++
+	if errors != "" {
+		h.failures++
+	}
+	h.duration = duration
+	h.built = time.Now()
+	h.errors = errors
+}
+
+// Changed records the files of a change reported by the watcher
+func (h *Health) Changed(files []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.changes++
+	h.changed = time.Now()
+	h.files = files
+}
+
+// LastChanged returns the files of the last change reported by the watcher
+func (h *Health) LastChanged() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.files
+}
+
+// Report returns the current health for the given build state, one of the
+// StatusDisplay states, and the process of runner
+func (h *Health) Report(name, version, state string, runner Runner) HealthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	report := HealthReport{
+		Name:    name,
+		Version: version,
+		State:   stateName(state),
+		Uptime:  time.Since(h.started).Seconds(),
+		Build: BuildHealth{
+			Count:        h.builds,
+			Failures:     h.failures,
+			LastDuration: float64(h.duration) / float64(time.Millisecond),
+			LastFinished: timeOrNil(h.built),
+			Errors:       h.errors,
+		},
+		Watch: WatchHealth{
+			Path:        h.path,
+			Watcher:     h.watcher,
+			Changes:     h.changes,
+			LastChange:  timeOrNil(h.changed),
+			LastChanged: h.files,
+		},
+	}
+
+	if r, ok := runner.(interface{ ProcessInfo() ProcessInfo }); ok {
+		info := r.ProcessInfo()
+		report.App = AppHealth{Pid: info.Pid, Running: info.Running, Started: timeOrNil(info.Started)}
+		if info.Running {
+			report.App.Uptime = time.Since(info.Started).Seconds()
+		}
+		if info.Starts > 1 {
+			report.App.Restarts = info.Starts - 1
+		}
+	}
+	return report
+}
+
+// timeOrNil returns nil for the zero time, so it's left out of reports
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// stateName returns the word for a StatusDisplay state
+func stateName(state string) string {
+	switch state {
+	case StatusBuilding:
+		return "building"
+	case StatusOK:
+		return "ok"
+	case StatusFailed:
+		return "failed"
+	case StatusStale:
+		return "stale"
+	}
+	return "starting"
+}
+
+// ServeHealth makes the proxy answer requests for HealthPath with the JSON
+// encoding of the value returned by fn, without passing them to the app
+func (p *Proxy) ServeHealth(fn func() interface{}) {
+	p.health = fn
+}
+
+func (p *Proxy) serveHealth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if p.health == nil || req.URL.Path != HealthPath {
+			next.ServeHTTP(res, req)
+			return
+		}
+		res.Header().Set("Cache-Control", "no-store")
+		writeJSON(res, p.health())
+	})
+}
@@ -4,21 +4,100 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"reflect"
 	"strings"
+	"text/tabwriter"
+	"text/template"
 	"unicode/utf8"
 )
 
+// AppHelpTemplate is the text template for the Default help topic.
+// cli.go uses text/template to render templates. You can
+// render custom help text by setting this variable.
+var AppHelpTemplate = `NAME:
+   {{.Name}}{{if .Usage}} - {{.Usage}}{{end}}
+
+USAGE:
+   {{if .UsageText}}{{.UsageText}}{{else}}{{.HelpName}} {{if .VisibleFlags}}[global options]{{end}}{{if .Commands}} command [command options]{{end}} {{if .ArgsUsage}}{{.ArgsUsage}}{{else}}[arguments...]{{end}}{{end}}{{if .Version}}{{if not .HideVersion}}
+
+VERSION:
+   {{.Version}}{{end}}{{end}}{{if .Description}}
+
+DESCRIPTION:
+   {{.Description}}{{end}}{{if len .Authors}}
+
+AUTHOR{{with $length := len .Authors}}{{if ne 1 $length}}S{{end}}{{end}}:
+   {{range $index, $author := .Authors}}{{if $index}}
+   {{end}}{{$author}}{{end}}{{end}}{{if .VisibleCommands}}
+
+COMMANDS:{{range .VisibleCategories}}{{if .Name}}
+
+   {{.Name}}:{{range .VisibleCommands}}
+     {{join .Names ", "}}{{"\t"}}{{.Usage}}{{end}}{{else}}{{range .VisibleCommands}}
+   {{join .Names ", "}}{{"\t"}}{{.Usage}}{{end}}{{end}}{{end}}{{end}}{{if .VisibleFlags}}
+
+GLOBAL OPTIONS:
+   {{range $index, $option := .VisibleFlags}}{{if $index}}
+   {{end}}{{$option}}{{end}}{{end}}{{if .Copyright}}
+
+COPYRIGHT:
+   {{.Copyright}}{{end}}
+`
+
+// CommandHelpTemplate is the text template for the command help topic.
+// cli.go uses text/template to render templates. You can
+// render custom help text by setting this variable.
+var CommandHelpTemplate = `NAME:
+   {{.HelpName}} - {{.Usage}}
+
+USAGE:
+   {{if .UsageText}}{{.UsageText}}{{else}}{{.HelpName}}{{if .Subcommands}} command{{end}}{{if .VisibleFlags}} [command options]{{end}} {{if .ArgsUsage}}{{.ArgsUsage}}{{else}}[arguments...]{{end}}{{end}}{{if .Category}}
+
+CATEGORY:
+   {{.Category}}{{end}}{{if .Description}}
+
+DESCRIPTION:
+   {{.Description}}{{end}}{{if .Subcommands}}
+
+COMMANDS:{{range .Subcommands}}{{if not .Hidden}}
+   {{join .Names ", "}}{{"\t"}}{{.Usage}}{{end}}{{end}}{{end}}{{if .VisibleFlags}}
+
+OPTIONS:
+   {{range .VisibleFlags}}{{.}}
+   {{end}}{{end}}
+`
+
+// SubcommandHelpTemplate is the text template for the subcommand help topic.
+// cli.go uses text/template to render templates. You can
+// render custom help text by setting this variable.
+var SubcommandHelpTemplate = `NAME:
+   {{.HelpName}} - {{if .Description}}{{.Description}}{{else}}{{.Usage}}{{end}}
+
+USAGE:
+   {{if .UsageText}}{{.UsageText}}{{else}}{{.HelpName}} command{{if .VisibleFlags}} [command options]{{end}} {{if .ArgsUsage}}{{.ArgsUsage}}{{else}}[arguments...]{{end}}{{end}}
+
+COMMANDS:{{range .VisibleCategories}}{{if .Name}}
+   {{.Name}}:{{range .VisibleCommands}}
+     {{join .Names ", "}}{{"\t"}}{{.Usage}}{{end}}{{else}}{{range .VisibleCommands}}
+   {{join .Names ", "}}{{"\t"}}{{.Usage}}{{end}}{{end}}{{end}}{{if .VisibleFlags}}
+
+OPTIONS:
+   {{range .VisibleFlags}}{{.}}
+   {{end}}{{end}}
+`
+
 var helpCommand = Command{
 	Name:      "help",
 	Aliases:   []string{"h"},
 	Usage:     "Shows a list of commands or help for one command",
-	ArgsUsage: "[command]",
+	ArgsUsage: "[command [subcommand...]]",
 	Action: func(c *Context) error {
 		args := c.Args()
 		if args.Present() {
-			return nil
+			return showNestedCommandHelp(c, args)
 		}
 
+		_ = ShowAppHelp(c)
 		return nil
 	},
 }
@@ -31,11 +110,211 @@ var helpSubcommand = Command{
 	Action: func(c *Context) error {
 		args := c.Args()
 		if args.Present() {
+			return ShowCommandHelp(c, args.First())
+		}
+
+		return ShowSubcommandHelp(c)
+	},
+}
+
+// Prints help for the App or Command
+type helpPrinter func(w io.Writer, templ string, data interface{})
+
+// Prints help for the App or Command with custom template function.
+type helpPrinterCustom func(w io.Writer, templ string, data interface{}, customFunc map[string]interface{})
+
+// HelpPrinter is a function that writes the help output. If not set explicitly,
+// this calls HelpPrinterCustom using only the default template functions.
+// Apps with HelpFuncs or ExtraInfo are printed by HelpPrinterCustom instead.
+var HelpPrinter helpPrinter = printHelp
+
+// HelpPrinterCustom is a function that writes the help output with template
+// functions added to the default ones. If not set explicitly, a default is
+// used.
+var HelpPrinterCustom helpPrinterCustom = printHelpCustom
+
+// HelpFuncs are the default functions of the help templates
+var HelpFuncs = map[string]interface{}{
+	"join":    strings.Join,
+	"envVars": envVars,
+}
+
+// VersionPrinter prints the version for the App
+var VersionPrinter = printVersion
+
+// ShowAppHelpAndExit - Prints the list of subcommands for the app and exits with exit code.
+func ShowAppHelpAndExit(c *Context, exitCode int) {
+	_ = ShowAppHelp(c)
+	os.Exit(exitCode)
+}
+
+// ShowAppHelp is an action that displays the help.
+func ShowAppHelp(c *Context) error {
+	template := c.App.CustomAppHelpTemplate
+	if template == "" {
+		template = AppHelpTemplate
+	}
+
+	c.App.printHelp(template, c.App)
+	return nil
+}
+
+// ShowCommandHelpAndExit - exits with code after showing help
+func ShowCommandHelpAndExit(c *Context, command string, code int) {
+	_ = ShowCommandHelp(c, command)
+	os.Exit(code)
+}
+
+// ShowCommandHelp prints help for the given command
+func ShowCommandHelp(ctx *Context, command string) error {
+	// show the subcommand help for a command with subcommands
+	if command == "" {
+		ctx.App.printHelp(SubcommandHelpTemplate, ctx.App)
+		return nil
+	}
+
+	for _, c := range ctx.App.Commands {
+		if c.hasName(command) {
+			printCommandHelp(ctx.App, c)
 			return nil
 		}
+	}
 
+	return commandNotFound(ctx, command)
+}
+
+// ShowSubcommandHelp prints help for the given subcommand
+func ShowSubcommandHelp(c *Context) error {
+	if c == nil {
 		return nil
-	},
+	}
+
+	if c.Command.Name != "" {
+		return ShowCommandHelp(c, c.Command.Name)
+	}
+
+	return ShowCommandHelp(c, "")
+}
+
+// ShowVersion prints the version number of the App
+func ShowVersion(c *Context) {
+	VersionPrinter(c)
+}
+
+func printVersion(c *Context) {
+	_, _ = fmt.Fprintf(c.App.Writer, "%v version %v\n", c.App.Name, c.App.Version)
+}
+
+// showNestedCommandHelp prints help for a command given by its path, e.g.
+// "control status" for the status subcommand of control
+func showNestedCommandHelp(ctx *Context, path []string) error {
+	command := ctx.App.command(path[0])
+	if command == nil {
+		return commandNotFound(ctx, path[0])
+	}
+
+	for _, name := range path[1:] {
+		var sub *Command
+		for _, c := range command.Subcommands {
+			if c.hasName(name) {
+				c := c
+				sub = &c
+				break
+			}
+		}
+		if sub == nil {
+			return commandNotFound(ctx, strings.Join(path, " "))
+		}
+		if sub.HelpName == "" {
+			sub.HelpName = fmt.Sprintf("%s %s", command.HelpName, sub.Name)
+		}
+		command = sub
+	}
+
+	printCommandHelp(ctx.App, *command)
+	return nil
+}
+
+func printCommandHelp(a *App, c Command) {
+	templ := c.CustomHelpTemplate
+	if templ == "" {
+		templ = CommandHelpTemplate
+	}
+	a.printHelp(templ, c)
+}
+
+// printHelp writes the help of data, the app or one of its commands, to the
+// HelpWriter of the app, with its template functions if it has any
+func (a *App) printHelp(templ string, data interface{}) {
+	w := a.HelpWriter
+	if w == nil {
+		w = a.Writer
+	}
+	if a.ExtraInfo == nil && len(a.HelpFuncs) == 0 {
+		HelpPrinter(w, templ, data)
+		return
+	}
+
+	funcs := make(map[string]interface{}, len(a.HelpFuncs)+1)
+	if a.ExtraInfo != nil {
+		funcs["ExtraInfo"] = a.ExtraInfo
+	}
+	for name, fn := range a.HelpFuncs {
+		funcs[name] = fn
+	}
+	HelpPrinterCustom(w, templ, data, funcs)
+}
+
+// envVars returns the environment variables setting flag, for the help
+// templates
+func envVars(flag Flag) []string {
+	var names []string
+	if v := flagValue(flag).FieldByName("EnvVar"); v.IsValid() && v.Kind() == reflect.String {
+		for _, name := range strings.Split(v.String(), ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func commandNotFound(ctx *Context, command string) error {
+	if ctx.App.CommandNotFound != nil {
+		ctx.App.CommandNotFound(ctx, command)
+		return nil
+	}
+
+	err := fmt.Errorf("No help topic for '%v'", command)
+	_, _ = fmt.Fprintln(ctx.App.Writer, err)
+	return err
+}
+
+func printHelpCustom(out io.Writer, templ string, data interface{}, customFuncs map[string]interface{}) {
+	funcMap := template.FuncMap{}
+	for key, value := range HelpFuncs {
+		funcMap[key] = value
+	}
+	for key, value := range customFuncs {
+		funcMap[key] = value
+	}
+
+	w := tabwriter.NewWriter(out, 1, 8, 2, ' ', 0)
+	t := template.Must(template.New("help").Funcs(funcMap).Parse(templ))
+	err := t.Execute(w, data)
+	if err != nil {
+		// If the writer is closed, t.Execute will fail, and there's nothing
+		// we can do to recover.
+		if os.Getenv("CLI_TEMPLATE_ERROR_DEBUG") != "" {
+			_, _ = fmt.Fprintf(os.Stderr, "CLI TEMPLATE ERROR: %#v\n", err)
+		}
+		return
+	}
+	_ = w.Flush()
+}
+
+func printHelp(out io.Writer, templ string, data interface{}) {
+	printHelpCustom(out, templ, data, nil)
 }
 
 // defaultAppComplete prints the list of subcommands as the default app completion method
@@ -146,8 +425,40 @@ func showCommandCompletions(ctx *Context, command string) {
 
 }
 
+func checkVersion(c *Context) bool {
+	found := false
+	for _, name := range strings.Split(VersionFlag.GetName(), ",") {
+		name = strings.TrimSpace(name)
+		if c.Bool(name) {
+			found = true
+		}
+	}
+	return found
+}
+
+func checkHelp(c *Context) bool {
+	found := false
+	for _, name := range strings.Split(HelpFlag.GetName(), ",") {
+		name = strings.TrimSpace(name)
+		if c.GlobalBool(name) {
+			found = true
+		}
+	}
+	return found
+}
+
+func checkCommandHelp(c *Context, name string) bool {
+	if c.Bool("h") || c.Bool("help") {
+		_ = ShowCommandHelp(c, name)
+		return true
+	}
+
+	return false
+}
+
 func checkSubcommandHelp(c *Context) bool {
 	if c.Bool("h") || c.Bool("help") {
+		_ = ShowSubcommandHelp(c)
 		return true
 	}
 
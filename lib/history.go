@@ -0,0 +1,163 @@
+package gin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rebuild is a recorded rebuild with the changes that triggered it
+type Rebuild struct {
+	ID    int       `json:"id"`
+	Time  time.Time `json:"time"`
+	Files []string  `json:"files"`
+	Diff  string    `json:"diff"`
+}
+
+// History records the diff of the files that triggered each rebuild in the
+// history directory below StateDir
+type History struct {
+	dir       string
+	mu        sync.Mutex
+	next      int
+	snapshots map[string]string
+}
+
+// NewHistory opens the rebuild history of the project in wd
+func NewHistory(wd string) (*History, error) {
+	h := &History{
+		dir:       filepath.Join(wd, StateDir, "history"),
+		snapshots: make(map[string]string),
+	}
+	if err := os.MkdirAll(h.dir, 0755); err != nil {
+		return nil, err
+	}
+
+	ids, err := h.ids()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) > 0 {
+		h.next = ids[len(ids)-1]
+	}
+	h.next++
+
+	return h, nil
+}
+
+// Record stores the changes made to files since they were last recorded.
+// Files seen for the first time are compared against git, if available.
+func (h *History) Record(files []string) (*Rebuild, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rebuild := &Rebuild{ID: h.next, Time: time.Now(), Files: files}
+
+	var diffs []string
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			continue
+		}
+		current := string(data)
+
+		previous, ok := h.snapshots[file]
+		var diff string
+		if ok {
+			diff = unifiedDiff(filepath.ToSlash(file), splitLines(previous), splitLines(current))
+		} else {
+			diff = gitDiff(file)
+		}
+		h.snapshots[file] = current
+
+		if diff != "" {
+			diffs = append(diffs, diff)
+		}
+	}
+	rebuild.Diff = strings.Join(diffs, "")
+
+	data, err := json.MarshalIndent(rebuild, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(h.path(rebuild.ID), data, 0644); err != nil {
+		return nil, err
+	}
+
+	h.next++
+	return rebuild, nil
+}
+
+// List returns the recorded rebuilds, oldest first
+func (h *History) List() ([]*Rebuild, error) {
+	ids, err := h.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	var rebuilds []*Rebuild
+	for _, id := range ids {
+		rebuild, err := h.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		rebuilds = append(rebuilds, rebuild)
+	}
+	return rebuilds, nil
+}
+
+// Get returns the rebuild with the given id
+func (h *History) Get(id int) (*Rebuild, error) {
+	data, err := ioutil.ReadFile(h.path(id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no rebuild #%d in history", id)
+	} else if err != nil {
+		return nil, err
+	}
+
+	rebuild := &Rebuild{}
+	if err := json.Unmarshal(data, rebuild); err != nil {
+		return nil, err
+	}
+	return rebuild, nil
+}
+
+func (h *History) path(id int) string {
+	return filepath.Join(h.dir, strconv.Itoa(id)+".json")
+}
+
+func (h *History) ids() ([]int, error) {
+	entries, err := ioutil.ReadDir(h.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	for _, entry := range entries {
+		id, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".json"))
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// gitDiff returns the uncommitted changes of file, if it is tracked by git
+func gitDiff(file string) string {
+	command := exec.Command("git", "diff", "--no-color", "HEAD", "--", filepath.Base(file))
+	command.Dir = filepath.Dir(file)
+	output, err := command.Output()
+	if err != nil {
+		return ""
+	}
+	return string(output)
+}
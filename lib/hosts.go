@@ -0,0 +1,94 @@
+package gin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	hostsBegin = "# begin gin"
+	hostsEnd   = "# end gin"
+)
+
+// HostsFile returns the path of the hosts file of the system
+func HostsFile() string {
+	if runtime.GOOS == "windows" {
+		root := os.Getenv("SystemRoot")
+		if root == "" {
+			root = `C:\Windows`
+		}
+		return filepath.Join(root, "System32", "drivers", "etc", "hosts")
+	}
+	return "/etc/hosts"
+}
+
+// HostsEntries returns the hosts file lines resolving names to the loopback
+// addresses. Names under .localhost are left out, since they resolve to the
+// loopback address without an entry.
+func HostsEntries(names []string) []string {
+	var lines []string
+	for _, name := range names {
+		if name == "localhost" || strings.HasSuffix(name, ".localhost") {
+			continue
+		}
+		lines = append(lines, "127.0.0.1 "+name, "::1 "+name)
+	}
+	return lines
+}
+
+// InstallHosts writes the entries for names to the hosts file at path,
+// replacing the ones installed before, in a block marked as gin's
+func InstallHosts(path string, names []string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	newline := "\n"
+	if strings.Contains(string(data), "\r\n") {
+		newline = "\r\n"
+	}
+
+	var kept []string
+	inBlock := false
+	for _, line := range strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n") {
+		switch strings.TrimSpace(line) {
+		case hostsBegin:
+			inBlock = true
+			continue
+		case hostsEnd:
+			inBlock = false
+			continue
+		}
+		if !inBlock {
+			kept = append(kept, line)
+		}
+	}
+	for len(kept) > 0 && strings.TrimSpace(kept[len(kept)-1]) == "" {
+		kept = kept[:len(kept)-1]
+	}
+
+	if entries := HostsEntries(names); len(entries) > 0 {
+		kept = append(kept, "", hostsBegin)
+		kept = append(kept, entries...)
+		kept = append(kept, hostsEnd)
+	}
+
+	info, err := os.Stat(path)
+	mode := os.FileMode(0644)
+	if err == nil {
+		mode = info.Mode().Perm()
+	}
+	content := strings.Join(kept, newline) + newline
+	if err := ioutil.WriteFile(path, []byte(content), mode); err != nil {
+		if os.IsPermission(err) {
+			return fmt.Errorf("%v, run gin hosts --install as root or administrator", err)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package gin
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AddressInterval is how often the addresses of this machine are checked,
+// e.g. to announce them again after switching networks
+var AddressInterval = 5 * time.Second
+
+// LANURLs returns the URLs of urls which other devices on the local network
+// can open. Unspecified addresses like 0.0.0.0 or an empty host are
+// replaced with the address of this machine on the network, loopback ones
+// are left out.
+func LANURLs(urls []string) []string {
+	var result []string
+	seen := map[string]bool{}
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		host := u.Hostname()
+		ip := net.ParseIP(host)
+		switch {
+		case host == "localhost" || strings.HasSuffix(host, ".localhost"):
+			continue
+		case host == "" || ip != nil && ip.IsUnspecified():
+			lan := outboundIP()
+			if lan == nil {
+				continue
+			}
+			u.Host = net.JoinHostPort(lan.String(), u.Port())
+		case ip != nil && ip.IsLoopback():
+			continue
+		}
+		if !seen[u.String()] {
+			seen[u.String()] = true
+			result = append(result, u.String())
+		}
+	}
+	return result
+}
+
+// ReachableURLs returns the URLs which reach the proxy listening at urls.
+// Unspecified addresses like 0.0.0.0 are replaced with localhost and the
+// addresses of this machine on its networks, IPv6 ones too for :: or an
+// empty host, which listen on IPv4 and IPv6.
+func ReachableURLs(urls []string) []string {
+	var result []string
+	seen := map[string]bool{}
+	add := func(u string) {
+		if !seen[u] {
+			seen[u] = true
+			result = append(result, u)
+		}
+	}
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		host := u.Hostname()
+		ip := net.ParseIP(host)
+		if host != "" && (ip == nil || !ip.IsUnspecified()) {
+			add(raw)
+			continue
+		}
+
+		ips := localIPv4s()
+		if ip == nil || ip.To4() == nil {
+			ips = append(ips, localIPv6s()...)
+		}
+		hosts := []string{"localhost"}
+		for _, ip := range ips {
+			hosts = append(hosts, ip.String())
+		}
+		for _, h := range hosts {
+			u.Host = net.JoinHostPort(h, u.Port())
+			add(u.String())
+		}
+	}
+	return result
+}
+
+// outboundIP returns the address of the interface routing to other hosts,
+// which is usually the one on the local network, or the first of
+// localIPv4s if there is no route
+func outboundIP() net.IP {
+	// connecting a UDP socket doesn't send anything
+	conn, err := net.Dial("udp4", "192.0.2.1:9")
+	if err == nil {
+		defer conn.Close()
+		if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok && !addr.IP.IsLoopback() {
+			return addr.IP
+		}
+	}
+	if ips := localIPv4s(); len(ips) > 0 {
+		return ips[0]
+	}
+	return nil
+}
+
+// localIPv4s returns the IPv4 addresses of the interfaces which are up,
+// except loopback ones
+func localIPv4s() []net.IP {
+	return interfaceIPs(func(ip net.IP) bool {
+		return ip.To4() != nil
+	})
+}
+
+// localIPv6s returns the IPv6 addresses of the interfaces which are up,
+// except loopback and link-local ones, which need a zone to be reached
+func localIPv6s() []net.IP {
+	return interfaceIPs(func(ip net.IP) bool {
+		return ip.To4() == nil && ip.IsGlobalUnicast()
+	})
+}
+
+// interfaceIPs returns the addresses of the interfaces which are up, except
+// loopback ones, for which keep returns true
+func interfaceIPs(keep func(net.IP) bool) []net.IP {
+	var ips []net.IP
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && keep(ipnet.IP) {
+				if ip4 := ipnet.IP.To4(); ip4 != nil {
+					ips = append(ips, ip4)
+				} else {
+					ips = append(ips, ipnet.IP)
+				}
+			}
+		}
+	}
+	return ips
+}
+
+func sameIPs(a, b []net.IP) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func joinIPs(ips []net.IP) string {
+	var s []string
+	for _, ip := range ips {
+		s = append(s, ip.String())
+	}
+	return strings.Join(s, ", ")
+}
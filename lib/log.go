@@ -0,0 +1,84 @@
+package gin
+
+import (
+	"log"
+	"strings"
+	"sync"
+)
+
+// LogLevel controls how much gin reports about what it is doing
+type LogLevel int
+
+const (
+	// LogQuiet only reports errors and the build status
+	LogQuiet LogLevel = iota
+	// LogInfo is the default level
+	LogInfo
+	// LogVerbose adds restarts, durations and process lifecycle details
+	LogVerbose
+	// LogTrace adds watcher events, executed command lines and proxy decisions
+	LogTrace
+)
+
+var (
+	logLevelMu sync.Mutex
+	logLevel   = LogInfo
+	logger     = log.Default()
+)
+
+// SetLogger makes Infof, Verbosef and Tracef write to l instead of the
+// standard logger, e.g. to share the prefix of the program embedding gin
+func SetLogger(l *log.Logger) {
+	logLevelMu.Lock()
+	defer logLevelMu.Unlock()
+	logger = l
+}
+
+// currentLogger returns the logger set with SetLogger
+func currentLogger() *log.Logger {
+	logLevelMu.Lock()
+	defer logLevelMu.Unlock()
+	return logger
+}
+
+// SetLogLevel sets the level of the messages gin logs
+func SetLogLevel(level LogLevel) {
+	logLevelMu.Lock()
+	defer logLevelMu.Unlock()
+	logLevel = level
+}
+
+// Logging reports whether messages of the given level are logged
+func Logging(level LogLevel) bool {
+	logLevelMu.Lock()
+	defer logLevelMu.Unlock()
+	return logLevel >= level
+}
+
+// Infof logs a message unless gin is quiet
+func Infof(format string, v ...interface{}) {
+	if Logging(LogInfo) {
+		currentLogger().Printf(format, v...)
+	}
+}
+
+// Verbosef logs a message if gin is verbose
+func Verbosef(format string, v ...interface{}) {
+	if Logging(LogVerbose) {
+		currentLogger().Printf(format, v...)
+	}
+}
+
+// Tracef logs a message if gin traces
+func Tracef(format string, v ...interface{}) {
+	if Logging(LogTrace) {
+		currentLogger().Printf(format, v...)
+	}
+}
+
+// traceCommand logs the command line of a command about to be executed
+func traceCommand(name string, args ...string) {
+	if Logging(LogTrace) {
+		currentLogger().Printf("exec %s", strings.Join(append([]string{name}, args...), " "))
+	}
+}
@@ -0,0 +1,353 @@
+package gin
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mDNS record types and classes, see RFC 1035 and RFC 6762
+const (
+	dnsTypeA    = 1
+	dnsTypePTR  = 12
+	dnsTypeTXT  = 16
+	dnsTypeAAAA = 28
+	dnsTypeSRV  = 33
+	dnsTypeANY  = 255
+
+	dnsClassIN         = 1
+	dnsClassCacheFlush = 0x8000
+
+	mdnsTTL     = 120
+	mdnsService = "_http._tcp.local."
+)
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// MDNS answers multicast DNS queries for name.local with the addresses of
+// this machine and advertises an HTTP service on port, so devices on the
+// local network can reach the proxy by name
+type MDNS struct {
+	name string
+	port int
+	conn *net.UDPConn
+
+	mu    sync.Mutex
+	addrs []net.IP
+	done  chan struct{}
+}
+
+// AdvertiseMDNS starts answering queries for name, e.g. myapp or myapp.local
+func AdvertiseMDNS(name string, port int) (*MDNS, error) {
+	name = strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(name), "."), ".local")
+	if name == "" || len(name) > 63 || strings.ContainsAny(name, ". ") {
+		return nil, fmt.Errorf("invalid mDNS name %q, expected a single label like myapp", name)
+	}
+
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return nil, fmt.Errorf("can't join the mDNS group: %v", err)
+	}
+
+	m := &MDNS{name: name, port: port, conn: conn, addrs: localIPv4s(), done: make(chan struct{})}
+	go m.serve()
+	go m.watch()
+	m.announce(mdnsTTL)
+	return m, nil
+}
+
+// Hostname returns the advertised host name, e.g. myapp.local
+func (m *MDNS) Hostname() string {
+	return m.name + ".local"
+}
+
+// Addrs returns the addresses currently announced
+func (m *MDNS) Addrs() []net.IP {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.addrs
+}
+
+// Close withdraws the records and stops answering queries
+func (m *MDNS) Close() error {
+	select {
+	case <-m.done:
+		return nil
+	default:
+	}
+	close(m.done)
+	m.announce(0)
+	return m.conn.Close()
+}
+
+func (m *MDNS) host() string {
+	return m.name + ".local."
+}
+
+func (m *MDNS) instance() string {
+	return m.name + "." + mdnsService
+}
+
+// watch announces the addresses again when they change, e.g. after joining
+// another network
+func (m *MDNS) watch() {
+	ticker := time.NewTicker(AddressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		}
+
+		addrs := localIPv4s()
+		m.mu.Lock()
+		changed := !sameIPs(addrs, m.addrs)
+		m.addrs = addrs
+		m.mu.Unlock()
+		if changed {
+			Verbosef("Announcing %s at %s", m.Hostname(), joinIPs(addrs))
+			m.announce(mdnsTTL)
+		}
+	}
+}
+
+// announce sends all records unsolicited, a ttl of 0 withdraws them
+func (m *MDNS) announce(ttl uint32) {
+	msg := newDNSMessage(0)
+	for _, r := range m.records(dnsTypeANY, m.host(), ttl) {
+		msg.answer(r)
+	}
+	for _, r := range m.records(dnsTypePTR, mdnsService, ttl) {
+		msg.answer(r)
+	}
+	m.conn.WriteToUDP(msg.bytes(), mdnsGroup)
+}
+
+func (m *MDNS) serve() {
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := m.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-m.done:
+				return
+			default:
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		id, questions, ok := parseDNSQuery(buf[:n])
+		if !ok {
+			continue
+		}
+
+		// legacy resolvers query from other ports and expect a unicast
+		// response echoing the id and question
+		legacy := from.Port != mdnsGroup.Port
+		msg := newDNSMessage(0)
+		if legacy {
+			msg = newDNSMessage(id)
+		}
+		for _, q := range questions {
+			records := m.records(q.qtype, q.name, mdnsTTL)
+			if len(records) == 0 {
+				continue
+			}
+			if legacy {
+				msg.question(q)
+			}
+			for _, r := range records {
+				r.flush = r.flush && !legacy
+				msg.answer(r)
+			}
+		}
+		if len(msg.answers) == 0 {
+			continue
+		}
+
+		Tracef("Answering the mDNS query of %s", from)
+		if legacy {
+			m.conn.WriteToUDP(msg.bytes(), from)
+		} else {
+			m.conn.WriteToUDP(msg.bytes(), mdnsGroup)
+		}
+	}
+}
+
+// records returns the records answering a question for name
+func (m *MDNS) records(qtype uint16, name string, ttl uint32) []dnsRecord {
+	name = strings.ToLower(name)
+	var records []dnsRecord
+
+	switch name {
+	case m.host():
+		if qtype == dnsTypeA || qtype == dnsTypeANY {
+			for _, ip := range m.Addrs() {
+				records = append(records, dnsRecord{name: m.host(), rtype: dnsTypeA, flush: true, ttl: ttl, data: ip.To4()})
+			}
+		}
+	case mdnsService:
+		if qtype == dnsTypePTR || qtype == dnsTypeANY {
+			records = append(records, dnsRecord{name: mdnsService, rtype: dnsTypePTR, ttl: ttl, data: encodeDNSName(m.instance())})
+			records = append(records, m.records(dnsTypeANY, m.instance(), ttl)...)
+		}
+	case m.instance():
+		if qtype == dnsTypeSRV || qtype == dnsTypeANY {
+			srv := make([]byte, 6)
+			binary.BigEndian.PutUint16(srv[4:], uint16(m.port))
+			records = append(records, dnsRecord{name: m.instance(), rtype: dnsTypeSRV, flush: true, ttl: ttl, data: append(srv, encodeDNSName(m.host())...)})
+		}
+		if qtype == dnsTypeTXT || qtype == dnsTypeANY {
+			txt := "path=/"
+			records = append(records, dnsRecord{name: m.instance(), rtype: dnsTypeTXT, flush: true, ttl: ttl, data: append([]byte{byte(len(txt))}, txt...)})
+		}
+		if qtype == dnsTypeANY || qtype == dnsTypeSRV {
+			records = append(records, m.records(dnsTypeA, m.host(), ttl)...)
+		}
+	}
+	return records
+}
+
+type dnsQuestion struct {
+	name  string
+	qtype uint16
+	class uint16
+}
+
+type dnsRecord struct {
+	name  string
+	rtype uint16
+	flush bool
+	ttl   uint32
+	data  []byte
+}
+
+type dnsMessage struct {
+	id        uint16
+	questions []dnsQuestion
+	answers   []dnsRecord
+}
+
+func newDNSMessage(id uint16) *dnsMessage {
+	return &dnsMessage{id: id}
+}
+
+func (m *dnsMessage) question(q dnsQuestion) {
+	m.questions = append(m.questions, q)
+}
+
+func (m *dnsMessage) answer(r dnsRecord) {
+	m.answers = append(m.answers, r)
+}
+
+// bytes encodes the message as an authoritative response
+func (m *dnsMessage) bytes() []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint16(b[0:], m.id)
+	binary.BigEndian.PutUint16(b[2:], 0x8400)
+	binary.BigEndian.PutUint16(b[4:], uint16(len(m.questions)))
+	binary.BigEndian.PutUint16(b[6:], uint16(len(m.answers)))
+
+	for _, q := range m.questions {
+		b = append(b, encodeDNSName(q.name)...)
+		b = appendUint16(b, q.qtype)
+		b = appendUint16(b, dnsClassIN)
+	}
+	for _, r := range m.answers {
+		class := uint16(dnsClassIN)
+		if r.flush {
+			class |= dnsClassCacheFlush
+		}
+		b = append(b, encodeDNSName(r.name)...)
+		b = appendUint16(b, r.rtype)
+		b = appendUint16(b, class)
+		b = append(b, byte(r.ttl>>24), byte(r.ttl>>16), byte(r.ttl>>8), byte(r.ttl))
+		b = appendUint16(b, uint16(len(r.data)))
+		b = append(b, r.data...)
+	}
+	return b
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// encodeDNSName encodes a fully qualified name like myapp.local.
+func encodeDNSName(name string) []byte {
+	var b []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+// parseDNSQuery returns the id and questions of a query, ok is false for
+// responses and malformed messages
+func parseDNSQuery(msg []byte) (id uint16, questions []dnsQuestion, ok bool) {
+	if len(msg) < 12 || msg[2]&0x80 != 0 {
+		return 0, nil, false
+	}
+	id = binary.BigEndian.Uint16(msg[0:])
+	count := int(binary.BigEndian.Uint16(msg[4:]))
+
+	off := 12
+	for i := 0; i < count; i++ {
+		name, next, err := readDNSName(msg, off)
+		if err != nil || next+4 > len(msg) {
+			return 0, nil, false
+		}
+		questions = append(questions, dnsQuestion{
+			name:  name,
+			qtype: binary.BigEndian.Uint16(msg[next:]),
+			// the top bit asks for a unicast response
+			class: binary.BigEndian.Uint16(msg[next+2:]) &^ dnsClassCacheFlush,
+		})
+		off = next + 4
+	}
+	return id, questions, true
+}
+
+// readDNSName reads the possibly compressed name at off and returns it with
+// the offset following it
+func readDNSName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errors.New("name out of bounds")
+		}
+		length := int(msg[off])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case length&0xc0 == 0xc0:
+			if off+1 >= len(msg) || jumps > 10 {
+				return "", 0, errors.New("invalid compression pointer")
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			jumps++
+		default:
+			if off+1+length > len(msg) {
+				return "", 0, errors.New("label out of bounds")
+			}
+			labels = append(labels, string(msg[off+1:off+1+length]))
+			off += 1 + length
+		}
+	}
+}
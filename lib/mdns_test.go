@@ -0,0 +1,81 @@
+package gin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+func TestParseDNSQuery(t *testing.T) {
+	msg := newDNSMessage(0x1234)
+	msg.question(dnsQuestion{name: "myapp.local.", qtype: dnsTypeA})
+	query := msg.bytes()
+	// clear the response bit set by bytes
+	binary.BigEndian.PutUint16(query[2:], 0)
+	// a second question for _http._tcp.local. pointing into the first
+	// one's name for local.
+	binary.BigEndian.PutUint16(query[4:], 2)
+	query = append(query, 5, '_', 'h', 't', 't', 'p', 4, '_', 't', 'c', 'p', 0xc0, 12+6)
+	query = appendUint16(query, dnsTypePTR)
+	query = appendUint16(query, dnsClassIN|dnsClassCacheFlush)
+
+	id, questions, ok := parseDNSQuery(query)
+	if !ok || id != 0x1234 || len(questions) != 2 {
+		t.Fatalf("parseDNSQuery = %#x, %v, %v", id, questions, ok)
+	}
+	if q := questions[0]; q.name != "myapp.local." || q.qtype != dnsTypeA {
+		t.Errorf("first question %+v", q)
+	}
+	if q := questions[1]; q.name != mdnsService || q.qtype != dnsTypePTR || q.class != dnsClassIN {
+		t.Errorf("second question %+v", q)
+	}
+
+	if _, _, ok := parseDNSQuery(msg.bytes()); ok {
+		t.Error("parseDNSQuery accepted a response")
+	}
+	if _, _, ok := parseDNSQuery(query[:len(query)-3]); ok {
+		t.Error("parseDNSQuery accepted a truncated query")
+	}
+	loop := append(append([]byte{}, query[:12]...), 0xc0, 12)
+	if _, _, ok := parseDNSQuery(loop); ok {
+		t.Error("parseDNSQuery accepted a compression loop")
+	}
+}
+
+func TestMDNSRecords(t *testing.T) {
+	m := &MDNS{name: "myapp", port: 3000, addrs: []net.IP{net.IPv4(192, 168, 1, 5)}}
+
+	a := m.records(dnsTypeA, "MyApp.local.", mdnsTTL)
+	if len(a) != 1 || a[0].rtype != dnsTypeA || !bytes.Equal(a[0].data, []byte{192, 168, 1, 5}) {
+		t.Errorf("A records %+v", a)
+	}
+	if records := m.records(dnsTypeAAAA, "myapp.local.", mdnsTTL); len(records) != 0 {
+		t.Errorf("AAAA records %+v, want none", records)
+	}
+	if records := m.records(dnsTypeA, "other.local.", mdnsTTL); len(records) != 0 {
+		t.Errorf("records for another name %+v", records)
+	}
+
+	// browsing the service finds the instance, its port and address
+	types := map[uint16]bool{}
+	for _, r := range m.records(dnsTypePTR, mdnsService, mdnsTTL) {
+		types[r.rtype] = true
+		if r.rtype == dnsTypeSRV && binary.BigEndian.Uint16(r.data[4:]) != 3000 {
+			t.Errorf("SRV port %d, want 3000", binary.BigEndian.Uint16(r.data[4:]))
+		}
+	}
+	for _, rtype := range []uint16{dnsTypePTR, dnsTypeSRV, dnsTypeTXT, dnsTypeA} {
+		if !types[rtype] {
+			t.Errorf("browsing the service lacks a record of type %d", rtype)
+		}
+	}
+}
+
+func TestAdvertiseMDNSInvalidName(t *testing.T) {
+	for _, name := range []string{"", ".local", "my.app", "my app"} {
+		if _, err := AdvertiseMDNS(name, 3000); err == nil {
+			t.Errorf("AdvertiseMDNS accepted %q", name)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package gin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Middleware wraps the handler of the proxy, e.g. to log or modify requests
+type Middleware func(next http.Handler) http.Handler
+
+// RequestHook is implemented by hooks which inspect or modify requests before
+// they are proxied to the app. An error fails the request with status 502.
+type RequestHook interface {
+	HookRequest(req *http.Request) error
+}
+
+// ResponseHook is implemented by hooks which inspect or modify the responses
+// of the app before they are sent to the client. Responses gin writes itself,
+// e.g. build errors, and websocket connections don't pass through them. An
+// error fails the request with status 502.
+type ResponseHook interface {
+	HookResponse(res *http.Response) error
+}
+
+// HookMiddleware returns a Middleware calling hook, which implements
+// RequestHook, ResponseHook or both
+func HookMiddleware(hook interface{}) (Middleware, error) {
+	reqHook, isReqHook := hook.(RequestHook)
+	resHook, isResHook := hook.(ResponseHook)
+	if !isReqHook && !isResHook {
+		return nil, fmt.Errorf("%T implements neither RequestHook nor ResponseHook", hook)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if isReqHook {
+				if err := reqHook.HookRequest(req); err != nil {
+					log.Printf("http: request hook: %v", err)
+					http.Error(res, err.Error(), http.StatusBadGateway)
+					return
+				}
+			}
+			if isResHook {
+				hooks, _ := req.Context().Value(responseHooksKey{}).([]ResponseHook)
+				hooks = append(hooks[:len(hooks):len(hooks)], resHook)
+				req = req.WithContext(context.WithValue(req.Context(), responseHooksKey{}, hooks))
+			}
+			next.ServeHTTP(res, req)
+		})
+	}, nil
+}
+
+// responseHooksKey is the context key of the ResponseHooks of a request, in
+// the order of their middleware
+type responseHooksKey struct{}
+
+// runResponseHooks calls the ResponseHooks of the request of res, the last
+// added first, so hooks see responses in the opposite order of requests
+// like other middleware. The body is decompressed for BodyRewriters.
+func runResponseHooks(res *http.Response) error {
+	hooks, _ := res.Request.Context().Value(responseHooksKey{}).([]ResponseHook)
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if rewriter, ok := hooks[i].(BodyRewriter); ok && rewriter.RewritesBody() {
+			if err := DecompressResponse(res); err != nil {
+				return err
+			}
+			res.Header.Del("Content-Length")
+			res.ContentLength = -1
+		}
+		if err := hooks[i].HookResponse(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MiddlewareFactory creates a Middleware from the argument given after "="
+// in its spec, e.g. "X-Frame-Options: DENY" for "headers=X-Frame-Options: DENY"
+type MiddlewareFactory func(arg string) (Middleware, error)
+
+var (
+	middlewareMu        sync.Mutex
+	middlewareFactories = map[string]MiddlewareFactory{
+		"accesslog":      newAccessLog,
+		"allowCIDR":      newAllowCIDR,
+		"auth":           newAuth,
+		"compress":       newCompress,
+		"cors":           newCORS,
+		"faults":         newFaults,
+		"headers":        newHeaders,
+		"mocks":          newMocks,
+		"requestHeaders": newRequestHeaders,
+	}
+
+	// guardMiddleware are the names of the Middleware which guard gin's own
+	// endpoints too, see Proxy.Protect
+	guardMiddleware = map[string]bool{
+		"allowCIDR": true,
+		"auth":      true,
+	}
+)
+
+// RegisterMiddleware makes a Middleware available under the given name
+func RegisterMiddleware(name string, factory MiddlewareFactory) {
+	middlewareMu.Lock()
+	defer middlewareMu.Unlock()
+	middlewareFactories[name] = factory
+}
+
+// Middlewares returns the names of the registered Middleware
+func Middlewares() []string {
+	middlewareMu.Lock()
+	defer middlewareMu.Unlock()
+
+	var names []string
+	for name := range middlewareFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MiddlewareSpec describes a Middleware of the chain by the name it was
+// registered under and the argument passed to its factory. In the config
+// file it is an object like {"name": "headers", "arg": "Cache-Control:
+// no-store"} or a spec as given to --middleware.
+type MiddlewareSpec struct {
+	Name string `json:"name"`
+	Arg  string `json:"arg,omitempty"`
+}
+
+// ParseMiddlewareSpec parses spec, a registered name optionally followed by
+// "=" and an argument, e.g. "accesslog" or "headers=Cache-Control: no-store"
+func ParseMiddlewareSpec(spec string) MiddlewareSpec {
+	name, arg := spec, ""
+	if i := strings.Index(spec, "="); i >= 0 {
+		name, arg = spec[:i], spec[i+1:]
+	}
+	return MiddlewareSpec{Name: strings.TrimSpace(name), Arg: arg}
+}
+
+// UnmarshalJSON accepts both an object and a spec string
+func (s *MiddlewareSpec) UnmarshalJSON(data []byte) error {
+	var spec string
+	if err := json.Unmarshal(data, &spec); err == nil {
+		*s = ParseMiddlewareSpec(spec)
+		return nil
+	}
+	type plain MiddlewareSpec
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+// Middleware creates the Middleware s describes
+func (s MiddlewareSpec) Middleware() (Middleware, error) {
+	middlewareMu.Lock()
+	factory, ok := middlewareFactories[s.Name]
+	middlewareMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown middleware %q (available: %s)", s.Name, strings.Join(Middlewares(), ", "))
+	}
+
+	mw, err := factory(s.Arg)
+	if err != nil {
+		return nil, fmt.Errorf("middleware %s: %s", s.Name, err)
+	}
+	return mw, nil
+}
+
+// Guard reports whether the Middleware s describes guards every request to
+// the proxy, including the ones for gin's own endpoints, so it belongs in
+// Proxy.Protect rather than Proxy.Use
+func (s MiddlewareSpec) Guard() bool {
+	return guardMiddleware[s.Name]
+}
+
+// NewMiddleware creates the Middleware described by spec, see
+// ParseMiddlewareSpec
+func NewMiddleware(spec string) (Middleware, error) {
+	return ParseMiddlewareSpec(spec).Middleware()
+}
+
+// chain wraps handler in middleware, the first of which sees requests first
+func chain(handler http.Handler, middleware []Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// newAccessLog logs every proxied request with its status and duration
+func newAccessLog(arg string) (Middleware, error) {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: res, status: http.StatusOK}
+			next.ServeHTTP(rec, req)
+			log.Printf("%s %s %d %s", req.Method, req.URL.RequestURI(), rec.status, time.Since(start).Round(time.Microsecond))
+		})
+	}, nil
+}
+
+// parseHeader parses a header given as "Name: value"
+func parseHeader(arg string) (name, value string, err error) {
+	i := strings.Index(arg, ":")
+	if i <= 0 {
+		return "", "", fmt.Errorf("expected \"Name: value\", got %q", arg)
+	}
+	return http.CanonicalHeaderKey(strings.TrimSpace(arg[:i])), strings.TrimSpace(arg[i+1:]), nil
+}
+
+// newHeaders sets the response header given as "Name: value"
+func newHeaders(arg string) (Middleware, error) {
+	name, value, err := parseHeader(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			res.Header().Set(name, value)
+			next.ServeHTTP(res, req)
+		})
+	}, nil
+}
+
+// requestHeader sets a header of the requests proxied to the app, e.g. to
+// stub the header an authenticating proxy would add in production
+type requestHeader struct {
+	name, value string
+}
+
+func (h requestHeader) HookRequest(req *http.Request) error {
+	req.Header.Set(h.name, h.value)
+	return nil
+}
+
+// newRequestHeaders sets the request header given as "Name: value"
+func newRequestHeaders(arg string) (Middleware, error) {
+	name, value, err := parseHeader(arg)
+	if err != nil {
+		return nil, err
+	}
+	return HookMiddleware(requestHeader{name: name, value: value})
+}
@@ -0,0 +1,116 @@
+package gin
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// BasicAuth returns a Middleware requiring clients to log in with user and
+// password
+func BasicAuth(user, password string) Middleware {
+	wantUser, wantPassword := sha256.Sum256([]byte(user)), sha256.Sum256([]byte(password))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			u, p, ok := req.BasicAuth()
+			gotUser, gotPassword := sha256.Sum256([]byte(u)), sha256.Sum256([]byte(p))
+			userOK := subtle.ConstantTimeCompare(gotUser[:], wantUser[:]) == 1
+			passwordOK := subtle.ConstantTimeCompare(gotPassword[:], wantPassword[:]) == 1
+			if !ok || !userOK || !passwordOK {
+				Tracef("%s %s: rejecting %s, wrong credentials", req.Method, req.URL.RequestURI(), req.RemoteAddr)
+				res.Header().Set("WWW-Authenticate", `Basic realm="gin", charset="UTF-8"`)
+				http.Error(res, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(res, req)
+		})
+	}
+}
+
+// ParseCredentials parses credentials given as "user:password"
+func ParseCredentials(value string) (user, password string, err error) {
+	i := strings.Index(value, ":")
+	if i <= 0 {
+		return "", "", fmt.Errorf("expected user:password")
+	}
+	return value[:i], value[i+1:], nil
+}
+
+// AllowCIDRs returns a Middleware rejecting clients whose address is in
+// none of the networks, given in CIDR notation or as single addresses.
+// Loopback clients are no exception, since tunnels connect from there, so
+// list 127.0.0.1 or ::1 to let in browsers on this machine.
+func AllowCIDRs(cidrs []string) (Middleware, error) {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			host, _, err := net.SplitHostPort(req.RemoteAddr)
+			if err != nil {
+				host = req.RemoteAddr
+			}
+			if ip := net.ParseIP(host); ip != nil && containsIP(networks, ip) {
+				next.ServeHTTP(res, req)
+				return
+			}
+			Tracef("%s %s: rejecting %s, not in the allowed networks", req.Method, req.URL.RequestURI(), req.RemoteAddr)
+			http.Error(res, "Forbidden", http.StatusForbidden)
+		})
+	}, nil
+}
+
+// newAuth requires clients to log in with the credentials given as
+// "user:password"
+func newAuth(arg string) (Middleware, error) {
+	user, password, err := ParseCredentials(arg)
+	if err != nil {
+		return nil, err
+	}
+	return BasicAuth(user, password), nil
+}
+
+// newAllowCIDR only lets in clients from the comma separated networks
+func newAllowCIDR(arg string) (Middleware, error) {
+	var cidrs []string
+	for _, cidr := range strings.Split(arg, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr != "" {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	if len(cidrs) == 0 {
+		return nil, fmt.Errorf("expected networks like 192.168.1.0/24,127.0.0.1")
+	}
+	return AllowCIDRs(cidrs)
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowCIDRs(t *testing.T) {
+	mw, err := AllowCIDRs([]string{"192.168.1.0/24", "10.0.0.7", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := mw(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+
+	tests := []struct {
+		remoteAddr string
+		want       int
+	}{
+		{"192.168.1.20:51000", http.StatusOK},
+		{"192.168.2.20:51000", http.StatusForbidden},
+		{"10.0.0.7:51000", http.StatusOK},
+		{"10.0.0.8:51000", http.StatusForbidden},
+		{"[fd00::2]:51000", http.StatusOK},
+		// tunnels forward from loopback, which isn't allowed unless listed
+		{"127.0.0.1:51000", http.StatusForbidden},
+		{"[::1]:51000", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = test.remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != test.want {
+			t.Errorf("request from %s: status %d, want %d", test.remoteAddr, rec.Code, test.want)
+		}
+	}
+}
+
+func TestAllowCIDRsInvalid(t *testing.T) {
+	for _, cidr := range []string{"192.168.1.0/33", "localhost"} {
+		if _, err := AllowCIDRs([]string{cidr}); err == nil {
+			t.Errorf("AllowCIDRs accepted %q", cidr)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package gin
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressMinSize is the smallest response Compress compresses, if its size
+// is known up front
+const compressMinSize = 1024
+
+// BodyRewriter is implemented by ResponseHooks which read or rewrite the
+// body of responses. The body is decompressed before they are called and the
+// response is sent without a Content-Length, since it may change.
+type BodyRewriter interface {
+	RewritesBody() bool
+}
+
+// DecompressResponse replaces a gzip or deflate encoded body of res with the
+// decoded one and removes the headers describing the encoded body
+func DecompressResponse(res *http.Response) error {
+	encoding := strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding")))
+
+	var body io.ReadCloser
+	var err error
+	switch encoding {
+	case "", "identity":
+		return nil
+	case "gzip", "x-gzip":
+		body, err = gzip.NewReader(res.Body)
+	case "deflate":
+		body, err = zlib.NewReader(res.Body)
+	default:
+		return fmt.Errorf("can't decode the %s encoded response of %s", encoding, res.Request.URL.RequestURI())
+	}
+	if err != nil {
+		return fmt.Errorf("can't decode the %s encoded response of %s: %v", encoding, res.Request.URL.RequestURI(), err)
+	}
+
+	res.Body = decodedBody{Reader: body, decoder: body, encoded: res.Body}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+	return nil
+}
+
+// decodedBody closes both the decoder and the encoded body
+type decodedBody struct {
+	io.Reader
+	decoder io.Closer
+	encoded io.Closer
+}
+
+func (b decodedBody) Close() error {
+	b.decoder.Close()
+	return b.encoded.Close()
+}
+
+// decompressor decompresses every response of the app
+type decompressor struct{}
+
+func (decompressor) HookResponse(res *http.Response) error {
+	return nil
+}
+
+func (decompressor) RewritesBody() bool {
+	return true
+}
+
+// Decompress returns a Middleware decompressing the responses of the app,
+// e.g. so they are readable in recordings
+func Decompress() Middleware {
+	mw, _ := HookMiddleware(decompressor{})
+	return mw
+}
+
+// Compress returns a Middleware compressing text responses with gzip for
+// clients accepting it, unless they are encoded already
+func Compress(level int) (Middleware, error) {
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+		return nil, err
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if req.Method == http.MethodHead || !acceptsGzip(req) {
+				next.ServeHTTP(res, req)
+				return
+			}
+
+			w := &compressWriter{ResponseWriter: res, level: level}
+			defer w.Close()
+			next.ServeHTTP(w, req)
+		})
+	}, nil
+}
+
+// newCompress compresses with the gzip level given as argument, the default
+// level if empty
+func newCompress(arg string) (Middleware, error) {
+	level := gzip.DefaultCompression
+	if arg != "" {
+		var err error
+		if level, err = strconv.Atoi(arg); err != nil {
+			return nil, fmt.Errorf("expected a gzip level, got %q", arg)
+		}
+	}
+	return Compress(level)
+}
+
+func acceptsGzip(req *http.Request) bool {
+	for _, part := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(part, ";")
+		if strings.TrimSpace(fields[0]) != "gzip" {
+			continue
+		}
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				return err == nil && q > 0
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// compressible reports whether responses of the content type are worth
+// compressing. Streamed events are left alone, so they aren't delayed.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "text/event-stream":
+		return false
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml",
+		"application/wasm", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// compressWriter decides whether to compress a response once its headers
+// are written
+type compressWriter struct {
+	http.ResponseWriter
+	level    int
+	gz       *gzip.Writer
+	decided  bool
+	hijacked bool
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if !w.decided {
+		w.decide(status)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *compressWriter) decide(status int) {
+	w.decided = true
+
+	header := w.Header()
+	if !compressible(header.Get("Content-Type")) {
+		return
+	}
+	header.Add("Vary", "Accept-Encoding")
+
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified ||
+		status == http.StatusPartialContent || header.Get("Content-Encoding") != "" {
+		return
+	}
+	if length, err := strconv.Atoi(header.Get("Content-Length")); err == nil && length < compressMinSize {
+		return
+	}
+
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", "gzip")
+	w.gz, _ = gzip.NewWriterLevel(w.ResponseWriter, w.level)
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T is not a hijacker", w.ResponseWriter)
+	}
+	w.hijacked = true
+	return hijacker.Hijack()
+}
+
+// Close flushes the compressed response
+func (w *compressWriter) Close() error {
+	if w.gz == nil || w.hijacked {
+		return nil
+	}
+	return w.gz.Close()
+}
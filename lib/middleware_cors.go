@@ -0,0 +1,87 @@
+package gin
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultCORSMethods are the methods allowed by CORS without configured methods
+var DefaultCORSMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+type cors struct {
+	origins []string
+	methods string
+}
+
+// CORS returns a Middleware allowing cross-origin requests from origins, any
+// origin if empty, with the given methods, DefaultCORSMethods if empty. It
+// answers preflight requests itself and replaces the CORS headers of the app,
+// so e.g. a frontend dev server on another port can call the app.
+func CORS(origins []string, methods []string) Middleware {
+	if len(methods) == 0 {
+		methods = DefaultCORSMethods
+	}
+	c := cors{origins: origins, methods: strings.ToUpper(strings.Join(methods, ", "))}
+	stripHeaders, _ := HookMiddleware(c)
+
+	return func(next http.Handler) http.Handler {
+		next = stripHeaders(next)
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			origin := req.Header.Get("Origin")
+			if origin == "" || !c.allowed(origin) {
+				next.ServeHTTP(res, req)
+				return
+			}
+
+			header := res.Header()
+			header.Set("Access-Control-Allow-Origin", origin)
+			header.Set("Access-Control-Allow-Credentials", "true")
+			header.Add("Vary", "Origin")
+
+			if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+				header.Set("Access-Control-Allow-Methods", c.methods)
+				if headers := req.Header.Get("Access-Control-Request-Headers"); headers != "" {
+					header.Set("Access-Control-Allow-Headers", headers)
+				}
+				header.Set("Access-Control-Max-Age", "600")
+				res.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(res, req)
+		})
+	}
+}
+
+func (c cors) allowed(origin string) bool {
+	if len(c.origins) == 0 {
+		return true
+	}
+	for _, o := range c.origins {
+		if o == "*" || strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// HookResponse removes the CORS headers of the app, which would otherwise
+// be sent in addition to the ones set by the middleware
+func (c cors) HookResponse(res *http.Response) error {
+	for name := range res.Header {
+		if strings.HasPrefix(name, "Access-Control-") {
+			res.Header.Del(name)
+		}
+	}
+	return nil
+}
+
+// newCORS allows the comma separated origins given as argument, or any
+func newCORS(arg string) (Middleware, error) {
+	var origins []string
+	for _, origin := range strings.Split(arg, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return CORS(origins, nil), nil
+}
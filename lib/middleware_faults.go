@@ -0,0 +1,141 @@
+package gin
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Faults describes the latency and failures injected into proxied requests,
+// e.g. to exercise the timeouts and retries of clients
+type Faults struct {
+	// Delay is added to every matched request
+	Delay time.Duration
+	// Jitter adds up to this much random delay on top of Delay
+	Jitter time.Duration
+	// FailRate is the fraction of matched requests, from 0 to 1, answered
+	// with 503 Service Unavailable instead of being proxied
+	FailRate float64
+	// Path limits the faults to requests whose path matches, all if nil
+	Path *regexp.Regexp
+}
+
+// Enabled reports whether any faults are injected
+func (f Faults) Enabled() bool {
+	return f.Delay > 0 || f.Jitter > 0 || f.FailRate > 0
+}
+
+// String formats f like the argument of the faults middleware
+func (f Faults) String() string {
+	var parts []string
+	if f.Delay > 0 {
+		parts = append(parts, "delay="+f.Delay.String())
+	}
+	if f.Jitter > 0 {
+		parts = append(parts, "jitter="+f.Jitter.String())
+	}
+	if f.FailRate > 0 {
+		parts = append(parts, "failRate="+strconv.FormatFloat(f.FailRate, 'g', -1, 64))
+	}
+	if f.Path != nil {
+		parts = append(parts, "path="+f.Path.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseFaults parses faults given as comma separated settings, e.g.
+// "delay=200ms,jitter=100ms,failRate=0.05,path=^/api/". The path pattern
+// may contain commas, so it has to come last.
+func ParseFaults(value string) (Faults, error) {
+	var f Faults
+	for value != "" {
+		setting := value
+		if strings.HasPrefix(setting, "path=") {
+			value = ""
+		} else if i := strings.Index(value, ","); i >= 0 {
+			setting, value = value[:i], value[i+1:]
+		} else {
+			value = ""
+		}
+
+		i := strings.Index(setting, "=")
+		if i < 0 {
+			return Faults{}, fmt.Errorf("expected setting=value, got %q", setting)
+		}
+		key, val := strings.TrimSpace(setting[:i]), strings.TrimSpace(setting[i+1:])
+		var err error
+		switch key {
+		case "delay":
+			f.Delay, err = time.ParseDuration(val)
+		case "jitter":
+			f.Jitter, err = time.ParseDuration(val)
+		case "failRate":
+			f.FailRate, err = strconv.ParseFloat(val, 64)
+			if err == nil && (f.FailRate < 0 || f.FailRate > 1) {
+				err = fmt.Errorf("expected a fraction from 0 to 1, got %s", val)
+			}
+		case "path":
+			f.Path, err = regexp.Compile(val)
+		default:
+			err = fmt.Errorf("unknown setting %q (expected delay, jitter, failRate or path)", key)
+		}
+		if err != nil {
+			return Faults{}, fmt.Errorf("%s: %v", key, err)
+		}
+	}
+	return f, nil
+}
+
+// newFaults injects the faults given like ParseFaults expects them
+func newFaults(arg string) (Middleware, error) {
+	f, err := ParseFaults(arg)
+	if err != nil {
+		return nil, err
+	}
+	return f.Middleware(), nil
+}
+
+// Middleware returns a Middleware injecting the faults
+func (f Faults) Middleware() Middleware {
+	var mu sync.Mutex
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if f.Path != nil && !f.Path.MatchString(req.URL.Path) {
+				next.ServeHTTP(res, req)
+				return
+			}
+
+			mu.Lock()
+			delay := f.Delay
+			if f.Jitter > 0 {
+				delay += time.Duration(random.Int63n(int64(f.Jitter) + 1))
+			}
+			fail := f.FailRate > 0 && random.Float64() < f.FailRate
+			mu.Unlock()
+
+			if delay > 0 {
+				Tracef("%s %s: delaying by %s", req.Method, req.URL.RequestURI(), delay)
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-req.Context().Done():
+					timer.Stop()
+					return
+				}
+			}
+			if fail {
+				Tracef("%s %s: injecting a failure", req.Method, req.URL.RequestURI())
+				http.Error(res, "gin: injected failure", http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(res, req)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package gin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFaultsDelay(t *testing.T) {
+	f := Faults{Delay: 50 * time.Millisecond, Jitter: 20 * time.Millisecond, Path: regexp.MustCompile(`^/api/`)}
+	handler := f.Middleware()(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users", nil))
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("matching request took %s, want 50ms plus up to 20ms", elapsed)
+	}
+
+	start = time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/index.html", nil))
+	if elapsed := time.Since(start); elapsed >= 50*time.Millisecond {
+		t.Errorf("other request took %s, want no delay", elapsed)
+	}
+}
+
+func TestFaultsDelayCanceled(t *testing.T) {
+	called := false
+	handler := Faults{Delay: time.Minute}.Middleware()(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		called = true
+	}))
+
+	// a client giving up ends the delay, without passing the request on
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the delay outlasted the request")
+	}
+	if called {
+		t.Error("the canceled request was passed on")
+	}
+}
+
+func TestFaultsFailRate(t *testing.T) {
+	handler := Faults{FailRate: 0.5}.Middleware()(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+
+	failed := 0
+	for i := 0; i < 1000; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code == http.StatusServiceUnavailable {
+			failed++
+		}
+	}
+	if failed < 350 || failed > 650 {
+		t.Errorf("%d of 1000 requests failed, want about 500", failed)
+	}
+}
+
+func TestFaultsEnabled(t *testing.T) {
+	if (Faults{Path: regexp.MustCompile(".")}).Enabled() {
+		t.Error("a path alone enables faults")
+	}
+	if !(Faults{Jitter: time.Millisecond}).Enabled() {
+		t.Error("jitter doesn't enable faults")
+	}
+}
@@ -0,0 +1,127 @@
+package gin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MockRoute is a response the proxy sends itself for matching requests,
+// declared in the config file, e.g.
+//
+//	{"path": "/api/users/*", "status": 200, "file": "mocks/users.json"}
+type MockRoute struct {
+	// Method limits the route to requests with this method, all if empty
+	Method string `json:"method"`
+	// Path is matched against the path of requests like path.Match, so *
+	// matches a single path segment
+	Path string `json:"path"`
+	// Status defaults to 200
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	// Body is sent as it is if it's a string and as JSON otherwise
+	Body json.RawMessage `json:"body"`
+	// File is sent instead of Body. It is read for every request, so it can
+	// be edited while gin runs.
+	File string `json:"file"`
+}
+
+// Mocks returns a Middleware answering requests matching one of routes,
+// the first that matches, without passing them to the app. Relative files
+// of the routes are looked up in dir.
+func Mocks(routes []MockRoute, dir string) (Middleware, error) {
+	for i, route := range routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			return nil, fmt.Errorf("mock route %d: expected a path starting with /, got %q", i+1, route.Path)
+		}
+		if _, err := path.Match(route.Path, "/"); err != nil {
+			return nil, fmt.Errorf("mock route %s: %v", route.Path, err)
+		}
+		if route.File != "" && !filepath.IsAbs(route.File) {
+			routes[i].File = filepath.Join(dir, route.File)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			for _, route := range routes {
+				if route.matches(req) {
+					Tracef("%s %s: answering with the mock route %s", req.Method, req.URL.RequestURI(), route.Path)
+					route.serve(res)
+					return
+				}
+			}
+			next.ServeHTTP(res, req)
+		})
+	}, nil
+}
+
+// newMocks answers the routes given as a JSON array, either inline or in the
+// file named by arg. Relative files of the routes are looked up next to that
+// file, or in the working directory for inline routes.
+func newMocks(arg string) (Middleware, error) {
+	data, dir := []byte(arg), "."
+	if !strings.HasPrefix(strings.TrimSpace(arg), "[") {
+		var err error
+		if data, err = ioutil.ReadFile(arg); err != nil {
+			return nil, err
+		}
+		dir = filepath.Dir(arg)
+	}
+
+	var routes []MockRoute
+	if err := json.Unmarshal(data, &routes); err != nil {
+		return nil, fmt.Errorf("expected a JSON array of routes: %v", err)
+	}
+	return Mocks(routes, dir)
+}
+
+func (r MockRoute) matches(req *http.Request) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, req.Method) {
+		return false
+	}
+	matched, _ := path.Match(r.Path, req.URL.Path)
+	return matched
+}
+
+func (r MockRoute) serve(res http.ResponseWriter) {
+	var body []byte
+	contentType := "text/plain; charset=utf-8"
+
+	if r.File != "" {
+		var err error
+		body, err = ioutil.ReadFile(r.File)
+		if err != nil {
+			http.Error(res, fmt.Sprintf("gin: mock route %s: %v", r.Path, err), http.StatusInternalServerError)
+			return
+		}
+		if strings.EqualFold(filepath.Ext(r.File), ".json") {
+			contentType = "application/json"
+		} else {
+			contentType = http.DetectContentType(body)
+		}
+	} else if len(r.Body) > 0 {
+		var text string
+		if err := json.Unmarshal(r.Body, &text); err == nil {
+			body = []byte(text)
+		} else {
+			body = r.Body
+			contentType = "application/json"
+		}
+	}
+
+	res.Header().Set("Content-Type", contentType)
+	for name, value := range r.Headers {
+		res.Header().Set(name, value)
+	}
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	res.WriteHeader(status)
+	res.Write(body)
+}
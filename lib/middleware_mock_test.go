@@ -0,0 +1,70 @@
+package gin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMocks(t *testing.T) {
+	mw, err := Mocks([]MockRoute{
+		{Method: "GET", Path: "/api/flags", Body: json.RawMessage(`{"newCheckout": true}`), Headers: map[string]string{"Cache-Control": "no-store"}},
+		{Path: "/api/users/*", Status: http.StatusNotFound, Body: json.RawMessage(`"no such user"`)},
+		{Path: "/api/users/*", Body: json.RawMessage(`"shadowed by the route before"`)},
+	}, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := mw(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Write([]byte("app"))
+	}))
+
+	tests := []struct {
+		method, path string
+		status       int
+		contentType  string
+		body         string
+	}{
+		{"GET", "/api/flags", http.StatusOK, "application/json", `{"newCheckout": true}`},
+		{"POST", "/api/flags", http.StatusOK, "text/plain; charset=utf-8", "app"},
+		{"DELETE", "/api/users/7", http.StatusNotFound, "text/plain; charset=utf-8", "no such user"},
+		// * matches a single path segment
+		{"GET", "/api/users/7/posts", http.StatusOK, "text/plain; charset=utf-8", "app"},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(test.method, test.path, nil))
+		if rec.Code != test.status || rec.Header().Get("Content-Type") != test.contentType || rec.Body.String() != test.body {
+			t.Errorf("%s %s: %d %s %q, want %d %s %q", test.method, test.path,
+				rec.Code, rec.Header().Get("Content-Type"), rec.Body.String(), test.status, test.contentType, test.body)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/flags", nil))
+	if rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", rec.Header().Get("Cache-Control"))
+	}
+}
+
+func TestMocksMissingFile(t *testing.T) {
+	mw, err := Mocks([]MockRoute{{Path: "/api/users", File: "mocks/users.json"}}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the file is read for every request, so it may appear later
+	rec := httptest.NewRecorder()
+	mw(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest("GET", "/api/users", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestMocksInvalidPath(t *testing.T) {
+	for _, path := range []string{"api/users", "/api/[users"} {
+		if _, err := Mocks([]MockRoute{{Path: path}}, "."); err == nil {
+			t.Errorf("Mocks accepted the path %q", path)
+		}
+	}
+}
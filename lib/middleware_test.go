@@ -0,0 +1,137 @@
+package gin
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// serve passes req through the Middleware spec describes to a handler
+// answering 200 OK
+func serve(t *testing.T, spec string, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	mw, err := NewMiddleware(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	mw(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {})).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if rec := serve(t, "auth=dev:secret", req); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without credentials: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	req.SetBasicAuth("dev", "secret")
+	if rec := serve(t, "auth=dev:secret", req); rec.Code != http.StatusOK {
+		t.Errorf("with credentials: status %d, want %d", rec.Code, http.StatusOK)
+	}
+	if _, err := NewMiddleware("auth=dev"); err == nil {
+		t.Error("auth accepted credentials without a password")
+	}
+}
+
+func TestAllowCIDRMiddleware(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.7:51000"
+	if rec := serve(t, "allowCIDR=192.168.1.0/24, 10.0.0.7", req); rec.Code != http.StatusOK {
+		t.Errorf("listed client: status %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := serve(t, "allowCIDR=192.168.1.0/24", req); rec.Code != http.StatusForbidden {
+		t.Errorf("unlisted client: status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if _, err := NewMiddleware("allowCIDR="); err == nil {
+		t.Error("allowCIDR accepted no networks")
+	}
+}
+
+func TestMiddlewareSpecGuard(t *testing.T) {
+	for spec, want := range map[string]bool{
+		"auth=dev:secret":    true,
+		"allowCIDR=::1":      true,
+		"accesslog":          false,
+		"faults=delay=10ms":  false,
+		"mocks=[]":           false,
+		"headers=X-Dev: yes": false,
+	} {
+		if got := ParseMiddlewareSpec(spec).Guard(); got != want {
+			t.Errorf("%s: Guard() = %v, want %v", spec, got, want)
+		}
+	}
+}
+
+func TestParseFaults(t *testing.T) {
+	f, err := ParseFaults("delay=200ms,jitter=100ms,failRate=0.05,path=^/(api|v1),x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Delay != 200*time.Millisecond || f.Jitter != 100*time.Millisecond || f.FailRate != 0.05 {
+		t.Errorf("ParseFaults = %+v", f)
+	}
+	if f.Path == nil || f.Path.String() != "^/(api|v1),x" {
+		t.Errorf("path = %v, want ^/(api|v1),x", f.Path)
+	}
+
+	again, err := ParseFaults(f.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != f.String() {
+		t.Errorf("ParseFaults(%q) = %q", f.String(), again.String())
+	}
+
+	for _, value := range []string{"delay", "delay=soon", "failRate=2", "timeout=1s", "path=("} {
+		if _, err := ParseFaults(value); err == nil {
+			t.Errorf("ParseFaults accepted %q", value)
+		}
+	}
+}
+
+func TestFaultsMiddleware(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/users", nil)
+	if rec := serve(t, "faults=failRate=1,path=^/api/", req); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("matching path: status %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	req = httptest.NewRequest("GET", "/index.html", nil)
+	if rec := serve(t, "faults=failRate=1,path=^/api/", req); rec.Code != http.StatusOK {
+		t.Errorf("other path: status %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestMocksMiddleware(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "mocks"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "mocks", "user.json"), []byte(`{"id": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	routes := filepath.Join(dir, "routes.json")
+	if err := ioutil.WriteFile(routes, []byte(`[{"method": "GET", "path": "/api/users/*", "file": "mocks/user.json"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// relative files are looked up next to the file of the routes
+	rec := serve(t, "mocks="+routes, httptest.NewRequest("GET", "/api/users/1", nil))
+	if rec.Body.String() != `{"id": 1}` || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("file route: %q (%s)", rec.Body.String(), rec.Header().Get("Content-Type"))
+	}
+	rec = serve(t, "mocks="+routes, httptest.NewRequest("POST", "/api/users/1", nil))
+	if rec.Body.Len() != 0 {
+		t.Errorf("other method answered with %q", rec.Body.String())
+	}
+
+	rec = serve(t, `mocks=[{"path": "/hooks/*", "status": 204}]`, httptest.NewRequest("POST", "/hooks/stripe", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("inline route: status %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if _, err := NewMiddleware(`mocks=[{"path": "users"}]`); err == nil {
+		t.Error("mocks accepted a relative path")
+	}
+}
@@ -0,0 +1,510 @@
+package gin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Migration is a config file for gin converted from the config of another
+// live reload tool
+type Migration struct {
+	// Tool is the name of the other tool, e.g. air
+	Tool string
+	// Config holds the converted option values and sections, e.g. env
+	Config ConfigFile
+	// Args are the arguments of the app, which go after gin run
+	Args []string
+	// Notes describe the settings gin has no equivalent for
+	Notes []string
+}
+
+// Migrator converts the config of another live reload tool
+type Migrator struct {
+	// Files are the usual names of its config, tried in order by FindMigration
+	Files []string
+	// Detect, if set, reports whether one of Files holds the config, e.g. a
+	// Makefile running the tool
+	Detect func(data []byte) bool
+	// Convert converts the config read from a file into m
+	Convert func(data []byte, m *Migration) error
+}
+
+var (
+	migratorMu sync.Mutex
+	migrators  = map[string]Migrator{
+		"air": {
+			Files:   []string{".air.toml", ".air.conf", "air.toml"},
+			Convert: convertAir,
+		},
+		"realize": {
+			Files:   []string{".realize.yaml", ".realize.yml", ".realize/realize.yaml"},
+			Convert: convertRealize,
+		},
+		"compiledaemon": {
+			Files: []string{"Makefile", "Procfile", "Dockerfile", "docker-compose.yml", "docker-compose.yaml"},
+			Detect: func(data []byte) bool {
+				return compileDaemonLine(data) != ""
+			},
+			Convert: convertCompileDaemon,
+		},
+	}
+)
+
+// RegisterMigrator makes a Migrator available under the name of its tool
+func RegisterMigrator(tool string, migrator Migrator) {
+	migratorMu.Lock()
+	defer migratorMu.Unlock()
+	migrators[tool] = migrator
+}
+
+// Migrators returns the names of the tools with a registered Migrator
+func Migrators() []string {
+	migratorMu.Lock()
+	defer migratorMu.Unlock()
+
+	var names []string
+	for name := range migrators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FindMigration returns the tool and path of the first config of another
+// tool found in dir
+func FindMigration(dir string) (string, string, error) {
+	for _, tool := range Migrators() {
+		if path := migrationFile(tool, dir); path != "" {
+			return tool, path, nil
+		}
+	}
+	return "", "", fmt.Errorf("no config of %s found in %s", strings.Join(Migrators(), ", "), dir)
+}
+
+// migrationFile returns the path of the config of tool in dir, empty if
+// there is none
+func migrationFile(tool string, dir string) string {
+	migratorMu.Lock()
+	migrator := migrators[tool]
+	migratorMu.Unlock()
+
+	for _, name := range migrator.Files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		data, err := ioutil.ReadFile(path)
+		if err == nil && (migrator.Detect == nil || migrator.Detect(data)) {
+			return path
+		}
+	}
+	return ""
+}
+
+// Migrate converts the config of tool at path. An empty tool is guessed from
+// the name of the file.
+func Migrate(tool string, path string) (*Migration, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if tool == "" {
+		for _, name := range Migrators() {
+			if migrationFile(name, filepath.Dir(path)) == path {
+				tool = name
+				break
+			}
+		}
+		if tool == "" {
+			return nil, fmt.Errorf("can't tell which tool %s configures, pass --tool (available: %s)", path, strings.Join(Migrators(), ", "))
+		}
+	}
+
+	migratorMu.Lock()
+	migrator, ok := migrators[tool]
+	migratorMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown tool %q (available: %s)", tool, strings.Join(Migrators(), ", "))
+	}
+
+	m := &Migration{Tool: tool, Config: make(ConfigFile)}
+	if err := migrator.Convert(data, m); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return m, nil
+}
+
+// JSON returns the converted config file
+func (m *Migration) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(m.Config, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// note records a setting gin has no equivalent for
+func (m *Migration) note(format string, v ...interface{}) {
+	m.Notes = append(m.Notes, fmt.Sprintf(format, v...))
+}
+
+// appendValues adds values to the repeatable option name
+func (m *Migration) appendValues(name string, values ...string) {
+	existing, _ := m.Config[name].([]string)
+	for _, value := range values {
+		if value != "" {
+			existing = append(existing, value)
+		}
+	}
+	if len(existing) > 0 {
+		m.Config[name] = existing
+	}
+}
+
+// setEnv sets a variable of the env section
+func (m *Migration) setEnv(name, value string) {
+	env, ok := m.Config["env"].(map[string]string)
+	if !ok {
+		env = make(map[string]string)
+		m.Config["env"] = env
+	}
+	env[name] = value
+}
+
+// goBuildValueFlags are the go build flags taking a separate value
+var goBuildValueFlags = map[string]bool{
+	"o": true, "p": true, "C": true, "asmflags": true, "buildmode": true, "buildvcs": true,
+	"compiler": true, "gccgoflags": true, "gcflags": true, "installsuffix": true, "ldflags": true,
+	"mod": true, "modfile": true, "overlay": true, "pgo": true, "pkgdir": true, "tags": true, "toolexec": true,
+}
+
+// buildCommand converts the command building the app, which gin can only
+// take over if it is a go build
+func (m *Migration) buildCommand(command string) {
+	parser := newParser()
+	envs, args, err := parser.ParseWithEnvs(command)
+	if err != nil || parser.Position > 0 || len(args) < 2 || args[0] != "go" || args[1] != "build" {
+		m.note("the build command %q isn't a single go build, gin always runs go build; a plugin can run the rest", command)
+		return
+	}
+	if len(envs) > 0 {
+		m.note("the build command sets %s, set it in the environment of gin instead", strings.Join(envs, " "))
+	}
+
+	var extra []string
+	for i := 2; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			switch {
+			case arg == ".":
+			case strings.HasSuffix(arg, "/..."):
+				m.note("gin builds a single package, not %s", arg)
+			case strings.HasPrefix(arg, "./") || strings.HasPrefix(arg, "../"):
+				m.Config["target"] = arg
+			default:
+				extra = append(extra, arg)
+			}
+			continue
+		}
+
+		name, value := strings.TrimLeft(arg, "-"), ""
+		if eq := strings.Index(name, "="); eq >= 0 {
+			name, value = name[:eq], name[eq+1:]
+		} else if goBuildValueFlags[name] && i+1 < len(args) {
+			i++
+			value = args[i]
+			if strings.ContainsAny(value, " \t\"'\\$`") {
+				arg += " " + shellQuote(value)
+			} else {
+				arg += " " + value
+			}
+		}
+		switch name {
+		case "o":
+			m.Config["bin"] = strings.TrimPrefix(filepath.ToSlash(value), "./")
+		case "tags", "ldflags", "gcflags":
+			m.Config[name] = value
+		case "race":
+			m.Config["race"] = value == "" || value == "true"
+		default:
+			extra = append(extra, arg)
+		}
+	}
+	if len(extra) > 0 {
+		m.Config["buildArgs"] = strings.Join(extra, " ")
+	}
+}
+
+// runCommand converts the command running the built app, taking over its
+// environment variables and arguments
+func (m *Migration) runCommand(command string) {
+	parser := newParser()
+	envs, args, err := parser.ParseWithEnvs(command)
+	if err != nil || parser.Position > 0 {
+		m.note("the run command %q runs more than the app, gin only runs the app", command)
+		return
+	}
+	for _, env := range envs {
+		eq := strings.Index(env, "=")
+		// gin tells the app its port
+		if port, err := strconv.Atoi(env[eq+1:]); err == nil && env[:eq] == "PORT" {
+			m.Config["appPort"] = port
+			continue
+		}
+		m.setEnv(env[:eq], env[eq+1:])
+	}
+	if len(args) > 1 {
+		m.Args = append(m.Args, args[1:]...)
+	}
+}
+
+// extensions converts the extensions of watched files. Files other than Go
+// ones, e.g. templates, only restart the app in gin.
+func (m *Migration) extensions(exts []string) {
+	for _, ext := range exts {
+		ext = strings.TrimPrefix(strings.TrimPrefix(ext, "*"), ".")
+		if ext != "" && ext != "go" {
+			m.appendValues("restartPattern", "*."+ext)
+		}
+	}
+}
+
+// convertAir converts the .air.toml of air
+func convertAir(data []byte, m *Migration) error {
+	doc, err := parseTOML(data)
+	if err != nil {
+		return err
+	}
+
+	if root := configString(doc["root"]); root != "" && root != "." {
+		m.Config["path"] = root
+	}
+
+	build, _ := doc["build"].(map[string]interface{})
+	if cmd := configString(build["cmd"]); cmd != "" {
+		m.buildCommand(cmd)
+	}
+	if fullBin := configString(build["full_bin"]); fullBin != "" {
+		m.runCommand(fullBin)
+	}
+	m.Args = append(m.Args, configStrings(build["args_bin"])...)
+	m.extensions(configStrings(build["include_ext"]))
+	m.appendValues("excludeDir", configStrings(build["exclude_dir"])...)
+	if build["stop_on_error"] == true {
+		m.Config["stopOnBuildError"] = true
+	}
+	if build["poll"] == true {
+		m.Config["watcher"] = "poll"
+	}
+	for _, key := range []string{"include_dir", "include_file", "exclude_file", "pre_cmd", "post_cmd"} {
+		if values := configStrings(build[key]); len(values) > 0 {
+			m.note("build.%s = %s has no equivalent in gin", key, strings.Join(values, ", "))
+		}
+	}
+	for _, regex := range configStrings(build["exclude_regex"]) {
+		if regex != "_test.go" {
+			m.note("build.exclude_regex %q has no equivalent in gin, exclude directories with excludeDir", regex)
+		}
+	}
+
+	if proxy, _ := doc["proxy"].(map[string]interface{}); proxy["enabled"] == true {
+		if port, ok := proxy["proxy_port"].(float64); ok {
+			m.Config["port"] = port
+		}
+		if port, ok := proxy["app_port"].(float64); ok {
+			m.Config["appPort"] = port
+		}
+	}
+	return nil
+}
+
+// convertRealize converts the .realize.yaml of realize, whose first project
+// is converted
+func convertRealize(data []byte, m *Migration) error {
+	raw, err := parseYAML(data)
+	if err != nil {
+		return err
+	}
+	doc, _ := raw.(map[string]interface{})
+	schema, _ := doc["schema"].([]interface{})
+	if len(schema) == 0 {
+		return fmt.Errorf("no project found in schema")
+	}
+	project, _ := schema[0].(map[string]interface{})
+	if len(schema) > 1 {
+		m.note("the projects after %s, gin runs a single app", configString(project["name"]))
+	}
+
+	if path := configString(project["path"]); path != "" && path != "." {
+		m.Config["path"] = path
+	}
+
+	commands, _ := project["commands"].(map[string]interface{})
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		command, _ := commands[name].(map[string]interface{})
+		if command["status"] != true {
+			continue
+		}
+		switch method := configString(command["method"]); name {
+		case "install", "build":
+			if method != "" {
+				m.buildCommand(method)
+			}
+		case "run":
+			if method != "" {
+				m.note("the run method %q has no equivalent in gin, which runs the built binary", method)
+			}
+		default:
+			m.note("the %s command, gin doesn't run go %s but a plugin can", name, name)
+		}
+	}
+
+	m.Args = append(m.Args, configStrings(project["args"])...)
+	watcher, _ := project["watcher"].(map[string]interface{})
+	m.extensions(configStrings(watcher["extensions"]))
+	ignore, _ := watcher["ignore"].(map[string]interface{})
+	for _, dir := range append(configStrings(watcher["ignored_paths"]), configStrings(ignore["paths"])...) {
+		m.appendValues("excludeDir", strings.TrimPrefix(dir, "./"))
+	}
+	for _, path := range configStrings(watcher["paths"]) {
+		if path != "/" && path != "." && path != "./" {
+			m.note("watcher path %s has no equivalent in gin, which watches all of path", path)
+		}
+	}
+	if watcher["scripts"] != nil {
+		m.note("watcher scripts have no equivalent in gin, a plugin can run them")
+	}
+
+	env, _ := project["env"].(map[string]interface{})
+	for name, value := range env {
+		m.setEnv(name, configString(value))
+	}
+	return nil
+}
+
+// compileDaemonBoolFlags are the flags of CompileDaemon taking no value
+var compileDaemonBoolFlags = map[string]bool{
+	"color": true, "graceful-kill": true, "log-prefix": true, "polling": true, "recursive": true, "verbose": true,
+}
+
+// convertCompileDaemon converts the command line of CompileDaemon found in
+// a Makefile, Procfile or similar
+func convertCompileDaemon(data []byte, m *Migration) error {
+	line := compileDaemonLine(data)
+	if line == "" {
+		return fmt.Errorf("no CompileDaemon command line found")
+	}
+	args, err := Parse(line)
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i < len(args); i++ {
+		name, value := strings.TrimLeft(args[i], "-"), ""
+		if eq := strings.Index(name, "="); eq >= 0 {
+			name, value = name[:eq], name[eq+1:]
+		} else if !compileDaemonBoolFlags[name] && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+
+		switch name {
+		case "directory":
+			if value != "." {
+				m.Config["path"] = value
+			}
+		case "build-dir":
+			m.Config["build"] = value
+		case "build":
+			m.buildCommand(value)
+		case "command":
+			m.runCommand(value)
+		case "run-dir":
+			m.Config["appDir"] = value
+		case "exclude-dir":
+			m.appendValues("excludeDir", value)
+		case "include":
+			m.appendValues("restartPattern", value)
+		case "polling":
+			if value == "" || value == "true" {
+				m.Config["watcher"] = "poll"
+			}
+		case "exclude", "pattern":
+			m.note("-%s=%s has no equivalent in gin", name, value)
+		}
+	}
+	return nil
+}
+
+// compileDaemonLine returns the first command line running CompileDaemon in
+// data, joining continued lines
+func compileDaemonLine(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := ""
+	for scanner.Scan() {
+		text := scanner.Text()
+		if strings.HasSuffix(text, "\\") {
+			line += strings.TrimSuffix(text, "\\") + " "
+			continue
+		}
+		line += text
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			line = ""
+			continue
+		}
+		// e.g. /go/bin/CompileDaemon -build=..., but not go install
+		// github.com/githubnemo/CompileDaemon@latest
+		for i := strings.Index(line, "CompileDaemon"); i >= 0; {
+			end := i + len("CompileDaemon")
+			if end == len(line) || line[end] == ' ' || line[end] == '\t' {
+				return line[i:]
+			}
+			next := strings.Index(line[end:], "CompileDaemon")
+			if next < 0 {
+				break
+			}
+			i = end + next
+		}
+		line = ""
+	}
+	return ""
+}
+
+// configString returns value if it is a string, its text if it is a number
+func configString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// configStrings returns the strings of a list value, or of a single string
+func configStrings(value interface{}) []string {
+	if s, ok := value.(string); ok && s != "" {
+		return []string{s}
+	}
+	list, _ := value.([]interface{})
+	var values []string
+	for _, v := range list {
+		if s := configString(v); s != "" {
+			values = append(values, s)
+		}
+	}
+	return values
+}
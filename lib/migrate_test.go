@@ -0,0 +1,145 @@
+package gin
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// migrate converts config, written to a file called name
+func migrate(t *testing.T, name, config string) *Migration {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Migrate("", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMigrateAir(t *testing.T) {
+	m := migrate(t, ".air.toml", `
+root = "."
+[build]
+  cmd = "go build -tags dev -o ./tmp/main ./cmd/api"
+  full_bin = "APP_ENV=dev PORT=8080 ./tmp/main --verbose"
+  args_bin = ["--port", "8080"]
+  include_ext = ["go", "tpl", "html"]
+  exclude_dir = ["assets", "tmp"]
+  stop_on_error = true
+  pre_cmd = ["make generate"]
+[proxy]
+  enabled = true
+  proxy_port = 3000
+  app_port = 8080
+`)
+	want := ConfigFile{
+		"tags":             "dev",
+		"bin":              "tmp/main",
+		"target":           "./cmd/api",
+		"appPort":          8080.0,
+		"env":              map[string]string{"APP_ENV": "dev"},
+		"restartPattern":   []string{"*.tpl", "*.html"},
+		"excludeDir":       []string{"assets", "tmp"},
+		"stopOnBuildError": true,
+		"port":             3000.0,
+	}
+	if m.Tool != "air" {
+		t.Errorf("tool = %q, want air", m.Tool)
+	}
+	if !reflect.DeepEqual(m.Config, want) {
+		t.Errorf("config =\n%#v\nwant\n%#v", m.Config, want)
+	}
+	if want := []string{"--verbose", "--port", "8080"}; !reflect.DeepEqual(m.Args, want) {
+		t.Errorf("args = %q, want %q", m.Args, want)
+	}
+	if len(m.Notes) != 1 {
+		t.Errorf("notes = %q, want one about pre_cmd", m.Notes)
+	}
+}
+
+func TestMigrateRealize(t *testing.T) {
+	m := migrate(t, ".realize.yaml", `settings:
+  legacy:
+    force: false
+schema:
+- name: api
+  path: ./server
+  commands:
+    install:
+      status: true
+      method: go build -race
+    test:
+      status: false
+  args:
+  - --debug
+  watcher:
+    extensions:
+    - go
+    - html
+    ignored_paths:
+    - ./vendor
+  env:
+    DB: postgres
+`)
+	want := ConfigFile{
+		"path":           "./server",
+		"race":           true,
+		"restartPattern": []string{"*.html"},
+		"excludeDir":     []string{"vendor"},
+		"env":            map[string]string{"DB": "postgres"},
+	}
+	if !reflect.DeepEqual(m.Config, want) {
+		t.Errorf("config =\n%#v\nwant\n%#v", m.Config, want)
+	}
+	if want := []string{"--debug"}; !reflect.DeepEqual(m.Args, want) {
+		t.Errorf("args = %q, want %q", m.Args, want)
+	}
+}
+
+func TestMigrateCompileDaemon(t *testing.T) {
+	m := migrate(t, "Makefile", `install:
+	go install github.com/githubnemo/CompileDaemon@latest
+
+dev:
+	CompileDaemon -polling -exclude-dir=.git \
+		-build="go build -o server ." -command="./server -addr :8080"
+`)
+	want := ConfigFile{
+		"watcher":    "poll",
+		"excludeDir": []string{".git"},
+		"bin":        "server",
+	}
+	if m.Tool != "compiledaemon" {
+		t.Errorf("tool = %q, want compiledaemon", m.Tool)
+	}
+	if !reflect.DeepEqual(m.Config, want) {
+		t.Errorf("config =\n%#v\nwant\n%#v", m.Config, want)
+	}
+	if want := []string{"-addr", ":8080"}; !reflect.DeepEqual(m.Args, want) {
+		t.Errorf("args = %q, want %q", m.Args, want)
+	}
+}
+
+func TestFindMigration(t *testing.T) {
+	dir := t.TempDir()
+	// a Makefile without CompileDaemon is no migration
+	if err := ioutil.WriteFile(filepath.Join(dir, "Makefile"), []byte("build:\n\tgo build\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := FindMigration(dir); err == nil {
+		t.Error("FindMigration found a config in a plain Makefile")
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, ".air.toml"), []byte("root = \".\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tool, path, err := FindMigration(dir)
+	if err != nil || tool != "air" || path != filepath.Join(dir, ".air.toml") {
+		t.Errorf("FindMigration = %q, %q, %v", tool, path, err)
+	}
+}
@@ -0,0 +1,477 @@
+package gin
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of spans, see the OpenTelemetry trace specification
+const (
+	SpanInternal = 1
+	SpanServer   = 2
+	SpanClient   = 3
+)
+
+// span status codes of OTLP
+const (
+	spanStatusOK    = 1
+	spanStatusError = 2
+)
+
+const (
+	// tracerBatch is the number of spans exported at once
+	tracerBatch = 256
+	// tracerQueue is the number of spans waiting for export, further ones
+	// are dropped
+	tracerQueue = 4096
+	// tracerInterval is how often queued spans are exported
+	tracerInterval = 2 * time.Second
+)
+
+// Tracer exports spans to an OpenTelemetry collector over OTLP/HTTP, with
+// the JSON encoding, in batches in the background
+type Tracer struct {
+	endpoint string
+	headers  http.Header
+	resource []otlpAttribute
+	client   *http.Client
+
+	spans  chan otlpSpan
+	flush  chan chan struct{}
+	done   chan struct{}
+	once   sync.Once
+	failed bool
+}
+
+// NewTracer exports spans of service to the collector at endpoint, e.g.
+// http://localhost:4318, sending headers like "Authorization: Bearer ..."
+// with every export. attributes describe the resource, e.g. the project.
+func NewTracer(endpoint string, service string, headers []string, attributes map[string]string) (*Tracer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q, expected an http or https URL like http://localhost:4318", endpoint)
+	}
+	if !strings.HasSuffix(u.Path, "/v1/traces") {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/v1/traces"
+	}
+
+	t := &Tracer{
+		endpoint: u.String(),
+		headers:  make(http.Header),
+		resource: []otlpAttribute{otlpAttr("service.name", service)},
+		client:   &http.Client{Timeout: 10 * time.Second},
+		spans:    make(chan otlpSpan, tracerQueue),
+		flush:    make(chan chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, header := range headers {
+		name, value, err := parseHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		t.headers.Add(name, value)
+	}
+	for key, value := range attributes {
+		t.resource = append(t.resource, otlpAttr(key, value))
+	}
+
+	go t.loop()
+	return t, nil
+}
+
+// Start starts a span without parent, beginning a new trace, at start
+func (t *Tracer) Start(name string, kind int, start time.Time) *Span {
+	if t == nil {
+		return nil
+	}
+	return &Span{tracer: t, traceID: newTraceID(), spanID: newSpanID(), name: name, kind: kind, start: start}
+}
+
+// StartRemote starts a span continuing the trace of traceparent, a W3C
+// Trace Context header sent by a client, or a new trace if it is invalid
+func (t *Tracer) StartRemote(name string, kind int, traceparent string) *Span {
+	span := t.Start(name, kind, time.Now())
+	if span == nil {
+		return nil
+	}
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) == 4 && parts[0] == "00" && len(parts[1]) == 32 && len(parts[2]) == 16 &&
+		parts[1] != strings.Repeat("0", 32) && parts[2] != strings.Repeat("0", 16) {
+		if _, err := hex.DecodeString(parts[1] + parts[2]); err == nil {
+			span.traceID, span.parentID = strings.ToLower(parts[1]), strings.ToLower(parts[2])
+		}
+	}
+	return span
+}
+
+// Close exports the queued spans, waiting up to timeout
+func (t *Tracer) Close(timeout time.Duration) {
+	if t == nil {
+		return
+	}
+	t.once.Do(func() {
+		flushed := make(chan struct{})
+		select {
+		case t.flush <- flushed:
+			select {
+			case <-flushed:
+			case <-time.After(timeout):
+			}
+		case <-time.After(timeout):
+		}
+		close(t.done)
+	})
+}
+
+// Middleware traces the requests proxied to the app with a server span
+// named after the method and route, e.g. GET /users/:id, continuing the
+// trace of the client if it sent a traceparent. A client span times the
+// upstream request, and the app receives its traceparent to continue the
+// trace.
+func (t *Tracer) Middleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			span := t.StartRemote(req.Method+" "+normalizeRoute(req.URL.Path), SpanServer, req.Header.Get("Traceparent"))
+			span.SetAttribute("http.request.method", req.Method)
+			span.SetAttribute("url.path", req.URL.Path)
+			span.SetAttribute("server.address", req.Host)
+			if agent := req.UserAgent(); agent != "" {
+				span.SetAttribute("user_agent.original", agent)
+			}
+
+			upstream := span.Child("upstream", SpanClient)
+			var connected, firstByte time.Time
+			trace := &httptrace.ClientTrace{
+				GetConn: func(string) {
+					upstream.start = time.Now()
+				},
+				GotConn: func(httptrace.GotConnInfo) {
+					connected = time.Now()
+				},
+				GotFirstResponseByte: func() {
+					firstByte = time.Now()
+				},
+			}
+			req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+			req.Header.Set("Traceparent", upstream.Traceparent())
+
+			rec := &statusRecorder{ResponseWriter: res, status: http.StatusOK}
+			next.ServeHTTP(rec, req)
+
+			span.SetAttribute("http.response.status_code", rec.status)
+			if rec.status >= 500 {
+				span.SetError(fmt.Errorf("%s", http.StatusText(rec.status)))
+			}
+			if !connected.IsZero() {
+				upstream.SetAttribute("gin.upstream.connect_ms", connected.Sub(upstream.start).Seconds()*1000)
+				if !firstByte.IsZero() {
+					upstream.SetAttribute("gin.upstream.ttfb_ms", firstByte.Sub(upstream.start).Seconds()*1000)
+				}
+				upstream.SetAttribute("http.response.status_code", rec.status)
+				upstream.End()
+			}
+			span.End()
+		})
+	}
+}
+
+// loop exports the queued spans every tracerInterval, when a batch is full
+// and when flushed
+func (t *Tracer) loop() {
+	ticker := time.NewTicker(tracerInterval)
+	defer ticker.Stop()
+
+	var batch []otlpSpan
+	for {
+		select {
+		case span := <-t.spans:
+			batch = append(batch, span)
+			if len(batch) >= tracerBatch {
+				t.export(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			t.export(batch)
+			batch = nil
+		case flushed := <-t.flush:
+			for len(t.spans) > 0 {
+				batch = append(batch, <-t.spans)
+			}
+			t.export(batch)
+			batch = nil
+			close(flushed)
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// export posts spans to the collector, logging the first failure and only
+// tracing later ones
+func (t *Tracer) export(spans []otlpSpan) {
+	if len(spans) == 0 {
+		return
+	}
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: t.resource},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "gin"}, Spans: spans}},
+	}}})
+	if err != nil {
+		log.Printf("Can't encode the traces: %v", err)
+		return
+	}
+
+	req, err := http.NewRequest("POST", t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	for name, values := range t.headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := t.client.Do(req)
+	if err == nil {
+		io.Copy(ioutil.Discard, io.LimitReader(res.Body, 4096))
+		res.Body.Close()
+		if res.StatusCode >= 300 {
+			err = fmt.Errorf("the collector responded %s", res.Status)
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("Can't export %d spans to %s: %v", len(spans), redactURL(t.endpoint), err)
+		if !t.failed {
+			t.failed = true
+			log.Print(err)
+		} else {
+			Tracef("%v", err)
+		}
+		return
+	}
+	t.failed = false
+}
+
+// Span is an operation traced by a Tracer. A nil Span, e.g. from a nil
+// Tracer, ignores all calls, so tracing needs no checks when disabled.
+type Span struct {
+	tracer   *Tracer
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	kind     int
+
+	mu         sync.Mutex
+	start      time.Time
+	attributes []otlpAttribute
+	err        error
+	ended      bool
+}
+
+// Child starts a span below s, now
+func (s *Span) Child(name string, kind int) *Span {
+	return s.ChildAt(name, kind, time.Now())
+}
+
+// ChildAt starts a span below s at start
+func (s *Span) ChildAt(name string, kind int, start time.Time) *Span {
+	if s == nil {
+		return nil
+	}
+	return &Span{tracer: s.tracer, traceID: s.traceID, spanID: newSpanID(), parentID: s.spanID, name: name, kind: kind, start: start}
+}
+
+// SetAttribute sets an attribute of the span, value is a string, bool,
+// integer or float
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes = append(s.attributes, otlpAttr(key, value))
+}
+
+// SetError marks the span as failed with err, if not nil
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// Traceparent returns the W3C Trace Context header making the receiver
+// continue the trace below s
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return "00-" + s.traceID + "-" + s.spanID + "-01"
+}
+
+// End ends the span now and queues it for export
+func (s *Span) End() {
+	s.EndAt(time.Now())
+}
+
+// EndAt ends the span at end and queues it for export. Later calls are
+// ignored.
+func (s *Span) EndAt(end time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	if end.Before(s.start) {
+		end = s.start
+	}
+	span := otlpSpan{
+		TraceID:      s.traceID,
+		SpanID:       s.spanID,
+		ParentSpanID: s.parentID,
+		Name:         s.name,
+		Kind:         s.kind,
+		Start:        strconv.FormatInt(s.start.UnixNano(), 10),
+		End:          strconv.FormatInt(end.UnixNano(), 10),
+		Attributes:   s.attributes,
+		Status:       otlpStatus{Code: spanStatusOK},
+	}
+	if s.err != nil {
+		span.Status = otlpStatus{Code: spanStatusError, Message: s.err.Error()}
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.tracer.spans <- span:
+	default:
+		Verbosef("Dropping the span %s, the collector doesn't keep up", s.name)
+	}
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying span, e.g. to add child
+// spans further down
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span carried by ctx, nil if none
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// newTraceID returns a random trace id, hex encoded
+func newTraceID() string {
+	return randomHex(16)
+}
+
+// newSpanID returns a random span id, hex encoded
+func newSpanID() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// The OTLP/HTTP JSON encoding of an export request, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID      string          `json:"traceId"`
+	SpanID       string          `json:"spanId"`
+	ParentSpanID string          `json:"parentSpanId,omitempty"`
+	Name         string          `json:"name"`
+	Kind         int             `json:"kind"`
+	Start        string          `json:"startTimeUnixNano"`
+	End          string          `json:"endTimeUnixNano"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	Status       otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	String *string  `json:"stringValue,omitempty"`
+	Bool   *bool    `json:"boolValue,omitempty"`
+	Int    *string  `json:"intValue,omitempty"`
+	Double *float64 `json:"doubleValue,omitempty"`
+}
+
+// otlpAttr encodes an attribute, 64-bit integers are strings in JSON
+func otlpAttr(key string, value interface{}) otlpAttribute {
+	attr := otlpAttribute{Key: key}
+	switch v := value.(type) {
+	case string:
+		attr.Value.String = &v
+	case bool:
+		attr.Value.Bool = &v
+	case int:
+		s := strconv.Itoa(v)
+		attr.Value.Int = &s
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		attr.Value.Int = &s
+	case float64:
+		attr.Value.Double = &v
+	default:
+		s := fmt.Sprint(v)
+		attr.Value.String = &s
+	}
+	return attr
+}
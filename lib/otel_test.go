@@ -0,0 +1,101 @@
+package gin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTracerMiddleware(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		exports []otlpRequest
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v1/traces" || req.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("export to %s with Authorization %q", req.URL.Path, req.Header.Get("Authorization"))
+		}
+		var export otlpRequest
+		if err := json.NewDecoder(req.Body).Decode(&export); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		exports = append(exports, export)
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	var appTraceparent string
+	app := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		appTraceparent = req.Header.Get("Traceparent")
+	}))
+	defer app.Close()
+	appURL, _ := url.Parse(app.URL)
+
+	tracer, err := NewTracer(collector.URL, "api", []string{"Authorization: Bearer secret"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := tracer.Middleware()(httputil.NewSingleHostReverseProxy(appURL))
+
+	const traceID, clientSpanID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	req := httptest.NewRequest("GET", "/users/42", nil)
+	req.Header.Set("Traceparent", "00-"+traceID+"-"+clientSpanID+"-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	tracer.Close(5 * time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+	spans := make(map[string]otlpSpan)
+	for _, export := range exports {
+		for _, span := range export.ResourceSpans[0].ScopeSpans[0].Spans {
+			spans[span.Name] = span
+		}
+	}
+	server, ok := spans["GET /users/:id"]
+	if !ok {
+		t.Fatalf("no server span among %v", spans)
+	}
+	if server.TraceID != traceID || server.ParentSpanID != clientSpanID || server.Kind != SpanServer {
+		t.Errorf("server span continues %s below %s as kind %d", server.TraceID, server.ParentSpanID, server.Kind)
+	}
+	upstream, ok := spans["upstream"]
+	if !ok {
+		t.Fatalf("no upstream span among %v", spans)
+	}
+	if upstream.ParentSpanID != server.SpanID || upstream.Kind != SpanClient {
+		t.Errorf("upstream span below %s as kind %d, want below %s", upstream.ParentSpanID, upstream.Kind, server.SpanID)
+	}
+	// the app continues the trace below the upstream span
+	if want := "00-" + traceID + "-" + upstream.SpanID + "-01"; appTraceparent != want {
+		t.Errorf("the app got traceparent %q, want %q", appTraceparent, want)
+	}
+}
+
+func TestStartRemoteInvalidTraceparent(t *testing.T) {
+	tracer := &Tracer{}
+	for _, traceparent := range []string{"", "00-xyz-00f067aa0ba902b7-01", "00-" + strings.Repeat("0", 32) + "-00f067aa0ba902b7-01"} {
+		span := tracer.StartRemote("GET /", SpanServer, traceparent)
+		if span.parentID != "" || len(span.traceID) != 32 {
+			t.Errorf("%q: trace %s below %q, want a new trace", traceparent, span.traceID, span.parentID)
+		}
+	}
+}
+
+func TestNilTracer(t *testing.T) {
+	var tracer *Tracer
+	span := tracer.Start("build", SpanInternal, time.Now())
+	span.SetAttribute("ok", true)
+	span.Child("compile", SpanInternal).End()
+	span.End()
+	tracer.Close(time.Second)
+	if span.Traceparent() != "" {
+		t.Errorf("nil span has traceparent %q", span.Traceparent())
+	}
+}
@@ -0,0 +1,190 @@
+package gin
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PrefixWriter writes each line of the output to w preceded by a prefix and,
+// optionally, a timestamp. Partial lines are passed through right away.
+// Colors the output leaves set at the end of a line are reset for the prefix
+// and set again after it, so they neither bleed into the prefix nor get lost.
+type PrefixWriter struct {
+	w          io.Writer
+	prefix     string
+	timestamps bool
+
+	mu        sync.Mutex
+	lineStart bool
+	// escape is the unfinished escape sequence at the end of the last write
+	escape []byte
+	// colors are the SGR sequences in effect since the last reset
+	colors []byte
+}
+
+// NewPrefixWriter creates a PrefixWriter, the prefix may contain color codes
+func NewPrefixWriter(w io.Writer, prefix string, timestamps bool) *PrefixWriter {
+	return &PrefixWriter{w: w, prefix: prefix, timestamps: timestamps, lineStart: true}
+}
+
+func (pw *PrefixWriter) Write(p []byte) (int, error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	out := make([]byte, 0, len(p)+len(pw.prefix)+16)
+	for _, b := range p {
+		if pw.lineStart {
+			if len(pw.colors) > 0 {
+				out = append(out, sgrReset...)
+			}
+			if pw.timestamps {
+				out = append(out, time.Now().Format("15:04:05.000 ")...)
+			}
+			out = append(out, pw.prefix...)
+			out = append(out, pw.colors...)
+			pw.lineStart = false
+		}
+		out = append(out, b)
+		pw.track(b)
+		if b == '\n' {
+			pw.lineStart = true
+		}
+	}
+
+	if _, err := pw.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// sgrReset resets all colors and text attributes of a terminal
+const sgrReset = "\x1b[0m"
+
+// track follows the escape sequences in the output to know the colors in
+// effect
+func (pw *PrefixWriter) track(b byte) {
+	switch {
+	case b == 0x1b:
+		pw.escape = append(pw.escape[:0], b)
+	case len(pw.escape) == 1:
+		if b == '[' {
+			pw.escape = append(pw.escape, b)
+		} else {
+			pw.escape = pw.escape[:0]
+		}
+	case len(pw.escape) > 1:
+		pw.escape = append(pw.escape, b)
+		if b < 0x40 || b > 0x7e {
+			// parameters of a control sequence, cap runaway ones
+			if len(pw.escape) > 64 {
+				pw.escape = pw.escape[:0]
+			}
+			return
+		}
+		if b == 'm' {
+			if params := string(pw.escape[2 : len(pw.escape)-1]); params == "" || params == "0" {
+				pw.colors = pw.colors[:0]
+			} else {
+				pw.colors = append(pw.colors, pw.escape...)
+			}
+		}
+		pw.escape = pw.escape[:0]
+	}
+}
+
+// ColorTerminal reports whether f is a terminal which shows colors. NO_COLOR
+// turns colors off, FORCE_COLOR or CLICOLOR_FORCE turn them on even if f is
+// no terminal.
+func ColorTerminal(f *os.File) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	if force := os.Getenv("FORCE_COLOR"); force != "" && force != "0" {
+		return true
+	}
+	if force := os.Getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		return true
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// RotatingFile is a log file which is renamed to path.1, path.2 and so on
+// once it grows beyond maxSize, keeping keep old files
+type RotatingFile struct {
+	path    string
+	maxSize int64
+	keep    int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewRotatingFile opens path for appending. A maxSize of 0 never rotates.
+func NewRotatingFile(path string, maxSize int64, keep int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxSize: maxSize, keep: keep}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *RotatingFile) rotate() error {
+	f.file.Close()
+
+	if f.keep > 0 {
+		for i := f.keep - 1; i >= 1; i-- {
+			os.Rename(f.path+"."+strconv.Itoa(i), f.path+"."+strconv.Itoa(i+1))
+		}
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return err
+	}
+
+	return f.open()
+}
@@ -0,0 +1,204 @@
+package gin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Colors used when reformatting stack traces
+const (
+	panicColorBold  = "\033[1m"
+	panicColorRed   = "\033[31;1m"
+	panicColorDim   = "\033[2m"
+	panicColorCyan  = "\033[36m"
+	panicColorReset = "\033[0m"
+)
+
+// maxPanicLines bounds the size of a recorded stack trace
+const maxPanicLines = 500
+
+// fileLine matches the location lines of a stack trace, e.g.
+// "	/home/me/app/main.go:12 +0x1d"
+var fileLine = regexp.MustCompile(`^\t(.+\.go):(\d+)( \+0x[0-9a-f]+)?$`)
+
+// Panics remembers the last panic of the app
+type Panics struct {
+	mu    sync.Mutex
+	trace string
+	time  time.Time
+}
+
+// Last returns the last recorded stack trace and when it was printed
+func (p *Panics) Last() (string, time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.trace, p.time
+}
+
+// Clear forgets the last panic, e.g. after a rebuild
+func (p *Panics) Clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.trace = ""
+	p.time = time.Time{}
+}
+
+// String describes the last panic for the browser
+func (p *Panics) String() string {
+	trace, at := p.Last()
+	if trace == "" {
+		return ""
+	}
+	return fmt.Sprintf("The app crashed at %s:\n\n%s\n", at.Format("15:04:05"), trace)
+}
+
+func (p *Panics) set(lines []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.trace = strings.Join(lines, "\n")
+	p.time = time.Now()
+}
+
+// PanicWriter passes the stderr of the app through to w, detecting Go panics
+// and fatal errors. Their stack traces are recorded in panics and printed
+// with colors and paths relative to wd.
+type PanicWriter struct {
+	w      io.Writer
+	panics *Panics
+	wd     string
+	links  string
+
+	buf       []byte
+	capturing bool
+	trace     []string
+}
+
+// NewPanicWriter creates a PanicWriter. links selects the hyperlinks added to
+// file locations in traces: "file" for file:// links, "vscode" to open
+// VS Code at the line, or "" for none.
+func NewPanicWriter(w io.Writer, panics *Panics, wd string, links string) *PanicWriter {
+	return &PanicWriter{w: w, panics: panics, wd: wd, links: links}
+}
+
+func (pw *PanicWriter) Write(p []byte) (int, error) {
+	pw.buf = append(pw.buf, p...)
+
+	var out bytes.Buffer
+	for {
+		i := bytes.IndexByte(pw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(pw.buf[:i])
+		pw.buf = pw.buf[i+1:]
+		out.WriteString(pw.line(line))
+		out.WriteByte('\n')
+	}
+
+	// don't hold back output that is unlikely to ever end in a newline
+	if !pw.capturing && len(pw.buf) > maxReadyLine {
+		out.Write(pw.buf)
+		pw.buf = nil
+	}
+
+	if out.Len() > 0 {
+		if _, err := pw.w.Write(out.Bytes()); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// line returns the formatted line, starting or ending a trace as needed
+func (pw *PanicWriter) line(line string) string {
+	if isPanicStart(line) {
+		pw.capturing = true
+		pw.trace = nil
+	} else if pw.capturing && !isTraceLine(line) {
+		pw.capturing = false
+	}
+	if !pw.capturing {
+		return line
+	}
+
+	plain, formatted := pw.format(line)
+	if len(pw.trace) < maxPanicLines {
+		pw.trace = append(pw.trace, plain)
+		pw.panics.set(pw.trace)
+	}
+	return formatted
+}
+
+// format returns the trace line with relative paths, once plain and once
+// for the terminal
+func (pw *PanicWriter) format(line string) (string, string) {
+	switch {
+	case isPanicStart(line):
+		return line, panicColorRed + line + panicColorReset
+	case strings.HasPrefix(line, "goroutine "):
+		return line, panicColorBold + line + panicColorReset
+	}
+
+	m := fileLine.FindStringSubmatch(line)
+	if m == nil {
+		return line, line
+	}
+
+	path := m[1]
+	if rel, err := filepath.Rel(pw.wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		path = rel
+	}
+	location := path + ":" + m[2]
+	plain := "\t" + location
+
+	formatted := panicColorCyan + location + panicColorReset
+	if link := pw.link(m[1], m[2]); link != "" {
+		// OSC 8 hyperlink, shown as a link by iTerm2, VS Code and others
+		formatted = "\033]8;;" + link + "\033\\" + formatted + "\033]8;;\033\\"
+	}
+	if m[3] != "" {
+		formatted += panicColorDim + m[3] + panicColorReset
+	}
+	return plain, "\t" + formatted
+}
+
+func (pw *PanicWriter) link(path string, line string) string {
+	path = filepath.ToSlash(path)
+	switch pw.links {
+	case "file":
+		return (&url.URL{Scheme: "file", Path: path}).String()
+	case "vscode":
+		return "vscode://file" + (&url.URL{Path: path}).EscapedPath() + ":" + line
+	}
+	return ""
+}
+
+// isPanicStart reports whether line starts a Go stack trace
+func isPanicStart(line string) bool {
+	return strings.HasPrefix(line, "panic: ") ||
+		strings.HasPrefix(line, "fatal error: ") ||
+		strings.Contains(line, "http: panic serving ")
+}
+
+// isTraceLine reports whether line can be part of a Go stack trace
+func isTraceLine(line string) bool {
+	switch {
+	case line == "",
+		strings.HasPrefix(line, "\t"),
+		strings.HasPrefix(line, "goroutine "),
+		strings.HasPrefix(line, "created by "),
+		strings.HasPrefix(line, "[signal "),
+		strings.HasPrefix(line, "panic: "),
+		strings.HasPrefix(line, "...additional frames elided..."),
+		strings.HasSuffix(line, ")"):
+		return true
+	}
+	return false
+}
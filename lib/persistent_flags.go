@@ -0,0 +1,134 @@
+package gin
+
+import (
+	"flag"
+	"strings"
+)
+
+// liftFlags returns arguments with the app flags given after the command
+// moved in front of it, all of them with PersistentFlags, otherwise only
+// --profile if the app has a config file. set holds the parsed app flags.
+func (a *App) liftFlags(arguments []string, set *flag.FlagSet) ([]string, bool) {
+	if a.PersistentFlags {
+		if lifted, ok := a.liftPersistentFlags(arguments, set); ok {
+			return lifted, true
+		}
+	}
+	if a.ConfigFile != "" {
+		return liftProfileFlag(arguments, set.Args())
+	}
+	return nil, false
+}
+
+// liftPersistentFlags moves the app flags given after the command, e.g. in
+// gin run --port 3000 ./cmd/server, in front of the command, where the app
+// parses them. Flags of the command, or of the subcommand once its name was
+// given, win over app flags of the same name. Like flag parsing, lifting
+// stops at the first argument, and a -- or a command that skips flag
+// parsing, so the arguments left for the action keep their flags.
+func (a *App) liftPersistentFlags(arguments []string, set *flag.FlagSet) ([]string, bool) {
+	args := set.Args()
+	if len(args) < 2 {
+		return nil, false
+	}
+	command := a.command(args[0])
+	if command == nil || command.SkipFlagParsing {
+		return nil, false
+	}
+	commandSet := command.persistentFlagSet()
+
+	var lifted []string
+	kept := []string{args[0]}
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			kept = append(kept, args[i:]...)
+			break
+		}
+
+		name, value, ok := parseFlagArg(arg)
+		if !ok {
+			sub := command.subcommand(arg)
+			if sub == nil || sub.SkipFlagParsing {
+				kept = append(kept, args[i:]...)
+				break
+			}
+			command, commandSet = sub, sub.persistentFlagSet()
+			kept = append(kept, arg)
+			continue
+		}
+
+		// the value of the flag is the next argument unless given with =
+		target := &kept
+		f := commandSet.Lookup(name)
+		needsValue := f != nil && !value && !isBoolFlag(f)
+		if f == nil {
+			if f = set.Lookup(name); f != nil {
+				needsValue = !value && !isBoolFlag(f)
+				// a missing value is left for the command to report,
+				// rather than taking the command name in front of it
+				if !needsValue || i+1 < len(args) {
+					target = &lifted
+				}
+			}
+		}
+		*target = append(*target, arg)
+		if needsValue && i+1 < len(args) {
+			i++
+			*target = append(*target, args[i])
+		}
+	}
+	if len(lifted) == 0 {
+		return nil, false
+	}
+
+	head := arguments[:len(arguments)-len(args)]
+	result := append(append([]string{}, head...), lifted...)
+	return append(result, kept...), true
+}
+
+// persistentFlagSet returns the flags the command parses itself, including
+// the help flag
+func (c *Command) persistentFlagSet() *flag.FlagSet {
+	flags := c.Flags
+	if !c.HideHelp && (HelpFlag != BoolFlag{}) {
+		flags = append(append([]Flag{}, flags...), HelpFlag)
+	}
+	set, err := flagSet(c.Name, flags)
+	if err != nil {
+		return flag.NewFlagSet(c.Name, flag.ContinueOnError)
+	}
+	return set
+}
+
+// subcommand returns the subcommand called name, nil if there is none
+func (c *Command) subcommand(name string) *Command {
+	for _, sub := range c.Subcommands {
+		if sub.hasName(name) {
+			return &sub
+		}
+	}
+	return nil
+}
+
+// parseFlagArg returns the name of the flag arg, e.g. port for --port=3000,
+// whether it includes its value and false if arg isn't a flag
+func parseFlagArg(arg string) (string, bool, bool) {
+	if len(arg) < 2 || arg[0] != '-' {
+		return "", false, false
+	}
+	name := strings.TrimPrefix(arg[1:], "-")
+	if name == "" || name[0] == '-' || name[0] == '=' {
+		return "", false, false
+	}
+	if i := strings.Index(name, "="); i >= 0 {
+		return name[:i], true, true
+	}
+	return name, false, true
+}
+
+// isBoolFlag reports whether f is given without a value
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
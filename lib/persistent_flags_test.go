@@ -0,0 +1,79 @@
+package gin
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestLiftPersistentFlags(t *testing.T) {
+	app := &App{
+		Name: "gin",
+		Flags: []Flag{
+			IntFlag{Name: "port,p"},
+			BoolFlag{Name: "immediate,i"},
+			StringFlag{Name: "config"},
+		},
+		Commands: []Command{
+			{Name: "run", Flags: []Flag{BoolFlag{Name: "immediate"}}},
+			{
+				Name: "cache",
+				Subcommands: []Command{
+					{Name: "clean", Flags: []Flag{BoolFlag{Name: "all"}}},
+				},
+			},
+			{Name: "exec", SkipFlagParsing: true},
+		},
+	}
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{
+			[]string{"gin", "run", "--port", "4000", "./cmd/server"},
+			[]string{"gin", "--port", "4000", "run", "./cmd/server"},
+		},
+		// flags of the command win over the app's
+		{
+			[]string{"gin", "run", "-p=4000", "--immediate", "-i"},
+			[]string{"gin", "-p=4000", "-i", "run", "--immediate"},
+		},
+		// the arguments of the app keep their flags
+		{
+			[]string{"gin", "run", "./cmd/server", "--port", "4000"},
+			nil,
+		},
+		{
+			[]string{"gin", "run", "--", "--config", "app.yaml"},
+			nil,
+		},
+		{
+			[]string{"gin", "cache", "clean", "--all", "--port", "4000"},
+			[]string{"gin", "--port", "4000", "cache", "clean", "--all"},
+		},
+		{
+			[]string{"gin", "exec", "--port", "4000"},
+			nil,
+		},
+		// a missing value is left for the command to report
+		{
+			[]string{"gin", "run", "--port"},
+			nil,
+		},
+	}
+	for _, test := range tests {
+		set := flag.NewFlagSet(app.Name, flag.ContinueOnError)
+		for _, f := range app.Flags {
+			f.Apply(set)
+		}
+		if err := set.Parse(test.args[1:]); err != nil {
+			t.Fatal(err)
+		}
+
+		got, ok := app.liftPersistentFlags(test.args, set)
+		if ok != (test.want != nil) || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("liftPersistentFlags(%q) = %q, %v, want %q", test.args, got, ok, test.want)
+		}
+	}
+}
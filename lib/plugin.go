@@ -0,0 +1,256 @@
+package gin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PluginPrefix starts the names of plugin executables, e.g. gin-assets for
+// the plugin assets
+const PluginPrefix = "gin-"
+
+// PluginProtocolEnv is set to the version of the protocol when gin starts a
+// plugin to send it lifecycle events, so the plugin can tell that apart
+// from being run as a subcommand
+const PluginProtocolEnv = "GIN_PLUGIN_PROTOCOL"
+
+// PluginProtocol is the version of the protocol between gin and plugins
+const PluginProtocol = "1"
+
+// Lifecycle events sent to plugins
+const (
+	PluginStart       = "start"
+	PluginChange      = "change"
+	PluginBuildStart  = "build_start"
+	PluginBuildFinish = "build_finish"
+	PluginStop        = "stop"
+)
+
+// pluginQueue is the number of events buffered for a plugin which doesn't
+// keep up, further ones are dropped
+const pluginQueue = 64
+
+// PluginEvent is written to the stdin of plugins as a line of JSON
+type PluginEvent struct {
+	Event string `json:"event"`
+	// Name of the project
+	Name    string   `json:"name,omitempty"`
+	URLs    []string `json:"urls,omitempty"`
+	AppPort string   `json:"app_port,omitempty"`
+	// Changed are the changed files, relative to the working directory
+	Changed []string `json:"changed,omitempty"`
+	Cause   string   `json:"cause,omitempty"`
+	Failed  bool     `json:"failed,omitempty"`
+	Errors  string   `json:"errors,omitempty"`
+	// Duration of the build in milliseconds
+	Duration int64 `json:"duration_ms,omitempty"`
+}
+
+// PluginMessage is read from the stdout of plugins, a line of JSON each.
+// Other lines are logged as they are.
+type PluginMessage struct {
+	// Subscribe limits the events sent to the plugin, all by default. The
+	// stop event is always sent.
+	Subscribe []string `json:"subscribe,omitempty"`
+	// Log is logged by gin
+	Log string `json:"log,omitempty"`
+	// Rebuild asks gin to rebuild and restart the app
+	Rebuild bool `json:"rebuild,omitempty"`
+}
+
+// FindPlugins returns the executables named PluginPrefix+name in the
+// directories of path, e.g. $PATH, keyed by name. Earlier directories win.
+func FindPlugins(path string) map[string]string {
+	plugins := make(map[string]string)
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !strings.HasPrefix(name, PluginPrefix) || entry.IsDir() {
+				continue
+			}
+			if runtime.GOOS == "windows" {
+				ext := strings.ToLower(filepath.Ext(name))
+				if ext != ".exe" && ext != ".bat" && ext != ".cmd" {
+					continue
+				}
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			} else if info, err := entry.Info(); err != nil || info.Mode()&0111 == 0 {
+				continue
+			}
+
+			name = strings.TrimPrefix(name, PluginPrefix)
+			if _, ok := plugins[name]; !ok && name != "" {
+				plugins[name] = filepath.Join(dir, entry.Name())
+			}
+		}
+	}
+	return plugins
+}
+
+// PluginNames returns the sorted names of plugins
+func PluginNames(plugins map[string]string) []string {
+	var names []string
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Plugin is a plugin running alongside gin, receiving its lifecycle events
+type Plugin struct {
+	Name string
+	// Log receives the messages and other output of the plugin
+	Log func(message string)
+	// Rebuild is called when the plugin asks for a rebuild
+	Rebuild func()
+
+	command []string
+	cmd     *exec.Cmd
+	events  chan PluginEvent
+	done    chan struct{}
+
+	mu         sync.Mutex
+	subscribed map[string]bool
+	closed     bool
+}
+
+// NewPlugin creates the plugin name started with the command line command
+func NewPlugin(name string, command []string) *Plugin {
+	return &Plugin{
+		Name:    name,
+		Log:     func(message string) { Infof("[%s] %s", name, message) },
+		Rebuild: func() {},
+		command: command,
+		events:  make(chan PluginEvent, pluginQueue),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start starts the plugin with PluginProtocolEnv set
+func (p *Plugin) Start() error {
+	p.cmd = exec.Command(p.command[0], p.command[1:]...)
+	p.cmd.Env = append(os.Environ(), PluginProtocolEnv+"="+PluginProtocol)
+	p.cmd.Stderr = os.Stderr
+	stdin, err := p.cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := p.cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	traceCommand(p.command[0], p.command[1:]...)
+	if err := p.cmd.Start(); err != nil {
+		return fmt.Errorf("can't start the plugin %s: %v", p.Name, err)
+	}
+
+	go p.write(stdin)
+	go func() {
+		// Wait closes stdout, so it must be read first
+		p.read(stdout)
+		err := p.cmd.Wait()
+		close(p.done)
+		if err != nil {
+			p.Log(fmt.Sprintf("exited: %v", err))
+		} else {
+			Verbosef("The plugin %s exited", p.Name)
+		}
+	}()
+	return nil
+}
+
+// Send queues event for the plugin unless it didn't subscribe to it
+func (p *Plugin) Send(event PluginEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || event.Event != PluginStop && p.subscribed != nil && !p.subscribed[event.Event] {
+		return
+	}
+
+	select {
+	case p.events <- event:
+	case <-p.done:
+	default:
+		Verbosef("Dropping the %s event, the plugin %s doesn't keep up", event.Event, p.Name)
+	}
+}
+
+// Close sends the stop event and waits up to timeout for the plugin to
+// exit before killing it
+func (p *Plugin) Close(timeout time.Duration) error {
+	if p.cmd == nil || p.cmd.Process == nil {
+		return nil
+	}
+	p.Send(PluginEvent{Event: PluginStop})
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.events)
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-p.done:
+		return nil
+	case <-time.After(timeout):
+		return p.cmd.Process.Kill()
+	}
+}
+
+// write writes the queued events to the stdin of the plugin, closing it
+// once the plugin is closed
+func (p *Plugin) write(stdin io.WriteCloser) {
+	defer stdin.Close()
+	enc := json.NewEncoder(stdin)
+	for event := range p.events {
+		if err := enc.Encode(event); err != nil {
+			Verbosef("Can't send the %s event to the plugin %s: %v", event.Event, p.Name, err)
+		}
+	}
+}
+
+// read handles the messages of the plugin
+func (p *Plugin) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var msg PluginMessage
+		if !strings.HasPrefix(strings.TrimSpace(line), "{") || json.Unmarshal([]byte(line), &msg) != nil {
+			p.Log(line)
+			continue
+		}
+
+		if msg.Subscribe != nil {
+			p.mu.Lock()
+			p.subscribed = make(map[string]bool)
+			for _, event := range msg.Subscribe {
+				p.subscribed[event] = true
+			}
+			p.mu.Unlock()
+		}
+		if msg.Log != "" {
+			p.Log(msg.Log)
+		}
+		if msg.Rebuild {
+			p.Rebuild()
+		}
+	}
+}
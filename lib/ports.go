@@ -0,0 +1,69 @@
+package gin
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+)
+
+// PortInUseError reports that another process is listening on a port gin
+// needs. PID and Name are set when the owning process could be determined.
+type PortInUseError struct {
+	Addr string
+	PID  int
+	Name string
+	Err  error
+}
+
+func (e *PortInUseError) Error() string {
+	msg := "address " + e.Addr + " is already in use"
+	if e.PID != 0 {
+		msg += " by "
+		if e.Name != "" {
+			msg += e.Name + " "
+		}
+		msg += fmt.Sprintf("(pid %d)", e.PID)
+	}
+	return msg
+}
+
+func (e *PortInUseError) Unwrap() error {
+	return e.Err
+}
+
+// FreePort returns a TCP port nobody is listening on
+func FreePort() (int, error) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// CheckPort returns a *PortInUseError if another process listens on addr
+func CheckPort(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return portInUse(addr, err)
+	}
+	return listener.Close()
+}
+
+// portInUse turns err into a *PortInUseError naming the process listening on
+// addr if err is caused by the address being in use.
+func portInUse(addr string, err error) error {
+	if !errors.Is(err, syscall.EADDRINUSE) {
+		return err
+	}
+
+	e := &PortInUseError{Addr: addr, Err: err}
+	if _, p, serr := net.SplitHostPort(addr); serr == nil {
+		if port, perr := strconv.Atoi(p); perr == nil {
+			e.PID, e.Name = portOwner(port)
+		}
+	}
+	return e
+}
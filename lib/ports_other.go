@@ -0,0 +1,32 @@
+//go:build !linux
+// +build !linux
+
+package gin
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// portOwner asks lsof for the process listening on port, if it is installed
+func portOwner(port int) (int, string) {
+	out, err := exec.Command("lsof", "-nP", "-iTCP:"+strconv.Itoa(port), "-sTCP:LISTEN", "-Fpc").Output()
+	if err != nil {
+		return 0, ""
+	}
+
+	var (
+		pid  int
+		name string
+	)
+	for _, line := range strings.Split(string(out), "\n") {
+		switch {
+		case strings.HasPrefix(line, "p") && pid == 0:
+			pid, _ = strconv.Atoi(line[1:])
+		case strings.HasPrefix(line, "c") && name == "":
+			name = line[1:]
+		}
+	}
+	return pid, name
+}
@@ -0,0 +1,82 @@
+package gin
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// PprofPath is where net/http/pprof registers its handlers
+const PprofPath = "/debug/pprof/"
+
+// profiles maps the profile names accepted by FetchProfile to their path
+// below PprofPath
+var profiles = map[string]string{
+	"cpu":       "profile",
+	"heap":      "heap",
+	"allocs":    "allocs",
+	"goroutine": "goroutine",
+	"block":     "block",
+	"mutex":     "mutex",
+	"trace":     "trace",
+}
+
+// ServePprof serves the pprof handlers of the app at target on addr, so
+// profiles can be taken on a dedicated port while the app is proxied.
+// The app must import net/http/pprof.
+func ServePprof(addr string, target string) (net.Listener, error) {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(PprofPath, httputil.NewSingleHostReverseProxy(targetURL))
+	mux.Handle("/", http.RedirectHandler(PprofPath, http.StatusFound))
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	go http.Serve(listener, mux)
+	return listener, nil
+}
+
+// FetchProfile downloads the named profile, e.g. cpu or heap, from the pprof
+// handlers of the app at target. seconds applies to the cpu profile and
+// execution trace.
+func FetchProfile(target string, name string, seconds int, w io.Writer) error {
+	path, ok := profiles[name]
+	if !ok {
+		var names []string
+		for n := range profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown profile %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+
+	profileURL := strings.TrimSuffix(target, "/") + PprofPath + path
+	if name == "cpu" || name == "trace" {
+		profileURL += fmt.Sprintf("?seconds=%d", seconds)
+	}
+
+	res, err := http.Get(profileURL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s: %s: %s (does the app import net/http/pprof?)", profileURL, res.Status, strings.TrimSpace(string(body)))
+	}
+
+	_, err = io.Copy(w, res.Body)
+	return err
+}
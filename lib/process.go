@@ -0,0 +1,60 @@
+package gin
+
+import (
+	"log"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// ProcessOptions restrict the child process so a leaky dev server can't take
+// down the machine
+type ProcessOptions struct {
+	// Dir is the working directory of the child, empty inherits gin's
+	Dir string
+	// Umask of the child, negative inherits gin's
+	Umask int
+	// Nice is the scheduling priority of the child, 0 inherits gin's
+	Nice int
+	// MemoryLimit caps the address space of the child in bytes (Linux only)
+	MemoryLimit int64
+	// CPULimit is the CPU time after which the child is killed (Linux only)
+	CPULimit time.Duration
+	// ProcessGroup starts the child in its own process group, so processes
+	// it spawns are stopped along with it
+	ProcessGroup bool
+}
+
+// DefaultProcessOptions are the ProcessOptions of new runners
+var DefaultProcessOptions = ProcessOptions{Umask: -1, ProcessGroup: true}
+
+// start starts command with the options applied
+func (o ProcessOptions) start(command *exec.Cmd) error {
+	if o.Dir != "" {
+		command.Dir = o.Dir
+	}
+
+	if o.Nice != 0 {
+		if nice, err := exec.LookPath("nice"); err != nil {
+			log.Print("Can't set the nice level: ", err)
+		} else {
+			command.Args = append([]string{"nice", "-n", strconv.Itoa(o.Nice), command.Path}, command.Args[1:]...)
+			command.Path = nice
+		}
+	}
+
+	if o.ProcessGroup {
+		setProcessGroup(command)
+	}
+
+	if err := startWithUmask(command, o.Umask); err != nil {
+		return err
+	}
+
+	if o.MemoryLimit > 0 || o.CPULimit > 0 {
+		if err := setLimits(command.Process.Pid, o.MemoryLimit, o.CPULimit); err != nil {
+			log.Print("Can't limit resources: ", err)
+		}
+	}
+	return nil
+}
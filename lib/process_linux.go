@@ -0,0 +1,41 @@
+//go:build linux
+// +build linux
+
+package gin
+
+import (
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// setLimits sets the address space and CPU time limits of the running
+// process pid. Limits survive exec, so it doesn't matter whether the process
+// is still the nice wrapper.
+func setLimits(pid int, memory int64, cpu time.Duration) error {
+	if memory > 0 {
+		if err := prlimit(pid, syscall.RLIMIT_AS, uint64(memory)); err != nil {
+			return err
+		}
+	}
+	if cpu > 0 {
+		seconds := uint64(cpu / time.Second)
+		if seconds == 0 {
+			seconds = 1
+		}
+		if err := prlimit(pid, syscall.RLIMIT_CPU, seconds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func prlimit(pid int, resource int, limit uint64) error {
+	rlimit := syscall.Rlimit{Cur: limit, Max: limit}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource), uintptr(unsafe.Pointer(&rlimit)), 0, 0, 0)
+	if errno != 0 {
+		return os.NewSyscallError("prlimit", errno)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package gin
+
+import (
+	"errors"
+	"time"
+)
+
+func setLimits(pid int, memory int64, cpu time.Duration) error {
+	return errors.New("memory and CPU limits are only supported on Linux")
+}
@@ -0,0 +1,120 @@
+//go:build !windows
+// +build !windows
+
+package gin
+
+import (
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+var umaskMu sync.Mutex
+
+// startWithUmask starts command with the given umask. The umask is process
+// wide, so gin's own is swapped for the duration of the fork.
+func startWithUmask(command *exec.Cmd, umask int) error {
+	if umask < 0 {
+		return command.Start()
+	}
+
+	umaskMu.Lock()
+	defer umaskMu.Unlock()
+
+	old := syscall.Umask(umask)
+	defer syscall.Umask(old)
+	return command.Start()
+}
+
+func setProcessGroup(command *exec.Cmd) {
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	command.SysProcAttr.Setpgid = true
+}
+
+// detachProcess starts command in a new session, so it keeps running when
+// the terminal it was started from closes
+func detachProcess(command *exec.Cmd) {
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	command.SysProcAttr.Setsid = true
+}
+
+// processAlive reports whether a process with pid exists
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// interruptProcess asks process, or its whole process group, to stop
+func interruptProcess(process *os.Process, group bool) error {
+	if group {
+		return syscall.Kill(-process.Pid, syscall.SIGINT)
+	}
+	return process.Signal(os.Interrupt)
+}
+
+// signalProcess sends sig to process, or to its whole process group
+func signalProcess(process *os.Process, group bool, sig os.Signal) error {
+	if group {
+		if s, ok := sig.(syscall.Signal); ok {
+			return syscall.Kill(-process.Pid, s)
+		}
+	}
+	return process.Signal(sig)
+}
+
+// killProcess kills process, or every process left in its process group
+func killProcess(process *os.Process, group bool) error {
+	if group {
+		err := syscall.Kill(-process.Pid, syscall.SIGKILL)
+		if err == syscall.ESRCH {
+			return nil
+		}
+		return err
+	}
+	return process.Kill()
+}
+
+// exitCode returns the exit code of a process, or 128 plus the number of
+// the signal which killed it
+func exitCode(state *os.ProcessState) int {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return state.ExitCode()
+}
+
+// signals are the signals which can be sent to the app, by name without
+// the SIG prefix
+var signals = map[string]syscall.Signal{
+	"HUP":   syscall.SIGHUP,
+	"INT":   syscall.SIGINT,
+	"QUIT":  syscall.SIGQUIT,
+	"KILL":  syscall.SIGKILL,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"TERM":  syscall.SIGTERM,
+	"CONT":  syscall.SIGCONT,
+	"STOP":  syscall.SIGSTOP,
+	"TSTP":  syscall.SIGTSTP,
+	"WINCH": syscall.SIGWINCH,
+}
+
+// ForwardedSignals are passed on to the app when gin receives them, they
+// ask apps to reload their config or dump their state
+var ForwardedSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2}
+
+// HasTerminal reports whether gin has a controlling terminal, which it
+// loses when the terminal is closed
+func HasTerminal() bool {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return false
+	}
+	tty.Close()
+	return true
+}
@@ -0,0 +1,79 @@
+package gin
+
+import (
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// startWithUmask starts command, Windows has no umask
+func startWithUmask(command *exec.Cmd, umask int) error {
+	if umask >= 0 {
+		log.Print("Ignoring the umask, Windows has none")
+	}
+	return command.Start()
+}
+
+// setProcessGroup does nothing, killProcess stops the process tree instead
+func setProcessGroup(command *exec.Cmd) {}
+
+// detachedProcess is the DETACHED_PROCESS creation flag, missing in syscall
+const detachedProcess = 0x00000008
+
+// detachProcess starts command without a console, so it keeps running when
+// the one it was started from closes
+func detachProcess(command *exec.Cmd) {
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	command.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess
+}
+
+// processAlive reports whether a process with pid exists
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
+
+// interruptProcess kills process, Windows can't deliver an interrupt to it
+func interruptProcess(process *os.Process, group bool) error {
+	return killProcess(process, group)
+}
+
+// signalProcess fails, Windows can't deliver signals to processes
+func signalProcess(process *os.Process, group bool, sig os.Signal) error {
+	return errors.New("signals aren't supported on Windows")
+}
+
+// killProcess kills process, and with group the processes it started
+func killProcess(process *os.Process, group bool) error {
+	if group {
+		if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(process.Pid)).Run(); err == nil {
+			return nil
+		}
+	}
+	return process.Kill()
+}
+
+// exitCode returns the exit code of a process
+func exitCode(state *os.ProcessState) int {
+	return state.ExitCode()
+}
+
+// signals is empty, Windows has no signals to send to the app
+var signals = map[string]syscall.Signal{}
+
+// ForwardedSignals is empty, Windows has no signals to forward
+var ForwardedSignals []os.Signal
+
+// HasTerminal reports false, Windows consoles don't hang up like terminals
+func HasTerminal() bool {
+	return false
+}
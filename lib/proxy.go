@@ -1,31 +1,118 @@
 package gin
 
 import (
+	"bufio"
 	"crypto/tls"
 	"fmt"
 	"io"
+	"log"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Proxy struct {
-	listener net.Listener
-	proxy    *httputil.ReverseProxy
-	builder  Builder
-	runner   Runner
-	to       *url.URL
+	listeners  []net.Listener
+	urls       []string
+	proxy      *httputil.ReverseProxy
+	builder    Builder
+	runner     Runner
+	to         *url.URL
+	stats      *RequestStats
+	middleware []Middleware
+	panics     *Panics
+	crashLoop  *CrashLoop
+	routes     []upstream
+	hosts      map[string]upstream
+	transport  TransportOptions
+	health     func() interface{}
+	guards     []Middleware
+	stale      bool
+}
+
+// upstream is a Route or Host with the proxy forwarding to it, nil for the
+// app
+type upstream struct {
+	Route
+	proxy *httputil.ReverseProxy
+}
+
+func newUpstream(route Route, transport http.RoundTripper) (upstream, error) {
+	u := upstream{Route: route}
+	if route.Upstream == "" {
+		return u, nil
+	}
+	to, err := url.Parse(route.Upstream)
+	if err != nil {
+		return u, err
+	}
+	u.proxy = httputil.NewSingleHostReverseProxy(to)
+	u.proxy.Transport = transport
+	u.proxy.ModifyResponse = runResponseHooks
+	return u, nil
+}
+
+// upstream returns the upstream of a request, nil for the app
+func (p *Proxy) upstream(req *http.Request) *upstream {
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if u, ok := p.hosts[strings.ToLower(host)]; ok {
+		return &u
+	}
+
+	for _, route := range p.routes {
+		if route.matches(req.URL.Path) {
+			return &route
+		}
+	}
+	return nil
 }
 
 func NewProxy(builder Builder, runner Runner) *Proxy {
 	return &Proxy{
 		builder: builder,
 		runner:  runner,
+		stats:   NewRequestStats(),
 	}
 }
 
+// Use appends middleware to the chain wrapping the proxied app. Middleware
+// added first sees requests first. It must be called before Run.
+func (p *Proxy) Use(middleware ...Middleware) {
+	p.middleware = append(p.middleware, middleware...)
+}
+
+// Protect adds middleware which guards every request to the proxy,
+// including the ones for gin's own endpoints, e.g. to require a login. It
+// must be called before Run.
+func (p *Proxy) Protect(middleware ...Middleware) {
+	p.guards = append(p.guards, middleware...)
+}
+
+// ShowPanics makes the proxy respond with the last panic of the app when it
+// can't be reached, e.g. because it crashes on startup
+func (p *Proxy) ShowPanics(panics *Panics) {
+	p.panics = panics
+}
+
+// LimitRestarts makes the proxy start an app in a crash loop again only
+// once its backoff passed, answering 503 Service Unavailable meanwhile,
+// instead of with every request
+func (p *Proxy) LimitRestarts(crashLoop *CrashLoop) {
+	p.crashLoop = crashLoop
+}
+
+// Stats returns the per route stats of the proxied requests
+func (p *Proxy) Stats() *RequestStats {
+	return p.stats
+}
+
 func (p *Proxy) Run(config *Config) error {
 
 	// create our reverse proxy
@@ -33,58 +120,193 @@ func (p *Proxy) Run(config *Config) error {
 	if err != nil {
 		return err
 	}
+	p.transport = config.Transport
+	transport := config.Transport.roundTripper()
 	p.proxy = httputil.NewSingleHostReverseProxy(proxyURL)
+	p.proxy.Transport = transport
+	p.proxy.ErrorHandler = p.proxyError
+	p.proxy.ModifyResponse = runResponseHooks
 	p.to = proxyURL
 
-	server := http.Server{Handler: http.HandlerFunc(p.defaultHandler)}
-
-	if config.CertFile != "" && config.KeyFile != "" {
-		cer, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	p.routes = nil
+	for _, route := range config.routes() {
+		u, err := newUpstream(route, transport)
 		if err != nil {
 			return err
 		}
-
-		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cer}}
-
-		p.listener, err = tls.Listen("tcp", fmt.Sprintf("%s:%d", config.Laddr, config.Port), server.TLSConfig)
+		p.routes = append(p.routes, u)
+	}
+	p.hosts = make(map[string]upstream)
+	for _, host := range config.Hosts {
+		u, err := newUpstream(Route{Prefix: "/", Upstream: host.Upstream}, transport)
 		if err != nil {
 			return err
 		}
-	} else {
-		p.listener, err = net.Listen("tcp", fmt.Sprintf("%s:%d", config.Laddr, config.Port))
+		p.hosts[strings.ToLower(host.Name)] = u
+	}
+
+	// stats cover the whole chain, so they include e.g. injected delays
+	handler := chain(p.serveHealth(p.stats.middleware(chain(http.HandlerFunc(p.defaultHandler), p.middleware))), p.guards)
+	server := http.Server{Handler: handler}
+
+	listeners := config.listeners()
+	if len(config.Sockets) > 0 {
+		listeners = nil
+	}
+	for _, l := range listeners {
+		var tlsConfig *tls.Config
+		if l.TLS {
+			if config.CertFile == "" || config.KeyFile == "" {
+				p.Close()
+				return fmt.Errorf("listener %s requires --certFile and --keyFile", l.URL())
+			}
+			if server.TLSConfig == nil {
+				if server.TLSConfig, err = config.tlsConfig(); err != nil {
+					p.Close()
+					return err
+				}
+			}
+			tlsConfig = server.TLSConfig
+		}
+		sockets, err := l.listen(tlsConfig)
 		if err != nil {
+			p.Close()
 			return err
 		}
+
+		for _, listener := range sockets {
+			p.listeners = append(p.listeners, listener)
+			go server.Serve(listener)
+		}
+		p.urls = append(p.urls, l.URL())
 	}
 
-	go server.Serve(p.listener)
+	// sockets are served with TLS when a certificate is configured
+	for _, socket := range config.Sockets {
+		listener := socket
+		l := Listener{Addr: socket.Addr().String(), TLS: config.CertFile != "" && config.KeyFile != ""}
+		if l.TLS {
+			if server.TLSConfig == nil {
+				if server.TLSConfig, err = config.tlsConfig(); err != nil {
+					p.Close()
+					return err
+				}
+			}
+			listener = tls.NewListener(socket, server.TLSConfig)
+		}
+
+		p.listeners = append(p.listeners, listener)
+		p.urls = append(p.urls, l.URL())
+		go server.Serve(listener)
+	}
 
 	return nil
 }
 
+// URLs returns the addresses the proxy is listening on
+func (p *Proxy) URLs() []string {
+	return p.urls
+}
+
 func (p *Proxy) Close() error {
-	return p.listener.Close()
+	var err error
+	for _, listener := range p.listeners {
+		if cerr := listener.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	p.listeners = nil
+	return err
 }
 
 func (p *Proxy) defaultHandler(res http.ResponseWriter, req *http.Request) {
+	if u := p.upstream(req); u != nil && u.proxy != nil {
+		Tracef("%s %s%s: routing to %s", req.Method, req.Host, req.URL.RequestURI(), u.Upstream)
+		u.proxy.ServeHTTP(res, req)
+		return
+	}
+
 	errors := p.builder.Errors()
+	if len(errors) > 0 && p.stale && p.hasBinary() {
+		Tracef("%s %s: the last build failed, proxying to the app of the build before", req.Method, req.URL.RequestURI())
+		errors = ""
+	}
 	if len(errors) > 0 {
+		Tracef("%s %s: the last build failed, responding with its errors", req.Method, req.URL.RequestURI())
 		res.Write([]byte(errors))
+	} else if p.backingOff(res, req) {
+		return
 	} else {
 		p.runner.Run()
 		if strings.ToLower(req.Header.Get("Upgrade")) == "websocket" || strings.ToLower(req.Header.Get("Accept")) == "text/event-stream" {
-			proxyWebsocket(res, req, p.to)
+			Tracef("%s %s: streaming to %s", req.Method, req.URL.RequestURI(), p.to.Host)
+			proxyWebsocket(res, req, p.to, p.transport)
 		} else {
+			Tracef("%s %s: proxying to %s", req.Method, req.URL.RequestURI(), p.to.Host)
 			p.proxy.ServeHTTP(res, req)
 		}
 	}
 }
 
-func proxyWebsocket(w http.ResponseWriter, r *http.Request, host *url.URL) {
-	d, err := net.Dial("tcp", host.Host)
+// ServeStale makes the proxy pass requests to the app of the last successful
+// build while the latest build fails, instead of responding with the errors
+func (p *Proxy) ServeStale(stale bool) {
+	p.stale = stale
+}
+
+func (p *Proxy) hasBinary() bool {
+	_, err := p.runner.Info()
+	return err == nil
+}
+
+// backingOff answers the request with the state of the crash loop instead
+// of starting the app while it keeps crashing
+func (p *Proxy) backingOff(res http.ResponseWriter, req *http.Request) bool {
+	if p.crashLoop == nil {
+		return false
+	}
+	if r, ok := p.runner.(interface{ ProcessInfo() ProcessInfo }); ok && r.ProcessInfo().Running {
+		return false
+	}
+	wait, retry := p.crashLoop.Wait()
+	if retry && wait == 0 {
+		return false
+	}
+
+	message := fmt.Sprintf("The app exited right after starting %d times in a row, ", p.crashLoop.Count())
+	if retry {
+		seconds := int(wait.Seconds()) + 1
+		Tracef("%s %s: the app is in a crash loop, starting it again in %s", req.Method, req.URL.RequestURI(), wait.Round(time.Millisecond))
+		res.Header().Set("Retry-After", strconv.Itoa(seconds))
+		message += fmt.Sprintf("gin starts it again in %ds at the earliest.", seconds)
+	} else {
+		Tracef("%s %s: the app is in a crash loop, not starting it until the next change", req.Method, req.URL.RequestURI())
+		message += "gin doesn't start it again until the next change."
+	}
+	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	res.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprintln(res, message)
+	if p.panics != nil {
+		if trace := p.panics.String(); trace != "" {
+			fmt.Fprintf(res, "\n%s", trace)
+		}
+	}
+	return true
+}
+
+func (p *Proxy) proxyError(res http.ResponseWriter, req *http.Request, err error) {
+	log.Printf("http: proxy error: %v", err)
+	res.WriteHeader(http.StatusBadGateway)
+	if p.panics != nil {
+		res.Write([]byte(p.panics.String()))
+	}
+}
+
+func proxyWebsocket(w http.ResponseWriter, r *http.Request, host *url.URL, transport TransportOptions) {
+	d, err := transport.dial(host.Host)
 	if err != nil {
 		http.Error(w, "Error contacting backend server.", 500)
-		fmt.Errorf("error dialing websocket backend %s: %v", host, err)
+		log.Printf("error dialing websocket backend %s: %v", host, err)
 		return
 	}
 	hj, ok := w.(http.Hijacker)
@@ -94,7 +316,7 @@ func proxyWebsocket(w http.ResponseWriter, r *http.Request, host *url.URL) {
 	}
 	nc, _, err := hj.Hijack()
 	if err != nil {
-		fmt.Errorf("hijack error: %v", err)
+		log.Printf("hijack error: %v", err)
 		return
 	}
 	defer nc.Close()
@@ -102,7 +324,7 @@ func proxyWebsocket(w http.ResponseWriter, r *http.Request, host *url.URL) {
 
 	err = r.Write(d)
 	if err != nil {
-		fmt.Errorf("error copying request to target: %v", err)
+		log.Printf("error copying request to target: %v", err)
 		return
 	}
 
@@ -115,3 +337,39 @@ func proxyWebsocket(w http.ResponseWriter, r *http.Request, host *url.URL) {
 	go cp(nc, d)
 	<-errc
 }
+
+// statusRecorder remembers the status code written to a ResponseWriter
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T is not a hijacker", r.ResponseWriter)
+	}
+	r.status = http.StatusSwitchingProtocols
+	r.wroteHeader = true
+	return hijacker.Hijack()
+}
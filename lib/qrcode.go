@@ -0,0 +1,538 @@
+package gin
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QR codes are encoded in byte mode with error correction level M, which
+// fits up to 213 bytes in version 10, plenty for URLs. See ISO/IEC 18004.
+const (
+	qrMaxVersion = 10
+	qrQuietZone  = 2
+)
+
+var (
+	// error correction codewords per block and number of blocks of level M
+	// by version
+	qrECCodewords = [qrMaxVersion + 1]int{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26}
+	qrECBlocks    = [qrMaxVersion + 1]int{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5}
+)
+
+// QRCode is a QR code, e.g. of the proxy URL so phones can open it
+type QRCode struct {
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// NewQRCode encodes text in the smallest QR code version it fits in
+func NewQRCode(text string) (*QRCode, error) {
+	data := []byte(text)
+
+	version := 1
+	for ; version <= qrMaxVersion; version++ {
+		if qrBits(version, len(data)) <= qrDataCodewords(version)*8 {
+			break
+		}
+	}
+	if version > qrMaxVersion {
+		return nil, fmt.Errorf("%d bytes are too long for a QR code", len(data))
+	}
+
+	size := version*4 + 17
+	q := &QRCode{size: size, modules: qrGrid(size), function: qrGrid(size)}
+	q.drawFunctionPatterns(version)
+	q.drawCodewords(qrCodewords(version, data))
+
+	best, penalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormatBits(mask)
+		if p := q.penalty(); penalty < 0 || p < penalty {
+			best, penalty = mask, p
+		}
+		q.applyMask(mask)
+	}
+	q.applyMask(best)
+	q.drawFormatBits(best)
+	return q, nil
+}
+
+// Size returns the number of modules per side
+func (q *QRCode) Size() int {
+	return q.size
+}
+
+// Dark reports whether the module at column x and row y is dark
+func (q *QRCode) Dark(x, y int) bool {
+	return x >= 0 && y >= 0 && x < q.size && y < q.size && q.modules[y][x]
+}
+
+// Lines renders the code for terminals with two rows of modules per line.
+// The colors are set explicitly, so the code scans on dark and light
+// terminal themes alike.
+func (q *QRCode) Lines() []string {
+	var lines []string
+	for y := -qrQuietZone; y < q.size+qrQuietZone; y += 2 {
+		var line strings.Builder
+		line.WriteString("\033[97;40m")
+		for x := -qrQuietZone; x < q.size+qrQuietZone; x++ {
+			top, bottom := !q.Dark(x, y), !q.Dark(x, y+1)
+			if y+1 >= q.size+qrQuietZone {
+				bottom = false
+			}
+			switch {
+			case top && bottom:
+				line.WriteString("█")
+			case top:
+				line.WriteString("▀")
+			case bottom:
+				line.WriteString("▄")
+			default:
+				line.WriteString(" ")
+			}
+		}
+		line.WriteString("\033[0m")
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+// QRCodeLines renders QR codes of texts side by side, each below its text
+func QRCodeLines(texts []string) ([]string, error) {
+	var columns [][]string
+	var widths []int
+	for _, text := range texts {
+		q, err := NewQRCode(text)
+		if err != nil {
+			return nil, err
+		}
+		width := q.size + 2*qrQuietZone
+		if len(text) > width {
+			width = len(text)
+		}
+		columns = append(columns, append([]string{text}, q.Lines()...))
+		widths = append(widths, width)
+	}
+
+	var lines []string
+	for row := 0; ; row++ {
+		var line []string
+		done := true
+		for i, column := range columns {
+			cell := ""
+			if row < len(column) {
+				cell = column[row]
+				done = false
+			}
+			line = append(line, cell+strings.Repeat(" ", widths[i]-visibleWidth(cell)))
+		}
+		if done {
+			return lines, nil
+		}
+		lines = append(lines, strings.TrimRight(strings.Join(line, "  "), " "))
+	}
+}
+
+func (q *QRCode) String() string {
+	return strings.Join(q.Lines(), "\n") + "\n"
+}
+
+func qrGrid(size int) [][]bool {
+	grid := make([][]bool, size)
+	for y := range grid {
+		grid[y] = make([]bool, size)
+	}
+	return grid
+}
+
+// qrBits returns the number of bits needed for n bytes in byte mode
+func qrBits(version, n int) int {
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	return 4 + countBits + n*8
+}
+
+// qrRawModules returns the number of modules available for codewords
+func qrRawModules(version int) int {
+	n := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		n -= (25*align-10)*align - 55
+		if version >= 7 {
+			n -= 36
+		}
+	}
+	return n
+}
+
+func qrDataCodewords(version int) int {
+	return qrRawModules(version)/8 - qrECCodewords[version]*qrECBlocks[version]
+}
+
+// qrCodewords returns the data codewords with padding, split into blocks
+// with their error correction codewords and interleaved
+func qrCodewords(version int, data []byte) []byte {
+	var bits qrBitBuffer
+	bits.append(0x4, 4)
+	if version >= 10 {
+		bits.append(len(data), 16)
+	} else {
+		bits.append(len(data), 8)
+	}
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+
+	capacity := qrDataCodewords(version) * 8
+	terminator := capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	bits.append(0, terminator)
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xec; len(bits) < capacity; pad ^= 0xec ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	codewords := bits.bytes()
+
+	blocks := qrECBlocks[version]
+	ecLen := qrECCodewords[version]
+	raw := qrRawModules(version) / 8
+	shortBlocks := blocks - raw%blocks
+	shortLen := raw / blocks
+	divisor := qrDivisor(ecLen)
+
+	var split [][]byte
+	for i, k := 0, 0; i < blocks; i++ {
+		n := shortLen - ecLen
+		if i >= shortBlocks {
+			n++
+		}
+		block := append([]byte{}, codewords[k:k+n]...)
+		k += n
+		ec := qrRemainder(block, divisor)
+		if i < shortBlocks {
+			block = append(block, 0)
+		}
+		split = append(split, append(block, ec...))
+	}
+
+	var result []byte
+	for i := range split[0] {
+		for j, block := range split {
+			// skip the padding of short blocks
+			if i != shortLen-ecLen || j >= shortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+type qrBitBuffer []bool
+
+func (b *qrBitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, value>>uint(i)&1 != 0)
+	}
+}
+
+func (b qrBitBuffer) bytes() []byte {
+	result := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			result[i/8] |= 1 << uint(7-i%8)
+		}
+	}
+	return result
+}
+
+// qrMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func qrMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11d
+		z ^= int(y>>uint(i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+// qrDivisor returns the Reed-Solomon generator polynomial of degree
+// without its leading term
+func qrDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = qrMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = qrMultiply(root, 2)
+	}
+	return result
+}
+
+// qrRemainder returns the error correction codewords of data
+func qrRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= qrMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+func (q *QRCode) set(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+func (q *QRCode) drawFunctionPatterns(version int) {
+	for i := 0; i < q.size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+
+	for _, center := range [][2]int{{3, 3}, {q.size - 4, 3}, {3, q.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := center[0]+dx, center[1]+dy
+				if x >= 0 && y >= 0 && x < q.size && y < q.size {
+					d := qrMax(qrAbs(dx), qrAbs(dy))
+					q.set(x, y, d != 2 && d != 4)
+				}
+			}
+		}
+	}
+
+	positions := qrAlignmentPositions(version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// skip the corners taken by finder patterns
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(x+dx, y+dy, qrMax(qrAbs(dx), qrAbs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// reserve the format areas until the mask is chosen
+	q.drawFormatBits(0)
+
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ (rem>>11)*0x1f25
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := bits>>uint(i)&1 != 0
+			a, b := q.size-11+i%3, i/3
+			q.set(a, b, dark)
+			q.set(b, a, dark)
+		}
+	}
+}
+
+func qrAlignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	count := version/7 + 2
+	step := (version*4 + count*2 + 1) / (count*2 - 2) * 2
+	positions := make([]int, count)
+	positions[0] = 6
+	for i, pos := count-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+// drawFormatBits draws the error correction level M and mask twice
+func (q *QRCode) drawFormatBits(mask int) {
+	data := mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool {
+		return bits>>uint(i)&1 != 0
+	}
+
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		q.set(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.size-15+i, bit(i))
+	}
+	q.set(8, q.size-8, true)
+}
+
+// drawCodewords fills the modules which aren't part of function patterns in
+// the zigzag order, two columns at a time from the bottom right
+func (q *QRCode) drawCodewords(codewords []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.size - 1 - vert
+				}
+				if !q.function[y][x] && i < len(codewords)*8 {
+					q.modules[y][x] = codewords[i/8]>>uint(7-i%8)&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask flips the data modules selected by mask, applying it twice
+// undoes it
+func (q *QRCode) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (x+y)%2 == 0
+			case 1:
+				flip = y%2 == 0
+			case 2:
+				flip = x%3 == 0
+			case 3:
+				flip = (x+y)%3 == 0
+			case 4:
+				flip = (x/3+y/2)%2 == 0
+			case 5:
+				flip = x*y%2+x*y%3 == 0
+			case 6:
+				flip = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				flip = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if flip && !q.function[y][x] {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code is to scan, lower is better
+func (q *QRCode) penalty() int {
+	penalty := 0
+	dark := 0
+	finder := []bool{true, false, true, true, true, false, true}
+
+	for a := 0; a < q.size; a++ {
+		for _, column := range []bool{false, true} {
+			at := func(i int) bool {
+				if column {
+					return q.modules[i][a]
+				}
+				return q.modules[a][i]
+			}
+
+			run := 1
+			for i := 1; i <= q.size; i++ {
+				if i < q.size && at(i) == at(i-1) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					penalty += run - 2
+				}
+				run = 1
+			}
+
+			// finder-like patterns with four light modules on either side
+			for i := 0; i+7 <= q.size; i++ {
+				matches := true
+				for k, d := range finder {
+					if at(i+k) != d {
+						matches = false
+						break
+					}
+				}
+				if matches && (qrLight(at, i-4, i, q.size) || qrLight(at, i+7, i+11, q.size)) {
+					penalty += 40
+				}
+			}
+		}
+	}
+
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x+1 < q.size && y+1 < q.size {
+				c := q.modules[y][x]
+				if c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+					penalty += 3
+				}
+			}
+		}
+	}
+
+	total := q.size * q.size
+	k := (qrAbs(dark*20-total*10)+total-1)/total - 1
+	return penalty + k*10
+}
+
+// qrLight reports whether the modules from to to are light, counting the
+// ones outside the code as light
+func qrLight(at func(int) bool, from, to, size int) bool {
+	for i := from; i < to; i++ {
+		if i >= 0 && i < size && at(i) {
+			return false
+		}
+	}
+	return true
+}
+
+func qrAbs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func qrMax(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,131 @@
+package gin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewQRCodeVersion(t *testing.T) {
+	tests := []struct {
+		n    int
+		size int
+	}{
+		// version 1 fits 14 bytes at level M, version 10 fits 213
+		{14, 21},
+		{15, 25},
+		{213, 57},
+	}
+	for _, test := range tests {
+		q, err := NewQRCode(strings.Repeat("a", test.n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.Size() != test.size {
+			t.Errorf("%d bytes: size %d, want %d", test.n, q.Size(), test.size)
+		}
+	}
+	if _, err := NewQRCode(strings.Repeat("a", 214)); err == nil {
+		t.Error("NewQRCode accepted 214 bytes")
+	}
+}
+
+func TestQRCodePatterns(t *testing.T) {
+	q, err := NewQRCode("http://192.168.1.5:3000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// finder patterns: a dark ring, a light ring and a dark 3x3 center
+	for _, corner := range [][2]int{{0, 0}, {q.Size() - 7, 0}, {0, q.Size() - 7}} {
+		for y := 0; y < 7; y++ {
+			for x := 0; x < 7; x++ {
+				ring := x == 0 || y == 0 || x == 6 || y == 6
+				center := x >= 2 && x <= 4 && y >= 2 && y <= 4
+				if got := q.Dark(corner[0]+x, corner[1]+y); got != (ring || center) {
+					t.Fatalf("finder pattern at %v: module %d,%d dark = %v", corner, x, y, got)
+				}
+			}
+		}
+	}
+	// timing patterns alternate between the finder patterns
+	for i := 8; i < q.Size()-8; i++ {
+		if q.Dark(i, 6) != (i%2 == 0) || q.Dark(6, i) != (i%2 == 0) {
+			t.Fatalf("timing pattern broken at %d", i)
+		}
+	}
+
+	// both copies of the format bits are equal, valid BCH codes for level M
+	var first, second int
+	firstAt := [][2]int{{8, 0}, {8, 1}, {8, 2}, {8, 3}, {8, 4}, {8, 5}, {8, 7}, {8, 8}, {7, 8}, {5, 8}, {4, 8}, {3, 8}, {2, 8}, {1, 8}, {0, 8}}
+	for i, at := range firstAt {
+		if q.Dark(at[0], at[1]) {
+			first |= 1 << uint(i)
+		}
+		x, y := q.Size()-1-i, 8
+		if i >= 8 {
+			x, y = 8, q.Size()-15+i
+		}
+		if q.Dark(x, y) {
+			second |= 1 << uint(i)
+		}
+	}
+	if first != second {
+		t.Errorf("format bits %015b and %015b differ", first, second)
+	}
+	format := first ^ 0x5412
+	if level := format >> 13; level != 0 {
+		t.Errorf("error correction level bits %02b, want 00 for M", level)
+	}
+	rem := format
+	for i := 14; i >= 10; i-- {
+		if rem>>uint(i)&1 != 0 {
+			rem ^= 0x537 << uint(i-10)
+		}
+	}
+	if rem != 0 {
+		t.Errorf("format bits %015b aren't a BCH code word", format)
+	}
+}
+
+func TestQRRemainder(t *testing.T) {
+	// a block followed by its error correction codewords is a multiple of
+	// the generator, whose roots are the powers of 2 in GF(2^8)
+	block := []byte("http://192.168.1.5:3000/")
+	for _, degree := range []int{10, 16, 26} {
+		codeword := append(append([]byte{}, block...), qrRemainder(block, qrDivisor(degree))...)
+		root := byte(1)
+		for i := 0; i < degree; i++ {
+			var value byte
+			for _, c := range codeword {
+				value = qrMultiply(value, root) ^ c
+			}
+			if value != 0 {
+				t.Errorf("degree %d: the codeword isn't 0 at root %d", degree, i)
+			}
+			root = qrMultiply(root, 2)
+		}
+	}
+}
+
+func TestQRCodewordsFillVersion(t *testing.T) {
+	for version := 1; version <= qrMaxVersion; version++ {
+		if got, want := len(qrCodewords(version, []byte("x"))), qrRawModules(version)/8; got != want {
+			t.Errorf("version %d: %d codewords, want %d", version, got, want)
+		}
+	}
+}
+
+func TestQRCodeLines(t *testing.T) {
+	texts := []string{"http://localhost:3000", "http://192.168.1.5:3000"}
+	lines, err := QRCodeLines(texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(lines[0], texts[0]) || !strings.Contains(lines[0], texts[1]) {
+		t.Errorf("first line %q doesn't label both codes", lines[0])
+	}
+	// version 2 with the quiet zone is 29 modules high, two rows per line
+	if len(lines) != 1+15 {
+		t.Errorf("%d lines, want %d", len(lines), 1+15)
+	}
+}
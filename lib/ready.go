@@ -0,0 +1,76 @@
+package gin
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sync"
+)
+
+// maxReadyLine bounds how much of an unterminated line is kept for matching
+const maxReadyLine = 64 * 1024
+
+// readySignal is closed once the child printed a line matching the ready
+// pattern
+type readySignal struct {
+	pattern *regexp.Regexp
+	ch      chan struct{}
+	once    sync.Once
+}
+
+func newReadySignal(pattern *regexp.Regexp) *readySignal {
+	return &readySignal{pattern: pattern, ch: make(chan struct{})}
+}
+
+func (s *readySignal) fire() {
+	s.once.Do(func() { close(s.ch) })
+}
+
+func (s *readySignal) fired() bool {
+	select {
+	case <-s.ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// readyWriter passes output through to w while matching each line against
+// the ready pattern
+type readyWriter struct {
+	w      io.Writer
+	signal *readySignal
+	buf    []byte
+}
+
+func (rw *readyWriter) Write(p []byte) (int, error) {
+	n, err := rw.w.Write(p)
+	if rw.signal.fired() {
+		return n, err
+	}
+
+	rw.buf = append(rw.buf, p...)
+	for {
+		i := bytes.IndexByte(rw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := rw.buf[:i]
+		rw.buf = rw.buf[i+1:]
+		if rw.signal.pattern.Match(line) {
+			rw.signal.fire()
+			rw.buf = nil
+			return n, err
+		}
+	}
+
+	// banners are not always terminated by a newline
+	if len(rw.buf) > 0 && rw.signal.pattern.Match(rw.buf) {
+		rw.signal.fire()
+		rw.buf = nil
+	} else if len(rw.buf) > maxReadyLine {
+		rw.buf = rw.buf[len(rw.buf)-maxReadyLine:]
+	}
+
+	return n, err
+}
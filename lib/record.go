@@ -0,0 +1,448 @@
+package gin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// RecordedRequest is a request captured by a Recorder
+type RecordedRequest struct {
+	Time   time.Time   `json:"time"`
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+	// BodyEncoding is base64 for bodies which aren't valid UTF-8
+	BodyEncoding string            `json:"bodyEncoding,omitempty"`
+	Response     *RecordedResponse `json:"response,omitempty"`
+}
+
+// RecordedResponse is the response to a RecordedRequest
+type RecordedResponse struct {
+	Status       int           `json:"status"`
+	Header       http.Header   `json:"header,omitempty"`
+	Body         string        `json:"body,omitempty"`
+	BodyEncoding string        `json:"bodyEncoding,omitempty"`
+	Duration     time.Duration `json:"duration"`
+}
+
+// Recorder captures the requests passing through the proxy, and optionally
+// the responses, to a file in HAR format if its name ends in .har and as
+// one JSON object per line otherwise
+type Recorder struct {
+	path      string
+	har       bool
+	responses bool
+
+	mu      sync.Mutex
+	file    *os.File
+	entries []RecordedRequest
+}
+
+// NewRecorder creates a Recorder writing to path, replacing an existing file
+func NewRecorder(path string, responses bool) (*Recorder, error) {
+	r := &Recorder{
+		path:      path,
+		har:       strings.EqualFold(filepath.Ext(path), ".har"),
+		responses: responses,
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if r.har {
+		// HAR files are a single document, rewritten for every request
+		file.Close()
+		return r, r.writeHAR()
+	}
+	r.file = file
+	return r, nil
+}
+
+// Middleware returns a Middleware recording every request
+func (r *Recorder) Middleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			body, err := ioutil.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				http.Error(res, err.Error(), http.StatusBadRequest)
+				return
+			}
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			entry := RecordedRequest{
+				Time:   time.Now(),
+				Method: req.Method,
+				URL:    requestURL(req),
+				Header: req.Header.Clone(),
+			}
+			entry.Body, entry.BodyEncoding = encodeBody(body)
+
+			if !r.responses {
+				r.record(entry)
+				next.ServeHTTP(res, req)
+				return
+			}
+
+			rec := &bodyRecorder{statusRecorder: &statusRecorder{ResponseWriter: res, status: http.StatusOK}}
+			next.ServeHTTP(rec, req)
+			if rec.status == http.StatusSwitchingProtocols {
+				r.record(entry)
+				return
+			}
+
+			entry.Response = &RecordedResponse{
+				Status:   rec.status,
+				Header:   res.Header().Clone(),
+				Duration: time.Since(entry.Time),
+			}
+			entry.Response.Body, entry.Response.BodyEncoding = encodeBody(rec.body.Bytes())
+			r.record(entry)
+		})
+	}
+}
+
+func (r *Recorder) record(entry RecordedRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+	if r.har {
+		r.entries = append(r.entries, entry)
+		err = r.writeHAR()
+	} else {
+		var line []byte
+		line, err = json.Marshal(entry)
+		if err == nil {
+			_, err = r.file.Write(append(line, '\n'))
+		}
+	}
+	if err != nil {
+		Infof("Can't record %s %s: %v", entry.Method, entry.URL, err)
+	}
+}
+
+// Close closes the recording
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *Recorder) writeHAR() error {
+	data, err := json.MarshalIndent(newHAR(r.entries), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
+// bodyRecorder keeps a copy of the response body
+type bodyRecorder struct {
+	*statusRecorder
+	body bytes.Buffer
+}
+
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.statusRecorder.Write(b)
+}
+
+// requestURL returns the absolute URL of a request received by the proxy
+func requestURL(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + req.Host + req.URL.RequestURI()
+}
+
+func encodeBody(body []byte) (text, encoding string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func decodeBody(text, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(text)
+	}
+	return []byte(text), nil
+}
+
+// LoadRecording reads the requests recorded to path by a Recorder, or a HAR
+// file saved by a browser if its name ends in .har
+func LoadRecording(path string) ([]RecordedRequest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".har") {
+		var h har
+		if err := json.NewDecoder(file).Decode(&h); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return h.requests(), nil
+	}
+
+	var requests []RecordedRequest
+	reader := bufio.NewReader(file)
+	for n := 1; ; n++ {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var request RecordedRequest
+			if err := json.Unmarshal(line, &request); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+			}
+			requests = append(requests, request)
+		}
+		if err == io.EOF {
+			return requests, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Replay sends a recorded request to the server at base, e.g.
+// http://localhost:3000, keeping only the path and query of its URL
+func Replay(client *http.Client, base string, request RecordedRequest) (*http.Response, error) {
+	u, err := url.Parse(request.URL)
+	if err != nil {
+		return nil, err
+	}
+	body, err := decodeBody(request.Body, request.BodyEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(request.Method, strings.TrimSuffix(base, "/")+u.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range request.Header {
+		if name == "Content-Length" || name == "Connection" {
+			continue
+		}
+		req.Header[name] = values
+	}
+	return client.Do(req)
+}
+
+// har is the subset of the HTTP Archive format gin reads and writes
+type har struct {
+	Log struct {
+		Version string `json:"version"`
+		Creator struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	HTTPVersion string       `json:"httpVersion"`
+	Headers     []harHeader  `json:"headers"`
+	QueryString []harHeader  `json:"queryString"`
+	Cookies     []harHeader  `json:"cookies"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    int          `json:"bodySize"`
+	PostData    *harPostData `json:"postData,omitempty"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harResponse struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Headers     []harHeader `json:"headers"`
+	Cookies     []harHeader `json:"cookies"`
+	Content     harContent  `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func newHAR(entries []RecordedRequest) har {
+	var h har
+	h.Log.Version = "1.2"
+	h.Log.Creator.Name = "gin"
+	h.Log.Entries = []harEntry{}
+
+	for _, entry := range entries {
+		e := harEntry{
+			StartedDateTime: entry.Time,
+			Request: harRequest{
+				Method:      entry.Method,
+				URL:         entry.URL,
+				HTTPVersion: "HTTP/1.1",
+				Headers:     harHeaders(entry.Header),
+				QueryString: []harHeader{},
+				Cookies:     []harHeader{},
+				HeadersSize: -1,
+				BodySize:    len(entry.Body),
+			},
+			// responses which weren't recorded have status 0, as HAR
+			// files do for requests without a response
+			Response: harResponse{
+				HTTPVersion: "HTTP/1.1",
+				Headers:     []harHeader{},
+				Cookies:     []harHeader{},
+				HeadersSize: -1,
+				BodySize:    -1,
+			},
+			Timings: harTimings{Send: 0, Wait: -1, Receive: 0},
+		}
+		if u, err := url.Parse(entry.URL); err == nil {
+			for name, values := range u.Query() {
+				for _, value := range values {
+					e.Request.QueryString = append(e.Request.QueryString, harHeader{Name: name, Value: value})
+				}
+			}
+		}
+		if entry.Body != "" {
+			e.Request.PostData = &harPostData{
+				MimeType: entry.Header.Get("Content-Type"),
+				Text:     entry.Body,
+				Encoding: entry.BodyEncoding,
+			}
+		}
+
+		if res := entry.Response; res != nil {
+			ms := float64(res.Duration) / float64(time.Millisecond)
+			e.Time = ms
+			e.Timings.Wait = ms
+			e.Response.Status = res.Status
+			e.Response.StatusText = http.StatusText(res.Status)
+			e.Response.Headers = harHeaders(res.Header)
+			e.Response.BodySize = len(res.Body)
+			e.Response.Content = harContent{
+				Size:     len(res.Body),
+				MimeType: res.Header.Get("Content-Type"),
+				Text:     res.Body,
+				Encoding: res.BodyEncoding,
+			}
+		}
+		h.Log.Entries = append(h.Log.Entries, e)
+	}
+	return h
+}
+
+func (h har) requests() []RecordedRequest {
+	var requests []RecordedRequest
+	for _, e := range h.Log.Entries {
+		request := RecordedRequest{
+			Time:   e.StartedDateTime,
+			Method: e.Request.Method,
+			URL:    e.Request.URL,
+			Header: http.Header{},
+		}
+		for _, header := range e.Request.Headers {
+			// HTTP/2 pseudo headers, e.g. :authority, recorded by browsers
+			if strings.HasPrefix(header.Name, ":") {
+				continue
+			}
+			request.Header.Add(header.Name, header.Value)
+		}
+		if e.Request.PostData != nil {
+			request.Body = e.Request.PostData.Text
+			request.BodyEncoding = e.Request.PostData.Encoding
+		}
+
+		if e.Response.Status > 0 {
+			request.Response = &RecordedResponse{
+				Status:       e.Response.Status,
+				Header:       http.Header{},
+				Body:         e.Response.Content.Text,
+				BodyEncoding: e.Response.Content.Encoding,
+				Duration:     time.Duration(e.Time * float64(time.Millisecond)),
+			}
+			for _, header := range e.Response.Headers {
+				request.Response.Header.Add(header.Name, header.Value)
+			}
+		}
+		requests = append(requests, request)
+	}
+	return requests
+}
+
+func harHeaders(header http.Header) []harHeader {
+	var names []string
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := []harHeader{}
+	for _, name := range names {
+		for _, value := range header[name] {
+			headers = append(headers, harHeader{Name: name, Value: value})
+		}
+	}
+	return headers
+}
@@ -0,0 +1,91 @@
+package gin
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recordRequests passes a JSON and a binary request through a Recorder
+// writing to a file called name and loads the recording
+func recordRequests(t *testing.T, name string) []RecordedRequest {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	recorder, err := NewRecorder(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := recorder.Middleware()(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "text/plain")
+		res.WriteHeader(http.StatusCreated)
+		res.Write([]byte("created"))
+	}))
+
+	req := httptest.NewRequest("POST", "http://localhost:3000/api/users?team=1", strings.NewReader(`{"name": "dev"}`))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PUT", "http://localhost:3000/avatar", strings.NewReader("\xff\xd8\xff")))
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests, err := LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("loaded %d requests, want 2", len(requests))
+	}
+	return requests
+}
+
+func TestRecorder(t *testing.T) {
+	for _, name := range []string{"requests.jsonl", "requests.har"} {
+		requests := recordRequests(t, name)
+
+		first := requests[0]
+		if first.Method != "POST" || first.URL != "http://localhost:3000/api/users?team=1" || first.Body != `{"name": "dev"}` {
+			t.Errorf("%s: first request %s %s %q", name, first.Method, first.URL, first.Body)
+		}
+		if first.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s: Content-Type = %q", name, first.Header.Get("Content-Type"))
+		}
+		if res := first.Response; res == nil || res.Status != http.StatusCreated || res.Body != "created" {
+			t.Errorf("%s: response %+v", name, res)
+		}
+
+		// bodies which aren't UTF-8 survive as base64
+		body, err := decodeBody(requests[1].Body, requests[1].BodyEncoding)
+		if err != nil || string(body) != "\xff\xd8\xff" {
+			t.Errorf("%s: binary body %q (%s), %v", name, requests[1].Body, requests[1].BodyEncoding, err)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	var got *http.Request
+	var gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		got, gotBody = req, string(body)
+	}))
+	defer server.Close()
+
+	request := recordRequests(t, "requests.jsonl")[0]
+	res, err := Replay(server.Client(), server.URL+"/", request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	// only the path and query of the recorded URL are kept
+	if got.Method != "POST" || got.URL.RequestURI() != "/api/users?team=1" || gotBody != `{"name": "dev"}` {
+		t.Errorf("replayed %s %s %q", got.Method, got.URL.RequestURI(), gotBody)
+	}
+	if got.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type = %q", got.Header.Get("Content-Type"))
+	}
+}
@@ -1,12 +1,17 @@
 package gin
 
 import (
+	"context"
+	"errors"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
-	"runtime"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -14,15 +19,38 @@ type Runner interface {
 	Run() (*exec.Cmd, error)
 	Info() (os.FileInfo, error)
 	SetWriter(io.Writer)
+	SetWriters(stdout io.Writer, stderr io.Writer)
+	SetReady(pattern *regexp.Regexp, timeout time.Duration)
+	SetEnv(Env)
+	SetWaitFor(addrs []string, timeout time.Duration)
+	SetProcessOptions(ProcessOptions)
 	Kill() error
 }
 
+// errNotRunning is returned by Signal when the app isn't running
+var errNotRunning = errors.New("the app isn't running")
+
 type runner struct {
-	bin       string
-	args      []string
-	writer    io.Writer
-	command   *exec.Cmd
-	starttime time.Time
+	bin          string
+	binary       string
+	args         []string
+	env          Env
+	writer       io.Writer
+	errWriter    io.Writer
+	command      *exec.Cmd
+	starttime    time.Time
+	readyPattern *regexp.Regexp
+	readyTimeout time.Duration
+	ready        *readySignal
+	waitFor      []string
+	waitTimeout  time.Duration
+	process      ProcessOptions
+	starts       int
+	onExit       func(uptime time.Duration)
+	// stopping is set when Kill stops the child, so its exit isn't reported
+	stopping *int32
+	// exited is closed once the child has exited
+	exited chan struct{}
 }
 
 func NewRunner(bin string, args ...string) Runner {
@@ -30,21 +58,48 @@ func NewRunner(bin string, args ...string) Runner {
 		bin:       bin,
 		args:      args,
 		writer:    ioutil.Discard,
+		errWriter: ioutil.Discard,
 		starttime: time.Now(),
+		process:   DefaultProcessOptions,
+	}
+}
+
+// NewDebugRunner creates a Runner which starts bin under a headless delve
+// server listening on listen, so debuggers can attach after every rebuild
+func NewDebugRunner(listen string, bin string, args ...string) Runner {
+	dlvArgs := []string{"exec", bin, "--headless", "--listen", listen, "--continue", "--accept-multiclient", "--api-version=2", "--"}
+	return &runner{
+		bin:       "dlv",
+		binary:    bin,
+		args:      append(dlvArgs, args...),
+		writer:    ioutil.Discard,
+		errWriter: ioutil.Discard,
+		starttime: time.Now(),
+		process:   DefaultProcessOptions,
 	}
 }
 
 func (r *runner) Run() (*exec.Cmd, error) {
+	return r.RunContext(context.Background())
+}
+
+// RunContext is like Run, but stops waiting for the addresses of SetWaitFor
+// and for the app to be ready once ctx is canceled, e.g. by a newer change.
+// The app isn't started if ctx is canceled before.
+func (r *runner) RunContext(ctx context.Context) (*exec.Cmd, error) {
 	if r.needsRefresh() {
 		r.Kill()
 	}
 
 	if r.command == nil || r.Exited() {
-		err := r.runBin()
+		err := r.runBin(ctx)
 		if err != nil {
-			log.Print("Error running: ", err)
+			if ctx.Err() == nil {
+				log.Print("Error running: ", err)
+			}
+		} else {
+			r.waitReady(ctx)
 		}
-		time.Sleep(250 * time.Millisecond)
 		return r.command, err
 	} else {
 		return r.command, nil
@@ -53,48 +108,145 @@ func (r *runner) Run() (*exec.Cmd, error) {
 }
 
 func (r *runner) Info() (os.FileInfo, error) {
+	if r.binary != "" {
+		return os.Stat(r.binary)
+	}
 	return os.Stat(r.bin)
 }
 
+// SetWriter sends both the stdout and stderr of the child to writer
 func (r *runner) SetWriter(writer io.Writer) {
-	r.writer = writer
+	r.SetWriters(writer, writer)
+}
+
+// SetWriters sends the stdout and stderr of the child to separate writers
+func (r *runner) SetWriters(stdout io.Writer, stderr io.Writer) {
+	r.writer = stdout
+	r.errWriter = stderr
+}
+
+// SetEnv sets variables added to gin's own environment when starting the
+// child. It applies from the next start of the child.
+func (r *runner) SetEnv(env Env) {
+	r.env = env
+}
+
+// SetReady makes Run wait until the child prints a line matching pattern,
+// instead of a fixed delay, before the first request is proxied.
+func (r *runner) SetReady(pattern *regexp.Regexp, timeout time.Duration) {
+	r.readyPattern = pattern
+	r.readyTimeout = timeout
+}
+
+// SetWaitFor delays every start of the child until the given TCP addresses,
+// e.g. of a database, accept connections or timeout elapses.
+func (r *runner) SetWaitFor(addrs []string, timeout time.Duration) {
+	r.waitFor = addrs
+	r.waitTimeout = timeout
+}
+
+// SetExitHandler makes the runner call fn with the uptime of the child when
+// it exits on its own, e.g. when it crashes, but not when Kill stops it
+func (r *runner) SetExitHandler(fn func(uptime time.Duration)) {
+	r.onExit = fn
+}
+
+// SetProcessOptions sets the working directory, umask, priority and resource
+// limits of the child. They apply from the next start of the child.
+func (r *runner) SetProcessOptions(opts ProcessOptions) {
+	r.process = opts
 }
 
 func (r *runner) Kill() error {
-	if r.command != nil && r.command.Process != nil {
-		done := make(chan error)
-		go func() {
-			r.command.Wait()
-			close(done)
-		}()
-
-		if runtime.GOOS == "windows" {
-			if err := r.command.Process.Kill(); err != nil {
-				return err
-			}
-		} else if err := r.command.Process.Signal(os.Interrupt); err != nil {
-			return err
-		}
+	if r.command == nil || r.command.Process == nil {
+		return nil
+	}
+	// the goroutine started by runBin waits for the child and closes exited
+	process, exited := r.command.Process, r.exited
+	// whatever happens, the next Run starts the app again
+	defer func() { r.command = nil }()
 
-		select {
-		case <-time.After(3 * time.Second):
-			if err := r.command.Process.Kill(); err != nil {
-				log.Println("failed to kill: ", err)
-			}
-		case <-done:
-		}
-		r.command = nil
+	atomic.StoreInt32(r.stopping, 1)
+	Verbosef("Stopping the app (pid %d)", process.Pid)
+	group := r.process.ProcessGroup
+	if err := interruptProcess(process, group); err != nil && !processGone(err) {
+		return err
 	}
 
+	select {
+	case <-time.After(3 * time.Second):
+		if err := killProcess(process, group); err != nil && !processGone(err) {
+			log.Println("failed to kill: ", err)
+		}
+	case <-exited:
+		// stop helpers the child left behind, e.g. asset watchers
+		if group {
+			killProcess(process, group)
+		}
+	}
 	return nil
 }
 
+// processGone reports whether err says the process to signal doesn't exist
+// anymore, e.g. because it was killed by a signal
+func processGone(err error) bool {
+	return errors.Is(err, os.ErrProcessDone) || errors.Is(err, syscall.ESRCH)
+}
+
+// Signal sends sig to the app, or with a process group to all its processes
+func (r *runner) Signal(sig os.Signal) error {
+	if r.command == nil || r.command.Process == nil || r.Exited() {
+		return errNotRunning
+	}
+	return signalProcess(r.command.Process, r.process.ProcessGroup, sig)
+}
+
+// Wait waits until the last started process of the app exits and returns
+// its exit code, 128 plus the number of the signal which killed it like
+// shells do
+func (r *runner) Wait() (int, error) {
+	command, exited := r.command, r.exited
+	if command == nil || exited == nil {
+		return 0, errNotRunning
+	}
+	<-exited
+	return exitCode(command.ProcessState), nil
+}
+
+// ProcessInfo describes the last started process of the app
+func (r *runner) ProcessInfo() ProcessInfo {
+	info := ProcessInfo{Starts: r.starts}
+	if r.command != nil && r.command.Process != nil {
+		info.Pid = r.command.Process.Pid
+		info.Running = !r.Exited()
+		info.Started = r.starttime
+	}
+	return info
+}
+
+// Exited reports whether the last started process of the app is gone,
+// whether it exited or was killed by a signal
 func (r *runner) Exited() bool {
-	return r.command != nil && r.command.ProcessState != nil && r.command.ProcessState.Exited()
+	if r.command == nil || r.exited == nil {
+		return false
+	}
+	select {
+	case <-r.exited:
+		return true
+	default:
+		return false
+	}
 }
 
-func (r *runner) runBin() error {
+func (r *runner) runBin(ctx context.Context) error {
+	if len(r.waitFor) > 0 {
+		if err := waitForAddrs(ctx, r.waitFor, r.waitTimeout); err != nil {
+			return err
+		}
+	}
+
 	r.command = exec.Command(r.bin, r.args...)
+	r.command.Env = r.env.Environ(os.Environ())
 	stdout, err := r.command.StdoutPipe()
 	if err != nil {
 		return err
@@ -104,23 +256,73 @@ func (r *runner) runBin() error {
 		return err
 	}
 
-	err = r.command.Start()
+	err = r.process.start(r.command)
 	if err != nil {
 		return err
 	}
 
 	r.starttime = time.Now()
+	r.starts++
+	r.stopping = new(int32)
+	r.exited = make(chan struct{})
+	traceCommand(r.command.Path, r.command.Args[1:]...)
+	Verbosef("Started the app (pid %d)", r.command.Process.Pid)
 
-	go io.Copy(r.writer, stdout)
-	go io.Copy(r.writer, stderr)
+	var stdoutWriter, stderrWriter io.Writer = r.writer, r.errWriter
+	r.ready = nil
+	if r.readyPattern != nil {
+		r.ready = newReadySignal(r.readyPattern)
+		stdoutWriter = &readyWriter{w: r.writer, signal: r.ready}
+		stderrWriter = &readyWriter{w: r.errWriter, signal: r.ready}
+	}
+
+	// Wait closes the pipes, so it must not be called before the output of a
+	// crashing child, e.g. its stack trace, has been read
+	var copied sync.WaitGroup
+	copied.Add(2)
 	go func() {
-		r.command.Wait()
+		io.Copy(stdoutWriter, stdout)
+		copied.Done()
+	}()
+	go func() {
+		io.Copy(stderrWriter, stderr)
+		copied.Done()
+	}()
+	go func(command *exec.Cmd, ready *readySignal, stopping *int32, exited chan struct{}, started time.Time, onExit func(time.Duration)) {
+		copied.Wait()
+		command.Wait()
+		close(exited)
+		Verbosef("The app (pid %d) exited: %s", command.Process.Pid, command.ProcessState)
 		stdout.Close()
 		stderr.Close()
-	}()
+		// stop waiting for a banner that will never be printed
+		if ready != nil {
+			ready.fire()
+		}
+		if onExit != nil && atomic.LoadInt32(stopping) == 0 {
+			onExit(time.Since(started))
+		}
+	}(r.command, r.ready, r.stopping, r.exited, r.starttime, r.onExit)
 	return nil
 }
 
+func (r *runner) waitReady(ctx context.Context) {
+	if r.ready == nil {
+		select {
+		case <-time.After(250 * time.Millisecond):
+		case <-ctx.Done():
+		}
+		return
+	}
+
+	select {
+	case <-r.ready.ch:
+	case <-ctx.Done():
+	case <-time.After(r.readyTimeout):
+		log.Printf("App output did not match %q within %s", r.readyPattern, r.readyTimeout)
+	}
+}
+
 func (r *runner) needsRefresh() bool {
 	info, err := r.Info()
 	if err != nil {
@@ -0,0 +1,123 @@
+package gin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DockerOptions configures the container the docker runner starts
+type DockerOptions struct {
+	// Image the binary is run in, it must be compatible with the built binary
+	Image string
+	// Name of the container, replaced on every restart
+	Name string
+	// Workspace is mounted at /app inside the container
+	Workspace string
+	// Port is published on localhost
+	Port string
+	// Volumes are additional bind mounts in the docker -v format
+	Volumes []string
+	// Args are passed to docker run before the image
+	Args []string
+}
+
+// dockerRunner runs the binary inside a docker container with the workspace
+// mounted. The docker client streams the output and is driven by an
+// embedded local runner.
+type dockerRunner struct {
+	*runner
+	opts      DockerOptions
+	local     string
+	localArgs []string
+	childEnv  Env
+	started   time.Time
+}
+
+// NewDockerRunner creates a Runner which runs bin, located inside the
+// workspace, in a docker container
+func NewDockerRunner(opts DockerOptions, bin string, args ...string) Runner {
+	if opts.Name == "" {
+		opts.Name = fmt.Sprintf("gin-%d", os.Getpid())
+	}
+
+	return &dockerRunner{
+		runner: &runner{
+			bin:       "docker",
+			writer:    ioutil.Discard,
+			errWriter: ioutil.Discard,
+			starttime: time.Now(),
+			process:   DefaultProcessOptions,
+		},
+		opts:      opts,
+		local:     bin,
+		localArgs: args,
+	}
+}
+
+func (r *dockerRunner) Run() (*exec.Cmd, error) {
+	if info, err := r.Info(); err == nil && info.ModTime().After(r.started) {
+		r.Kill()
+	}
+
+	if r.command == nil || r.Exited() {
+		args, err := r.dockerArgs()
+		if err != nil {
+			return nil, err
+		}
+		r.runner.args = args
+		r.started = time.Now()
+	}
+	return r.runner.Run()
+}
+
+func (r *dockerRunner) Info() (os.FileInfo, error) {
+	return os.Stat(r.local)
+}
+
+// SetEnv sets the variables passed to the container
+func (r *dockerRunner) SetEnv(env Env) {
+	r.childEnv = env
+}
+
+func (r *dockerRunner) Kill() error {
+	running := r.command != nil
+	err := r.runner.Kill()
+	if running {
+		exec.Command("docker", "rm", "-f", r.opts.Name).Run()
+	}
+	return err
+}
+
+func (r *dockerRunner) dockerArgs() ([]string, error) {
+	rel, err := filepath.Rel(r.opts.Workspace, r.local)
+	if err != nil || rel == ".." || filepath.IsAbs(rel) || (len(rel) > 2 && rel[:3] == ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("binary %s must be inside the workspace %s", r.local, r.opts.Workspace)
+	}
+
+	args := []string{"run", "--rm", "--name", r.opts.Name, "-v", r.opts.Workspace + ":/app", "-w", "/app"}
+	if r.opts.Port != "" {
+		args = append(args, "-p", "127.0.0.1:"+r.opts.Port+":"+r.opts.Port)
+	}
+	for _, volume := range r.opts.Volumes {
+		args = append(args, "-v", volume)
+	}
+
+	keys := make([]string, 0, len(r.childEnv))
+	for key := range r.childEnv {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "-e", key+"="+r.childEnv[key])
+	}
+
+	args = append(args, r.opts.Args...)
+	args = append(args, r.opts.Image, path.Join("/app", filepath.ToSlash(rel)))
+	return append(args, r.localArgs...), nil
+}
@@ -0,0 +1,118 @@
+package gin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// remoteRunner deploys the binary with a Deployer and runs it over ssh. The
+// ssh session streams the output of the app and is driven by an embedded
+// local runner.
+type remoteRunner struct {
+	*runner
+	deployer  Deployer
+	local     string
+	localArgs []string
+	remoteEnv Env
+	remote    string
+	deployed  time.Time
+}
+
+// NewRemoteRunner creates a Runner which deploys bin with deployer and runs
+// it on the deployer's host
+func NewRemoteRunner(deployer Deployer, bin string, args ...string) Runner {
+	return &remoteRunner{
+		runner: &runner{
+			bin:       "ssh",
+			writer:    ioutil.Discard,
+			errWriter: ioutil.Discard,
+			starttime: time.Now(),
+			process:   DefaultProcessOptions,
+		},
+		deployer:  deployer,
+		local:     bin,
+		localArgs: args,
+	}
+}
+
+func (r *remoteRunner) Run() (*exec.Cmd, error) {
+	info, err := r.Info()
+	if err == nil && info.ModTime().After(r.deployed) {
+		r.Kill()
+
+		Verbosef("Deploying %s to %s", r.local, r.deployer.Host())
+		remote, err := r.deployer.Deploy(r.local)
+		if err != nil {
+			log.Print("Error deploying: ", err)
+			return nil, err
+		}
+		r.remote = remote
+		r.deployed = info.ModTime()
+	}
+
+	r.runner.args = []string{r.deployer.Host(), r.commandLine()}
+	return r.runner.Run()
+}
+
+func (r *remoteRunner) Info() (os.FileInfo, error) {
+	return os.Stat(r.local)
+}
+
+// SetEnv sets the variables passed to the remote app
+func (r *remoteRunner) SetEnv(env Env) {
+	r.remoteEnv = env
+}
+
+func (r *remoteRunner) Kill() error {
+	running := r.command != nil && r.remote != ""
+
+	// stopping the ssh session does not stop the app without a tty
+	err := r.runner.Kill()
+	if running {
+		exec.Command("ssh", r.deployer.Host(), "kill $(cat "+shellQuote(r.pidFile())+") 2>/dev/null").Run()
+	}
+	return err
+}
+
+// Signal sends sig to the remote app, ssh doesn't forward signals to it
+func (r *remoteRunner) Signal(sig os.Signal) error {
+	if r.command == nil || r.remote == "" || r.Exited() {
+		return errNotRunning
+	}
+	name := strings.TrimPrefix(SignalName(sig), "SIG")
+	out, err := exec.Command("ssh", r.deployer.Host(), "kill -s "+name+" $(cat "+shellQuote(r.pidFile())+")").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("can't signal the app on %s: %v: %s", r.deployer.Host(), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (r *remoteRunner) pidFile() string {
+	return path.Join(path.Dir(r.remote), "."+path.Base(r.remote)+".pid")
+}
+
+// commandLine returns the shell command starting the app on the remote host
+func (r *remoteRunner) commandLine() string {
+	keys := make([]string, 0, len(r.remoteEnv))
+	for key := range r.remoteEnv {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := []string{"cd", shellQuote(path.Dir(r.remote)), "&&", "echo", "$$", ">", shellQuote(r.pidFile()), "&&", "exec", "env"}
+	for _, key := range keys {
+		parts = append(parts, shellQuote(key+"="+r.remoteEnv[key]))
+	}
+	parts = append(parts, shellQuote(r.remote))
+	for _, arg := range r.localArgs {
+		parts = append(parts, shellQuote(arg))
+	}
+	return strings.Join(parts, " ")
+}
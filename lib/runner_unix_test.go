@@ -0,0 +1,57 @@
+//go:build !windows
+// +build !windows
+
+package gin
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunnerRestartsAppKilledBySignal(t *testing.T) {
+	r := NewRunner("/bin/sh", "-c", "kill -KILL $$").(*runner)
+	if _, err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+	<-r.exited
+	if !r.Exited() {
+		t.Fatal("Exited = false for an app killed by a signal")
+	}
+	if info := r.ProcessInfo(); info.Running {
+		t.Error("ProcessInfo reports the killed app as running")
+	}
+
+	if err := r.Kill(); err != nil {
+		t.Errorf("Kill of an app which is gone: %v", err)
+	}
+	if _, err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if starts := r.ProcessInfo().Starts; starts != 2 {
+		t.Errorf("the app was started %d times, want 2", starts)
+	}
+}
+
+func TestRunnerKill(t *testing.T) {
+	r := NewRunner("/bin/sh", "-c", "sleep 10").(*runner)
+	if _, err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+	exited := r.exited
+
+	start := time.Now()
+	if err := r.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-exited:
+	default:
+		t.Error("Kill returned before the app exited")
+	}
+	if time.Since(start) >= 3*time.Second {
+		t.Error("the app ignored the interrupt")
+	}
+	if r.Exited() || r.ProcessInfo().Running {
+		t.Error("the runner still refers to the killed app")
+	}
+}
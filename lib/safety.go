@@ -0,0 +1,33 @@
+package gin
+
+import (
+	"os"
+	"runtime"
+)
+
+// IsRoot reports whether gin runs as the superuser
+func IsRoot() bool {
+	return os.Geteuid() == 0
+}
+
+// WorldWritable returns the directories and watched files below the watched
+// path which any user may modify. Changes to them make gin build and run
+// code, so they let other users execute code as the user running gin.
+func (o WatchOptions) WorldWritable() []string {
+	// permission bits don't describe ACLs on Windows
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	var paths []string
+	o.walk(o.Path, func(path string, info os.FileInfo) error {
+		if !info.IsDir() && !o.matches(path) {
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink == 0 && info.Mode().Perm()&0002 != 0 {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths
+}
@@ -0,0 +1,236 @@
+package gin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// ProjectTemplate describes an app gin new can scaffold
+type ProjectTemplate struct {
+	Description string
+	// Dependencies are the modules the app imports, go mod tidy adds them
+	Dependencies []string
+	main         string
+}
+
+var projectTemplates = map[string]ProjectTemplate{
+	"nethttp": {
+		Description: "net/http from the standard library",
+		main: `package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+func main() {
+	// gin passes the port the app listens on in PORT
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "3001"
+	}
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "Hello from {{.Name}}")
+	})
+
+	log.Printf("listening on :%s", port)
+	log.Fatal(http.ListenAndServe(":"+port, nil))
+}
+`,
+	},
+	"chi": {
+		Description:  "the chi router",
+		Dependencies: []string{"github.com/go-chi/chi/v5"},
+		main: `package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func main() {
+	// gin passes the port the app listens on in PORT
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "3001"
+	}
+
+	r := chi.NewRouter()
+	r.Use(middleware.Logger)
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello from {{.Name}}\n"))
+	})
+
+	log.Printf("listening on :%s", port)
+	log.Fatal(http.ListenAndServe(":"+port, r))
+}
+`,
+	},
+	"echo": {
+		Description:  "the Echo framework",
+		Dependencies: []string{"github.com/labstack/echo/v4"},
+		main: `package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/labstack/echo/v4"
+)
+
+func main() {
+	// gin passes the port the app listens on in PORT
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "3001"
+	}
+
+	e := echo.New()
+	e.HideBanner = true
+	e.GET("/", func(c echo.Context) error {
+		return c.String(http.StatusOK, "Hello from {{.Name}}\n")
+	})
+
+	log.Printf("listening on :%s", port)
+	log.Fatal(e.Start(":" + port))
+}
+`,
+	},
+	"gin": {
+		Description:  "the Gin web framework",
+		Dependencies: []string{"github.com/gin-gonic/gin"},
+		main: `package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	// gin passes the port the app listens on in PORT
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "3001"
+	}
+
+	r := gin.Default()
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, "Hello from {{.Name}}\n")
+	})
+
+	log.Printf("listening on :%s", port)
+	log.Fatal(r.Run(":" + port))
+}
+`,
+	},
+}
+
+// the config file makes gin start the app right away and proxy requests
+// once it printed the line logged by all templates
+const projectConfig = `{
+  "port": 3000,
+  "appPort": 3001,
+  "immediate": true,
+  "readyRegex": "listening on"
+}
+`
+
+const projectGitignore = `gin-bin
+.gin/
+`
+
+// ProjectTemplates returns the names of the templates gin new can scaffold
+func ProjectTemplates() []string {
+	var names []string
+	for name := range projectTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupProjectTemplate returns the template with the given name
+func LookupProjectTemplate(name string) (ProjectTemplate, error) {
+	t, ok := projectTemplates[name]
+	if !ok {
+		return t, fmt.Errorf("unknown template %q, expected one of %s", name, strings.Join(ProjectTemplates(), ", "))
+	}
+	return t, nil
+}
+
+// NewProject scaffolds an app from the named template in dir, including a
+// go.mod for module and a gin config file named configFile. Existing files
+// are never overwritten. It returns the paths of the created files.
+func NewProject(dir, name, module, configFile string) ([]string, error) {
+	t, err := LookupProjectTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var main strings.Builder
+	data := struct{ Name string }{Name: filepath.Base(module)}
+	if err := template.Must(template.New(name).Parse(t.main)).Execute(&main, data); err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		name, content string
+	}{
+		{"go.mod", fmt.Sprintf("module %s\n\ngo %s\n", module, newGoVersion())},
+		{"main.go", main.String()},
+		{configFile, projectConfig},
+		{".gitignore", projectGitignore},
+	}
+
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(dir, file.name)); err == nil {
+			return nil, fmt.Errorf("%s already exists", filepath.Join(dir, file.name))
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var created []string
+	for _, file := range files {
+		path := filepath.Join(dir, file.name)
+		if err := ioutil.WriteFile(path, []byte(file.content), 0644); err != nil {
+			return created, err
+		}
+		created = append(created, path)
+	}
+	return created, nil
+}
+
+// newGoVersion returns the go version for a new go.mod, the version of the
+// installed toolchain or else the one gin was built with
+func newGoVersion() string {
+	version := runtime.Version()
+	if out, err := exec.Command("go", "env", "GOVERSION").Output(); err == nil {
+		version = strings.TrimSpace(string(out))
+	}
+
+	if m := goVersion.FindStringSubmatch(version); m != nil {
+		return m[1] + "." + m[2]
+	}
+	return "1.17"
+}
@@ -0,0 +1,51 @@
+package gin
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ParseSignal parses the name of a signal, e.g. SIGHUP, HUP or hup, or its
+// number
+func ParseSignal(name string) (os.Signal, error) {
+	if len(signals) == 0 {
+		return nil, fmt.Errorf("signals aren't supported on %s", runtime.GOOS)
+	}
+
+	key := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "SIG")
+	if sig, ok := signals[key]; ok {
+		return sig, nil
+	}
+	if number, err := strconv.Atoi(key); err == nil {
+		for _, sig := range signals {
+			if int(sig) == number {
+				return sig, nil
+			}
+		}
+	}
+
+	names := make([]string, 0, len(signals))
+	for name := range signals {
+		names = append(names, "SIG"+name)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown signal %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// SignalName returns the name of sig, e.g. SIGHUP
+func SignalName(sig os.Signal) string {
+	for name, s := range signals {
+		if s == sig {
+			return "SIG" + name
+		}
+	}
+	if s, ok := sig.(syscall.Signal); ok {
+		return strconv.Itoa(int(s))
+	}
+	return sig.String()
+}
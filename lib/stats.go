@@ -0,0 +1,162 @@
+package gin
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRoutes bounds the number of routes tracked, further routes are
+	// counted as "other"
+	maxRoutes = 500
+	// latencySamples is the number of recent latencies kept per route
+	latencySamples = 1000
+)
+
+var idSegment = regexp.MustCompile(`^([0-9]+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{24,})$`)
+
+// RouteStats summarizes the proxied requests of a route
+type RouteStats struct {
+	Route  string         `json:"route"`
+	Count  int            `json:"count"`
+	P50    float64        `json:"p50_ms"`
+	P95    float64        `json:"p95_ms"`
+	Status map[string]int `json:"status"`
+}
+
+// RequestStats aggregates latency and status classes of proxied requests per
+// route. Numeric and uuid-like path segments are collapsed into ":id".
+type RequestStats struct {
+	mu     sync.Mutex
+	routes map[string]*routeStats
+	total  int
+}
+
+type routeStats struct {
+	count     int
+	latencies []time.Duration
+	next      int
+	status    map[string]int
+}
+
+func NewRequestStats() *RequestStats {
+	return &RequestStats{routes: make(map[string]*routeStats)}
+}
+
+// Total returns the number of requests recorded since gin started, it is not
+// affected by Reset
+func (s *RequestStats) Total() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total
+}
+
+// Record adds a proxied request to the stats
+func (s *RequestStats) Record(method, path string, status int, latency time.Duration) {
+	route := method + " " + normalizeRoute(path)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.total++
+	rs, ok := s.routes[route]
+	if !ok {
+		if len(s.routes) >= maxRoutes {
+			route = "other"
+			rs, ok = s.routes[route]
+		}
+		if !ok {
+			rs = &routeStats{status: make(map[string]int)}
+			s.routes[route] = rs
+		}
+	}
+
+	rs.count++
+	if len(rs.latencies) < latencySamples {
+		rs.latencies = append(rs.latencies, latency)
+	} else {
+		rs.latencies[rs.next] = latency
+		rs.next = (rs.next + 1) % latencySamples
+	}
+	rs.status[statusClass(status)]++
+}
+
+// Summary returns the stats of all routes, busiest first
+func (s *RequestStats) Summary() []RouteStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summary := make([]RouteStats, 0, len(s.routes))
+	for route, rs := range s.routes {
+		sorted := append([]time.Duration(nil), rs.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		status := make(map[string]int, len(rs.status))
+		for class, n := range rs.status {
+			status[class] = n
+		}
+
+		summary = append(summary, RouteStats{
+			Route:  route,
+			Count:  rs.count,
+			P50:    percentile(sorted, 0.50),
+			P95:    percentile(sorted, 0.95),
+			Status: status,
+		})
+	}
+
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].Count != summary[j].Count {
+			return summary[i].Count > summary[j].Count
+		}
+		return summary[i].Route < summary[j].Route
+	})
+	return summary
+}
+
+// Reset forgets all recorded requests
+func (s *RequestStats) Reset() {
+	s.mu.Lock()
+	s.routes = make(map[string]*routeStats)
+	s.mu.Unlock()
+}
+
+func normalizeRoute(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if idSegment.MatchString(segment) {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "other"
+	}
+	return string(rune('0'+status/100)) + "xx"
+}
+
+// percentile returns the p-th percentile of sorted latencies in milliseconds
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted)-1) * p)
+	return float64(sorted[i]) / float64(time.Millisecond)
+}
+
+// middleware records the requests passed to next
+func (s *RequestStats) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: res, status: http.StatusOK}
+		next.ServeHTTP(rec, req)
+		s.Record(req.Method, req.URL.Path, rec.status, time.Since(start))
+	})
+}
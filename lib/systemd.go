@@ -0,0 +1,137 @@
+package gin
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// systemdFirstFD is the first file descriptor passed by socket activation,
+// see sd_listen_fds(3)
+const systemdFirstFD = 3
+
+// SystemdListeners returns the sockets systemd passed to gin when a socket
+// unit started it, none otherwise. The variables describing them are
+// removed from the environment, so the app doesn't take them over too.
+func SystemdListeners() ([]net.Listener, error) {
+	pid, fds := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(fds)
+	if err != nil || count < 1 {
+		return nil, nil
+	}
+
+	var listeners []net.Listener
+	for fd := systemdFirstFD; fd < systemdFirstFD+count; fd++ {
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		// FileListener duplicates the descriptor with close-on-exec set
+		listener, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("can't use socket %d passed by systemd, expected a stream socket: %v", fd, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// Systemd tells systemd about the state of gin when it runs as a service
+// with Type=notify, see sd_notify(3), and pings its watchdog if enabled
+type Systemd struct {
+	conn *net.UnixConn
+	once sync.Once
+	done chan struct{}
+}
+
+// NewSystemd connects to the notification socket of systemd. It returns nil
+// when gin isn't run by systemd as a notify service. The variables
+// describing the socket are removed from the environment, so the app can't
+// report in gin's place.
+func NewSystemd() (*Systemd, error) {
+	path := os.Getenv("NOTIFY_SOCKET")
+	watchdogPID, watchdogUsec := os.Getenv("WATCHDOG_PID"), os.Getenv("WATCHDOG_USEC")
+	os.Unsetenv("NOTIFY_SOCKET")
+	os.Unsetenv("WATCHDOG_PID")
+	os.Unsetenv("WATCHDOG_USEC")
+	if path == "" {
+		return nil, nil
+	}
+
+	// a leading @ names a socket in the abstract namespace
+	if strings.HasPrefix(path, "@") {
+		path = "\x00" + path[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to the systemd notification socket: %v", err)
+	}
+	s := &Systemd{conn: conn, done: make(chan struct{})}
+
+	if watchdogPID == "" || watchdogPID == strconv.Itoa(os.Getpid()) {
+		if usec, err := strconv.ParseInt(watchdogUsec, 10, 64); err == nil && usec > 0 {
+			go s.watchdog(time.Duration(usec) * time.Microsecond / 2)
+		}
+	}
+	return s, nil
+}
+
+// Notify sends state, newline separated assignments like READY=1
+func (s *Systemd) Notify(state string) error {
+	if s == nil {
+		return nil
+	}
+	_, err := s.conn.Write([]byte(state))
+	return err
+}
+
+// Ready tells systemd that gin finished starting up
+func (s *Systemd) Ready() error {
+	return s.Notify("READY=1")
+}
+
+// Status shows status in systemctl status
+func (s *Systemd) Status(status string) error {
+	return s.Notify("STATUS=" + status)
+}
+
+// Close tells systemd that gin is stopping and stops the watchdog pings
+func (s *Systemd) Close() error {
+	if s == nil {
+		return nil
+	}
+	var err error
+	s.once.Do(func() {
+		close(s.done)
+		s.Notify("STOPPING=1")
+		err = s.conn.Close()
+	})
+	return err
+}
+
+// watchdog pings the watchdog of systemd every interval, half the timeout
+func (s *Systemd) watchdog(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.Notify("WATCHDOG=1"); err != nil {
+				Verbosef("Can't ping the systemd watchdog: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package gin
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Build states shown by a StatusDisplay
+const (
+	StatusBuilding = "⟳"
+	StatusOK       = "✓"
+	StatusFailed   = "✗"
+	// StatusStale means the last build failed and the app of the build
+	// before it keeps running
+	StatusStale = "⚠"
+)
+
+// StatusDisplay shows the build state of the app outside of the log, in the
+// terminal title and in a status file that e.g. tmux can show with
+// set -g status-right '#(cat ~/.gin-status)'
+type StatusDisplay struct {
+	Name string
+	// Title receives the escape sequence setting the terminal title, nil
+	// leaves the title alone
+	Title io.Writer
+	// File is overwritten with the status line, empty disables it
+	File string
+	// Systemd, if set, shows the state in systemctl status
+	Systemd *Systemd
+
+	mu    sync.Mutex
+	state string
+}
+
+// Update shows the given build state, one of StatusBuilding, StatusOK,
+// StatusFailed or StatusStale
+func (d *StatusDisplay) Update(state string) error {
+	d.mu.Lock()
+	d.state = state
+	d.mu.Unlock()
+
+	line := state + " " + d.Name
+
+	if d.Systemd != nil {
+		d.Systemd.Status(d.Name + ": " + stateName(state))
+	}
+
+	if d.Title != nil {
+		fmt.Fprintf(d.Title, "\033]0;%s\007", line)
+	}
+
+	if d.File != "" {
+		// write and rename so readers never see a partial line
+		tmp := filepath.Join(filepath.Dir(d.File), "."+filepath.Base(d.File)+".tmp")
+		if err := ioutil.WriteFile(tmp, []byte(line+"\n"), 0644); err != nil {
+			return err
+		}
+		return os.Rename(tmp, d.File)
+	}
+	return nil
+}
+
+// State returns the build state last passed to Update
+func (d *StatusDisplay) State() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.state
+}
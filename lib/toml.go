@@ -0,0 +1,184 @@
+package gin
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML parses the subset of TOML used by the config files of other
+// tools: tables, dotted keys, strings, numbers, booleans and arrays of them,
+// which may span several lines. Values are decoded like encoding/json does
+// into an interface{}.
+func parseTOML(data []byte) (map[string]interface{}, error) {
+	root := make(map[string]interface{})
+	table := root
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		// arrays may continue on the next lines until their brackets close
+		for strings.Count(text, "[")-strings.Count(text, "]") > 0 && strings.Contains(text, "=") && scanner.Scan() {
+			line++
+			text += " " + strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		}
+
+		switch {
+		case text == "":
+			continue
+		case strings.HasPrefix(text, "[["):
+			return nil, fmt.Errorf("line %d: arrays of tables aren't supported", line)
+		case strings.HasPrefix(text, "["):
+			if !strings.HasSuffix(text, "]") {
+				return nil, fmt.Errorf("line %d: expected ] after the table name", line)
+			}
+			var err error
+			if table, err = tomlTable(root, strings.TrimSpace(text[1:len(text)-1])); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			continue
+		}
+
+		eq := strings.Index(text, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", line)
+		}
+		value, rest, err := parseTOMLValue(strings.TrimSpace(text[eq+1:]))
+		if err == nil && strings.TrimSpace(rest) != "" {
+			err = fmt.Errorf("unexpected %q after the value", rest)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		keys := tomlKeys(text[:eq])
+		parent, err := tomlTable(table, strings.Join(keys[:len(keys)-1], "."))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		parent[keys[len(keys)-1]] = value
+	}
+	return root, scanner.Err()
+}
+
+// tomlKeys splits a dotted key, dropping the quotes of quoted parts
+func tomlKeys(key string) []string {
+	var keys []string
+	for _, k := range strings.Split(strings.TrimSpace(key), ".") {
+		k = strings.TrimSpace(k)
+		if unquoted, err := strconv.Unquote(k); err == nil {
+			k = unquoted
+		} else {
+			k = strings.Trim(k, "'")
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// tomlTable returns the table named by the dotted key name below root,
+// creating the missing ones
+func tomlTable(root map[string]interface{}, name string) (map[string]interface{}, error) {
+	table := root
+	if name == "" {
+		return table, nil
+	}
+	for _, key := range tomlKeys(name) {
+		next, ok := table[key]
+		if !ok {
+			next = make(map[string]interface{})
+			table[key] = next
+		}
+		if table, ok = next.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("%s is a value, not a table", key)
+		}
+	}
+	return table, nil
+}
+
+// parseTOMLValue parses the value at the start of s and returns the rest
+func parseTOMLValue(s string) (interface{}, string, error) {
+	switch {
+	case s == "":
+		return nil, "", fmt.Errorf("missing value")
+	case strings.HasPrefix(s, `"""`) || strings.HasPrefix(s, "'''"):
+		return nil, "", fmt.Errorf("multi-line strings aren't supported")
+	case s[0] == '"':
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				value, err := strconv.Unquote(s[:i+1])
+				return value, s[i+1:], err
+			}
+		}
+		return nil, "", fmt.Errorf("unterminated string")
+	case s[0] == '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return nil, "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	case s[0] == '[':
+		values := []interface{}{}
+		rest := strings.TrimSpace(s[1:])
+		for !strings.HasPrefix(rest, "]") {
+			value, r, err := parseTOMLValue(rest)
+			if err != nil {
+				return nil, "", err
+			}
+			values = append(values, value)
+			rest = strings.TrimSpace(r)
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimSpace(rest[1:])
+			} else if !strings.HasPrefix(rest, "]") {
+				return nil, "", fmt.Errorf("expected , or ] in array")
+			}
+		}
+		return values, rest[1:], nil
+	case s[0] == '{':
+		return nil, "", fmt.Errorf("inline tables aren't supported")
+	}
+
+	end := strings.IndexAny(s, ",] \t")
+	if end < 0 {
+		end = len(s)
+	}
+	word := s[:end]
+	switch word {
+	case "true":
+		return true, s[end:], nil
+	case "false":
+		return false, s[end:], nil
+	}
+	number, err := strconv.ParseFloat(strings.Replace(word, "_", "", -1), 64)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid value %q", word)
+	}
+	return number, s[end:], nil
+}
+
+// stripTOMLComment removes a # comment outside of strings from line
+func stripTOMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
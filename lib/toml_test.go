@@ -0,0 +1,63 @@
+package gin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	data := `root = "."  # the project
+tmp_dir = 'tmp'
+
+[build]
+  cmd = "go build -o ./tmp/main ."
+  include_ext = [
+    "go",   # sources
+    "tpl",
+  ]
+  delay = 1_000
+  stop_on_error = true
+
+[proxy]
+  enabled = false
+  app.port = 8080
+`
+	got, err := parseTOML([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"root":    ".",
+		"tmp_dir": "tmp",
+		"build": map[string]interface{}{
+			"cmd":           "go build -o ./tmp/main .",
+			"include_ext":   []interface{}{"go", "tpl"},
+			"delay":         1000.0,
+			"stop_on_error": true,
+		},
+		"proxy": map[string]interface{}{
+			"enabled": false,
+			"app":     map[string]interface{}{"port": 8080.0},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTOML =\n%#v\nwant\n%#v", got, want)
+	}
+}
+
+func TestParseTOMLInvalid(t *testing.T) {
+	for _, data := range []string{
+		"[[bin]]\n",
+		"[build\n",
+		"cmd\n",
+		"cmd = \"go build\n",
+		"cmd = \"go\" \"build\"\n",
+		"delay = soon\n",
+		"env = {a = 1}\n",
+		"a = 1\n[a]\n",
+	} {
+		if _, err := parseTOML([]byte(data)); err == nil {
+			t.Errorf("parseTOML accepted %q", data)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package gin
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// TransportOptions tunes the connections of the proxy to the app and other
+// upstreams. Zero values keep the defaults of net/http.
+type TransportOptions struct {
+	// DialTimeout limits how long connecting to the upstream may take
+	DialTimeout time.Duration `json:"dial_timeout"`
+	// ResponseHeaderTimeout limits how long the upstream may take to send
+	// the headers of its response
+	ResponseHeaderTimeout time.Duration `json:"response_header_timeout"`
+	// MaxIdleConns is the number of idle connections kept open per upstream
+	MaxIdleConns int `json:"max_idle_conns"`
+	// IdleConnTimeout closes idle connections after this long
+	IdleConnTimeout time.Duration `json:"idle_conn_timeout"`
+	// Retries is how often requests without a body are retried when the
+	// connection is refused, e.g. while the app restarts
+	Retries int `json:"retries"`
+	// RetryDelay is the pause between retries
+	RetryDelay time.Duration `json:"retry_delay"`
+}
+
+// roundTripper returns the transport of the proxies
+func (o TransportOptions) roundTripper() http.RoundTripper {
+	dialer := &net.Dialer{Timeout: o.DialTimeout, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   o.MaxIdleConns,
+		IdleConnTimeout:       o.IdleConnTimeout,
+		ResponseHeaderTimeout: o.ResponseHeaderTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	if o.Retries <= 0 {
+		return transport
+	}
+	return &retryTransport{RoundTripper: transport, retries: o.Retries, delay: o.RetryDelay}
+}
+
+// dial connects to the upstream at addr, e.g. for websockets
+func (o TransportOptions) dial(addr string) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	for attempt := 0; ; attempt++ {
+		conn, err = net.DialTimeout("tcp", addr, o.DialTimeout)
+		if err == nil || attempt >= o.Retries || !connectionRefused(err) {
+			return conn, err
+		}
+		time.Sleep(o.RetryDelay)
+	}
+}
+
+// retryTransport retries requests when the connection is refused. Only
+// requests without a body are retried, since the body is consumed by the
+// first attempt.
+type retryTransport struct {
+	http.RoundTripper
+	retries int
+	delay   time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		res, err := t.RoundTripper.RoundTrip(req)
+		if err == nil || attempt >= t.retries || !connectionRefused(err) || (req.Body != nil && req.Body != http.NoBody) {
+			return res, err
+		}
+
+		Tracef("%s %s: %s refused the connection, retrying in %s", req.Method, req.URL.RequestURI(), req.URL.Host, t.delay)
+		timer := time.NewTimer(t.delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+func connectionRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
@@ -0,0 +1,169 @@
+package gin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tunnel exposes the proxy on a public URL, e.g. for webhooks of third-party
+// services. It lives as long as gin, so the URL doesn't change on rebuilds.
+type Tunnel interface {
+	URL() string
+	Close() error
+}
+
+// TunnelFactory opens a Tunnel to target, e.g. http://localhost:3000, and
+// returns once its public URL is known
+type TunnelFactory func(target string) (Tunnel, error)
+
+// TunnelTimeout is how long the tunnel commands may take to report the URL
+var TunnelTimeout = 30 * time.Second
+
+var (
+	tunnelMu        sync.Mutex
+	tunnelFactories = map[string]TunnelFactory{
+		"ngrok": func(target string) (Tunnel, error) {
+			return startCommandTunnel(tunnelURLPattern(`url=(https://\S+)`),
+				"ngrok", "http", target, "--log", "stdout", "--log-format", "logfmt")
+		},
+		"cloudflared": func(target string) (Tunnel, error) {
+			return startCommandTunnel(tunnelURLPattern(`(https://[a-z0-9-]+\.trycloudflare\.com)`),
+				"cloudflared", "tunnel", "--no-autoupdate", "--url", target)
+		},
+		"localhost.run": func(target string) (Tunnel, error) {
+			u, err := url.Parse(target)
+			if err != nil {
+				return nil, err
+			}
+			return startCommandTunnel(tunnelURLPattern(`(https://[a-z0-9-]+\.(?:lhr\.life|localhost\.run))`),
+				"ssh", "-o", "StrictHostKeyChecking=accept-new", "-o", "ServerAliveInterval=30",
+				"-R", "80:"+u.Host, "nokey@localhost.run")
+		},
+	}
+)
+
+// RegisterTunnel makes a Tunnel provider available under the given name
+func RegisterTunnel(name string, factory TunnelFactory) {
+	tunnelMu.Lock()
+	defer tunnelMu.Unlock()
+	tunnelFactories[name] = factory
+}
+
+// Tunnels returns the names of the registered Tunnel providers
+func Tunnels() []string {
+	tunnelMu.Lock()
+	defer tunnelMu.Unlock()
+
+	var names []string
+	for name := range tunnelFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewTunnel opens a Tunnel to target with the named provider
+func NewTunnel(name, target string) (Tunnel, error) {
+	tunnelMu.Lock()
+	factory, ok := tunnelFactories[name]
+	tunnelMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown tunnel %q (available: %s)", name, strings.Join(Tunnels(), ", "))
+	}
+
+	tunnel, err := factory(target)
+	if err != nil {
+		return nil, fmt.Errorf("tunnel %s: %v", name, err)
+	}
+	return tunnel, nil
+}
+
+func tunnelURLPattern(pattern string) *regexp.Regexp {
+	return regexp.MustCompile(pattern)
+}
+
+// commandTunnel is a tunnel kept open by a command which prints the public
+// URL, e.g. ngrok
+type commandTunnel struct {
+	command *exec.Cmd
+	url     string
+	once    sync.Once
+	closed  chan struct{}
+}
+
+func startCommandTunnel(pattern *regexp.Regexp, name string, args ...string) (Tunnel, error) {
+	command := exec.Command(name, args...)
+	output, writer := io.Pipe()
+	command.Stdout = writer
+	command.Stderr = writer
+	traceCommand(name, args...)
+	if err := command.Start(); err != nil {
+		return nil, err
+	}
+
+	found := make(chan string, 1)
+	exited := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(output)
+		for scanner.Scan() {
+			Tracef("%s: %s", name, scanner.Text())
+			if m := pattern.FindStringSubmatch(scanner.Text()); m != nil {
+				select {
+				case found <- m[1]:
+				default:
+				}
+			}
+		}
+		// keep draining if lines are too long for the scanner
+		io.Copy(io.Discard, output)
+	}()
+	go func() {
+		err := command.Wait()
+		writer.Close()
+		exited <- err
+	}()
+
+	t := &commandTunnel{command: command, closed: make(chan struct{})}
+	select {
+	case t.url = <-found:
+	case err := <-exited:
+		if err == nil {
+			err = fmt.Errorf("%s exited", name)
+		}
+		return nil, fmt.Errorf("%v before reporting the URL, see --trace for its output", err)
+	case <-time.After(TunnelTimeout):
+		t.Close()
+		return nil, fmt.Errorf("%s didn't report the URL within %s, see --trace for its output", name, TunnelTimeout)
+	}
+
+	go func() {
+		err := <-exited
+		select {
+		case <-t.closed:
+		default:
+			Infof("The tunnel at %s closed: %v", t.url, err)
+		}
+	}()
+	return t, nil
+}
+
+func (t *commandTunnel) URL() string {
+	return t.url
+}
+
+func (t *commandTunnel) Close() error {
+	var err error
+	t.once.Do(func() {
+		close(t.closed)
+		err = t.command.Process.Kill()
+	})
+	return err
+}
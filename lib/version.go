@@ -0,0 +1,131 @@
+package gin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReleaseURL is the endpoint describing the latest release of gin
+const ReleaseURL = "https://api.github.com/repos/gbradleypro/go-reload/releases/latest"
+
+// BuildInfo describes the gin binary
+type BuildInfo struct {
+	Version   string
+	Commit    string
+	Date      string
+	Modified  bool
+	GoVersion string
+	Platform  string
+}
+
+// ReadBuildInfo returns the version and commit set through -ldflags -X,
+// falling back to the module and VCS information embedded by go build
+func ReadBuildInfo(version, commit string) BuildInfo {
+	info := BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && build.Main.Version != "(devel)" {
+			info.Version = build.Main.Version
+		}
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				info.Date = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
+
+// Release describes a published release of gin
+type Release struct {
+	Version string `json:"tag_name"`
+	URL     string `json:"html_url"`
+}
+
+// LatestRelease queries the release endpoint at url for the latest release
+func LatestRelease(url string) (Release, error) {
+	var release Release
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Get(url)
+	if err != nil {
+		return release, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return release, fmt.Errorf("%s: %s", url, res.Status)
+	}
+	if err := json.NewDecoder(res.Body).Decode(&release); err != nil {
+		return release, fmt.Errorf("%s: %v", url, err)
+	}
+	if release.Version == "" {
+		return release, fmt.Errorf("%s: no version in the response", url)
+	}
+	return release, nil
+}
+
+// NewerVersion reports whether version a, e.g. v1.10.0, is newer than b.
+// Versions which aren't numbered, e.g. dev, are never newer.
+func NewerVersion(a, b string) bool {
+	av, aok := parseVersion(a)
+	bv, bok := parseVersion(b)
+	if !aok || !bok {
+		return false
+	}
+
+	for i := 0; i < len(av) || i < len(bv); i++ {
+		var x, y int
+		if i < len(av) {
+			x = av[i]
+		}
+		if i < len(bv) {
+			y = bv[i]
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	return false
+}
+
+// parseVersion returns the numbers of a version like v1.2.3, ignoring
+// pre-release and build suffixes
+func parseVersion(version string) ([]int, bool) {
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+
+	var numbers []int
+	for _, part := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, true
+}
@@ -0,0 +1,48 @@
+package gin
+
+import (
+	"context"
+	"log"
+	"net"
+	"time"
+)
+
+// waitProgressInterval is how often waitForAddrs logs that it is still waiting
+const waitProgressInterval = 5 * time.Second
+
+// waitForAddrs blocks until every TCP address in addrs accepts connections or
+// timeout elapses, logging which dependencies are still unavailable. It
+// returns the error of ctx once it is canceled.
+func waitForAddrs(ctx context.Context, addrs []string, timeout time.Duration) error {
+	start := time.Now()
+	lastLog := time.Time{}
+
+	for _, addr := range addrs {
+		for {
+			conn, err := net.DialTimeout("tcp", addr, time.Second)
+			if err == nil {
+				conn.Close()
+				if !lastLog.IsZero() {
+					Infof("%s is up after %s", addr, time.Since(start).Round(time.Second))
+				}
+				break
+			}
+
+			elapsed := time.Since(start)
+			if timeout > 0 && elapsed >= timeout {
+				log.Printf("%s still unavailable after %s, starting anyway: %s", addr, timeout, err)
+				return nil
+			}
+			if time.Since(lastLog) >= waitProgressInterval {
+				Infof("Waiting for %s (%s)...", addr, elapsed.Round(time.Second))
+				lastLog = time.Now()
+			}
+			select {
+			case <-time.After(250 * time.Millisecond):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package gin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event describes a change reported by a Watcher
+type Event struct {
+	Path   string
+	Source string
+}
+
+// Watcher reports file changes below the watched path
+type Watcher interface {
+	Events() <-chan Event
+	Close() error
+}
+
+// WatchOptions configures which paths a Watcher reports
+type WatchOptions struct {
+	Path        string
+	BuildPath   string
+	ExcludeDirs []string
+	AllFiles    bool
+	Interval    time.Duration
+	TriggerFile string
+	// RestartPatterns are glob patterns of files which only require the app
+	// to be restarted, not rebuilt, e.g. ".env" or "config/*.yaml".
+	RestartPatterns []string
+	// ReloadPatterns are glob patterns of files the app reads again whenever
+	// it uses them, e.g. templates parsed on every request, which only
+	// require the page to be reloaded
+	ReloadPatterns []string
+	// Embeds are the files embedded into the binary, which always require a
+	// rebuild
+	Embeds *Embeds
+}
+
+// WatcherFactory creates a Watcher backend from the given options
+type WatcherFactory func(opts WatchOptions) (Watcher, error)
+
+var (
+	watcherMu        sync.Mutex
+	watcherFactories = map[string]WatcherFactory{
+		"poll":     newPollWatcher,
+		"fsnotify": newFSNotifyWatcher,
+		"fsevents": newFSEventsWatcher,
+		"trigger":  newTriggerWatcher,
+		"deps":     newDepsWatcher,
+	}
+)
+
+// RegisterWatcher makes a Watcher backend available under the given name
+func RegisterWatcher(name string, factory WatcherFactory) {
+	watcherMu.Lock()
+	defer watcherMu.Unlock()
+	watcherFactories[name] = factory
+}
+
+// Watchers returns the names of the registered Watcher backends
+func Watchers() []string {
+	watcherMu.Lock()
+	defer watcherMu.Unlock()
+
+	var names []string
+	for name := range watcherFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewWatcher creates the Watcher described by spec. Several backends can be
+// combined with a "+", e.g. "fsnotify+trigger".
+func NewWatcher(spec string, opts WatchOptions) (Watcher, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = 500 * time.Millisecond
+	}
+
+	var watchers []Watcher
+	for _, name := range strings.Split(spec, "+") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		watcherMu.Lock()
+		factory, ok := watcherFactories[name]
+		watcherMu.Unlock()
+
+		var (
+			w   Watcher
+			err error
+		)
+		if !ok {
+			err = fmt.Errorf("unknown watcher %q (available: %s)", name, strings.Join(Watchers(), ", "))
+		} else {
+			w, err = factory(opts)
+		}
+		if err != nil {
+			for _, w := range watchers {
+				w.Close()
+			}
+			return nil, err
+		}
+		Tracef("Watching %s with the %s watcher", opts.Path, name)
+		watchers = append(watchers, w)
+	}
+
+	switch len(watchers) {
+	case 0:
+		return newPollWatcher(opts)
+	case 1:
+		return watchers[0], nil
+	}
+	return newMultiWatcher(watchers), nil
+}
+
+// CombineWatchers returns a Watcher reporting the events of all watchers
+func CombineWatchers(watchers ...Watcher) Watcher {
+	if len(watchers) == 1 {
+		return watchers[0]
+	}
+	return newMultiWatcher(watchers)
+}
+
+// skipDir reports whether the directory at path should not be watched
+func (o WatchOptions) skipDir(path string) bool {
+	if filepath.Base(path) == ".git" {
+		return true
+	}
+	for _, x := range o.ExcludeDirs {
+		if x == path {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether a change to the file at path should be reported
+func (o WatchOptions) matches(path string) bool {
+	if o.Embeds.Contains(path) || o.IsRestartOnly(path) || o.IsReloadOnly(path) {
+		return true
+	}
+	// ignore hidden files
+	if filepath.Base(path)[0] == '.' {
+		return false
+	}
+	return o.AllFiles || filepath.Ext(path) == ".go"
+}
+
+// IsRestartOnly reports whether a change to the file at path only requires
+// the app to be restarted. Patterns containing a path separator are matched
+// against the path relative to the watched path, others against the file name.
+func (o WatchOptions) IsRestartOnly(path string) bool {
+	return o.matchPatterns(o.RestartPatterns, path)
+}
+
+// IsReloadOnly reports whether a change to the file at path neither requires
+// a rebuild nor a restart, only a reload of the page. Patterns are matched
+// like by IsRestartOnly.
+func (o WatchOptions) IsReloadOnly(path string) bool {
+	return o.matchPatterns(o.ReloadPatterns, path) && !o.IsRestartOnly(path)
+}
+
+// matchPatterns reports whether the file at path matches one of patterns,
+// which Go files and embedded files never do
+func (o WatchOptions) matchPatterns(patterns []string, path string) bool {
+	if filepath.Ext(path) == ".go" || o.Embeds.Contains(path) {
+		return false
+	}
+
+	rel, err := filepath.Rel(o.Path, path)
+	if err != nil {
+		rel = path
+	}
+	for _, pattern := range patterns {
+		name := filepath.Base(path)
+		if strings.ContainsRune(pattern, '/') || strings.ContainsRune(pattern, filepath.Separator) {
+			name = rel
+		}
+		if ok, _ := filepath.Match(filepath.FromSlash(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// walk visits the directories and files below root, honouring the excluded
+// directories.
+func (o WatchOptions) walk(root string, fn func(path string, info os.FileInfo) error) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && path != o.Path && o.skipDir(path) {
+			return filepath.SkipDir
+		}
+		return fn(path, info)
+	})
+}
+
+type multiWatcher struct {
+	watchers []Watcher
+	events   chan Event
+	done     chan struct{}
+	once     sync.Once
+}
+
+func newMultiWatcher(watchers []Watcher) Watcher {
+	m := &multiWatcher{
+		watchers: watchers,
+		events:   make(chan Event),
+		done:     make(chan struct{}),
+	}
+
+	// the events end once every watcher's events ended or on Close
+	var wg sync.WaitGroup
+	wg.Add(len(watchers))
+	go func() {
+		wg.Wait()
+		close(m.events)
+	}()
+
+	for _, w := range watchers {
+		go func(w Watcher) {
+			defer wg.Done()
+			for {
+				select {
+				case ev, ok := <-w.Events():
+					if !ok {
+						return
+					}
+					select {
+					case m.events <- ev:
+					case <-m.done:
+						return
+					}
+				case <-m.done:
+					return
+				}
+			}
+		}(w)
+	}
+
+	return m
+}
+
+func (m *multiWatcher) Events() <-chan Event {
+	return m.events
+}
+
+func (m *multiWatcher) Close() error {
+	var err error
+	m.once.Do(func() {
+		close(m.done)
+		for _, w := range m.watchers {
+			if cerr := w.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	})
+	return err
+}
@@ -0,0 +1,150 @@
+package gin
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// depsWatcher reports changes to the dependencies of the app: go.mod,
+// go.sum, vendor/modules.txt and the sources of direct dependencies that
+// live outside the module cache (e.g. replace directives to local checkouts).
+type depsWatcher struct {
+	opts   WatchOptions
+	dir    string
+	dirs   []string
+	stamps map[string]time.Time
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newDepsWatcher(opts WatchOptions) (Watcher, error) {
+	dir := opts.BuildPath
+	if dir == "" {
+		dir = opts.Path
+	}
+
+	w := &depsWatcher{
+		opts:   opts,
+		dir:    dir,
+		events: make(chan Event),
+		done:   make(chan struct{}),
+	}
+	w.dirs = w.dependencyDirs()
+	w.stamps = w.snapshot()
+
+	go w.loop()
+	return w, nil
+}
+
+func (w *depsWatcher) Events() <-chan Event {
+	return w.events
+}
+
+func (w *depsWatcher) Close() error {
+	w.once.Do(func() { close(w.done) })
+	return nil
+}
+
+func (w *depsWatcher) loop() {
+	defer close(w.events)
+
+	for {
+		select {
+		case <-time.After(w.opts.Interval):
+		case <-w.done:
+			return
+		}
+
+		stamps := w.snapshot()
+		changed := ""
+		for path, stamp := range stamps {
+			if old, ok := w.stamps[path]; !ok || !old.Equal(stamp) {
+				changed = path
+				break
+			}
+		}
+		if changed == "" {
+			continue
+		}
+
+		// go.mod changes may add, upgrade or drop dependencies
+		if filepath.Base(changed) == "go.mod" {
+			w.dirs = w.dependencyDirs()
+			stamps = w.snapshot()
+		}
+		w.stamps = stamps
+
+		select {
+		case w.events <- Event{Path: changed, Source: "deps"}:
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// snapshot records the modification times of the module files and the
+// newest source file of each dependency directory
+func (w *depsWatcher) snapshot() map[string]time.Time {
+	stamps := make(map[string]time.Time)
+	for _, name := range []string{"go.mod", "go.sum", filepath.Join("vendor", "modules.txt")} {
+		path := filepath.Join(w.dir, name)
+		if info, err := os.Stat(path); err == nil {
+			stamps[path] = info.ModTime()
+		}
+	}
+
+	for _, dir := range w.dirs {
+		var newest time.Time
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info.IsDir() && path != dir && w.opts.skipDir(path) {
+				return filepath.SkipDir
+			}
+			if filepath.Ext(path) == ".go" && info.ModTime().After(newest) {
+				newest = info.ModTime()
+			}
+			return nil
+		})
+		stamps[dir] = newest
+	}
+
+	return stamps
+}
+
+// dependencyDirs lists the directories of direct dependencies which are not
+// in the read-only module cache
+func (w *depsWatcher) dependencyDirs() []string {
+	command := exec.Command("go", "list", "-m", "-f", "{{if and (not .Main) (not .Indirect)}}{{.Dir}}{{end}}", "all")
+	command.Dir = w.dir
+	output, err := command.Output()
+	if err != nil {
+		return w.dirs
+	}
+
+	modCache := goEnv("GOMODCACHE")
+	var dirs []string
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || (modCache != "" && strings.HasPrefix(line, modCache)) {
+			continue
+		}
+		dirs = append(dirs, line)
+	}
+	return dirs
+}
+
+func goEnv(name string) string {
+	output, err := exec.Command("go", "env", name).Output()
+	if err != nil {
+		return ""
+	}
+	return string(bytes.TrimSpace(output))
+}
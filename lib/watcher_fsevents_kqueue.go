@@ -0,0 +1,287 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package gin
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// kqueue reports changes of a file to a descriptor opened for it, and
+// changes of a directory only as the adding, removing or renaming of its
+// entries, so every watched directory and file is opened
+const (
+	kqueueDirMask  = syscall.NOTE_WRITE | syscall.NOTE_DELETE | syscall.NOTE_RENAME
+	kqueueFileMask = syscall.NOTE_WRITE | syscall.NOTE_EXTEND | syscall.NOTE_ATTRIB |
+		syscall.NOTE_DELETE | syscall.NOTE_RENAME
+)
+
+// fseventsWatcher receives change notifications from the kernel via kqueue
+// on macOS and the BSDs instead of walking the tree. FSEvents itself needs
+// cgo, kqueue is reachable through the syscall package.
+type fseventsWatcher struct {
+	opts   WatchOptions
+	kq     int
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+
+	mu    sync.Mutex
+	paths map[int]string
+	fds   map[string]int
+	dirs  map[string]bool
+	// poll watches the trees beyond the open file limit
+	poll       *pollWatcher
+	forwarders sync.WaitGroup
+}
+
+func newFSEventsWatcher(opts WatchOptions) (Watcher, error) {
+	kq, err := syscall.Kqueue()
+	if err != nil {
+		return nil, os.NewSyscallError("kqueue", err)
+	}
+	syscall.CloseOnExec(kq)
+
+	w := &fseventsWatcher{
+		opts:   opts,
+		kq:     kq,
+		events: make(chan Event),
+		done:   make(chan struct{}),
+		paths:  make(map[int]string),
+		fds:    make(map[string]int),
+		dirs:   make(map[string]bool),
+	}
+
+	if err := w.addTree(opts.Path); err != nil {
+		w.closeAll()
+		return nil, err
+	}
+
+	go w.loop()
+	return w, nil
+}
+
+func (w *fseventsWatcher) Events() <-chan Event {
+	return w.events
+}
+
+// Close stops the watcher. The descriptors are closed by loop once it
+// returns, since it may be waiting for the kqueue meanwhile.
+func (w *fseventsWatcher) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+		w.mu.Lock()
+		if w.poll != nil {
+			w.poll.Close()
+		}
+		w.mu.Unlock()
+	})
+	return nil
+}
+
+func (w *fseventsWatcher) closeAll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for fd := range w.paths {
+		syscall.Close(fd)
+	}
+	w.paths, w.fds, w.dirs = nil, nil, nil
+	syscall.Close(w.kq)
+}
+
+func (w *fseventsWatcher) addTree(root string) error {
+	return w.opts.walk(root, func(path string, info os.FileInfo) error {
+		if !info.IsDir() && !w.opts.matches(path) {
+			return nil
+		}
+		err := w.add(path, info.IsDir())
+		if errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE) {
+			dir := path
+			if !info.IsDir() {
+				dir = filepath.Dir(path)
+			}
+			w.pollTree(dir)
+			return filepath.SkipDir
+		}
+		return err
+	})
+}
+
+// pollTree polls the tree at dir since kqueue can't watch it, rather than
+// missing its changes
+func (w *fseventsWatcher) pollTree(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.poll != nil {
+		Tracef("Polling %s beyond the open file limit", dir)
+		w.poll.add(dir)
+		return
+	}
+
+	log.Printf("Reached the open file limit at %s, polling it and the directories after it instead, which is slower. Raise it with ulimit -n 10240", dir)
+	w.poll = newPollRoots(w.opts, dir)
+	w.forwarders.Add(1)
+	go func() {
+		defer w.forwarders.Done()
+		for ev := range w.poll.Events() {
+			select {
+			case w.events <- ev:
+			case <-w.done:
+				return
+			}
+		}
+	}()
+}
+
+// add watches path unless it is watched already. A path removed meanwhile
+// is left alone.
+func (w *fseventsWatcher) add(path string, dir bool) error {
+	w.mu.Lock()
+	_, ok := w.fds[path]
+	w.mu.Unlock()
+	if ok {
+		return nil
+	}
+
+	fd, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_CLOEXEC|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		if err == syscall.ENOENT {
+			return nil
+		}
+		return os.NewSyscallError("open "+path, err)
+	}
+
+	mask := kqueueFileMask
+	if dir {
+		mask = kqueueDirMask
+	}
+	change := make([]syscall.Kevent_t, 1)
+	syscall.SetKevent(&change[0], fd, syscall.EVFILT_VNODE, syscall.EV_ADD|syscall.EV_CLEAR|syscall.EV_ENABLE)
+	change[0].Fflags = uint32(mask)
+	if _, err := syscall.Kevent(w.kq, change, nil, nil); err != nil {
+		syscall.Close(fd)
+		return os.NewSyscallError("kevent "+path, err)
+	}
+
+	w.mu.Lock()
+	w.paths[fd] = path
+	w.fds[path] = fd
+	w.dirs[path] = dir
+	w.mu.Unlock()
+	return nil
+}
+
+// remove stops watching path, the kernel drops its events along with the
+// descriptor
+func (w *fseventsWatcher) remove(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if fd, ok := w.fds[path]; ok {
+		syscall.Close(fd)
+		delete(w.paths, fd)
+		delete(w.fds, path)
+		delete(w.dirs, path)
+	}
+}
+
+func (w *fseventsWatcher) loop() {
+	defer func() {
+		w.forwarders.Wait()
+		w.closeAll()
+		close(w.events)
+	}()
+
+	// kevent can't be interrupted by closing the queue, so it wakes up
+	// every interval to check whether the watcher was closed
+	timeout := syscall.NsecToTimespec(int64(w.opts.Interval))
+	buf := make([]syscall.Kevent_t, 64)
+	for {
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+
+		n, err := syscall.Kevent(w.kq, nil, buf, &timeout)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			log.Println("kqueue watcher stopped:", os.NewSyscallError("kevent", err))
+			return
+		}
+
+		for _, ev := range buf[:n] {
+			w.mu.Lock()
+			path, ok := w.paths[int(ev.Ident)]
+			dir := w.dirs[path]
+			w.mu.Unlock()
+			if !ok {
+				continue
+			}
+
+			var changed []string
+			if ev.Fflags&(syscall.NOTE_DELETE|syscall.NOTE_RENAME) != 0 {
+				w.remove(path)
+				// an editor saving by renaming a new file over the old one
+				// leaves a new file at path, which is watched from now on
+				if info, err := os.Stat(path); err == nil && info.IsDir() == dir {
+					w.addTree(path)
+				}
+				if !dir {
+					changed = append(changed, path)
+				}
+			} else if dir {
+				changed = w.rescan(path)
+			} else {
+				changed = append(changed, path)
+			}
+
+			for _, path := range changed {
+				select {
+				case w.events <- Event{Path: path, Source: "fsevents"}:
+				case <-w.done:
+					return
+				}
+			}
+		}
+	}
+}
+
+// rescan watches the files and directories added to dir and returns the
+// added files which are reported. Removed files are reported by their own
+// descriptors.
+func (w *fseventsWatcher) rescan(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var added []string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		w.mu.Lock()
+		_, watched := w.fds[path]
+		w.mu.Unlock()
+		if watched {
+			continue
+		}
+
+		if entry.IsDir() {
+			if !w.opts.skipDir(path) {
+				w.addTree(path)
+			}
+		} else if w.opts.matches(path) {
+			if err := w.add(path, false); err == nil {
+				added = append(added, path)
+			}
+		}
+	}
+	return added
+}
@@ -0,0 +1,57 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package gin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFSEventsWatcherReportsChanges(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(existing, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewWatcher("fsevents", WatchOptions{Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	expect := func(path string) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case ev := <-w.Events():
+				if ev.Path == path {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("no event for %s", path)
+			}
+		}
+	}
+
+	if err := os.WriteFile(existing, []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expect(existing)
+
+	sub := filepath.Join(dir, "pkg")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// give the watcher the chance to pick up the new directory
+	time.Sleep(100 * time.Millisecond)
+	added := filepath.Join(sub, "pkg.go")
+	if err := os.WriteFile(added, []byte("package pkg\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expect(added)
+}
@@ -0,0 +1,13 @@
+//go:build !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package gin
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func newFSEventsWatcher(opts WatchOptions) (Watcher, error) {
+	return nil, fmt.Errorf("fsevents watcher is not supported on %s, use --watcher fsnotify or poll", runtime.GOOS)
+}
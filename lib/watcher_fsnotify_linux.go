@@ -0,0 +1,217 @@
+//go:build linux
+// +build linux
+
+package gin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// fsnotifyWatcher receives change notifications from the kernel via inotify
+// instead of walking the tree.
+type fsnotifyWatcher struct {
+	opts   WatchOptions
+	fd     int
+	file   *os.File
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+
+	mu   sync.Mutex
+	dirs map[int32]string
+	// poll watches the trees beyond the inotify watch limit
+	poll       *pollWatcher
+	forwarders sync.WaitGroup
+	overflowed bool
+}
+
+func newFSNotifyWatcher(opts WatchOptions) (Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err == syscall.EMFILE {
+		log.Printf("Reached the inotify instance limit%s, polling %s instead. Raise it with sudo sysctl fs.inotify.max_user_instances=1024",
+			currentLimit("max_user_instances"), opts.Path)
+		return newPollWatcher(opts)
+	}
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	w := &fsnotifyWatcher{
+		opts:   opts,
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		events: make(chan Event),
+		done:   make(chan struct{}),
+		dirs:   make(map[int32]string),
+	}
+
+	if err := w.addTree(opts.Path); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+
+	go w.loop()
+	return w, nil
+}
+
+func (w *fsnotifyWatcher) Events() <-chan Event {
+	return w.events
+}
+
+func (w *fsnotifyWatcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		err = w.file.Close()
+		w.mu.Lock()
+		if w.poll != nil {
+			w.poll.Close()
+		}
+		w.mu.Unlock()
+	})
+	return err
+}
+
+func (w *fsnotifyWatcher) addTree(root string) error {
+	return w.opts.walk(root, func(path string, info os.FileInfo) error {
+		if !info.IsDir() {
+			return nil
+		}
+		err := w.addDir(path)
+		if errors.Is(err, syscall.ENOSPC) {
+			w.pollTree(path)
+			return filepath.SkipDir
+		}
+		return err
+	})
+}
+
+// pollTree polls the tree at dir since inotify can't watch it, rather than
+// missing its changes
+func (w *fsnotifyWatcher) pollTree(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.poll != nil {
+		Tracef("Polling %s beyond the inotify watch limit", dir)
+		w.poll.add(dir)
+		return
+	}
+
+	log.Printf("Reached the inotify watch limit%s at %s, polling it and the directories after it instead, which is slower: %s",
+		currentLimit("max_user_watches"), dir, watchLimitFix)
+	w.poll = newPollRoots(w.opts, dir)
+	w.forwarders.Add(1)
+	go func() {
+		defer w.forwarders.Done()
+		for ev := range w.poll.Events() {
+			select {
+			case w.events <- ev:
+			case <-w.done:
+				return
+			}
+		}
+	}()
+}
+
+// currentLimit formats an inotify limit for messages, empty if unknown
+func currentLimit(name string) string {
+	if limit, err := inotifyLimit(name); err == nil {
+		return fmt.Sprintf(" (%d)", limit)
+	}
+	return ""
+}
+
+func (w *fsnotifyWatcher) addDir(dir string) error {
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, inotifyMask)
+	if err != nil {
+		return os.NewSyscallError("inotify_add_watch "+dir, err)
+	}
+
+	w.mu.Lock()
+	w.dirs[int32(wd)] = dir
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *fsnotifyWatcher) loop() {
+	defer func() {
+		w.forwarders.Wait()
+		close(w.events)
+	}()
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(raw.Len)]
+			offset += syscall.SizeofInotifyEvent + int(raw.Len)
+
+			if raw.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				if !w.overflowed {
+					w.overflowed = true
+					log.Printf("inotify dropped changes because too many happened at once, rebuilding to catch up. Raise the queue size with sudo sysctl fs.inotify.max_queued_events=65536")
+				}
+				select {
+				case w.events <- Event{Path: w.opts.Path, Source: "fsnotify"}:
+				case <-w.done:
+					return
+				}
+				continue
+			}
+
+			w.mu.Lock()
+			dir, ok := w.dirs[raw.Wd]
+			if raw.Mask&syscall.IN_IGNORED != 0 {
+				delete(w.dirs, raw.Wd)
+			}
+			w.mu.Unlock()
+			if !ok {
+				continue
+			}
+
+			path := filepath.Join(dir, cString(nameBytes))
+			if raw.Mask&syscall.IN_ISDIR != 0 {
+				if raw.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 && !w.opts.skipDir(path) {
+					w.addTree(path)
+				}
+				continue
+			}
+
+			if !w.opts.matches(path) {
+				continue
+			}
+
+			select {
+			case w.events <- Event{Path: path, Source: "fsnotify"}:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+// cString trims the NUL padding inotify appends to file names
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
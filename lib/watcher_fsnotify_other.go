@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package gin
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func newFSNotifyWatcher(opts WatchOptions) (Watcher, error) {
+	return nil, fmt.Errorf("fsnotify watcher is not supported on %s, use --watcher poll", runtime.GOOS)
+}
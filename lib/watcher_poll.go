@@ -0,0 +1,227 @@
+package gin
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PollWorkers is the number of directories the poll watcher reads at once.
+// Network file systems benefit from more, since most of the time is spent
+// waiting for the server.
+var PollWorkers = 8
+
+// pollWatcher periodically scans the watched path and reports the files
+// added, modified or removed since the previous scan.
+type pollWatcher struct {
+	opts   WatchOptions
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+
+	mu    sync.Mutex
+	roots []string
+}
+
+// pollFile is the state of a file seen by a scan
+type pollFile struct {
+	modTime time.Time
+	size    int64
+}
+
+// pollDir is the listing of a directory, reused while its mtime is unchanged
+type pollDir struct {
+	modTime time.Time
+	listed  time.Time
+	files   []string
+	dirs    []string
+}
+
+// pollIndex is the state of a tree after a scan
+type pollIndex struct {
+	files map[string]pollFile
+	dirs  map[string]pollDir
+}
+
+func newPollWatcher(opts WatchOptions) (Watcher, error) {
+	return newPollRoots(opts, opts.Path), nil
+}
+
+// newPollRoots polls the trees at roots instead of the whole watched path,
+// e.g. the ones the fsnotify watcher can't watch
+func newPollRoots(opts WatchOptions, roots ...string) *pollWatcher {
+	w := &pollWatcher{
+		opts:   opts,
+		events: make(chan Event),
+		done:   make(chan struct{}),
+		roots:  roots,
+	}
+	go w.loop()
+	return w
+}
+
+func (w *pollWatcher) Events() <-chan Event {
+	return w.events
+}
+
+func (w *pollWatcher) Close() error {
+	w.once.Do(func() { close(w.done) })
+	return nil
+}
+
+// add polls the tree at root too, unless it is polled already
+func (w *pollWatcher) add(root string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, r := range w.roots {
+		if r == root || strings.HasPrefix(root, r+string(filepath.Separator)) {
+			return
+		}
+	}
+	w.roots = append(w.roots, root)
+}
+
+func (w *pollWatcher) loop() {
+	defer close(w.events)
+
+	// the first scan of a root only indexes it
+	indexes := make(map[string]*pollIndex)
+	for {
+		w.mu.Lock()
+		roots := append([]string{}, w.roots...)
+		w.mu.Unlock()
+
+		var changed []string
+		for _, root := range roots {
+			prev := indexes[root]
+			next := w.scan(root, prev)
+			if prev != nil {
+				changed = append(changed, prev.changes(next)...)
+			}
+			indexes[root] = next
+		}
+
+		for _, path := range changed {
+			select {
+			case w.events <- Event{Path: path, Source: "poll"}:
+			case <-w.done:
+				return
+			}
+		}
+
+		select {
+		case <-time.After(w.opts.Interval):
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// scan indexes the tree at root, reading PollWorkers directories at a time.
+// Listings are reused from prev while the mtime of their directory is
+// unchanged, since adding, removing or renaming entries changes it. Files
+// are checked on every scan, since writing to a file leaves the mtime of its
+// directory alone.
+func (w *pollWatcher) scan(root string, prev *pollIndex) *pollIndex {
+	index := &pollIndex{files: make(map[string]pollFile), dirs: make(map[string]pollDir)}
+	workers := PollWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, workers)
+	)
+	var visit func(dir string)
+	visit = func(dir string) {
+		defer wg.Done()
+
+		sem <- struct{}{}
+		listing, ok := w.list(dir, prev)
+		files := make(map[string]pollFile, len(listing.files))
+		for _, path := range listing.files {
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				files[path] = pollFile{modTime: info.ModTime(), size: info.Size()}
+			}
+		}
+		<-sem
+		if !ok {
+			return
+		}
+
+		mu.Lock()
+		index.dirs[dir] = listing
+		for path, file := range files {
+			index.files[path] = file
+		}
+		mu.Unlock()
+
+		for _, sub := range listing.dirs {
+			wg.Add(1)
+			go visit(sub)
+		}
+	}
+
+	wg.Add(1)
+	visit(root)
+	wg.Wait()
+	return index
+}
+
+// list returns the files of dir which are reported and its subdirectories
+// which aren't excluded
+func (w *pollWatcher) list(dir string, prev *pollIndex) (pollDir, bool) {
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return pollDir{}, false
+	}
+	if prev != nil {
+		// a change right after the listing may not move the mtime on file
+		// systems with coarse timestamps, so only listings made well after
+		// the last change are trusted
+		cached, ok := prev.dirs[dir]
+		if ok && cached.modTime.Equal(info.ModTime()) && cached.listed.Sub(cached.modTime) > time.Second {
+			return cached, true
+		}
+	}
+
+	listed := time.Now()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return pollDir{}, false
+	}
+	listing := pollDir{modTime: info.ModTime(), listed: listed}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			if !w.opts.skipDir(path) {
+				listing.dirs = append(listing.dirs, path)
+			}
+		} else if w.opts.matches(path) {
+			listing.files = append(listing.files, path)
+		}
+	}
+	return listing, true
+}
+
+// changes returns the files added, modified or removed since prev
+func (prev *pollIndex) changes(next *pollIndex) []string {
+	var changed []string
+	for path, file := range next.files {
+		if old, ok := prev.files[path]; !ok || !old.modTime.Equal(file.modTime) || old.size != file.size {
+			changed = append(changed, path)
+		}
+	}
+	for path := range prev.files {
+		if _, ok := next.files[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
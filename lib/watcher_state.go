@@ -0,0 +1,480 @@
+package gin
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileState is what was last seen of a watched file
+type FileState struct {
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	Hash    string    `json:"hash,omitempty"`
+	Removed bool      `json:"removed,omitempty"`
+}
+
+// WatchState records the state of each changed file when the change was
+// acted upon. Events repeating a change already acted upon, e.g. late
+// notifications of a save that arrive during the build it caused, and saves
+// that leave the content as it was are dropped.
+type WatchState struct {
+	mu    sync.Mutex
+	files map[string]FileState
+}
+
+// NewWatchState creates an empty WatchState
+func NewWatchState() *WatchState {
+	return &WatchState{files: make(map[string]FileState)}
+}
+
+// Changed returns the paths of events which changed since they were last
+// acted upon, in order and without duplicates, and records their state.
+// Events of the trigger watcher always count as changes.
+func (s *WatchState) Changed(events []Event) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changed []string
+	seen := make(map[string]bool)
+	for _, ev := range events {
+		if seen[ev.Path] {
+			continue
+		}
+		seen[ev.Path] = true
+		if ev.Source == "trigger" || s.update(ev.Path) {
+			changed = append(changed, ev.Path)
+		}
+	}
+	return changed
+}
+
+// State returns the recorded state of the file at path
+func (s *WatchState) State(path string) (FileState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.files[path]
+	return state, ok
+}
+
+// update records the current state of the file at path and reports whether
+// it differs from the recorded one. The content is only hashed when the
+// modification time or size moved.
+func (s *WatchState) update(path string) bool {
+	old, known := s.files[path]
+
+	info, err := os.Stat(path)
+	if err != nil {
+		s.files[path] = FileState{Removed: true}
+		return !known || !old.Removed
+	}
+	if info.IsDir() {
+		return true
+	}
+
+	state := FileState{ModTime: info.ModTime(), Size: info.Size()}
+	if known && !old.Removed && old.ModTime.Equal(state.ModTime) && old.Size == state.Size {
+		return false
+	}
+
+	state.Hash, err = hashFile(path)
+	s.files[path] = state
+	if err != nil {
+		return true
+	}
+	return !known || old.Removed || old.Hash != state.Hash
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// watcherStateFile is the file below StateDir holding the FileIndex of the
+// last successful build
+const watcherStateFile = "watcher.json"
+
+// FileIndex is the state of the files a build depends on
+type FileIndex struct {
+	// Key describes the build settings, e.g. the build flags, whose change
+	// requires a rebuild
+	Key string `json:"key"`
+	// Binary is the state of the built binary
+	Binary FileState            `json:"binary"`
+	Files  map[string]FileState `json:"files"`
+}
+
+// BuildState remembers across runs of gin which files the binary was built
+// from. The index of the files taken right before a successful build is
+// saved, so the next start can tell whether the binary is still up to date
+// and which files changed while gin wasn't running.
+type BuildState struct {
+	wd     string
+	path   string
+	opts   WatchOptions
+	key    string
+	binary string
+
+	mu    sync.Mutex
+	saved *FileIndex
+}
+
+// NewBuildState loads the state saved by the last run of gin in wd. key
+// describes the build settings and binary is the path of the built binary.
+// A missing or unreadable state is treated like one without files.
+func NewBuildState(wd string, opts WatchOptions, key string, binary string) *BuildState {
+	s := &BuildState{
+		wd:     wd,
+		path:   filepath.Join(wd, StateDir, watcherStateFile),
+		opts:   opts,
+		key:    key,
+		binary: binary,
+	}
+	if data, err := ioutil.ReadFile(s.path); err == nil {
+		var saved FileIndex
+		if err := json.Unmarshal(data, &saved); err == nil {
+			s.saved = &saved
+		} else {
+			Verbosef("Ignoring %s: %v", s.path, err)
+		}
+	}
+	return s
+}
+
+// Snapshot indexes the files the build depends on: the Go files, the
+// embedded files and the module files, including vendor/modules.txt, below
+// the watched path, and the Go and module files of the modules used by
+// go.work or replaced by local directories outside of it. Files whose
+// modification time and size are unchanged since the saved state keep their
+// hash instead of being read again. A nil BuildState returns nil.
+func (s *BuildState) Snapshot() *FileIndex {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	saved := s.saved
+	s.mu.Unlock()
+
+	binary, _ := filepath.Abs(s.binary)
+	index := &FileIndex{Key: s.key, Files: make(map[string]FileState)}
+	add := func(path string, info os.FileInfo) {
+		state := FileState{ModTime: info.ModTime(), Size: info.Size()}
+		if old, ok := saved.file(path); ok && old.ModTime.Equal(state.ModTime) && old.Size == state.Size {
+			state.Hash = old.Hash
+		} else if hash, err := hashFile(path); err == nil {
+			state.Hash = hash
+		}
+		index.Files[path] = state
+	}
+
+	s.opts.walk(s.opts.Path, func(path string, info os.FileInfo) error {
+		if info.IsDir() {
+			if filepath.Base(path) == StateDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !s.builtFrom(path) {
+			return nil
+		}
+		if abs, err := filepath.Abs(path); err == nil && abs == binary {
+			return nil
+		}
+		add(path, info)
+		return nil
+	})
+
+	// go.work and the other modules of the build, unless they are below
+	// the watched path
+	root, _ := filepath.Abs(s.opts.Path)
+	below := func(path string) bool {
+		return path == root || strings.HasPrefix(path, root+string(filepath.Separator))
+	}
+	if work := findGoWork(s.wd); work != "" && !below(work) {
+		if info, err := os.Stat(work); err == nil {
+			add(work, info)
+		}
+	}
+	for _, dir := range localModules(s.wd) {
+		if below(dir) {
+			continue
+		}
+		s.opts.walk(dir, func(path string, info os.FileInfo) error {
+			if info.IsDir() {
+				if path != dir && (filepath.Base(path) == "vendor" || filepath.Base(path) == "testdata") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			switch filepath.Base(path) {
+			case "go.mod", "go.sum", "go.work":
+				add(path, info)
+				return nil
+			}
+			if filepath.Ext(path) == ".go" {
+				add(path, info)
+			}
+			return nil
+		})
+	}
+	return index
+}
+
+// localModules returns the absolute directories of the modules the build in
+// wd uses from the local disk: the ones listed by the use directives of its
+// go.work and the ones replaced by local paths in go.work or go.mod
+func localModules(wd string) []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	add := func(base, dir string) {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(base, dir)
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	if work := findGoWork(wd); work != "" {
+		base := filepath.Dir(work)
+		for _, dir := range modDirectives(work, "use") {
+			add(base, dir)
+		}
+		for _, dir := range modDirectives(work, "replace") {
+			add(base, dir)
+		}
+	}
+	if base, err := filepath.Abs(wd); err == nil {
+		for _, dir := range modDirectives(filepath.Join(base, "go.mod"), "replace") {
+			add(base, dir)
+		}
+	}
+	return dirs
+}
+
+// findGoWork returns the go.work file the go command uses in wd, following
+// GOWORK like it, or "" if there is none
+func findGoWork(wd string) string {
+	switch work := os.Getenv("GOWORK"); work {
+	case "off":
+		return ""
+	case "":
+	default:
+		return work
+	}
+
+	dir, err := filepath.Abs(wd)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, "go.work")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// modDirectives returns the local directories named by the directives of
+// kind in the go.mod or go.work file at path, either "use" or "replace",
+// whose targets only count when they are directories rather than module
+// paths, e.g. ../shared in replace example.com/shared => ../shared
+func modDirectives(path string, kind string) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var dirs []string
+	block := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case block && fields[0] == ")":
+			block = false
+			continue
+		case !block && fields[0] == kind:
+			if len(fields) == 2 && fields[1] == "(" {
+				block = true
+				continue
+			}
+			fields = fields[1:]
+		case !block:
+			continue
+		}
+
+		if kind == "replace" {
+			target := -1
+			for i, field := range fields {
+				if field == "=>" {
+					target = i + 1
+				}
+			}
+			if target < 0 || target >= len(fields) {
+				continue
+			}
+			fields = fields[target:]
+		}
+		if dir := strings.Trim(fields[0], `"`); isLocalPath(dir) {
+			dirs = append(dirs, filepath.FromSlash(dir))
+		}
+	}
+	return dirs
+}
+
+// isLocalPath reports whether path in a go.mod or go.work file is a
+// directory rather than a module path, which the go command tells by its
+// leading ./, ../ or /
+func isLocalPath(path string) bool {
+	return path == "." || path == ".." || filepath.IsAbs(path) ||
+		strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") ||
+		strings.HasPrefix(path, `.\`) || strings.HasPrefix(path, `..\`)
+}
+
+// buildEnv are the go env settings which change the binary built from the
+// same files
+var buildEnv = []string{
+	"GOVERSION", "GOFLAGS", "GOOS", "GOARCH", "GOEXPERIMENT", "GOAMD64", "GOARM", "GO386",
+	"CGO_ENABLED", "CC", "CXX", "CGO_CFLAGS", "CGO_CPPFLAGS", "CGO_CXXFLAGS", "CGO_LDFLAGS", "GOWORK",
+}
+
+// BuildEnvKey describes the go toolchain used in wd and the go env settings
+// which change the binary, e.g. CGO_ENABLED, to become part of the key of
+// a BuildState. It is empty if the go command can't tell.
+func BuildEnvKey(wd string) string {
+	command := exec.Command("go", append([]string{"env"}, buildEnv...)...)
+	command.Dir = wd
+	output, err := command.Output()
+	if err != nil {
+		return ""
+	}
+
+	var settings []string
+	for i, value := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		if i < len(buildEnv) && value != "" {
+			settings = append(settings, buildEnv[i]+"="+value)
+		}
+	}
+	return strings.Join(settings, " ")
+}
+
+// builtFrom reports whether a change to the file at path requires a rebuild
+func (s *BuildState) builtFrom(path string) bool {
+	switch filepath.Base(path) {
+	case "go.mod", "go.sum", "go.work":
+		return true
+	case "modules.txt":
+		if filepath.Base(filepath.Dir(path)) == "vendor" {
+			return true
+		}
+	}
+	return s.opts.matches(path) && !s.opts.IsRestartOnly(path) && !s.opts.IsReloadOnly(path)
+}
+
+// Changes compares snapshot to the saved state. It returns the files which
+// were added, modified or removed since the last successful build, in order,
+// and whether the binary is still the one built from the saved files with
+// the same settings. Without a saved state, or with other settings, no
+// files are returned and the binary is out of date.
+func (s *BuildState) Changes(snapshot *FileIndex) ([]string, bool) {
+	if s == nil || snapshot == nil {
+		return nil, false
+	}
+	s.mu.Lock()
+	saved := s.saved
+	s.mu.Unlock()
+	if saved == nil || saved.Key != snapshot.Key {
+		return nil, false
+	}
+
+	var changed []string
+	for path, file := range snapshot.Files {
+		if old, ok := saved.Files[path]; !ok || old.Hash != file.Hash {
+			changed = append(changed, path)
+		}
+	}
+	for path := range saved.Files {
+		if _, ok := snapshot.Files[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+
+	info, err := os.Stat(s.binary)
+	upToDate := err == nil && len(changed) == 0 && info.ModTime().Equal(saved.Binary.ModTime) && info.Size() == saved.Binary.Size
+	return changed, upToDate
+}
+
+// Built saves snapshot, taken before a build which succeeded, along with the
+// state of the binary it produced
+func (s *BuildState) Built(snapshot *FileIndex) error {
+	if s == nil || snapshot == nil {
+		return nil
+	}
+	info, err := os.Stat(s.binary)
+	if err != nil {
+		return err
+	}
+	index := *snapshot
+	index.Binary = FileState{ModTime: info.ModTime(), Size: info.Size()}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved = &index
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	// write and rename so a gin stopped meanwhile never leaves a partial file
+	tmp := filepath.Join(filepath.Dir(s.path), "."+filepath.Base(s.path)+".tmp")
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// file returns the saved state of the file at path
+func (x *FileIndex) file(path string) (FileState, bool) {
+	if x == nil {
+		return FileState{}, false
+	}
+	state, ok := x.Files[path]
+	return state, ok
+}
@@ -0,0 +1,99 @@
+package gin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildStateChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gin-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/app\n")
+	write("main.go", "package main\n")
+	write("util.go", "package main\n")
+	write("README.md", "not built from\n")
+	write("gin-bin", "binary")
+
+	opts := WatchOptions{Path: dir}
+	binary := filepath.Join(dir, "gin-bin")
+	state := NewBuildState(dir, opts, "key", binary)
+	if _, upToDate := state.Changes(state.Snapshot()); upToDate {
+		t.Error("the binary is up to date without a saved state")
+	}
+	if err := state.Built(state.Snapshot()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a later run of gin loads the saved state
+	state = NewBuildState(dir, opts, "key", binary)
+	if changed, upToDate := state.Changes(state.Snapshot()); !upToDate || len(changed) > 0 {
+		t.Errorf("Changes = %q, %v right after the build", changed, upToDate)
+	}
+
+	// saving the same content again doesn't count
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "main.go"), later, later)
+	write("README.md", "still not built from\n")
+	if changed, upToDate := state.Changes(state.Snapshot()); !upToDate || len(changed) > 0 {
+		t.Errorf("Changes = %q, %v after touching files", changed, upToDate)
+	}
+
+	write("main.go", "package main\n\nfunc main() {}\n")
+	os.Remove(filepath.Join(dir, "util.go"))
+	write("new.go", "package main\n")
+	changed, upToDate := state.Changes(state.Snapshot())
+	want := []string{filepath.Join(dir, "main.go"), filepath.Join(dir, "new.go"), filepath.Join(dir, "util.go")}
+	if upToDate || !reflect.DeepEqual(changed, want) {
+		t.Errorf("Changes = %q, %v, want %q, false", changed, upToDate, want)
+	}
+
+	// other build settings always rebuild
+	other := NewBuildState(dir, opts, "other key", binary)
+	if changed, upToDate := other.Changes(other.Snapshot()); upToDate || len(changed) > 0 {
+		t.Errorf("Changes = %q, %v with another key", changed, upToDate)
+	}
+}
+
+func TestBuildStateLocalModules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gin-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("GOWORK", "off")
+	defer os.Unsetenv("GOWORK")
+
+	app, shared := filepath.Join(dir, "app"), filepath.Join(dir, "shared")
+	os.Mkdir(app, 0755)
+	os.Mkdir(shared, 0755)
+	ioutil.WriteFile(filepath.Join(app, "go.mod"), []byte("module example.com/app\n\nreplace (\n\texample.com/shared => ../shared // local\n\texample.com/lib v1.0.0 => example.com/fork v1.0.1\n)\n"), 0644)
+	ioutil.WriteFile(filepath.Join(app, "gin-bin"), []byte("binary"), 0644)
+	ioutil.WriteFile(filepath.Join(shared, "shared.go"), []byte("package shared\n"), 0644)
+
+	if got, want := localModules(app), []string{shared}; !reflect.DeepEqual(got, want) {
+		t.Errorf("localModules = %q, want %q", got, want)
+	}
+
+	state := NewBuildState(app, WatchOptions{Path: app}, "key", filepath.Join(app, "gin-bin"))
+	if err := state.Built(state.Snapshot()); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(shared, "shared.go"), []byte("package shared\n\nconst X = 1\n"), 0644)
+	changed, upToDate := state.Changes(state.Snapshot())
+	if want := []string{filepath.Join(shared, "shared.go")}; upToDate || !reflect.DeepEqual(changed, want) {
+		t.Errorf("Changes = %q, %v, want %q, false", changed, upToDate, want)
+	}
+}
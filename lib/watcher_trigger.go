@@ -0,0 +1,80 @@
+package gin
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TriggerWatcher reports a change whenever its trigger file is touched or
+// Trigger is called, letting external tools request a rebuild.
+type TriggerWatcher struct {
+	file   string
+	since  time.Time
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newTriggerWatcher(opts WatchOptions) (Watcher, error) {
+	return NewTriggerWatcher(opts.TriggerPath(), opts.Interval), nil
+}
+
+// TriggerPath returns the path of the trigger file, relative ones being
+// below the watched path rather than the current directory
+func (o WatchOptions) TriggerPath() string {
+	if o.TriggerFile == "" || filepath.IsAbs(o.TriggerFile) {
+		return o.TriggerFile
+	}
+	return filepath.Join(o.Path, o.TriggerFile)
+}
+
+// NewTriggerWatcher creates a TriggerWatcher for the given file. An empty
+// file disables file based triggering.
+func NewTriggerWatcher(file string, interval time.Duration) *TriggerWatcher {
+	w := &TriggerWatcher{
+		file:   file,
+		since:  time.Now(),
+		events: make(chan Event),
+		done:   make(chan struct{}),
+	}
+	if file != "" {
+		go w.loop(interval)
+	}
+	return w
+}
+
+// Trigger reports a change to path as if it had been detected on disk
+func (w *TriggerWatcher) Trigger(path string) {
+	select {
+	case w.events <- Event{Path: path, Source: "trigger"}:
+	case <-w.done:
+	}
+}
+
+func (w *TriggerWatcher) Events() <-chan Event {
+	return w.events
+}
+
+func (w *TriggerWatcher) Close() error {
+	w.once.Do(func() { close(w.done) })
+	return nil
+}
+
+func (w *TriggerWatcher) loop(interval time.Duration) {
+	for {
+		select {
+		case <-time.After(interval):
+		case <-w.done:
+			return
+		}
+
+		info, err := os.Stat(w.file)
+		if err != nil || !info.ModTime().After(w.since) {
+			continue
+		}
+		w.since = info.ModTime()
+		w.Trigger(w.file)
+	}
+}
@@ -0,0 +1,269 @@
+package gin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Webhook events
+const (
+	// WebhookBuildFailed is sent when a build fails after a working one
+	WebhookBuildFailed = "build_failed"
+	// WebhookBuildRecovered is sent when a build works after failed ones
+	WebhookBuildRecovered = "build_recovered"
+	// WebhookCrashLoop is sent when the app keeps exiting right after it
+	// starts
+	WebhookCrashLoop = "crash_loop"
+)
+
+// WebhookEvents are the events webhooks can be sent for
+var WebhookEvents = []string{WebhookBuildFailed, WebhookBuildRecovered, WebhookCrashLoop}
+
+// WebhookTimeout is how long a webhook may take to respond
+var WebhookTimeout = 10 * time.Second
+
+// webhookDetailsMax is how much of the build errors or crash output is sent
+const webhookDetailsMax = 1500
+
+// WebhookEvent is sent to the webhooks, as the JSON body with the json
+// format and as the data of the URL template
+type WebhookEvent struct {
+	Event string    `json:"event"`
+	Name  string    `json:"name"`
+	Host  string    `json:"host"`
+	Time  time.Time `json:"time"`
+	// Message summarizes the event in a line
+	Message string `json:"message"`
+	// Cause describes the changes built, e.g. 1 file changed: main.go
+	Cause string `json:"cause,omitempty"`
+	// Details are the build errors or the last panic of the crashing app
+	Details string `json:"details,omitempty"`
+	Crashes int    `json:"crashes,omitempty"`
+}
+
+// WebhookFormat encodes an event as the body of a webhook request
+type WebhookFormat func(event WebhookEvent) ([]byte, error)
+
+var (
+	webhookMu      sync.Mutex
+	webhookFormats = map[string]WebhookFormat{
+		"json": func(event WebhookEvent) ([]byte, error) {
+			return json.Marshal(event)
+		},
+		"slack": func(event WebhookEvent) ([]byte, error) {
+			return json.Marshal(map[string]string{"text": chatMessage(event, "*")})
+		},
+		"discord": func(event WebhookEvent) ([]byte, error) {
+			return json.Marshal(map[string]string{"content": chatMessage(event, "**")})
+		},
+	}
+)
+
+// chatMessage formats event for chat services, with the message in bold
+// using marker and the details as a code block
+func chatMessage(event WebhookEvent, bold string) string {
+	text := bold + event.Message + bold
+	if event.Cause != "" {
+		text += "\n" + event.Cause
+	}
+	if event.Details != "" {
+		text += "\n```\n" + strings.Replace(event.Details, "```", "'''", -1) + "\n```"
+	}
+	return text
+}
+
+// RegisterWebhookFormat makes a WebhookFormat available under the given name
+func RegisterWebhookFormat(name string, format WebhookFormat) {
+	webhookMu.Lock()
+	defer webhookMu.Unlock()
+	webhookFormats[name] = format
+}
+
+// WebhookFormats returns the names of the registered WebhookFormats
+func WebhookFormats() []string {
+	webhookMu.Lock()
+	defer webhookMu.Unlock()
+
+	var names []string
+	for name := range webhookFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Webhook posts events to a URL
+type Webhook struct {
+	url    *template.Template
+	format WebhookFormat
+	events map[string]bool
+}
+
+// ParseWebhook parses a webhook like https://example.com/hook, prefixed
+// with a format and = for other formats than json, e.g.
+// slack=https://hooks.slack.com/services/... The URL is a text/template
+// executed with the WebhookEvent, e.g. https://example.com/{{.Event}}.
+// events limits the events sent, all if empty.
+func ParseWebhook(spec string, events []string) (*Webhook, error) {
+	name, rawURL := "json", spec
+	if i := strings.Index(spec, "="); i > 0 && !strings.Contains(spec[:i], "/") {
+		name, rawURL = spec[:i], spec[i+1:]
+	}
+
+	webhookMu.Lock()
+	format, ok := webhookFormats[name]
+	webhookMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown webhook format %q (available: %s)", name, strings.Join(WebhookFormats(), ", "))
+	}
+
+	tmpl, err := template.New("webhook").Option("missingkey=error").Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook URL %q: %v", rawURL, err)
+	}
+	var example bytes.Buffer
+	if err := tmpl.Execute(&example, WebhookEvent{Event: WebhookBuildFailed}); err != nil {
+		return nil, fmt.Errorf("invalid webhook URL %q: %v", rawURL, err)
+	}
+	if u, err := url.Parse(example.String()); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid webhook URL %q, expected an http or https URL", rawURL)
+	}
+
+	w := &Webhook{url: tmpl, format: format}
+	if len(events) > 0 {
+		known := make(map[string]bool)
+		for _, event := range WebhookEvents {
+			known[event] = true
+		}
+		w.events = make(map[string]bool)
+		for _, event := range events {
+			if !known[event] {
+				return nil, fmt.Errorf("unknown webhook event %q (available: %s)", event, strings.Join(WebhookEvents, ", "))
+			}
+			w.events[event] = true
+		}
+	}
+	return w, nil
+}
+
+// Send posts event unless the webhook ignores it
+func (w *Webhook) Send(client *http.Client, event WebhookEvent) error {
+	if w.events != nil && !w.events[event.Event] {
+		return nil
+	}
+
+	var target bytes.Buffer
+	if err := w.url.Execute(&target, event); err != nil {
+		return err
+	}
+	body, err := w.format(event)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Post(target.String(), "application/json", bytes.NewReader(body))
+	if err != nil {
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return fmt.Errorf("can't reach %s: %v", redactURL(target.String()), err)
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 4096))
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s responded %s", redactURL(target.String()), res.Status)
+	}
+	return nil
+}
+
+// Webhooks sends the events of a project to webhooks, in the background
+type Webhooks struct {
+	Name string
+
+	hooks  []*Webhook
+	client *http.Client
+	host   string
+
+	mu      sync.Mutex
+	failing bool
+}
+
+// NewWebhooks sends the events of the project name to hooks
+func NewWebhooks(name string, hooks []*Webhook) *Webhooks {
+	host, _ := os.Hostname()
+	return &Webhooks{
+		Name:   name,
+		hooks:  hooks,
+		client: &http.Client{Timeout: WebhookTimeout},
+		host:   host,
+	}
+}
+
+// Built sends WebhookBuildFailed when a build fails after a working one and
+// WebhookBuildRecovered when one works after failed ones. errors are the
+// errors of a failed build.
+func (w *Webhooks) Built(failed bool, errors string, cause string) {
+	w.mu.Lock()
+	was := w.failing
+	w.failing = failed
+	w.mu.Unlock()
+
+	switch {
+	case failed && !was:
+		w.Send(WebhookEvent{Event: WebhookBuildFailed, Message: "Build of " + w.Name + " failed", Cause: cause, Details: errors})
+	case !failed && was:
+		w.Send(WebhookEvent{Event: WebhookBuildRecovered, Message: "Build of " + w.Name + " works again", Cause: cause})
+	}
+}
+
+// CrashLoop sends WebhookCrashLoop, output is e.g. the trace of the last panic
+func (w *Webhooks) CrashLoop(crashes int, output string) {
+	w.Send(WebhookEvent{
+		Event:   WebhookCrashLoop,
+		Message: fmt.Sprintf("%s crashed %d times in a row right after starting", w.Name, crashes),
+		Details: output,
+		Crashes: crashes,
+	})
+}
+
+// Send fills in the project, host and time of event and sends it to every
+// webhook in the background, logging failures
+func (w *Webhooks) Send(event WebhookEvent) {
+	event.Name = w.Name
+	event.Host = w.host
+	event.Time = time.Now()
+	if len(event.Details) > webhookDetailsMax {
+		event.Details = event.Details[:webhookDetailsMax] + "\n..."
+	}
+
+	for _, hook := range w.hooks {
+		go func(hook *Webhook) {
+			Tracef("Sending the %s webhook", event.Event)
+			if err := hook.Send(w.client, event); err != nil {
+				log.Printf("Can't send the %s webhook: %v", event.Event, err)
+			}
+		}(hook)
+	}
+}
+
+// redactURL drops the path and query of a webhook URL for logs, since they
+// often contain the secret
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "URL"
+	}
+	return u.Scheme + "://" + u.Host
+}
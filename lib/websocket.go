@@ -0,0 +1,203 @@
+package gin
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// websocketGUID is appended to the client key to compute the accept key
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xA
+)
+
+// maxWebsocketFrame bounds the frames accepted from clients, which have no
+// reason to send more than pings
+const maxWebsocketFrame = 64 * 1024
+
+// websocketHub keeps the connected WebSocket clients and sends messages to
+// all of them. Messages from clients are ignored apart from pings and close.
+type websocketHub struct {
+	mu    sync.Mutex
+	conns map[*websocketConn]bool
+}
+
+type websocketConn struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newWebsocketHub() *websocketHub {
+	return &websocketHub{conns: make(map[*websocketConn]bool)}
+}
+
+// ServeHTTP upgrades the request to a WebSocket connection
+func (h *websocketHub) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(req.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(res, "expected a WebSocket upgrade", http.StatusBadRequest)
+		return
+	}
+
+	hijacker, ok := res.(http.Hijacker)
+	if !ok {
+		http.Error(res, "can't upgrade the connection", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return
+	}
+
+	c := &websocketConn{conn: conn}
+	h.mu.Lock()
+	h.conns[c] = true
+	h.mu.Unlock()
+
+	go func() {
+		c.readLoop(rw.Reader)
+		h.mu.Lock()
+		delete(h.conns, c)
+		h.mu.Unlock()
+		conn.Close()
+	}()
+}
+
+// Broadcast sends the text message to all connected clients
+func (h *websocketHub) Broadcast(message []byte) {
+	h.mu.Lock()
+	conns := make([]*websocketConn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range conns {
+		if err := c.write(wsText, message); err != nil {
+			c.conn.Close()
+		}
+	}
+}
+
+// Close disconnects all clients
+func (h *websocketHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.conns {
+		c.write(wsClose, nil)
+		c.conn.Close()
+	}
+}
+
+// readLoop answers pings until the client closes the connection
+func (c *websocketConn) readLoop(r *bufio.Reader) {
+	for {
+		opcode, payload, err := readFrame(r)
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case wsPing:
+			c.write(wsPong, payload)
+		case wsClose:
+			c.write(wsClose, nil)
+			return
+		}
+	}
+}
+
+func (c *websocketConn) write(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// servers send unmasked, unfragmented frames
+	header := []byte{0x80 | opcode, 0}
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+	default:
+		header[1] = 127
+		header = append(header, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+
+	if _, err := c.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(payload)
+	return err
+}
+
+// readFrame reads a masked frame sent by a client
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > maxWebsocketFrame {
+		return 0, nil, errors.New("websocket frame too large")
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return opcode, payload, nil
+}
@@ -0,0 +1,125 @@
+package gin
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dialWebsocket upgrades a connection to the hub served by server
+func dialWebsocket(t *testing.T, server *httptest.Server) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	// the key and accept key of the example in RFC 6455
+	conn.Write([]byte("GET /_gin/events HTTP/1.1\r\nHost: localhost\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status %d, want %d", res.StatusCode, http.StatusSwitchingProtocols)
+	}
+	if accept := res.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Sec-WebSocket-Accept = %q", accept)
+	}
+	return conn, r
+}
+
+// maskedFrame encodes a frame like clients send it
+func maskedFrame(opcode byte, payload []byte) []byte {
+	mask := []byte{1, 2, 3, 4}
+	frame := append([]byte{0x80 | opcode, 0x80 | byte(len(payload))}, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+func TestWebsocketHub(t *testing.T) {
+	hub := newWebsocketHub()
+	server := httptest.NewServer(hub)
+	defer server.Close()
+	defer hub.Close()
+
+	conn, r := dialWebsocket(t, server)
+	defer conn.Close()
+
+	conn.Write(maskedFrame(wsPing, []byte("hi")))
+	opcode, payload, err := readFrame(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opcode != wsPong || string(payload) != "hi" {
+		t.Errorf("answer to ping: opcode %#x %q, want a pong with %q", opcode, payload, "hi")
+	}
+
+	// the ping was answered, so the client is registered
+	message := []byte(strings.Repeat("x", 300))
+	hub.Broadcast(message)
+	opcode, payload, err = readFrame(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opcode != wsText || !bytes.Equal(payload, message) {
+		t.Errorf("broadcast: opcode %#x, %d bytes, want text with %d bytes", opcode, len(payload), len(message))
+	}
+
+	conn.Write(maskedFrame(wsClose, nil))
+	if opcode, _, err := readFrame(r); err != nil || opcode != wsClose {
+		t.Errorf("answer to close: opcode %#x, %v", opcode, err)
+	}
+}
+
+func TestProxyWebsocket(t *testing.T) {
+	hub := newWebsocketHub()
+	app := httptest.NewServer(hub)
+	defer app.Close()
+	defer hub.Close()
+	to, _ := url.Parse(app.URL)
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		proxyWebsocket(res, req, to, TransportOptions{DialTimeout: time.Second})
+	}))
+	defer proxy.Close()
+
+	// the upgrade and the frames in both directions pass through the proxy
+	conn, r := dialWebsocket(t, proxy)
+	defer conn.Close()
+	conn.Write(maskedFrame(wsPing, nil))
+	if opcode, _, err := readFrame(r); err != nil || opcode != wsPong {
+		t.Fatalf("answer to ping: opcode %#x, %v", opcode, err)
+	}
+	hub.Broadcast([]byte("rebuilt"))
+	if opcode, payload, err := readFrame(r); err != nil || opcode != wsText || string(payload) != "rebuilt" {
+		t.Errorf("broadcast: opcode %#x %q, %v", opcode, payload, err)
+	}
+}
+
+func TestWebsocketHubRejectsPlainRequests(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newWebsocketHub().ServeHTTP(rec, httptest.NewRequest("GET", "/_gin/events", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestReadFrameTooLarge(t *testing.T) {
+	frame := []byte{0x80 | wsText, 127, 0, 0, 0, 0, 0, 0x10, 0, 0}
+	if _, _, err := readFrame(bufio.NewReader(bytes.NewReader(frame))); err == nil {
+		t.Error("readFrame accepted a 1MB frame")
+	}
+}
@@ -0,0 +1,229 @@
+package gin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a line of a YAML document without its indentation
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+// yamlParser parses the block style subset of YAML used by the config files
+// of other tools: nested mappings and sequences of scalars, flow sequences
+// and quoted strings. Anchors, multi-line strings and several documents
+// aren't supported.
+type yamlParser struct {
+	lines []yamlLine
+	i     int
+}
+
+// parseYAML parses data into values decoded like encoding/json does into an
+// interface{}
+func parseYAML(data []byte) (interface{}, error) {
+	p := &yamlParser{}
+	for n, line := range strings.Split(string(data), "\n") {
+		text := strings.TrimRight(stripYAMLComment(line), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs can't indent YAML", n+1)
+		}
+		p.lines = append(p.lines, yamlLine{number: n + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+
+	value, err := p.parse(p.lines[0].indent)
+	if err == nil && p.i < len(p.lines) {
+		err = fmt.Errorf("line %d: unexpected indentation", p.lines[p.i].number)
+	}
+	return value, err
+}
+
+// parse parses the mapping or sequence whose entries are indented by indent
+func (p *yamlParser) parse(indent int) (interface{}, error) {
+	if isYAMLItem(p.lines[p.i].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+// sequence parses the items of a sequence indented by indent
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	values := []interface{}{}
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent && isYAMLItem(p.lines[p.i].text) {
+		line := p.lines[p.i]
+		rest := strings.TrimLeft(line.text[1:], " ")
+		switch {
+		case rest == "":
+			p.i++
+			value, err := p.nested(indent)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		case isYAMLItem(rest) || yamlKey(rest) != "":
+			// the item starts a nested block on its own line, continue
+			// parsing it from there
+			p.lines[p.i] = yamlLine{number: line.number, indent: indent + len(line.text) - len(rest), text: rest}
+			value, err := p.parse(p.lines[p.i].indent)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		default:
+			p.i++
+			value, err := yamlScalar(rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line.number, err)
+			}
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+// mapping parses the entries of a mapping indented by indent
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	values := make(map[string]interface{})
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent && !isYAMLItem(p.lines[p.i].text) {
+		line := p.lines[p.i]
+		key := yamlKey(line.text)
+		if key == "" {
+			return nil, fmt.Errorf("line %d: expected key: value", line.number)
+		}
+		rest := strings.TrimSpace(line.text[len(key)+1:])
+		if unquoted, err := yamlScalar(key); err == nil && (key[0] == '"' || key[0] == '\'') {
+			key = fmt.Sprint(unquoted)
+		}
+		p.i++
+
+		if rest != "" {
+			value, err := yamlScalar(rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line.number, err)
+			}
+			values[key] = value
+			continue
+		}
+		// sequences may be indented like the key they belong to
+		if p.i < len(p.lines) && p.lines[p.i].indent == indent && isYAMLItem(p.lines[p.i].text) {
+			value, err := p.sequence(indent)
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+			continue
+		}
+		value, err := p.nested(indent)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// nested parses the block indented deeper than indent on the next line, nil
+// if there is none
+func (p *yamlParser) nested(indent int) (interface{}, error) {
+	if p.i >= len(p.lines) || p.lines[p.i].indent <= indent {
+		return nil, nil
+	}
+	return p.parse(p.lines[p.i].indent)
+}
+
+// isYAMLItem reports whether text starts an item of a sequence
+func isYAMLItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// yamlKey returns the key of a mapping entry starting text, empty if text
+// isn't one
+func yamlKey(text string) string {
+	if text[0] == '"' || text[0] == '\'' {
+		end := strings.IndexByte(text[1:], text[0])
+		if end < 0 || !strings.HasPrefix(text[end+2:], ":") {
+			return ""
+		}
+		return text[:end+2]
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i == len(text)-1 || text[i+1] == ' ') {
+			return text[:i]
+		}
+	}
+	return ""
+}
+
+// yamlScalar decodes a scalar or flow sequence
+func yamlScalar(text string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("expected ] at the end of %s", text)
+		}
+		values := []interface{}{}
+		for _, item := range strings.Split(text[1:len(text)-1], ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			value, err := yamlScalar(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case strings.HasPrefix(text, "{"):
+		return nil, fmt.Errorf("flow mappings aren't supported")
+	case strings.HasPrefix(text, "|") || strings.HasPrefix(text, ">"):
+		return nil, fmt.Errorf("multi-line strings aren't supported")
+	case strings.HasPrefix(text, `"`):
+		return strconv.Unquote(text)
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("unterminated string %s", text)
+		}
+		return strings.Replace(text[1:len(text)-1], "''", "'", -1), nil
+	}
+
+	switch text {
+	case "true", "yes", "on":
+		return true, nil
+	case "false", "no", "off":
+		return false, nil
+	case "null", "~":
+		return nil, nil
+	}
+	if number, err := strconv.ParseFloat(text, 64); err == nil {
+		return number, nil
+	}
+	return text, nil
+}
+
+// stripYAMLComment removes a # comment outside of quotes from line
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" \t-:[,", line[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
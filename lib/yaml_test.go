@@ -0,0 +1,65 @@
+package gin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	data := `---
+settings:
+  legacy:
+    force: false   # a comment
+schema:
+- name: "api # not a comment"
+  path: .
+  args: [--debug, "-v"]
+  watcher:
+    extensions:
+      - go
+      - 'tmpl'
+    ignored_paths:
+    - vendor
+  env:
+    PORT: 8080
+    EMPTY: ~
+`
+	got, err := parseYAML([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"settings": map[string]interface{}{
+			"legacy": map[string]interface{}{"force": false},
+		},
+		"schema": []interface{}{
+			map[string]interface{}{
+				"name": "api # not a comment",
+				"path": ".",
+				"args": []interface{}{"--debug", "-v"},
+				"watcher": map[string]interface{}{
+					"extensions":    []interface{}{"go", "tmpl"},
+					"ignored_paths": []interface{}{"vendor"},
+				},
+				"env": map[string]interface{}{"PORT": 8080.0, "EMPTY": nil},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseYAML =\n%#v\nwant\n%#v", got, want)
+	}
+}
+
+func TestParseYAMLUnsupported(t *testing.T) {
+	for _, data := range []string{
+		"key: |\n  text\n",
+		"key: {a: 1}\n",
+		"key:\n\t- tab\n",
+		"a: 1\n  b: 2\n",
+		"key: [a, b\n",
+	} {
+		if _, err := parseYAML([]byte(data)); err == nil {
+			t.Errorf("parseYAML accepted %q", data)
+		}
+	}
+}
@@ -1,51 +1,118 @@
 package main
 
 import (
-	"errors"
+	"compress/gzip"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"reload-gode/lib"
 )
 
+// configFile is read from the working directory for default option values
+const configFile = "gin.json"
+
+// set with -ldflags "-X main.version=v1.2.3 -X main.commit=abc123" by releases
+var (
+	version string
+	commit  string
+)
+
 var (
-	startTime  = time.Now()
-	logger     = log.New(os.Stdout, "[gin] ", 0)
-	immediate  = false
-	colorGreen = string([]byte{27, 91, 57, 55, 59, 51, 50, 59, 49, 109})
-	colorRed   = string([]byte{27, 91, 57, 55, 59, 51, 49, 59, 49, 109})
-	colorReset = string([]byte{27, 91, 48, 109})
+	logger    = log.New(os.Stdout, "[gin] ", 0)
+	immediate = false
+	once      = false
+	benchmark = false
+	status    = &gin.StatusDisplay{}
+	builds    *gin.BuildStore
+	panics    = &gin.Panics{}
+
+	diagnosticsFormat string
+	diagnosticsFile   string
+	stopOnBuildError  bool
+	control           *gin.ControlServer
+	dashboard         *gin.Dashboard
+	published         []string
+	tunnel            gin.Tunnel
+	mdns              *gin.MDNS
+	daemon            *gin.Daemon
+	systemd           *gin.Systemd
+	webhooks          *gin.Webhooks
+	crashLoop         = gin.NewCrashLoop()
+	plugins           []*gin.Plugin
+	configEnv         gin.Env
+	health            *gin.Health
+	buildHistory      = gin.NewBuildHistory("")
+	tracer            *gin.Tracer
+	sentinel          *gin.Sentinel
+	buildState        *gin.BuildState
+	colorGreen        = string([]byte{27, 91, 57, 55, 59, 51, 50, 59, 49, 109})
+	colorRed          = string([]byte{27, 91, 57, 55, 59, 51, 49, 59, 49, 109})
+	colorReset        = string([]byte{27, 91, 48, 109})
+	colorCyan         = string([]byte{27, 91, 57, 55, 59, 51, 54, 59, 49, 109})
 )
 
 func main() {
+	gin.SetLogger(logger)
 	app := gin.NewApp()
 	app.Name = "gin"
 	app.Usage = "A live reload utility for Go web applications."
 	app.Action = mainAction
+	app.EnableBashCompletion = true
+	app.Version = gin.ReadBuildInfo(version, commit).Version
+	app.ConfigFile = configFile
+	app.PersistentFlags = true
+	app.ConfigSections = []string{"mocks", "plugins", "env", "middlewareChain"}
+	gin.ConfigFlag.EnvVar = "GIN_CONFIG"
+	gin.ConfigFlag.Usage = "JSON file with default values for the options, keyed by their long names (default: " + configFile + ")"
+	// -v is short for --verbose
+	gin.VersionFlag = gin.BoolFlag{
+		Name:  "version",
+		Usage: "print the version",
+	}
 	app.Flags = []gin.Flag{
-		gin.StringFlag{
+		gin.StringSliceFlag{
 			Name:   "laddr,l",
-			Value:  "",
+			Value:  &gin.StringSlice{},
 			EnvVar: "GIN_LADDR",
-			Usage:  "listening address for the proxy server",
+			Usage:  "listening address for the proxy server, can be repeated, e.g. 127.0.0.1, [::1], dev.local or https://192.168.1.5:3443",
+		},
+		gin.BoolFlag{
+			Name:   "noQRCode",
+			EnvVar: "GIN_NO_QR_CODE",
+			Usage:  "don't print a QR code of the proxy URL when --laddr is reachable from the local network",
 		},
 		gin.IntFlag{
-			Name:   "port,p",
-			Value:  3000,
-			EnvVar: "GIN_PORT",
-			Usage:  "port for the proxy server",
+			Name:      "port,p",
+			Value:     3000,
+			EnvVar:    "GIN_PORT",
+			Usage:     "port for the proxy server, 0 picks a free one",
+			Validator: gin.IntRange(0, 65535),
 		},
 		gin.IntFlag{
-			Name:   "appPort,a",
-			Value:  3001,
-			EnvVar: "BIN_APP_PORT",
-			Usage:  "port for the Go web server",
+			Name:      "appPort,a",
+			Value:     3001,
+			EnvVar:    "BIN_APP_PORT",
+			Usage:     "port for the Go web server, 0 picks a free one",
+			Validator: gin.IntRange(0, 65535),
 		},
 		gin.StringFlag{
 			Name:   "bin,b",
@@ -53,17 +120,84 @@ func main() {
 			EnvVar: "GIN_BIN",
 			Usage:  "name of generated binary file",
 		},
+		gin.PathFlag{
+			Name:      "path,t",
+			MustExist: true,
+			Directory: true,
+			Value:     ".",
+			EnvVar:    "GIN_PATH",
+			Usage:     "Path to watch files from",
+		},
+		gin.PathFlag{
+			Name:      "build,d",
+			MustExist: true,
+			Directory: true,
+			Value:     "",
+			EnvVar:    "GIN_BUILD",
+			Usage:     "Path to build files from (defaults to same value as --path)",
+		},
+		gin.PathFlag{
+			Name:      "target",
+			MustExist: true,
+			Directory: true,
+			EnvVar:    "GIN_TARGET",
+			Usage:     "main package to build, e.g. ./cmd/server, also given as the first argument of gin run; names the binary gin-<dir> unless --bin is set",
+		},
+		gin.PathFlag{
+			Name:      "appDir",
+			MustExist: true,
+			Directory: true,
+			EnvVar:    "GIN_APP_DIR",
+			Usage:     "working directory of the app (default: the current directory)",
+		},
 		gin.StringFlag{
-			Name:   "path,t",
-			Value:  ".",
-			EnvVar: "GIN_PATH",
-			Usage:  "Path to watch files from",
+			Name:   "appUmask",
+			EnvVar: "GIN_APP_UMASK",
+			Usage:  "umask of the app, e.g. 077",
+			Validator: func(umask string) error {
+				if _, err := strconv.ParseUint(umask, 8, 32); umask != "" && err != nil {
+					return fmt.Errorf("expected an octal number such as 022")
+				}
+				return nil
+			},
+		},
+		gin.IntFlag{
+			Name:   "appNice",
+			EnvVar: "GIN_APP_NICE",
+			Usage:  "nice level of the app, e.g. 10 to keep it from slowing down the machine",
 		},
 		gin.StringFlag{
-			Name:   "build,d",
-			Value:  "",
-			EnvVar: "GIN_BUILD",
-			Usage:  "Path to build files from (defaults to same value as --path)",
+			Name:   "appMemoryLimit",
+			EnvVar: "GIN_APP_MEMORY_LIMIT",
+			Usage:  "maximum address space of the app, e.g. 2G (Linux only)",
+		},
+		gin.DurationFlag{
+			Name:   "appCPULimit",
+			EnvVar: "GIN_APP_CPU_LIMIT",
+			Usage:  "CPU time after which the app is killed, e.g. 10m (Linux only)",
+		},
+		gin.BoolFlag{
+			Name:   "noProcessGroup",
+			EnvVar: "GIN_NO_PROCESS_GROUP",
+			Usage:  "don't start the app in its own process group, so processes it spawns survive restarts",
+		},
+		gin.StringSliceFlag{
+			Name:   "waitFor",
+			EnvVar: "GIN_WAIT_FOR",
+			Usage:  "host:port which must accept TCP connections before the app is started, e.g. of a database (repeatable)",
+		},
+		gin.DurationFlag{
+			Name:   "waitTimeout",
+			Value:  time.Minute,
+			EnvVar: "GIN_WAIT_TIMEOUT",
+			Usage:  "how long to wait for --waitFor addresses before starting the app anyway",
+		},
+		gin.StringSliceFlag{
+			Name:      "envFile,e",
+			Value:     &gin.StringSlice{},
+			EnvVar:    "GIN_ENV_FILE",
+			Usage:     "env file to load, can be repeated with later files overriding earlier ones (default: .env and .env.local)",
+			TakesFile: true,
 		},
 		gin.StringSliceFlag{
 			Name:   "excludeDir,x",
@@ -71,16 +205,73 @@ func main() {
 			EnvVar: "GIN_EXCLUDE_DIR",
 			Usage:  "Relative directories to exclude",
 		},
+		gin.StringSliceFlag{
+			Name:   "restartPattern",
+			Value:  &gin.StringSlice{},
+			EnvVar: "GIN_RESTART_PATTERN",
+			Usage:  "files that only restart the app without rebuilding it, can be repeated (always includes the env files and config.yaml)",
+		},
+		gin.StringFlag{
+			Name:   "reloadSignal",
+			EnvVar: "GIN_RELOAD_SIGNAL",
+			Usage:  "signal sent to the app instead of restarting it when files matching a --restartPattern or config.yaml change, e.g. SIGHUP",
+		},
+		gin.StringSliceFlag{
+			Name:   "reloadPattern",
+			Value:  &gin.StringSlice{},
+			EnvVar: "GIN_RELOAD_PATTERN",
+			Usage:  "files the app reads whenever it uses them, e.g. templates/*.html, which neither rebuild nor restart it but notify the clients of /_gin/events, can be repeated",
+		},
 		gin.BoolFlag{
 			Name:   "immediate,i",
 			EnvVar: "GIN_IMMEDIATE",
 			Usage:  "run the server immediately after it's built",
 		},
+		gin.DurationFlag{
+			Name:   "retryInterval",
+			EnvVar: "GIN_RETRY_INTERVAL",
+			Usage:  "retry builds which failed to reach the network, e.g. to download modules, after this interval, e.g. 30s (default: off)",
+		},
+		gin.BoolFlag{
+			Name:   "stopOnBuildError",
+			EnvVar: "GIN_STOP_ON_BUILD_ERROR",
+			Usage:  "stop the app when a build fails and answer requests with the errors, instead of keeping the last working build running",
+		},
 		gin.BoolFlag{
 			Name:   "all",
 			EnvVar: "GIN_ALL",
 			Usage:  "reloads whenever any file changes, as opposed to reloading only on .go file change",
 		},
+		gin.BoolFlag{
+			Name:   "history",
+			EnvVar: "GIN_HISTORY",
+			Usage:  "record the diff of the changes that triggered each rebuild, see gin history",
+		},
+		gin.PathFlag{
+			Name:   "buildLog",
+			EnvVar: "GIN_BUILD_LOG",
+			Usage:  "file to append each build to as a line of JSON, so gin stats covers past sessions too",
+		},
+		gin.StringFlag{
+			Name:   "otlpEndpoint",
+			EnvVar: "GIN_OTLP_ENDPOINT,OTEL_EXPORTER_OTLP_ENDPOINT",
+			Usage:  "OpenTelemetry collector to export traces of the reloads and proxied requests to over OTLP/HTTP, e.g. http://localhost:4318",
+		},
+		gin.StringSliceFlag{
+			Name:   "otlpHeader",
+			EnvVar: "GIN_OTLP_HEADER",
+			Usage:  "header sent with every export to the collector, e.g. \"Authorization: Bearer token\"",
+		},
+		gin.BoolFlag{
+			Name:   "alwaysBuild",
+			EnvVar: "GIN_ALWAYS_BUILD",
+			Usage:  "build at startup even if no file changed since the last build, which gin otherwise skips",
+		},
+		gin.BoolFlag{
+			Name:   "warmCache",
+			EnvVar: "GIN_WARM_CACHE",
+			Usage:  "compile all packages in the background at startup so the first rebuild hits a hot build cache",
+		},
 		gin.BoolFlag{
 			Name:   "godep,g",
 			EnvVar: "GIN_GODEP",
@@ -92,14 +283,383 @@ func main() {
 			Usage:  "Additional go build arguments",
 		},
 		gin.StringFlag{
-			Name:   "certFile",
-			EnvVar: "GIN_CERT_FILE",
-			Usage:  "TLS Certificate",
+			Name:   "tags",
+			EnvVar: "GIN_TAGS",
+			Usage:  "build tags passed to go build",
+		},
+		gin.StringFlag{
+			Name:   "ldflags",
+			EnvVar: "GIN_LDFLAGS",
+			Usage:  "linker flags passed to go build",
+		},
+		gin.StringFlag{
+			Name:   "gcflags",
+			EnvVar: "GIN_GCFLAGS",
+			Usage:  "compiler flags passed to go build",
+		},
+		gin.BoolFlag{
+			Name:   "race",
+			EnvVar: "GIN_RACE",
+			Usage:  "build with the race detector enabled",
+		},
+		gin.StringFlag{
+			Name:   "devVersion",
+			EnvVar: "GIN_DEV_VERSION",
+			Usage:  "variable set to dev-<timestamp> on each build through -ldflags -X, e.g. main.version",
+		},
+		gin.StringFlag{
+			Name:      "runner",
+			Value:     "local",
+			EnvVar:    "GIN_RUNNER",
+			Usage:     "how the app is run: local, docker or ssh (implied by --remote)",
+			Validator: gin.OneOf("local", "docker", "ssh"),
+		},
+		gin.StringFlag{
+			Name:   "dockerImage",
+			Value:  "debian:stable-slim",
+			EnvVar: "GIN_DOCKER_IMAGE",
+			Usage:  "image the app runs in with --runner docker",
+		},
+		gin.StringSliceFlag{
+			Name:   "dockerVolume",
+			Value:  &gin.StringSlice{},
+			EnvVar: "GIN_DOCKER_VOLUME",
+			Usage:  "additional volume mounted with --runner docker, can be repeated, e.g. ./data:/data",
+		},
+		gin.StringFlag{
+			Name:   "dockerArgs",
+			EnvVar: "GIN_DOCKER_ARGS",
+			Usage:  "additional docker run arguments used with --runner docker",
+		},
+		gin.StringFlag{
+			Name:   "goos",
+			EnvVar: "GIN_GOOS",
+			Usage:  "target operating system for cross-compiling",
+		},
+		gin.StringFlag{
+			Name:   "goarch",
+			EnvVar: "GIN_GOARCH",
+			Usage:  "target architecture for cross-compiling",
+		},
+		gin.StringFlag{
+			Name:   "remote",
+			EnvVar: "GIN_REMOTE",
+			Usage:  "ssh destination to deploy and run the binary on, e.g. pi@raspberrypi",
+		},
+		gin.StringFlag{
+			Name:   "remoteDir",
+			Value:  "/tmp/gin",
+			EnvVar: "GIN_REMOTE_DIR",
+			Usage:  "directory on the remote host the binary is copied to",
+		},
+		gin.BoolFlag{
+			Name:   "rsync",
+			EnvVar: "GIN_RSYNC",
+			Usage:  "copy the binary to the remote host with rsync instead of scp",
+		},
+		gin.BoolFlag{
+			Name:   "debug",
+			EnvVar: "GIN_DEBUG",
+			Usage:  "build without optimizations and run the app under a headless delve server",
+		},
+		gin.StringFlag{
+			Name:   "debugAddr",
+			Value:  "127.0.0.1:2345",
+			EnvVar: "GIN_DEBUG_ADDR",
+			Usage:  "listening address of the delve server used with --debug",
+		},
+		gin.PathFlag{
+			Name:      "certFile",
+			MustExist: true,
+			EnvVar:    "GIN_CERT_FILE",
+			Usage:     "TLS Certificate",
+		},
+		gin.PathFlag{
+			Name:      "keyFile",
+			MustExist: true,
+			EnvVar:    "GIN_KEY_FILE",
+			Usage:     "TLS Certificate Key",
+		},
+		gin.StringFlag{
+			Name:   "watcher,w",
+			Value:  "poll",
+			EnvVar: "GIN_WATCHER",
+			Usage:  "change detection backends (poll, fsnotify, fsevents, trigger, deps), combined with '+'",
+		},
+		gin.BoolFlag{
+			Name:   "watchDeps",
+			EnvVar: "GIN_WATCH_DEPS",
+			Usage:  "rebuild when go.mod, go.sum, vendor/ or locally replaced dependencies change",
+		},
+		gin.PathFlag{
+			Name:   "triggerFile",
+			Value:  ".gin-trigger",
+			EnvVar: "GIN_TRIGGER_FILE",
+			Usage:  "file that triggers a rebuild when touched, relative to --path (used by the trigger watcher)",
+		},
+		gin.StringFlag{
+			Name:   "readyRegex",
+			EnvVar: "GIN_READY_REGEX",
+			Usage:  "wait for the app to print a line matching this pattern before proxying, e.g. \"Listening on .*\"",
+		},
+		gin.DurationFlag{
+			Name:   "readyTimeout",
+			Value:  30 * time.Second,
+			EnvVar: "GIN_READY_TIMEOUT",
+			Usage:  "how long to wait for --readyRegex to match",
+		},
+		gin.StringFlag{
+			Name:   "controlAddr",
+			EnvVar: "GIN_CONTROL_ADDR",
+			Usage:  "listening address of the control API, e.g. 127.0.0.1:3030 or https://0.0.0.0:3030 (disabled by default)",
+		},
+		gin.StringFlag{
+			Name:   "controlToken",
+			EnvVar: "GIN_CONTROL_TOKEN",
+			Usage:  "token clients of the control API must send",
+		},
+		gin.BoolFlag{
+			Name:   "allowRoot",
+			EnvVar: "GIN_ALLOW_ROOT",
+			Usage:  "run even as root, although gin builds and runs any code in the watched files",
+		},
+		gin.BoolFlag{
+			Name:   "prefixOutput",
+			EnvVar: "GIN_PREFIX_OUTPUT",
+			Usage:  "prefix the lines the app writes to stdout and stderr with out| and err|",
+		},
+		gin.BoolFlag{
+			Name:   "timestamps",
+			EnvVar: "GIN_TIMESTAMPS",
+			Usage:  "prefix the lines the app writes with the time",
+		},
+		gin.StringFlag{
+			Name:      "diagnosticsFormat",
+			EnvVar:    "GIN_DIAGNOSTICS_FORMAT",
+			Usage:     "write the build errors for editors in this format: json, checkstyle or lsp",
+			Validator: gin.OneOf(gin.DiagnosticsFormats()...),
+		},
+		gin.PathFlag{
+			Name:   "diagnosticsFile",
+			EnvVar: "GIN_DIAGNOSTICS_FILE",
+			Usage:  "file the build errors are written to (default: .gin/diagnostics.<format>)",
+		},
+		gin.BoolFlag{
+			Name:   "tui",
+			EnvVar: "GIN_TUI",
+			Usage:  "show a dashboard with the build state, restarts, recent changes, request rate and logs",
+		},
+		gin.StringFlag{
+			Name:      "links",
+			EnvVar:    "GIN_LINKS",
+			Usage:     "add hyperlinks to the file locations in stack traces: file or vscode",
+			Validator: gin.OneOf("file", "vscode"),
+		},
+		gin.PathFlag{
+			Name:   "logFile",
+			EnvVar: "GIN_LOG_FILE",
+			Usage:  "file the output of the app is copied to, with timestamps",
+		},
+		gin.StringFlag{
+			Name:   "logMaxSize",
+			Value:  "10M",
+			EnvVar: "GIN_LOG_MAX_SIZE",
+			Usage:  "size at which the log file is rotated, 0 disables rotation",
+			Validator: func(size string) error {
+				_, err := gin.ParseBytes(size)
+				return err
+			},
+		},
+		gin.IntFlag{
+			Name:   "logKeep",
+			Value:  3,
+			EnvVar: "GIN_LOG_KEEP",
+			Usage:  "number of rotated log files kept",
+		},
+		gin.IntFlag{
+			Name:   "keepBuilds",
+			Value:  3,
+			EnvVar: "GIN_KEEP_BUILDS",
+			Usage:  "number of previous builds kept for gin swap, 0 disables",
+		},
+		gin.StringFlag{
+			Name:   "auth",
+			EnvVar: "GIN_AUTH",
+			Usage:  "require clients of the proxy to log in with these credentials, as user:password",
+			Validator: func(value string) error {
+				if value == "" {
+					return nil
+				}
+				_, _, err := gin.ParseCredentials(value)
+				return err
+			},
+		},
+		gin.StringSliceFlag{
+			Name:   "allowCIDR",
+			EnvVar: "GIN_ALLOW_CIDR",
+			Usage:  "only accept clients of the proxy from this network, e.g. 192.168.1.0/24, list 127.0.0.1 for this machine (repeatable)",
+		},
+		gin.DurationFlag{
+			Name:   "dialTimeout",
+			EnvVar: "GIN_DIAL_TIMEOUT",
+			Value:  10 * time.Second,
+			Usage:  "how long the proxy may take to connect to the app",
+		},
+		gin.DurationFlag{
+			Name:   "responseHeaderTimeout",
+			EnvVar: "GIN_RESPONSE_HEADER_TIMEOUT",
+			Usage:  "how long the app may take to send the headers of a response, 0 waits forever",
+		},
+		gin.IntFlag{
+			Name:   "maxIdleConns",
+			EnvVar: "GIN_MAX_IDLE_CONNS",
+			Value:  10,
+			Usage:  "number of idle connections to the app kept open for reuse",
+		},
+		gin.DurationFlag{
+			Name:   "idleConnTimeout",
+			EnvVar: "GIN_IDLE_CONN_TIMEOUT",
+			Value:  90 * time.Second,
+			Usage:  "how long idle connections to the app are kept open",
+		},
+		gin.IntFlag{
+			Name:   "proxyRetries",
+			EnvVar: "GIN_PROXY_RETRIES",
+			Value:  10,
+			Usage:  "how often requests without a body are retried while the app refuses connections, e.g. during a restart",
+		},
+		gin.DurationFlag{
+			Name:   "proxyRetryDelay",
+			EnvVar: "GIN_PROXY_RETRY_DELAY",
+			Value:  100 * time.Millisecond,
+			Usage:  "pause between the retries of --proxyRetries",
+		},
+		gin.StringSliceFlag{
+			Name:   "route",
+			EnvVar: "GIN_ROUTE",
+			Usage:  "send requests under a path prefix to another server instead of the app, e.g. /=5173 or /assets=http://localhost:8080, the longest prefix wins (repeatable)",
+		},
+		gin.StringSliceFlag{
+			Name:   "vhost",
+			EnvVar: "GIN_VHOST",
+			Usage:  "send requests for a host name to a server, e.g. web.local.test=5173 or api.local.test=app, see gin hosts (repeatable)",
+		},
+		gin.StringFlag{
+			Name:      "tunnel",
+			EnvVar:    "GIN_TUNNEL",
+			Usage:     "expose the proxy on a public URL, e.g. for webhooks, through " + strings.Join(gin.Tunnels(), ", "),
+			Validator: gin.OneOf(gin.Tunnels()...),
+		},
+		gin.StringFlag{
+			Name:   "mdns",
+			EnvVar: "GIN_MDNS",
+			Usage:  "advertise the proxy on the local network as name.local, e.g. myapp for http://myapp.local:3000",
+		},
+		gin.StringSliceFlag{
+			Name:   "middleware",
+			EnvVar: "GIN_MIDDLEWARE",
+			Usage:  "proxy middleware such as accesslog, \"headers=Name: value\" or \"requestHeaders=Name: value\", applied in the given order (repeatable)",
+		},
+		gin.BoolFlag{
+			Name:   "cors",
+			EnvVar: "GIN_CORS",
+			Usage:  "allow cross-origin requests to the app, e.g. from a frontend dev server, and answer preflight requests",
+		},
+		gin.StringSliceFlag{
+			Name:   "corsOrigin",
+			EnvVar: "GIN_CORS_ORIGIN",
+			Usage:  "origin allowed by --cors, e.g. http://localhost:5173, implies --cors (repeatable, default: any)",
+		},
+		gin.StringSliceFlag{
+			Name:   "corsMethod",
+			EnvVar: "GIN_CORS_METHOD",
+			Usage:  "method allowed by --cors (repeatable, default: " + strings.Join(gin.DefaultCORSMethods, ", ") + ")",
+		},
+		gin.DurationFlag{
+			Name:   "delay",
+			EnvVar: "GIN_DELAY",
+			Usage:  "delay proxied requests by this long, e.g. 200ms",
+		},
+		gin.DurationFlag{
+			Name:   "delayJitter",
+			EnvVar: "GIN_DELAY_JITTER",
+			Usage:  "add up to this much random delay to proxied requests",
+		},
+		gin.Float64Flag{
+			Name:   "failRate",
+			EnvVar: "GIN_FAIL_RATE",
+			Usage:  "fraction of proxied requests answered with 503 instead, e.g. 0.05",
+			Validator: func(rate float64) error {
+				if rate < 0 || rate > 1 {
+					return fmt.Errorf("expected a value between 0 and 1")
+				}
+				return nil
+			},
+		},
+		gin.StringFlag{
+			Name:   "faultPath",
+			EnvVar: "GIN_FAULT_PATH",
+			Usage:  "only delay or fail requests whose path matches this regular expression, e.g. ^/api/",
+		},
+		gin.PathFlag{
+			Name:   "record",
+			EnvVar: "GIN_RECORD",
+			Usage:  "record the proxied requests to this file, as HAR if it ends in .har and JSON lines otherwise, see gin replay",
+		},
+		gin.BoolFlag{
+			Name:   "recordResponses",
+			EnvVar: "GIN_RECORD_RESPONSES",
+			Usage:  "include the responses in the --record file",
+		},
+		gin.BoolFlag{
+			Name:   "compress",
+			EnvVar: "GIN_COMPRESS",
+			Usage:  "gzip text responses for clients accepting it, unless the app compressed them",
+		},
+		gin.BoolFlag{
+			Name:   "decompress",
+			EnvVar: "GIN_DECOMPRESS",
+			Usage:  "decompress gzip and deflate responses of the app, e.g. to read them in --record files",
+		},
+		gin.StringSliceFlag{
+			Name:   "setHeader",
+			EnvVar: "GIN_SET_HEADER",
+			Usage:  "set a header of the requests passed to the app, as \"Name: value\" (repeatable)",
+		},
+		gin.StringSliceFlag{
+			Name:   "setResponseHeader",
+			EnvVar: "GIN_SET_RESPONSE_HEADER",
+			Usage:  "set a header of the responses, as \"Name: value\" (repeatable)",
 		},
 		gin.StringFlag{
-			Name:   "keyFile",
-			EnvVar: "GIN_KEY_FILE",
-			Usage:  "TLS Certificate Key",
+			Name:   "pprof",
+			EnvVar: "GIN_PPROF",
+			Usage:  "serve the /debug/pprof handlers of the app on this address, e.g. 127.0.0.1:6060",
+		},
+		gin.BoolFlag{
+			Name:   "title",
+			EnvVar: "GIN_TITLE",
+			Usage:  "show the build state in the terminal title",
+		},
+		gin.PathFlag{
+			Name:   "statusFile",
+			EnvVar: "GIN_STATUS_FILE",
+			Usage:  "file updated with the build state, e.g. for the tmux status line",
+		},
+		gin.StringSliceFlag{
+			Name:   "plugin",
+			EnvVar: "GIN_PLUGIN",
+			Usage:  "run a plugin receiving the lifecycle events of gin, gin-<name> on the PATH or declared in the config file (repeatable)",
+		},
+		gin.StringSliceFlag{
+			Name:   "webhook",
+			EnvVar: "GIN_WEBHOOK",
+			Usage:  "URL notified of failed and recovered builds and crash loops, prefixed with " + strings.Join(gin.WebhookFormats(), "=, ") + "= for the format of the body (repeatable)",
+		},
+		gin.StringSliceFlag{
+			Name:   "webhookEvent",
+			EnvVar: "GIN_WEBHOOK_EVENT",
+			Usage:  "only send these events to the webhooks: " + strings.Join(gin.WebhookEvents, ", ") + " (repeatable, default: all)",
 		},
 		gin.StringFlag{
 			Name:   "logPrefix",
@@ -107,168 +667,2923 @@ func main() {
 			Usage:  "Log prefix",
 			Value:  "gin",
 		},
+		gin.StringFlag{
+			Name:   "logPrefixApp",
+			EnvVar: "GIN_LOG_PREFIX_APP",
+			Usage:  "tag the lines the app writes with this prefix, e.g. app, aligned with gin's own messages",
+		},
+		gin.BoolFlag{
+			Name:   "verbose,v",
+			EnvVar: "GIN_VERBOSE",
+			Usage:  "log restarts, build durations and the lifecycle of the app",
+		},
+		gin.BoolFlag{
+			Name:   "trace",
+			EnvVar: "GIN_TRACE",
+			Usage:  "log watcher events, executed commands and proxy decisions, implies --verbose",
+		},
+		gin.BoolFlag{
+			Name:   "quiet,q",
+			EnvVar: "GIN_QUIET",
+			Usage:  "only log errors and the build status",
+		},
 	}
 	app.Commands = []gin.Command{
 		{
-			Name:            "run",
-			ShortName:       "r",
-			Usage:           "Run the gin proxy in the current working directory",
-			Action:          mainAction,
-			SkipFlagParsing: true,
+			Name:      "run",
+			ShortName: "r",
+			Usage:     "Run the gin proxy in the current working directory",
+			ArgsUsage: "[<package>] [-- <app args>]",
+			Action:    mainAction,
+		},
+		{
+			Name:      "once",
+			Usage:     "Build and run the app once without watching, exiting with its exit code",
+			ArgsUsage: "[<package>] [-- <app args>]",
+			Action: func(c *gin.Context) {
+				once = true
+				mainAction(c)
+			},
+		},
+		{
+			Name:      "start",
+			Usage:     "Run the gin proxy like gin run, with --daemon in the background",
+			ArgsUsage: "[--daemon] [-- <app args>]",
+			Action:    startAction,
+			Flags: []gin.Flag{
+				gin.BoolFlag{
+					Name:  "daemon",
+					Usage: "detach from the terminal, writing the output to " + filepath.Join(gin.StateDir, "daemon.log"),
+				},
+			},
+		},
+		{
+			Name:   "stop",
+			Usage:  "Stop the gin started with gin start --daemon",
+			Action: stopAction,
+		},
+		{
+			Name:   "status",
+			Usage:  "Show the build state of the gin started with gin start --daemon",
+			Action: statusAction,
+		},
+		{
+			Name:   "logs",
+			Usage:  "Show the output of the gin started with gin start --daemon",
+			Action: logsAction,
+			Flags: []gin.Flag{
+				gin.BoolFlag{
+					Name:  "follow,f",
+					Usage: "keep printing the output as it is written",
+				},
+			},
 		},
 		{
 			Name:      "env",
 			ShortName: "e",
-			Usage:     "Display environment variables set by the .env file",
+			Usage:     "Display environment variables set by the .env files",
 			Action:    envAction,
+			Flags: []gin.Flag{
+				gin.StringFlag{
+					Name:  "format,f",
+					Value: "text",
+					Usage: "output format: text, json or shell",
+				},
+			},
+		},
+		{
+			Name:  "history",
+			Usage: "Show the changes that triggered each rebuild, recorded with --history",
+			Subcommands: []gin.Command{
+				{
+					Name:   "list",
+					Usage:  "List the recorded rebuilds",
+					Action: historyListAction,
+				},
+				{
+					Name:      "show",
+					Usage:     "Show the diff that triggered a rebuild",
+					ArgsUsage: "<n>",
+					Action:    historyShowAction,
+				},
+			},
+		},
+		{
+			Name:   "stats",
+			Usage:  "Summarize the builds of the running gin, or of the --buildLog file",
+			Action: statsAction,
+			Flags: []gin.Flag{
+				gin.IntFlag{
+					Name:  "limit,n",
+					Value: 10,
+					Usage: "number of recent builds to list",
+				},
+				gin.BoolFlag{
+					Name:  "json",
+					Usage: "print the summary and all builds as JSON",
+				},
+			},
 		},
+		{
+			Name:      "bench",
+			Usage:     "Rebuild and restart the app several times and report how long it takes",
+			ArgsUsage: "[<app args>]",
+			Action: func(c *gin.Context) {
+				benchmark = true
+				mainAction(c)
+			},
+			Flags: []gin.Flag{
+				gin.IntFlag{
+					Name:  "count,n",
+					Value: 10,
+					Usage: "number of rebuild cycles",
+				},
+				gin.PathFlag{
+					Name:  "touch",
+					Usage: "file changed before every cycle so the build can't reuse its last result, restored afterwards, e.g. main.go",
+				},
+				gin.PathFlag{
+					Name:  "save",
+					Usage: "file to save the result to as a baseline",
+				},
+				gin.PathFlag{
+					Name:  "baseline",
+					Usage: "file of a result saved with --save to compare with",
+				},
+				gin.BoolFlag{
+					Name:  "json",
+					Usage: "print the result as JSON",
+				},
+			},
+		},
+		{
+			Name:  "cache",
+			Usage: "Inspect and trim the go build cache and gin's state directory",
+			Subcommands: []gin.Command{
+				{
+					Name:   "stats",
+					Usage:  "Show the size and last use of the caches",
+					Action: cacheStatsAction,
+				},
+				{
+					Name:   "clean",
+					Usage:  "Remove gin's caches and trim the go build cache",
+					Action: cacheCleanAction,
+					Flags: []gin.Flag{
+						gin.DurationFlag{
+							Name:  "olderThan",
+							Value: 5 * 24 * time.Hour,
+							Usage: "remove go build cache entries not used for this long",
+						},
+						gin.BoolFlag{
+							Name:  "all",
+							Usage: "remove the whole go build cache with go clean -cache",
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:  "control",
+			Usage: "Manage a gin running with --controlAddr, e.g. on a staging box",
+			Subcommands: []gin.Command{
+				{
+					Name:   "status",
+					Usage:  "Show the build state and addresses",
+					Action: controlStatusAction,
+					Flags:  controlFlags,
+				},
+				{
+					Name:   "rebuild",
+					Usage:  "Rebuild and restart the app",
+					Action: controlAction("rebuild"),
+					Flags:  controlFlags,
+				},
+				{
+					Name:   "stop",
+					Usage:  "Stop the app and gin",
+					Action: controlAction("stop"),
+					Flags:  controlFlags,
+				},
+			},
+		},
+		{
+			Name:      "completion",
+			Usage:     "Print a script completing gin's commands and options in your shell",
+			ArgsUsage: "bash|zsh|fish|powershell",
+			Action:    completionAction,
+		},
+		{
+			Name:  "docs",
+			Usage: "Generate the documentation of gin's commands and options",
+			Subcommands: []gin.Command{
+				{
+					Name:   "man",
+					Usage:  "Write troff man pages, e.g. gin.1 and gin-run.1",
+					Action: docsAction("man"),
+					Flags: []gin.Flag{
+						gin.PathFlag{
+							Name:      "output,o",
+							Value:     "man",
+							Usage:     "directory the pages are written to",
+							Directory: true,
+						},
+					},
+				},
+				{
+					Name:   "markdown",
+					Usage:  "Write a markdown page per command, e.g. gin.md and gin-run.md",
+					Action: docsAction("markdown"),
+					Flags: []gin.Flag{
+						gin.PathFlag{
+							Name:      "output,o",
+							Value:     "docs",
+							Usage:     "directory the pages are written to",
+							Directory: true,
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:      "new",
+			Usage:     "Create a web app set up for live reloading from a template: " + strings.Join(gin.ProjectTemplates(), ", "),
+			ArgsUsage: "<template> [directory]",
+			Action:    newAction,
+			Flags: []gin.Flag{
+				gin.StringFlag{
+					Name:  "module,m",
+					Usage: "module path of the app (default: the name of the directory)",
+				},
+			},
+		},
+		{
+			Name:      "migrate",
+			Usage:     "Convert the config of " + strings.Join(gin.Migrators(), ", ") + " into " + configFile,
+			ArgsUsage: "[file]",
+			Action:    migrateAction,
+			Flags: []gin.Flag{
+				gin.StringFlag{
+					Name:  "tool",
+					Usage: "tool the file configures (default: guessed from its name)",
+				},
+				gin.BoolFlag{
+					Name:  "force",
+					Usage: "overwrite an existing " + configFile,
+				},
+				gin.BoolFlag{
+					Name:  "dry-run,n",
+					Usage: "print the converted config instead of writing it",
+				},
+			},
+		},
+		{
+			Name:   "version",
+			Usage:  "Show the version of gin and how it was built",
+			Action: versionAction,
+			Flags: []gin.Flag{
+				gin.BoolFlag{
+					Name:  "check",
+					Usage: "check whether a newer gin has been released",
+				},
+				gin.StringFlag{
+					Name:   "releaseURL",
+					Value:  gin.ReleaseURL,
+					EnvVar: "GIN_RELEASE_URL",
+					Usage:  "endpoint describing the latest release, queried by --check",
+					Hidden: true,
+				},
+			},
+		},
+		{
+			Name:   "doctor",
+			Usage:  "Check the environment for problems and suggest fixes",
+			Action: doctorAction,
+		},
+		{
+			Name:      "swap",
+			Usage:     "Run a previous build in a gin running with --controlAddr, or list the retained builds",
+			ArgsUsage: "[<id>|latest]",
+			Action:    swapAction,
+			Flags:     controlFlags,
+		},
+		{
+			Name:      "pprof",
+			Usage:     "Capture a profile from the running app, which must import net/http/pprof",
+			ArgsUsage: "cpu|heap|allocs|goroutine|block|mutex|trace",
+			Action:    pprofAction,
+			Flags: []gin.Flag{
+				gin.IntFlag{
+					Name:  "seconds",
+					Value: 30,
+					Usage: "duration of cpu profiles and traces",
+				},
+				gin.StringFlag{
+					Name:      "output,o",
+					Usage:     "file the profile is written to (default: <profile>.pprof)",
+					TakesFile: true,
+				},
+			},
+		},
+		{
+			Name:      "replay",
+			Usage:     "Send the requests recorded with --record, or saved as HAR by a browser, to the running app again",
+			ArgsUsage: "<file>",
+			Action:    replayAction,
+			Flags: []gin.Flag{
+				gin.StringFlag{
+					Name:  "url",
+					Usage: "server the requests are sent to (default: the proxy, http://localhost:<port>)",
+				},
+			},
+		},
+		{
+			Name:   "hosts",
+			Usage:  "Print or install the hosts file entries resolving the --vhost names to this machine",
+			Action: hostsAction,
+			Flags: []gin.Flag{
+				gin.BoolFlag{
+					Name:  "install",
+					Usage: "add the entries to the hosts file, replacing the ones added before, usually requires root",
+				},
+				gin.PathFlag{
+					Name:   "file",
+					Value:  gin.HostsFile(),
+					Usage:  "hosts file",
+					Hidden: true,
+				},
+			},
+		},
+		{
+			Name:   "clean",
+			Usage:  "Remove generated binaries, caches and persisted state",
+			Action: cleanAction,
+			Flags: []gin.Flag{
+				gin.BoolFlag{
+					Name:  "dry-run,n",
+					Usage: "only print what would be deleted",
+				},
+			},
+		},
+	}
+
+	// executables named gin-<name> on the PATH and the plugins of the
+	// config file are subcommands too
+	for _, name := range gin.PluginNames(gin.FindPlugins(os.Getenv("PATH"))) {
+		app.Commands = appendPluginCommand(app.Commands, name, "")
+	}
+	app.Before = func(c *gin.Context) error {
+		declared, err := configPlugins(c)
+		if err != nil {
+			// the app exits right after, without saying why otherwise
+			logger.Println(err)
+			return err
+		}
+		for _, name := range gin.PluginNames(declared) {
+			app.Commands = appendPluginCommand(app.Commands, name, app.Name+" "+name)
+		}
+		return nil
 	}
 
-	app.Run(os.Args)
+	if err := app.Run(os.Args); err != nil {
+		os.Exit(1)
+	}
 }
 
 func mainAction(c *gin.Context) {
-	laddr := c.GlobalString("laddr")
+	laddrs := c.GlobalStringSlice("laddr")
 	port := c.GlobalInt("port")
 	all := c.GlobalBool("all")
 	appPort := strconv.Itoa(c.GlobalInt("appPort"))
 	immediate = c.GlobalBool("immediate")
-	keyFile := c.GlobalString("keyFile")
-	certFile := c.GlobalString("certFile")
+	stopOnBuildError = c.GlobalBool("stopOnBuildError")
+	keyFile := c.GlobalPath("keyFile")
+	certFile := c.GlobalPath("certFile")
 	logPrefix := c.GlobalString("logPrefix")
 
 	logger.SetPrefix(fmt.Sprintf("[%s] ", logPrefix))
+	switch {
+	case c.GlobalBool("quiet"):
+		gin.SetLogLevel(gin.LogQuiet)
+	case c.GlobalBool("trace"):
+		gin.SetLogLevel(gin.LogTrace)
+	case c.GlobalBool("verbose"):
+		gin.SetLogLevel(gin.LogVerbose)
+	}
+	alignOutput(c, logPrefix)
 
-	// Bootstrap the environment
-	gin.Bootstrap()
+	// gin builds and runs whatever code the watched files contain
+	if gin.IsRoot() && !c.GlobalBool("allowRoot") {
+		logger.Fatal("Refusing to run as root, pass --allowRoot to do so anyway")
+	}
 
-	// Set the PORT env
-	os.Setenv("PORT", appPort)
+	envFiles := c.GlobalStringSlice("envFile")
+	buildHistory = gin.NewBuildHistory(c.GlobalPath("buildLog"))
+	if _, err := c.ConfigSection("env", &configEnv); err != nil {
+		logger.Fatal(err)
+	}
+	if profile := c.ConfigProfile(); profile != "" {
+		gin.Infof("Using the %s profile of %s\n", profile, c.ConfigFilePath())
+	}
 
 	wd, err := os.Getwd()
 	if err != nil {
 		logger.Fatal(err)
 	}
 
-	buildArgs, err := gin.Parse(c.GlobalString("buildArgs"))
-	if err != nil {
-		logger.Fatal(err)
+	// started by gin start --daemon, the app must not inherit the marker
+	if os.Getenv(gin.DaemonEnv) != "" {
+		daemon = gin.NewDaemon(wd)
+		os.Unsetenv(gin.DaemonEnv)
 	}
 
-	buildPath := c.GlobalString("build")
-	if buildPath == "" {
-		buildPath = c.GlobalString("path")
+	status.Name = filepath.Base(wd)
+	if endpoint := c.GlobalString("otlpEndpoint"); endpoint != "" {
+		tracer, err = gin.NewTracer(endpoint, "gin", c.GlobalStringSlice("otlpHeader"), map[string]string{
+			"service.version": c.App.Version,
+			"gin.project":     status.Name,
+		})
+		if err != nil {
+			logger.Fatal(err)
+		}
+		gin.Infof("Exporting traces to %s\n", endpoint)
+	}
+	status.File = c.GlobalPath("statusFile")
+	if c.GlobalBool("title") {
+		status.Title = os.Stdout
 	}
-	builder := gin.NewBuilder(buildPath, c.GlobalString("bin"), c.GlobalBool("godep"), wd, buildArgs)
-	runner := gin.NewRunner(filepath.Join(wd, builder.Binary()), c.Args()...)
-	runner.SetWriter(os.Stdout)
-	proxy := gin.NewProxy(builder, runner)
 
-	config := &gin.Config{
-		Laddr:    laddr,
-		Port:     port,
-		ProxyTo:  "http://localhost:" + appPort,
-		KeyFile:  keyFile,
-		CertFile: certFile,
+	diagnosticsFormat = c.GlobalString("diagnosticsFormat")
+	diagnosticsFile = c.GlobalPath("diagnosticsFile")
+	if diagnosticsFormat != "" && diagnosticsFile == "" {
+		diagnosticsFile = filepath.Join(wd, gin.StateDir, "diagnostics"+gin.DiagnosticsExt(diagnosticsFormat))
 	}
 
-	err = proxy.Run(config)
+	buildArgs, err := gin.Parse(c.GlobalString("buildArgs"))
 	if err != nil {
 		logger.Fatal(err)
 	}
 
-	if laddr != "" {
-		logger.Printf("Listening at %s:%d\n", laddr, port)
-	} else {
-		logger.Printf("Listening on port %d\n", port)
+	target, args := buildTarget(c)
+	buildPath := buildDir(c, target)
+	if target != "" {
+		if err := gin.MainPackage(buildPath); err != nil {
+			logger.Fatalf("Can't build %s: %v\n", buildPath, err)
+		}
+	}
+	runnerKind := c.GlobalString("runner")
+	if c.GlobalString("remote") != "" {
+		runnerKind = "ssh"
 	}
 
-	shutdown(runner)
+	debugAddr := ""
+	gcflags := c.GlobalString("gcflags")
+	if c.GlobalBool("debug") {
+		debugAddr = c.GlobalString("debugAddr")
+		// disable optimizations and inlining for the debugger
+		gcflags = strings.TrimSpace(gcflags + " all=-N -l")
+	}
 
-	// build right now
-	build(builder, runner, logger)
+	goos := c.GlobalString("goos")
+	if runnerKind == "docker" && goos == "" {
+		// containers run linux binaries, even on macOS and Windows hosts
+		goos = "linux"
+	}
 
-	// scan for changes
-	scanChanges(c.GlobalString("path"), c.GlobalStringSlice("excludeDir"), all, func(path string) {
-		runner.Kill()
-		build(builder, runner, logger)
-	})
+	builder := gin.NewBuilder(buildPath, binaryName(c, target), c.GlobalBool("godep"), wd, buildArgs)
+	buildFlags := gin.BuildFlags{
+		Tags:       c.GlobalString("tags"),
+		LDFlags:    c.GlobalString("ldflags"),
+		GCFlags:    gcflags,
+		Race:       c.GlobalBool("race"),
+		VersionVar: c.GlobalString("devVersion"),
+		GOOS:       goos,
+		GOARCH:     c.GlobalString("goarch"),
+	}
+	builder.SetFlags(buildFlags)
+
+	if appPort == "0" {
+		free, err := gin.FreePort()
+		if err != nil {
+			logger.Fatal(err)
+		}
+		appPort = strconv.Itoa(free)
+		gin.Infof("Using app port %s\n", appPort)
+	} else if runnerKind != "ssh" {
+		if err := gin.CheckPort(":" + appPort); err != nil {
+			logger.Fatalf("Can't use app port: %s, pass another --appPort or 0 to pick a free one\n", err)
+		}
+	}
+
+	if keep := c.GlobalInt("keepBuilds"); keep > 0 {
+		builds, err = gin.NewBuildStore(wd, filepath.Join(wd, builder.Binary()), keep)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	var runner gin.Runner
+	proxyTo := "http://localhost:" + appPort
+	switch runnerKind {
+	case "ssh":
+		remote := c.GlobalString("remote")
+		if remote == "" {
+			logger.Fatal("--runner ssh requires --remote")
+		}
+		deployer := &gin.SSHDeployer{
+			Destination: remote,
+			Dir:         c.GlobalString("remoteDir"),
+			Rsync:       c.GlobalBool("rsync"),
+		}
+		runner = gin.NewRemoteRunner(deployer, filepath.Join(wd, builder.Binary()), args...)
+		proxyTo = "http://" + remoteHostname(remote) + ":" + appPort
+	case "docker":
+		dockerArgs, err := gin.Parse(c.GlobalString("dockerArgs"))
+		if err != nil {
+			logger.Fatal(err)
+		}
+		runner = gin.NewDockerRunner(gin.DockerOptions{
+			Image:     c.GlobalString("dockerImage"),
+			Workspace: wd,
+			Port:      appPort,
+			Volumes:   c.GlobalStringSlice("dockerVolume"),
+			Args:      dockerArgs,
+		}, filepath.Join(wd, builder.Binary()), args...)
+	case "local", "":
+		if debugAddr != "" {
+			runner = gin.NewDebugRunner(debugAddr, filepath.Join(wd, builder.Binary()), args...)
+		} else {
+			runner = gin.NewRunner(filepath.Join(wd, builder.Binary()), args...)
+		}
+	default:
+		logger.Fatalf("unknown runner %q, expected local, docker or ssh", runnerKind)
+	}
+	var stdout, stderr io.Writer = os.Stdout, os.Stderr
+	if c.GlobalBool("tui") {
+		dashboard = gin.NewDashboard(os.Stdout, status.Name)
+		logger.SetOutput(dashboard)
+		log.SetOutput(dashboard)
+		stdout, stderr = dashboard, dashboard
+	}
+	runner.SetWriters(childOutput(c, wd, stdout, stderr))
+	runner.SetEnv(childEnv(envFiles, appPort))
+	processOpts := gin.DefaultProcessOptions
+	processOpts.Dir = c.GlobalPath("appDir")
+	processOpts.Nice = c.GlobalInt("appNice")
+	processOpts.CPULimit = c.GlobalDuration("appCPULimit")
+	processOpts.ProcessGroup = !c.GlobalBool("noProcessGroup")
+	if umask := c.GlobalString("appUmask"); umask != "" {
+		mask, err := strconv.ParseUint(umask, 8, 32)
+		if err != nil {
+			logger.Fatalf("invalid --appUmask %q, expected an octal number such as 022", umask)
+		}
+		processOpts.Umask = int(mask)
+	}
+	if limit := c.GlobalString("appMemoryLimit"); limit != "" {
+		processOpts.MemoryLimit, err = gin.ParseBytes(limit)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+	if runnerKind == "local" || runnerKind == "" {
+		runner.SetProcessOptions(processOpts)
+	} else if processOpts != gin.DefaultProcessOptions {
+		logger.Printf("Ignoring the --app* process options, they only apply to the local runner\n")
+	}
+	if specs := c.GlobalStringSlice("webhook"); len(specs) > 0 {
+		var hooks []*gin.Webhook
+		for _, spec := range specs {
+			hook, err := gin.ParseWebhook(spec, c.GlobalStringSlice("webhookEvent"))
+			if err != nil {
+				logger.Fatal(err)
+			}
+			hooks = append(hooks, hook)
+		}
+		webhooks = gin.NewWebhooks(status.Name, hooks)
+	}
+	if r, ok := runner.(interface{ SetExitHandler(func(time.Duration)) }); ok {
+		r.SetExitHandler(func(uptime time.Duration) {
+			started := crashLoop.Exited(uptime)
+			wait, retry := crashLoop.Wait()
+			switch {
+			case started:
+				logger.Printf("%sWarning:%s the app exited right after starting %d times in a row, check its output. Requests start it again in %s at the earliest\n", colorRed, colorReset, crashLoop.Count(), wait.Round(time.Second))
+				if webhooks != nil {
+					trace, _ := panics.Last()
+					webhooks.CrashLoop(crashLoop.Count(), trace)
+				}
+			case !crashLoop.Looping():
+			case retry:
+				gin.Verbosef("The app exited again, requests start it again in %s at the earliest\n", wait.Round(time.Second))
+			default:
+				logger.Printf("%sWarning:%s the app keeps exiting right after starting, it isn't started again until the next change\n", colorRed, colorReset)
+			}
+		})
+	}
+	if waitFor := c.GlobalStringSlice("waitFor"); len(waitFor) > 0 {
+		runner.SetWaitFor(waitFor, c.GlobalDuration("waitTimeout"))
+	}
+	if pattern := c.GlobalString("readyRegex"); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		runner.SetReady(re, c.GlobalDuration("readyTimeout"))
+	}
+	proxy := gin.NewProxy(builder, runner)
+	proxy.ShowPanics(panics)
+	proxy.LimitRestarts(crashLoop)
+	health = gin.NewHealth()
+	healthReport := func() interface{} {
+		return health.Report(status.Name, c.App.Version, status.State(), runner)
+	}
+	proxy.ServeHealth(healthReport)
+	proxy.ServeStale(!stopOnBuildError)
+	if tracer != nil {
+		proxy.Use(tracer.Middleware())
+	}
+	if c.GlobalBool("compress") {
+		mw, err := gin.Compress(gzip.DefaultCompression)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		proxy.Use(mw)
+	}
+	if c.GlobalBool("decompress") {
+		proxy.Use(gin.Decompress())
+	}
+	if path := c.GlobalPath("record"); path != "" {
+		recorder, err := gin.NewRecorder(path, c.GlobalBool("recordResponses"))
+		if err != nil {
+			logger.Fatal(err)
+		}
+		proxy.Use(recorder.Middleware())
+	}
+	// the chain declared in the config file comes first, then the one given
+	// with flags. The flags for guards, faults and mocks are shorthands for
+	// their middleware too.
+	var specs []gin.MiddlewareSpec
+	if cidrs := c.GlobalStringSlice("allowCIDR"); len(cidrs) > 0 {
+		specs = append(specs, gin.MiddlewareSpec{Name: "allowCIDR", Arg: strings.Join(cidrs, ",")})
+	}
+	if auth := c.GlobalString("auth"); auth != "" {
+		specs = append(specs, gin.MiddlewareSpec{Name: "auth", Arg: auth})
+	}
+	var chain []gin.MiddlewareSpec
+	if _, err := c.ConfigSection("middlewareChain", &chain); err != nil {
+		logger.Fatal(err)
+	}
+	specs = append(specs, chain...)
+	for _, spec := range c.GlobalStringSlice("middleware") {
+		specs = append(specs, gin.ParseMiddlewareSpec(spec))
+	}
+	for _, header := range c.GlobalStringSlice("setHeader") {
+		specs = append(specs, gin.MiddlewareSpec{Name: "requestHeaders", Arg: header})
+	}
+	for _, header := range c.GlobalStringSlice("setResponseHeader") {
+		specs = append(specs, gin.MiddlewareSpec{Name: "headers", Arg: header})
+	}
+	useMiddleware(proxy, specs)
+	if origins := c.GlobalStringSlice("corsOrigin"); c.GlobalBool("cors") || len(origins) > 0 {
+		proxy.Use(gin.CORS(origins, c.GlobalStringSlice("corsMethod")))
+	}
+	// faults and mocks come last, so mocked responses are delayed and the
+	// other middleware sees them like responses of the app
+	specs = nil
+	faults := gin.Faults{
+		Delay:    c.GlobalDuration("delay"),
+		Jitter:   c.GlobalDuration("delayJitter"),
+		FailRate: c.GlobalFloat64("failRate"),
+	}
+	if pattern := c.GlobalString("faultPath"); pattern != "" {
+		faults.Path, err = regexp.Compile(pattern)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+	if faults.Enabled() {
+		specs = append(specs, gin.MiddlewareSpec{Name: "faults", Arg: faults.String()})
+	}
+	var mocks []gin.MockRoute
+	if _, err := c.ConfigSection("mocks", &mocks); err != nil {
+		logger.Fatal(err)
+	}
+	if len(mocks) > 0 {
+		// files of the routes are relative to the config file
+		for i, route := range mocks {
+			if route.File != "" && !filepath.IsAbs(route.File) {
+				mocks[i].File = filepath.Join(filepath.Dir(c.ConfigFilePath()), route.File)
+			}
+		}
+		routes, err := json.Marshal(mocks)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		specs = append(specs, gin.MiddlewareSpec{Name: "mocks", Arg: string(routes)})
+	}
+	useMiddleware(proxy, specs)
+
+	config := &gin.Config{
+		Port:     port,
+		ProxyTo:  proxyTo,
+		KeyFile:  keyFile,
+		CertFile: certFile,
+		Transport: gin.TransportOptions{
+			DialTimeout:           c.GlobalDuration("dialTimeout"),
+			ResponseHeaderTimeout: c.GlobalDuration("responseHeaderTimeout"),
+			MaxIdleConns:          c.GlobalInt("maxIdleConns"),
+			IdleConnTimeout:       c.GlobalDuration("idleConnTimeout"),
+			Retries:               c.GlobalInt("proxyRetries"),
+			RetryDelay:            c.GlobalDuration("proxyRetryDelay"),
+		},
+	}
+	for _, laddr := range laddrs {
+		config.Listeners = append(config.Listeners, gin.ParseListener(laddr, port, certFile != "" && keyFile != ""))
+	}
+	for _, spec := range c.GlobalStringSlice("route") {
+		route, err := gin.ParseRoute(spec)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		config.Routes = append(config.Routes, route)
+	}
+	config.Hosts = parseHosts(c)
+
+	// a systemd socket unit passes the sockets of the proxy
+	config.Sockets, err = gin.SystemdListeners()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if len(config.Sockets) > 0 {
+		gin.Verbosef("Serving %d socket(s) passed by systemd instead of --port and --laddr\n", len(config.Sockets))
+	}
+
+	err = proxy.Run(config)
+	if _, ok := err.(*gin.PortInUseError); ok {
+		logger.Fatalf("Can't start the proxy: %s, pass another --port or 0 to pick a free one\n", err)
+	} else if err != nil {
+		logger.Fatal(err)
+	}
+
+	if pprofAddr := c.GlobalString("pprof"); pprofAddr != "" {
+		listener, err := gin.ServePprof(pprofAddr, proxyTo)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		gin.Infof("Profiles of the app available at http://%s%s\n", listener.Addr(), gin.PprofPath)
+	}
+
+	// rebuilds requested through the control API
+	rebuilds := gin.NewTriggerWatcher("", 0)
+	startPlugins(c, rebuilds)
+
+	if controlAddr := c.GlobalString("controlAddr"); controlAddr != "" || daemon != nil {
+		control = gin.NewControlServer()
+		control.Token = c.GlobalString("controlToken")
+		control.HandleJSON("diagnostics", func() interface{} {
+			return builder.Diagnostics()
+		})
+		control.HandleJSON("stats", func() interface{} {
+			return proxy.Stats().Summary()
+		})
+		control.HandleJSON("status", func() interface{} {
+			trace, _ := panics.Last()
+			return gin.ControlStatus{
+				Name:      status.Name,
+				State:     status.State(),
+				Stale:     status.State() == gin.StatusStale,
+				Errors:    builder.Errors(),
+				URLs:      proxy.URLs(),
+				AppPort:   appPort,
+				Panic:     trace,
+				CrashLoop: crashLoop.Status(),
+				Changed:   health.LastChanged(),
+			}
+		})
+		control.HandleJSON("health", healthReport)
+		control.HandleJSON("build-history", func() interface{} {
+			return buildHistory.Stats()
+		})
+		control.HandleAction("rebuild", func(req *http.Request) (interface{}, error) {
+			go rebuilds.Trigger(gin.ControlPrefix + "rebuild")
+			return map[string]bool{"rebuilding": true}, nil
+		})
+		control.HandleJSON("builds", func() interface{} {
+			if builds == nil {
+				return []gin.RetainedBuild{}
+			}
+			list, err := builds.List()
+			if err != nil {
+				logger.Println(err)
+			}
+			return list
+		})
+		control.HandleAction("swap", func(req *http.Request) (interface{}, error) {
+			return swap(builds, runner, req.URL.Query().Get("id"))
+		})
+		control.HandleAction("stop", func(req *http.Request) (interface{}, error) {
+			go func() {
+				// let the response go out first
+				time.Sleep(100 * time.Millisecond)
+				gin.Infof("Stopped through the control API\n")
+				closeServices()
+				runner.Kill()
+				os.Exit(0)
+			}()
+			return map[string]bool{"stopping": true}, nil
+		})
+
+		if daemon != nil {
+			if err := control.ListenUnix(daemon.Socket()); err != nil {
+				logger.Fatal(err)
+			}
+			gin.Verbosef("Control API listening on %s\n", daemon.Socket())
+		}
+		if controlAddr != "" {
+			listener := gin.ParseListener(controlAddr, 0, false)
+			if !listener.TLS {
+				err = control.Listen(listener.Addr)
+			} else if certFile == "" || keyFile == "" {
+				err = fmt.Errorf("%s requires --certFile and --keyFile", listener.URL())
+			} else {
+				err = control.ListenTLS(listener.Addr, certFile, keyFile)
+			}
+			if err != nil {
+				logger.Fatal(err)
+			}
+			if control.Token == "" && !isLoopback(control.Addr()) {
+				logger.Printf("%sWarning:%s anyone who can reach %s can stop and rebuild the app, set --controlToken\n", colorRed, colorReset, control.Addr())
+			}
+			scheme := "http"
+			if listener.TLS {
+				scheme = "https"
+			}
+			gin.Infof("Control API listening at %s://%s%s\n", scheme, control.Addr(), gin.ControlPrefix)
+		}
+	}
+
+	if len(laddrs) > 0 || port == 0 || len(config.Sockets) > 0 {
+		for _, url := range proxy.URLs() {
+			gin.Infof("Listening at %s\n", url)
+		}
+	} else {
+		gin.Infof("Listening on port %d\n", port)
+	}
+	printReachable(proxy.URLs())
+
+	qrCodes := len(laddrs) > 0 && !c.GlobalBool("noQRCode") && !c.GlobalBool("quiet") && showQRCodes(proxy.URLs())
+
+	if name := c.GlobalString("tunnel"); name != "" {
+		go openTunnel(name, proxy.URLs()[0])
+	}
+	if name := c.GlobalString("mdns"); name != "" {
+		advertiseMDNS(name, proxy.URLs())
+	}
+
+	// as a Type=notify service gin is ready once the proxy listens, builds
+	// show up in systemctl status
+	systemd, err = gin.NewSystemd()
+	if err != nil {
+		logger.Println(err)
+	}
+	status.Systemd = systemd
+	if err := systemd.Ready(); err != nil {
+		logger.Println(err)
+	}
+	notifyPlugins(gin.PluginEvent{Event: gin.PluginStart, Name: status.Name, URLs: proxy.URLs(), AppPort: appPort})
+
+	shutdown(runner)
+
+	watcherSpec := c.GlobalString("watcher")
+	if c.GlobalBool("watchDeps") {
+		watcherSpec += "+deps"
+	}
+	envPatterns := envFiles
+	if len(envFiles) == 0 {
+		envPatterns = gin.DefaultEnvFiles
+	}
+	restartPatterns := append([]string{"config.yaml", "config.yml"}, envPatterns...)
+	restartPatterns = append(restartPatterns, c.GlobalStringSlice("restartPattern")...)
+
+	watchOptions := gin.WatchOptions{
+		Path:            c.GlobalPath("path"),
+		BuildPath:       buildPath,
+		ExcludeDirs:     c.GlobalStringSlice("excludeDir"),
+		AllFiles:        all,
+		TriggerFile:     c.GlobalPath("triggerFile"),
+		RestartPatterns: restartPatterns,
+		ReloadPatterns:  c.GlobalStringSlice("reloadPattern"),
+	}
+	triggerFile := relativePaths(wd, []string{watchOptions.TriggerPath()})[0]
+	// the environment only changes when the app starts, changes to the env
+	// files restart it even with a reload signal
+	envOptions := gin.WatchOptions{Path: watchOptions.Path, RestartPatterns: envPatterns}
+	var reloadSignal os.Signal
+	if name := c.GlobalString("reloadSignal"); name != "" {
+		if reloadSignal, err = gin.ParseSignal(name); err != nil {
+			logger.Fatal(err)
+		}
+	}
+	// files embedded with //go:embed are built into the binary
+	watchOptions.Embeds = gin.ScanEmbeds(watchOptions)
+	if watchOptions.Embeds.Len() > 0 {
+		gin.Verbosef("Rebuilding on changes to the files embedded with //go:embed\n")
+	}
+	if writable := watchOptions.WorldWritable(); len(writable) > 0 {
+		logger.Printf("%sWarning:%s any user can modify %s, which lets them run code as you\n", colorRed, colorReset, writable[0])
+		if len(writable) > 1 {
+			logger.Printf("%sWarning:%s and %d more world-writable paths below %s\n", colorRed, colorReset, len(writable)-1, watchOptions.Path)
+		}
+	}
+
+	// gin bench measures the builds, which must not be skipped
+	if !c.GlobalBool("alwaysBuild") && !benchmark {
+		key := fmt.Sprintf("%s %q %+v godep=%v %s", buildPath, buildArgs, buildFlags, c.GlobalBool("godep"), gin.BuildEnvKey(wd))
+		buildState = gin.NewBuildState(wd, watchOptions, key, filepath.Join(wd, builder.Binary()))
+	}
+
+	if once {
+		os.Exit(runOnce(builder, runner))
+	}
+	if benchmark {
+		os.Exit(runBench(c, builder, runner, proxyTo))
+	}
+
+	if dashboard != nil {
+		dashboard.CountRequests(proxy.Stats().Total)
+		dashboard.Bind('r', "rebuild", func() {
+			go rebuilds.Trigger("key r")
+		})
+		dashboard.Bind('c', "clear logs", dashboard.ClearLogs)
+		if qrCodes {
+			dashboard.Bind('u', "QR codes", dashboard.ToggleQRCodes)
+		}
+		dashboard.Bind('q', "quit", func() {
+			dashboard.Close()
+			closeServices()
+			runner.Kill()
+			os.Exit(0)
+		})
+		dashboard.Run(time.Second)
+	}
+
+	if debugAddr != "" {
+		gin.Infof("Delve will listen on %s after each build, attach with dlv connect %s or your editor\n", debugAddr, debugAddr)
+	}
+
+	watcher, err := gin.NewWatcher(watcherSpec, watchOptions)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	health.Watching(watchOptions.Path, watcherSpec)
+	watcher = gin.CombineWatchers(watcher, rebuilds)
+
+	var history *gin.History
+	if c.GlobalBool("history") {
+		history, err = gin.NewHistory(wd)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	// retry builds which failed to reach the network, e.g. to download
+	// modules, until one succeeds or the files change
+	retryInterval := c.GlobalDuration("retryInterval")
+	var retryMu sync.Mutex
+	var retry *time.Timer
+	built := func(err error) {
+		retryMu.Lock()
+		defer retryMu.Unlock()
+		if retry != nil {
+			retry.Stop()
+			retry = nil
+		}
+		if err == nil || err == gin.ErrBuildCanceled || retryInterval <= 0 || !gin.TransientBuildError(builder.Errors()) {
+			return
+		}
+		gin.Infof("The build failed to reach the network, retrying in %s\n", retryInterval)
+		retry = time.AfterFunc(retryInterval, func() {
+			rebuilds.Trigger("retry")
+		})
+	}
+
+	// build right now, the watcher already reports changes made meanwhile,
+	// which cancel the build or the wait for the app to be ready
+	queue := &gin.BuildQueue{}
+	startup := tracer.Start("startup", gin.SpanInternal, time.Now())
+	warmCache := c.GlobalBool("warmCache")
+	queue.Run(func(ctx context.Context) {
+		defer startup.End()
+		built(buildAtStartup(gin.ContextWithSpan(ctx, startup), builder, runner, wd))
+		if warmCache {
+			go func() {
+				if err := builder.Warm(); err != nil {
+					logger.Println(err)
+				}
+			}()
+		}
+	})
+
+	// wait for changes, building in the background so a change during a
+	// build cancels it
+	state := gin.NewWatchState()
+	building := true
+	for ev := range watcher.Events() {
+		events := append([]gin.Event{ev}, drain(watcher.Events())...)
+		for _, ev := range events {
+			gin.Tracef("%s reported %s\n", ev.Source, ev.Path)
+		}
+
+		files := state.Changed(events)
+		if len(files) == 0 {
+			gin.Tracef("Ignoring the events, the files are unchanged since the last build\n")
+			continue
+		}
+		cycle := traceChanges(files)
+		watchOptions.Embeds.Update(files)
+		restartOnly, reloadOnly := true, true
+		signalOnly := reloadSignal != nil
+		for _, file := range files {
+			switch {
+			case watchOptions.IsReloadOnly(file):
+			case watchOptions.IsRestartOnly(file):
+				reloadOnly = false
+				if envOptions.IsRestartOnly(file) {
+					signalOnly = false
+				}
+			default:
+				restartOnly, reloadOnly = false, false
+			}
+		}
+
+		// triggers like the r key request rebuilds without changing files
+		var changed, requested []string
+		for _, file := range relativePaths(wd, files) {
+			if file != triggerFile && triggered(events, file) {
+				requested = append(requested, file)
+			} else {
+				changed = append(changed, file)
+			}
+		}
+
+		health.Changed(files)
+		if reloadOnly && len(requested) == 0 {
+			gin.Infof("Reloading (%s)\n", describeChanges(changed, nil))
+			if control != nil {
+				if err := control.Broadcast(gin.NewReloadNotification(changed)); err != nil {
+					logger.Println(err)
+				}
+			}
+			notifyPlugins(gin.PluginEvent{Event: gin.PluginChange, Changed: changed})
+			cycle.SetAttribute("gin.reload_only", true)
+			cycle.End()
+			continue
+		}
+		// a change may fix the app, so it's started again right away
+		crashLoop.Reset()
+		if history != nil {
+			if _, err := history.Record(files); err != nil {
+				logger.Println(err)
+			}
+		}
+		if dashboard != nil {
+			dashboard.Restarted(relativePaths(wd, files))
+		}
+		if control != nil {
+			if err := control.Broadcast(gin.NewRebuildNotification(changed, requested, restartOnly)); err != nil {
+				logger.Println(err)
+			}
+		}
+
+		notifyPlugins(gin.PluginEvent{Event: gin.PluginChange, Changed: relativePaths(wd, files)})
+
+		cause := describeChanges(changed, requested)
+		if len(changed) > changesShown {
+			gin.Verbosef("Changed: %s\n", strings.Join(changed, ", "))
+		}
+
+		// a restart can't replace an unfinished build, the sources changed
+		rebuild := !restartOnly || building && queue.Running()
+		building = rebuild
+		cycle.SetAttribute("gin.cause", cause)
+		cycle.SetAttribute("gin.restart_only", !rebuild)
+		if queue.Run(func(ctx context.Context) {
+			defer cycle.End()
+			ctx = gin.ContextWithSpan(ctx, cycle)
+			if rebuild {
+				// the app keeps running during the build unless it has to
+				// stop on errors, or its binary can't be replaced while it
+				// runs, as on Windows
+				if stopOnBuildError || runtime.GOOS == "windows" {
+					killApp(ctx, runner)
+				}
+				built(build(ctx, builder, runner, logger, cause, relativePaths(wd, files)))
+			} else if !signalOnly || !signalApp(ctx, runner, reloadSignal, cause) {
+				killApp(ctx, runner)
+				restart(ctx, runner, childEnv(envFiles, appPort), cause)
+			}
+		}) {
+			gin.Verbosef("Canceled the running build\n")
+		}
+	}
+}
+
+// changesShown is the number of changed files listed in the log
+const changesShown = 3
+
+// describeChanges summarizes the cause of a rebuild for the log, e.g.
+// "3 files changed: handlers/user.go, main.go, routes.go"
+func describeChanges(changed, requested []string) string {
+	var parts []string
+	if n := len(changed); n > 0 {
+		list := changed
+		if n > changesShown {
+			list = append(list[:changesShown:changesShown], "...")
+		}
+		noun := "files"
+		if n == 1 {
+			noun = "file"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s changed: %s", n, noun, strings.Join(list, ", ")))
+	}
+	if len(requested) > 0 {
+		parts = append(parts, "requested by "+strings.Join(requested, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// useMiddleware adds the middleware specs describe to the proxy, guards to
+// the ones protecting gin's own endpoints too
+func useMiddleware(proxy *gin.Proxy, specs []gin.MiddlewareSpec) {
+	for _, spec := range specs {
+		mw, err := spec.Middleware()
+		if err != nil {
+			logger.Fatal(err)
+		}
+		if spec.Guard() {
+			proxy.Protect(mw)
+		} else {
+			proxy.Use(mw)
+		}
+	}
+}
+
+// triggered reports whether the trigger watcher reported path, rather than
+// a watcher of files
+func triggered(events []gin.Event, path string) bool {
+	for _, ev := range events {
+		if ev.Path == path && ev.Source == "trigger" {
+			return true
+		}
+	}
+	return false
+}
+
+// relativePaths returns paths relative to dir where they are below it
+func relativePaths(dir string, paths []string) []string {
+	var result []string
+	for _, path := range paths {
+		if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+		result = append(result, path)
+	}
+	return result
+}
+
+func envAction(c *gin.Context) {
+	logPrefix := c.GlobalString("logPrefix")
+	logger.SetPrefix(fmt.Sprintf("[%s] ", logPrefix))
+
+	// Bootstrap the environment
+	seed := gin.Env{"PORT": strconv.Itoa(c.GlobalInt("appPort"))}
+	env, err := gin.BootstrapEnv(seed, c.GlobalStringSlice("envFile")...)
+	if err != nil {
+		logger.Fatalln(err)
+	}
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	switch format := c.String("format"); format {
+	case "json":
+		out, err := json.MarshalIndent(env, "", "  ")
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		fmt.Println(string(out))
+	case "shell":
+		for _, k := range keys {
+			fmt.Printf("export %s='%s'\n", k, strings.Replace(env[k], "'", `'\''`, -1))
+		}
+	case "text", "":
+		for _, k := range keys {
+			fmt.Printf("%s: %s\n", k, env[k])
+		}
+	default:
+		logger.Fatalf("unknown format %q, expected text, json or shell", format)
+	}
+}
+
+func historyListAction(c *gin.Context) {
+	history := openHistory(c)
+	rebuilds, err := history.List()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	for _, rebuild := range rebuilds {
+		fmt.Printf("%4d  %s  %s\n", rebuild.ID, rebuild.Time.Format("2006-01-02 15:04:05"), strings.Join(rebuild.Files, ", "))
+	}
+}
+
+func historyShowAction(c *gin.Context) {
+	id, err := strconv.Atoi(c.Args().First())
+	if err != nil {
+		logger.Fatalf("expected the number of a rebuild, see gin history list")
+	}
+
+	rebuild, err := openHistory(c).Get(id)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	fmt.Printf("Rebuild #%d at %s\n\n", rebuild.ID, rebuild.Time.Format("2006-01-02 15:04:05"))
+	if rebuild.Diff == "" {
+		fmt.Println("No diff recorded for " + strings.Join(rebuild.Files, ", "))
+		return
+	}
+	fmt.Print(rebuild.Diff)
+}
+
+func openHistory(c *gin.Context) *gin.History {
+	logPrefix := c.GlobalString("logPrefix")
+	logger.SetPrefix(fmt.Sprintf("[%s] ", logPrefix))
+
+	wd, err := os.Getwd()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	history, err := gin.NewHistory(wd)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	return history
+}
+
+func statsAction(c *gin.Context) {
+	logger.SetPrefix(fmt.Sprintf("[%s] ", c.GlobalString("logPrefix")))
+	wd, err := os.Getwd()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	// the running gin knows the builds of its session, the log file those
+	// of past ones
+	var stats gin.BuildStats
+	background := gin.NewDaemon(wd)
+	switch {
+	case c.GlobalString("controlAddr") != "":
+		err = controlClient(c).Get("build-history", &stats)
+	case background.PID() != 0:
+		err = background.Client().Get("build-history", &stats)
+	case c.GlobalPath("buildLog") != "":
+		stats, err = gin.ReadBuildHistory(c.GlobalPath("buildLog"))
+	default:
+		err = fmt.Errorf("no gin found to ask, pass --controlAddr of a running gin or the --buildLog file it writes")
+	}
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	if c.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(stats)
+		return
+	}
+
+	summary := stats.Summary
+	fmt.Printf("%s since %s\n", summary, summary.Since.Format("2006-01-02 15:04"))
+	if summary.Builds == 0 {
+		return
+	}
+	fmt.Printf("fastest %s, slowest %s\n", millis(summary.Fastest), millis(summary.Slowest))
+
+	recent := stats.Builds
+	if limit := c.Int("limit"); limit >= 0 && len(recent) > limit {
+		recent = recent[len(recent)-limit:]
+	}
+	if len(recent) > 0 {
+		fmt.Println("\nRecent builds:")
+	}
+	for _, record := range recent {
+		result := colorGreen + "ok    " + colorReset
+		if record.Failed {
+			result = colorRed + "failed" + colorReset
+		}
+		cause := record.Cause
+		if cause == "" {
+			cause = "first build"
+		}
+		fmt.Printf("  %s  %8s  %s  %s\n", record.Time.Format("Jan 02 15:04:05"), millis(record.Duration), result, cause)
+	}
+}
+
+// millis formats a duration in milliseconds, e.g. 1.24s
+func millis(ms float64) string {
+	return time.Duration(ms * float64(time.Millisecond)).Round(10 * time.Millisecond).String()
+}
+
+func cacheStatsAction(c *gin.Context) {
+	logPrefix := c.GlobalString("logPrefix")
+	logger.SetPrefix(fmt.Sprintf("[%s] ", logPrefix))
+
+	wd, err := os.Getwd()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	for _, dir := range []string{gin.GoCacheDir(), filepath.Join(wd, gin.StateDir)} {
+		if dir == "" {
+			continue
+		}
+		stats, err := gin.DirStats(dir)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		fmt.Println(stats.Dir)
+		fmt.Printf("  size:      %s in %d files\n", gin.FormatBytes(stats.Size), stats.Files)
+		if stats.Files > 0 {
+			fmt.Printf("  last used: %s\n", stats.LastUsed.Format("2006-01-02 15:04:05"))
+			fmt.Printf("  oldest:    %s\n", stats.Oldest.Format("2006-01-02 15:04:05"))
+		}
+	}
+}
+
+func cacheCleanAction(c *gin.Context) {
+	logPrefix := c.GlobalString("logPrefix")
+	logger.SetPrefix(fmt.Sprintf("[%s] ", logPrefix))
+
+	wd, err := os.Getwd()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	stateDir := filepath.Join(wd, gin.StateDir)
+	freed, err := gin.CleanState(wd)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	logger.Printf("Removed %s (%s)\n", stateDir, gin.FormatBytes(freed))
+	if pid := gin.NewDaemon(wd).PID(); pid != 0 {
+		logger.Printf("Kept the files of gin running in the background (pid %d)\n", pid)
+	}
+
+	if c.Bool("all") {
+		output, err := exec.Command("go", "clean", "-cache").CombinedOutput()
+		if err != nil {
+			logger.Fatalf("go clean -cache: %v\n%s", err, output)
+		}
+		logger.Println("Removed the go build cache")
+		return
+	}
+
+	removed, freed, err := gin.TrimGoCache(gin.GoCacheDir(), c.Duration("olderThan"))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	logger.Printf("Removed %d go build cache entries unused for %s (%s)\n", removed, c.Duration("olderThan"), gin.FormatBytes(freed))
+}
+
+var controlFlags = []gin.Flag{
+	gin.PathFlag{
+		Name:      "caFile",
+		MustExist: true,
+		Usage:     "PEM certificate to trust, e.g. a self-signed --certFile",
+	},
+}
+
+func controlClient(c *gin.Context) *gin.ControlClient {
+	logPrefix := c.GlobalString("logPrefix")
+	logger.SetPrefix(fmt.Sprintf("[%s] ", logPrefix))
+
+	addr := c.GlobalString("controlAddr")
+	if addr == "" {
+		logger.Fatal("Pass the address of the control API with --controlAddr")
+	}
+
+	client, err := gin.NewControlClient(addr, c.GlobalString("controlToken"), c.Path("caFile"))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	return client
+}
+
+func controlStatusAction(c *gin.Context) {
+	var st gin.ControlStatus
+	if err := controlClient(c).Get("status", &st); err != nil {
+		logger.Fatal(err)
+	}
+	printStatus(st)
+}
+
+func printStatus(st gin.ControlStatus) {
+	fmt.Printf("%s %s\n", st.State, st.Name)
+	if st.Stale {
+		fmt.Println("  the last build failed, the app of the build before keeps running")
+	}
+	for _, url := range st.URLs {
+		fmt.Printf("  proxy:    %s\n", url)
+	}
+	fmt.Printf("  app port: %s\n", st.AppPort)
+	if loop := st.CrashLoop; loop != nil {
+		next := "the next request starts it again"
+		if loop.Stopped {
+			next = "it isn't started again until the next change"
+		} else if loop.RetryIn > 0 {
+			next = fmt.Sprintf("requests start it again in %s at the earliest", time.Duration(loop.RetryIn*float64(time.Second)).Round(time.Second))
+		}
+		fmt.Printf("  the app exited right after starting %d times in a row, %s\n", loop.Crashes, next)
+	}
+	if len(st.Changed) > 0 {
+		fmt.Printf("  changed:  %s\n", strings.Join(st.Changed, ", "))
+	}
+	if st.Errors != "" {
+		fmt.Println(st.Errors)
+	}
+}
+
+// daemonStartTimeout is how long gin start --daemon waits for the daemon to
+// answer, and gin stop for it to exit before killing it
+const daemonStartTimeout = 30 * time.Second
+
+func startAction(c *gin.Context) {
+	if !c.Bool("daemon") || os.Getenv(gin.DaemonEnv) != "" {
+		mainAction(c)
+		return
+	}
+
+	logger.SetPrefix(fmt.Sprintf("[%s] ", c.GlobalString("logPrefix")))
+	if c.GlobalBool("tui") {
+		logger.Fatal("--tui needs a terminal, gin start --daemon detaches from it")
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	// the daemon runs the same command line and sees DaemonEnv
+	pid, err := gin.NewDaemon(wd).Start(os.Args[1:], daemonStartTimeout)
+	if pid == 0 {
+		logger.Fatal(err)
+	}
+	if err != nil {
+		logger.Printf("%sWarning:%s %v\n", colorRed, colorReset, err)
+	}
+	logger.Printf("Started gin in the background with pid %d, see gin status and gin logs -f\n", pid)
+}
+
+func stopAction(c *gin.Context) {
+	logger.SetPrefix(fmt.Sprintf("[%s] ", c.GlobalString("logPrefix")))
+	wd, err := os.Getwd()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	pid, err := gin.NewDaemon(wd).Stop(daemonStartTimeout)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	logger.Printf("Stopped gin with pid %d\n", pid)
+}
+
+func statusAction(c *gin.Context) {
+	logger.SetPrefix(fmt.Sprintf("[%s] ", c.GlobalString("logPrefix")))
+	wd, err := os.Getwd()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	background := gin.NewDaemon(wd)
+	pid := background.PID()
+	if pid == 0 {
+		logger.Fatal(gin.ErrDaemonNotRunning)
+	}
+	var st gin.ControlStatus
+	if err := background.Client().Get("status", &st); err != nil {
+		logger.Fatalf("gin runs with pid %d but doesn't answer on %s: %v\n", pid, background.Socket(), err)
+	}
+	printStatus(st)
+	fmt.Printf("  pid:      %d\n", pid)
+	fmt.Printf("  log:      %s\n", background.LogFile())
+}
+
+func logsAction(c *gin.Context) {
+	logger.SetPrefix(fmt.Sprintf("[%s] ", c.GlobalString("logPrefix")))
+	wd, err := os.Getwd()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	if err := gin.NewDaemon(wd).CopyLog(os.Stdout, c.Bool("follow")); err != nil {
+		logger.Fatal(err)
+	}
+}
+
+func controlAction(name string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var res map[string]bool
+		if err := controlClient(c).Post(name, &res); err != nil {
+			logger.Fatal(err)
+		}
+		logger.Printf("Requested %s from %s\n", name, c.GlobalString("controlAddr"))
+	}
+}
+
+// appendPluginCommand adds the plugin name as a subcommand, unless a command
+// of gin has that name
+func appendPluginCommand(commands []gin.Command, name string, helpName string) []gin.Command {
+	for _, command := range commands {
+		for _, n := range command.Names() {
+			if n == name {
+				return commands
+			}
+		}
+	}
+	return append(commands, gin.Command{
+		Name:            name,
+		HelpName:        helpName,
+		Category:        "Plugins",
+		Usage:           "Run the plugin " + name,
+		Action:          pluginAction(name),
+		SkipFlagParsing: true,
+	})
+}
+
+// configPlugins returns the command lines of the plugins declared in the
+// config file, e.g. {"plugins": {"assets": "./tools/assets --minify"}}
+func configPlugins(c *gin.Context) (map[string]string, error) {
+	declared := make(map[string]string)
+	_, err := c.ConfigSection("plugins", &declared)
+	return declared, err
+}
+
+// pluginCommand returns the command line of the plugin name, declared in the
+// config file or found on the PATH. Relative paths in the config file are
+// relative to its directory.
+func pluginCommand(c *gin.Context, name string) ([]string, error) {
+	declared, err := configPlugins(c)
+	if err != nil {
+		return nil, err
+	}
+	if line, ok := declared[name]; ok {
+		command, err := gin.Parse(line)
+		if err != nil || len(command) == 0 {
+			return nil, fmt.Errorf("invalid command line %q of the plugin %s", line, name)
+		}
+		if strings.ContainsRune(command[0], filepath.Separator) && !filepath.IsAbs(command[0]) {
+			command[0] = filepath.Join(filepath.Dir(c.ConfigFilePath()), command[0])
+		}
+		return command, nil
+	}
+
+	found := gin.FindPlugins(os.Getenv("PATH"))
+	if path, ok := found[name]; ok {
+		return []string{path}, nil
+	}
+	names := append(gin.PluginNames(declared), gin.PluginNames(found)...)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("unknown plugin %q, install %s%s on the PATH or declare it in %s", name, gin.PluginPrefix, name, configFile)
+	}
+	return nil, fmt.Errorf("unknown plugin %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// pluginAction runs the plugin name with the arguments of the command
+func pluginAction(name string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		command, err := pluginCommand(c, name)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		cmd := exec.Command(command[0], append(command[1:], c.Args()...)...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if exe, err := os.Executable(); err == nil {
+			cmd.Env = append(os.Environ(), "GIN_EXECUTABLE="+exe)
+		}
+		if err := cmd.Run(); err != nil {
+			if exit, ok := err.(*exec.ExitError); ok {
+				os.Exit(exit.ExitCode())
+			}
+			logger.Fatal(err)
+		}
+	}
+}
+
+// startPlugins starts the plugins passed with --plugin, which may request
+// rebuilds through rebuilds
+func startPlugins(c *gin.Context, rebuilds *gin.TriggerWatcher) {
+	for _, name := range c.GlobalStringSlice("plugin") {
+		command, err := pluginCommand(c, name)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		plugin := gin.NewPlugin(name, command)
+		plugin.Log = func(message string) {
+			logger.Printf("[%s] %s\n", plugin.Name, message)
+		}
+		plugin.Rebuild = func() {
+			go rebuilds.Trigger("plugin " + plugin.Name)
+		}
+		if err := plugin.Start(); err != nil {
+			logger.Fatal(err)
+		}
+		gin.Verbosef("Started the plugin %s\n", name)
+		plugins = append(plugins, plugin)
+	}
+}
+
+// notifyPlugins sends event to the plugins
+func notifyPlugins(event gin.PluginEvent) {
+	for _, plugin := range plugins {
+		plugin.Send(event)
+	}
+}
+
+func completionAction(c *gin.Context) {
+	script, err := c.App.CompletionScript(c.Args().First())
+	if err != nil {
+		logger.Fatal(err)
+	}
+	fmt.Print(script)
+}
+
+func docsAction(format string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// c.App is the docs command, document the whole of gin
+		app := c.App
+		for ctx := c.Parent(); ctx != nil; ctx = ctx.Parent() {
+			app = ctx.App
+		}
+
+		write := app.WriteManPages
+		if format == "markdown" {
+			write = app.WriteMarkdownDocs
+		}
+
+		paths, err := write(c.Path("output"))
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Printf("Wrote %d pages to %s\n", len(paths), c.Path("output"))
+	}
+}
+
+func newAction(c *gin.Context) {
+	name := c.Args().First()
+	if name == "" {
+		logger.Fatalf("Pass a template: %s\n", strings.Join(gin.ProjectTemplates(), ", "))
+	}
+	template, err := gin.LookupProjectTemplate(name)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	dir := c.Args().Get(1)
+	if dir == "" {
+		dir = "."
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	module := c.String("module")
+	if module == "" {
+		module = filepath.Base(abs)
+	}
+
+	if _, err := gin.NewProject(dir, name, module, configFile); err != nil {
+		logger.Fatal(err)
+	}
+
+	fmt.Printf("Created %s using %s in %s, to start it:\n\n", module, template.Description, dir)
+	if dir != "." {
+		fmt.Printf("  cd %s\n", dir)
+	}
+	if len(template.Dependencies) > 0 {
+		fmt.Println("  go mod tidy")
+	}
+	fmt.Println("  gin run")
+	fmt.Printf("\nthen open http://localhost:3000, edit main.go and reload.\n")
+}
+
+func migrateAction(c *gin.Context) {
+	path, tool := c.Args().First(), c.String("tool")
+	if path == "" {
+		var err error
+		if tool, path, err = gin.FindMigration("."); err != nil {
+			logger.Fatal(err)
+		}
+	}
+	migration, err := gin.Migrate(tool, path)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	data, err := migration.JSON()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	if c.Bool("dry-run") {
+		os.Stdout.Write(data)
+	} else {
+		if _, err := os.Stat(configFile); err == nil && !c.Bool("force") {
+			logger.Fatalf("%s exists already, pass --force to overwrite it or --dry-run to print the converted config\n", configFile)
+		}
+		if err := ioutil.WriteFile(configFile, data, 0644); err != nil {
+			logger.Fatal(err)
+		}
+		logger.Printf("Converted the %s config %s into %s\n", migration.Tool, path, configFile)
+	}
+
+	for _, note := range migration.Notes {
+		logger.Printf("Not converted: %s\n", note)
+	}
+	command := "gin run"
+	if len(migration.Args) > 0 {
+		command += " " + strings.Join(migration.Args, " ")
+	}
+	logger.Printf("Start the app with %s, it listens on $PORT behind the proxy\n", command)
+}
+
+func versionAction(c *gin.Context) {
+	info := gin.ReadBuildInfo(version, commit)
+
+	fmt.Printf("gin %s\n", info.Version)
+	if info.Commit != "" {
+		modified := ""
+		if info.Modified {
+			modified = " (modified)"
+		}
+		fmt.Printf("commit:   %s%s\n", info.Commit, modified)
+	}
+	if info.Date != "" {
+		fmt.Printf("built:    %s\n", info.Date)
+	}
+	fmt.Printf("go:       %s\n", info.GoVersion)
+	fmt.Printf("platform: %s\n", info.Platform)
+
+	if !c.Bool("check") {
+		return
+	}
+
+	release, err := gin.LatestRelease(c.String("releaseURL"))
+	if err != nil {
+		logger.Fatalf("Can't check for updates: %s\n", err)
+	}
+	switch {
+	case gin.NewerVersion(release.Version, info.Version):
+		fmt.Printf("\n%sgin %s is available%s, see %s\n", colorGreen, release.Version, colorReset, release.URL)
+	case info.Version == "dev":
+		fmt.Printf("\nThe latest release is %s, this is a development build\n", release.Version)
+	default:
+		fmt.Println("\ngin is up to date")
+	}
+}
+
+func doctorAction(c *gin.Context) {
+	target, _ := buildTarget(c)
+	buildPath := buildDir(c, target)
+	envFiles := c.GlobalStringSlice("envFile")
+
+	checkups := []gin.Checkup{
+		gin.CheckGo(buildPath),
+		gin.CheckModules(buildPath),
+		gin.CheckMainPackage(buildPath),
+		gin.CheckWatchLimit(gin.WatchOptions{
+			Path:        c.GlobalPath("path"),
+			ExcludeDirs: c.GlobalStringSlice("excludeDir"),
+		}),
+		gin.CheckPortFree("Proxy port", ":"+strconv.Itoa(c.GlobalInt("port")), "--port"),
+		gin.CheckPortFree("App port", ":"+strconv.Itoa(c.GlobalInt("appPort")), "--appPort"),
+	}
+	if len(envFiles) > 0 {
+		checkups = append(checkups, gin.CheckEnvFiles(envFiles, false))
+	} else {
+		checkups = append(checkups, gin.CheckEnvFiles(gin.DefaultEnvFiles, true))
+	}
+	if certFile := c.GlobalPath("certFile"); certFile != "" {
+		checkups = append(checkups, gin.CheckCert(certFile, time.Now()))
+	}
+
+	failed := false
+	for _, checkup := range checkups {
+		mark := colorGreen + "✓" + colorReset
+		switch checkup.Status {
+		case gin.CheckWarn:
+			mark = "!"
+		case gin.CheckFail:
+			mark = colorRed + "✗" + colorReset
+			failed = true
+		}
+
+		fmt.Printf("%s %-16s %s\n", mark, checkup.Name, strings.Replace(checkup.Detail, "\n", "\n                   ", -1))
+		if checkup.Fix != "" {
+			fmt.Printf("  %-16s fix: %s\n", "", checkup.Fix)
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func swapAction(c *gin.Context) {
+	client := controlClient(c)
+
+	id := c.Args().First()
+	if id == "" {
+		var list []gin.RetainedBuild
+		if err := client.Get("builds", &list); err != nil {
+			logger.Fatal(err)
+		}
+		if len(list) == 0 {
+			fmt.Println("No retained builds")
+		}
+		for _, build := range list {
+			fmt.Printf("#%-4d %s  %s\n", build.ID, build.Time.Format("2006-01-02 15:04:05"), gin.FormatBytes(build.Size))
+		}
+		return
+	}
+
+	var build gin.RetainedBuild
+	if err := client.Post("swap?id="+url.QueryEscape(id), &build); err != nil {
+		logger.Fatal(err)
+	}
+	logger.Printf("Swapped in build #%d from %s\n", build.ID, build.Time.Format("15:04:05"))
+}
+
+// isLoopback reports whether the listening address addr only accepts local
+// connections
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func replayAction(c *gin.Context) {
+	logPrefix := c.GlobalString("logPrefix")
+	logger.SetPrefix(fmt.Sprintf("[%s] ", logPrefix))
+
+	if c.Args().First() == "" {
+		logger.Fatal("Pass the file with the recorded requests")
+	}
+	requests, err := gin.LoadRecording(c.Args().First())
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	target := c.String("url")
+	if target == "" {
+		target = "http://localhost:" + strconv.Itoa(c.GlobalInt("port"))
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	failed := 0
+	for _, request := range requests {
+		start := time.Now()
+		res, err := gin.Replay(client, target, request)
+		if err != nil {
+			failed++
+			fmt.Printf("%s %s %s\n", request.Method, request.URL, err)
+			continue
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+
+		line := fmt.Sprintf("%s %s %d %s", request.Method, res.Request.URL.RequestURI(), res.StatusCode, time.Since(start).Round(time.Millisecond))
+		if request.Response != nil && request.Response.Status != res.StatusCode {
+			line += fmt.Sprintf(" (recorded %d)", request.Response.Status)
+		}
+		fmt.Println(line)
+	}
+
+	if failed > 0 {
+		logger.Fatalf("%d of %d requests failed\n", failed, len(requests))
+	}
+}
+
+func pprofAction(c *gin.Context) {
+	logPrefix := c.GlobalString("logPrefix")
+	logger.SetPrefix(fmt.Sprintf("[%s] ", logPrefix))
+
+	name := c.Args().First()
+	if name == "" {
+		name = "cpu"
+	}
+
+	output := c.String("output")
+	if output == "" {
+		output = name + ".pprof"
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer file.Close()
+
+	if name == "cpu" || name == "trace" {
+		logger.Printf("Capturing %s profile for %d seconds...\n", name, c.Int("seconds"))
+	}
+
+	if c.GlobalInt("appPort") == 0 {
+		logger.Fatal("Pass the port the app is listening on with --appPort")
+	}
+
+	target := "http://localhost:" + strconv.Itoa(c.GlobalInt("appPort"))
+	if err := gin.FetchProfile(target, name, c.Int("seconds"), file); err != nil {
+		file.Close()
+		os.Remove(output)
+		logger.Fatal(err)
+	}
+
+	if name == "trace" {
+		logger.Printf("Wrote %s, inspect it with go tool trace %s\n", output, output)
+	} else {
+		logger.Printf("Wrote %s, inspect it with go tool pprof %s\n", output, output)
+	}
+}
+
+func parseHosts(c *gin.Context) []gin.Host {
+	var hosts []gin.Host
+	for _, spec := range c.GlobalStringSlice("vhost") {
+		host, err := gin.ParseHost(spec)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+func hostsAction(c *gin.Context) {
+	logPrefix := c.GlobalString("logPrefix")
+	logger.SetPrefix(fmt.Sprintf("[%s] ", logPrefix))
+
+	var names []string
+	for _, host := range parseHosts(c) {
+		names = append(names, host.Name)
+	}
+	if len(names) == 0 {
+		logger.Fatal("No virtual hosts, add them with --vhost")
+	}
+
+	entries := gin.HostsEntries(names)
+	if !c.Bool("install") {
+		if len(entries) == 0 {
+			fmt.Println("Names under .localhost need no hosts file entries")
+			return
+		}
+		fmt.Println(strings.Join(entries, "\n"))
+		return
+	}
+
+	if err := gin.InstallHosts(c.Path("file"), names); err != nil {
+		logger.Fatal(err)
+	}
+	logger.Printf("Updated %s\n", c.Path("file"))
+}
+
+func cleanAction(c *gin.Context) {
+	logPrefix := c.GlobalString("logPrefix")
+	logger.SetPrefix(fmt.Sprintf("[%s] ", logPrefix))
+
+	wd, err := os.Getwd()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	target, _ := buildTarget(c)
+	builder := gin.NewBuilder("", binaryName(c, target), false, wd, nil)
+	artifacts := gin.Artifacts(wd, builder.Binary())
+	if pid := gin.NewDaemon(wd).PID(); pid != 0 {
+		logger.Printf("Keeping the files of gin running in the background (pid %d), stop it with gin stop to remove them\n", pid)
+	}
+	if len(artifacts) == 0 {
+		logger.Println("Nothing to clean")
+		return
+	}
+
+	for _, path := range artifacts {
+		if c.Bool("dry-run") {
+			logger.Printf("Would remove %s\n", path)
+		} else {
+			logger.Printf("Removing %s\n", path)
+		}
+	}
+
+	if c.Bool("dry-run") {
+		return
+	}
+
+	if err := gin.Clean(wd, artifacts); err != nil {
+		logger.Fatal(err)
+	}
+}
+
+// buildTarget returns the main package to build, given with --target or as
+// the first argument of gin run, e.g. ./cmd/server, empty if neither is,
+// and the arguments left for the app
+func buildTarget(c *gin.Context) (string, []string) {
+	args := c.Args()
+	target := c.GlobalPath("target")
+	if first := args.First(); target == "" && isPackagePath(first) {
+		if info, err := os.Stat(first); err != nil || !info.IsDir() {
+			logger.Fatalf("Can't build %s: not a directory\n", first)
+		}
+		target, args = first, args.Tail()
+	}
+	// the flags of the command stop at the target, e.g. in
+	// gin run ./cmd/server -- -config dev.yaml, leaving the -- in front of
+	// the arguments of the app
+	if args.First() == "--" {
+		args = args.Tail()
+	}
+
+	if target != "" && c.GlobalPath("build") != "" {
+		logger.Fatalf("--build and the target %s can't be combined\n", target)
+	}
+	return target, args
+}
+
+// buildDir returns the directory to build, the target if there is one,
+// otherwise the --build path, which defaults to the watched --path
+func buildDir(c *gin.Context, target string) string {
+	if target != "" {
+		return target
+	}
+	if buildPath := c.GlobalPath("build"); buildPath != "" {
+		return buildPath
+	}
+	return c.GlobalPath("path")
+}
+
+// isPackagePath reports whether the argument of gin run names a package
+// directory the way go build takes them, rather than an argument of the app
+func isPackagePath(arg string) bool {
+	for _, prefix := range []string{"./", "../", "." + string(filepath.Separator), ".." + string(filepath.Separator)} {
+		if strings.HasPrefix(arg, prefix) {
+			return true
+		}
+	}
+	return arg == "." || arg == ".."
 }
 
-func envAction(c *gin.Context) {
-	logPrefix := c.GlobalString("logPrefix")
-	logger.SetPrefix(fmt.Sprintf("[%s] ", logPrefix))
+// binaryName returns the name of the binary, --bin unless it isn't set and
+// there is a target to name it after
+func binaryName(c *gin.Context, target string) string {
+	if target == "" || c.GlobalIsSet("bin") {
+		return c.GlobalString("bin")
+	}
+	return gin.TargetBinary(target)
+}
 
-	// Bootstrap the environment
-	env, err := gin.Bootstrap()
+// build builds and starts the app, cause describes why, e.g. the changed
+// files, empty for the first build. It returns the error of the build.
+func build(ctx context.Context, builder gin.Builder, runner gin.Runner, logger *log.Logger, cause string, changed []string) error {
+	if cause == "" {
+		gin.Infof("Building...\n")
+	} else {
+		gin.Infof("Rebuilding (%s)...\n", cause)
+	}
+	updateStatus(gin.StatusBuilding)
+	notifyPlugins(gin.PluginEvent{Event: gin.PluginBuildStart, Cause: cause})
+
+	start := time.Now()
+	// files saved during the build are newer than the snapshot, so the next
+	// start of gin still sees them as changed
+	snapshot := buildState.Snapshot()
+	span := gin.SpanFromContext(ctx).Child("build", gin.SpanInternal)
+	err := builder.BuildContext(ctx)
+	if err == gin.ErrBuildCanceled {
+		span.SetAttribute("gin.canceled", true)
+		span.End()
+		return err
+	}
+	span.SetError(err)
+	span.End()
+	notifyPlugins(gin.PluginEvent{
+		Event:    gin.PluginBuildFinish,
+		Cause:    cause,
+		Failed:   err != nil,
+		Errors:   builder.Errors(),
+		Duration: time.Since(start).Milliseconds(),
+	})
+	if dashboard != nil {
+		dashboard.BuildFinished(time.Since(start))
+	}
+	if health != nil {
+		health.BuildFinished(time.Since(start), builder.Errors())
+	}
+	if webhooks != nil {
+		webhooks.Built(err != nil, builder.Errors(), cause)
+	}
+	if err := buildHistory.Add(gin.BuildRecord{
+		Time:     start,
+		Cause:    cause,
+		Files:    changed,
+		Duration: float64(time.Since(start)) / float64(time.Millisecond),
+		Failed:   err != nil,
+	}); err != nil {
+		logger.Println(err)
+	}
 	if err != nil {
-		logger.Fatalln(err)
+		if dashboard != nil {
+			fmt.Fprintln(dashboard, builder.Errors())
+		} else {
+			fmt.Println(builder.Errors())
+		}
+		if !stopOnBuildError && keepRunning(runner) {
+			logger.Printf("%sBuild failed%s, the last working build keeps running\n", colorRed, colorReset)
+			updateStatus(gin.StatusStale)
+		} else {
+			killApp(ctx, runner)
+			logger.Printf("%sBuild failed%s\n", colorRed, colorReset)
+			updateStatus(gin.StatusFailed)
+		}
+	} else {
+		logger.Printf("%sBuild finished%s\n", colorGreen, colorReset)
+		gin.Verbosef("Built in %s\n", time.Since(start).Round(time.Millisecond))
+		updateStatus(gin.StatusOK)
+		panics.Clear()
+		crashLoop.Reset()
+		if err := buildState.Built(snapshot); err != nil {
+			logger.Println(err)
+		}
+		if builds != nil {
+			if _, err := builds.Retain(); err != nil {
+				logger.Println(err)
+			}
+		}
+		killApp(ctx, runner)
+		if immediate {
+			runApp(ctx, runner)
+		}
+	}
+
+	writeDiagnostics(builder)
+	publishDiagnostics(builder)
+
+	time.Sleep(100 * time.Millisecond)
+	return err
+}
+
+// buildAtStartup builds the app unless nothing changed since the last
+// successful build according to the saved build state, naming the files
+// changed while gin wasn't running as the cause of the build
+func buildAtStartup(ctx context.Context, builder gin.Builder, runner gin.Runner, wd string) error {
+	changed, upToDate := buildState.Changes(buildState.Snapshot())
+	if upToDate {
+		gin.Infof("Nothing changed since the last build, using %s\n", builder.Binary())
+		updateStatus(gin.StatusOK)
+		if immediate {
+			runApp(ctx, runner)
+		}
+		return nil
 	}
+	if len(changed) == 0 {
+		return build(ctx, builder, runner, logger, "", nil)
+	}
+
+	files := relativePaths(wd, changed)
+	if health != nil {
+		health.Changed(changed)
+	}
+	cause := strings.Replace(describeChanges(files, nil), " changed:", " changed while gin wasn't running:", 1)
+	return build(ctx, builder, runner, logger, cause, files)
+}
 
-	for k, v := range env {
-		fmt.Printf("%s: %s\n", k, v)
+// runOnce builds the app and runs it until it exits, for gin once. It
+// returns the exit code of the app, or 1 if the build failed.
+func runOnce(builder gin.Builder, runner gin.Runner) int {
+	startup := tracer.Start("startup", gin.SpanInternal, time.Now())
+	ctx := gin.ContextWithSpan(context.Background(), startup)
+	wd, err := os.Getwd()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if err := buildAtStartup(ctx, builder, runner, wd); err != nil {
+		startup.End()
+		closeServices()
+		return 1
 	}
+	runApp(ctx, runner)
+	startup.End()
 
+	r, ok := runner.(interface{ Wait() (int, error) })
+	if !ok {
+		logger.Fatal("The runner can't wait for the app to exit")
+	}
+	code, err := r.Wait()
+	if err != nil {
+		logger.Println(err)
+		code = 1
+	}
+	gin.Verbosef("The app exited with code %d\n", code)
+	closeServices()
+	return code
 }
 
-func build(builder gin.Builder, runner gin.Runner, logger *log.Logger) {
-	logger.Println("Building...")
+// benchReadyTimeout is how long gin bench waits for the app to accept
+// connections after starting it
+const benchReadyTimeout = time.Minute
 
-	err := builder.Build()
+// runBench rebuilds and restarts the app for gin bench, waiting until it
+// accepts connections at the URL proxyTo after each build. It returns the
+// exit code of gin.
+func runBench(c *gin.Context, builder gin.Builder, runner gin.Runner, proxyTo string) int {
+	count := c.Int("count")
+	if count < 1 {
+		logger.Fatal("--count must be at least 1")
+	}
+	var baseline *gin.BenchResult
+	if file := c.Path("baseline"); file != "" {
+		result, err := gin.ReadBenchResult(file)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		baseline = &result
+	}
+	if file := c.Path("touch"); file != "" {
+		var err error
+		if sentinel, err = gin.NewSentinel(file); err != nil {
+			logger.Fatal(err)
+		}
+	}
+	target, err := url.Parse(proxyTo)
 	if err != nil {
-		logger.Printf("%sBuild failed%s\n", colorRed, colorReset)
+		logger.Fatal(err)
+	}
+
+	// the first build fills the build cache, the cycles measure rebuilds
+	gin.Infof("Warming up...\n")
+	if err := builder.Build(); err != nil {
 		fmt.Println(builder.Errors())
-	} else {
-		logger.Printf("%sBuild finished%s\n", colorGreen, colorReset)
-		if immediate {
+		logger.Printf("%sBuild failed%s\n", colorRed, colorReset)
+		return 1
+	}
+
+	samples := make([]gin.BenchSample, 0, count)
+	for i := 1; i <= count; i++ {
+		if err := sentinel.Touch(); err != nil {
+			logger.Println(err)
+			return 1
+		}
+		start := time.Now()
+		if err := builder.Build(); err != nil {
+			fmt.Println(builder.Errors())
+			logger.Printf("%sBuild failed%s\n", colorRed, colorReset)
+			return 1
+		}
+		built := time.Since(start)
+
+		runner.Kill()
+		started := make(chan struct{})
+		go func() {
 			runner.Run()
+			close(started)
+		}()
+		serving, err := gin.WaitServing(target.Host, benchReadyTimeout)
+		<-started
+		if err != nil {
+			logger.Println(err)
+			return 1
 		}
+
+		sample := gin.BenchSample{
+			Build: float64(built) / float64(time.Millisecond),
+			Ready: float64(serving.Sub(start)) / float64(time.Millisecond),
+		}
+		samples = append(samples, sample)
+		gin.Infof("Cycle %d/%d: built in %s, serving after %s\n", i, count, millis(sample.Build), millis(sample.Ready))
+	}
+	runner.Kill()
+	if err := sentinel.Restore(); err != nil {
+		logger.Println(err)
 	}
 
-	time.Sleep(100 * time.Millisecond)
+	result := gin.NewBenchResult(benchFlags(c), samples)
+	if c.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(result)
+	} else {
+		printBench(result, baseline)
+	}
+	if file := c.Path("save"); file != "" {
+		if err := result.Save(file); err != nil {
+			logger.Println(err)
+			return 1
+		}
+		gin.Infof("Saved the result to %s\n", file)
+	}
+	return 0
+}
+
+// printBench prints the stats of result, and their change since baseline
+func printBench(result gin.BenchResult, baseline *gin.BenchResult) {
+	fmt.Printf("\n%-8s %9s %9s %9s %9s\n", "", "min", "avg", "p95", "max")
+	rows := []struct {
+		name      string
+		stats     gin.BenchStats
+		baseStats gin.BenchStats
+	}{
+		{"build", result.Build, gin.BenchStats{}},
+		{"ready", result.Ready, gin.BenchStats{}},
+	}
+	if baseline != nil {
+		rows[0].baseStats, rows[1].baseStats = baseline.Build, baseline.Ready
+	}
+	for _, row := range rows {
+		s := row.stats
+		fmt.Printf("%-8s %9s %9s %9s %9s\n", row.name, millis(s.Min), millis(s.Average), millis(s.P95), millis(s.Max))
+	}
+	if baseline == nil {
+		return
+	}
+
+	flags := baseline.Flags
+	if flags == "" {
+		flags = "default flags"
+	}
+	fmt.Printf("\nChange since the baseline of %s (%s):\n", baseline.Time.Format("2006-01-02 15:04"), flags)
+	for _, row := range rows {
+		s, b := row.stats, row.baseStats
+		fmt.Printf("%-8s %9s %9s %9s %9s\n", row.name, percent(b.Min, s.Min), percent(b.Average, s.Average), percent(b.P95, s.P95), percent(b.Max, s.Max))
+	}
 }
 
-type scanCallback func(path string)
+// percent formats the change from baseline to current, faster in green and
+// slower in red
+func percent(baseline, current float64) string {
+	change := gin.Change(baseline, current)
+	text := fmt.Sprintf("%+.0f%%", change*100)
+	// pad before coloring, the escape codes have no width
+	text = fmt.Sprintf("%9s", text)
+	switch {
+	case change <= -0.05:
+		return colorGreen + text + colorReset
+	case change >= 0.05:
+		return colorRed + text + colorReset
+	}
+	return text
+}
 
-func scanChanges(watchPath string, excludeDirs []string, allFiles bool, cb scanCallback) {
-	for {
-		filepath.Walk(watchPath, func(path string, info os.FileInfo, err error) error {
-			if path == ".git" && info.IsDir() {
-				return filepath.SkipDir
+// benchFlags describes the build flags of gin bench, e.g. "-tags dev -race"
+func benchFlags(c *gin.Context) string {
+	var flags []string
+	for _, name := range []string{"tags", "ldflags", "gcflags"} {
+		if value := c.GlobalString(name); value != "" {
+			flags = append(flags, "-"+name+" "+value)
+		}
+	}
+	if c.GlobalBool("race") {
+		flags = append(flags, "-race")
+	}
+	if buildArgs := c.GlobalString("buildArgs"); buildArgs != "" {
+		flags = append(flags, buildArgs)
+	}
+	return strings.Join(flags, " ")
+}
+
+// keepRunning starts the app of the last working build again if it was
+// stopped for the build, and reports whether it runs
+func keepRunning(runner gin.Runner) bool {
+	if _, err := runner.Info(); err != nil {
+		return false
+	}
+	if immediate {
+		runner.Run()
+	}
+	r, ok := runner.(interface{ ProcessInfo() gin.ProcessInfo })
+	return ok && r.ProcessInfo().Running
+}
+
+// publishDiagnostics pushes the errors of the last build to the clients of
+// the control API's events endpoint as LSP publishDiagnostics notifications
+func publishDiagnostics(builder gin.Builder) {
+	if control == nil {
+		return
+	}
+
+	diagnostics := builder.Diagnostics()
+	for _, notification := range gin.PublishDiagnostics(diagnostics, published) {
+		if err := control.Broadcast(notification); err != nil {
+			logger.Println(err)
+		}
+	}
+
+	published = published[:0]
+	for _, d := range diagnostics {
+		published = appendUnique(published, d.File)
+	}
+}
+
+// writeDiagnostics writes the errors of the last build to the diagnostics
+// file, if enabled, so editors pick them up. Successful builds clear it.
+func writeDiagnostics(builder gin.Builder) {
+	if diagnosticsFile == "" {
+		return
+	}
+
+	format := diagnosticsFormat
+	if format == "" {
+		format = "json"
+	}
+
+	if err := os.MkdirAll(filepath.Dir(diagnosticsFile), 0755); err != nil {
+		logger.Println(err)
+		return
+	}
+	file, err := os.Create(diagnosticsFile)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	defer file.Close()
+
+	if err := gin.WriteDiagnostics(file, format, builder.Diagnostics()); err != nil {
+		logger.Println(err)
+	}
+}
+
+// alignOutput makes gin's own messages line up with the tagged or
+// timestamped output of the app, logPrefix tags gin's messages
+func alignOutput(c *gin.Context, logPrefix string) {
+	app := c.GlobalString("logPrefixApp")
+	timestamps := c.GlobalBool("timestamps")
+	if app == "" && !timestamps {
+		return
+	}
+
+	tag, _ := logTags(logPrefix, app)
+	if app != "" {
+		logger.SetPrefix(tag)
+	}
+	// the messages of the packages gin uses get the same prefix instead of
+	// the date
+	log.SetFlags(0)
+	log.SetPrefix(logger.Prefix())
+	if timestamps {
+		logger.SetOutput(gin.NewPrefixWriter(os.Stdout, "", true))
+		log.SetOutput(gin.NewPrefixWriter(os.Stderr, "", true))
+	}
+}
+
+// logTags returns the tags of gin's messages and of the app output, e.g.
+// "[gin] " and "[app] ", padded to the same width. Without an app tag gin's
+// is unpadded.
+func logTags(own, app string) (string, string) {
+	ginTag, appTag := "["+own+"]", "["+app+"]"
+	width := len(ginTag)
+	if len(appTag) > width {
+		width = len(appTag)
+	}
+	if app == "" {
+		return ginTag + " ", ""
+	}
+	return fmt.Sprintf("%-*s ", width, ginTag), fmt.Sprintf("%-*s ", width, appTag)
+}
+
+// childOutput returns the writers receiving the stdout and stderr of the app,
+// prefixed and teed into the log file as configured, on top of stdout and
+// stderr
+func childOutput(c *gin.Context, wd string, stdout io.Writer, stderr io.Writer) (io.Writer, io.Writer) {
+	timestamps := c.GlobalBool("timestamps")
+	var outPrefix, errPrefix string
+	if name := c.GlobalString("logPrefixApp"); name != "" {
+		_, tag := logTags(c.GlobalString("logPrefix"), name)
+		if gin.ColorTerminal(os.Stdout) {
+			tag = strings.Replace(tag, "["+name+"]", colorCyan+"["+name+"]"+colorReset, 1)
+		}
+		outPrefix, errPrefix = tag, tag
+	}
+	if c.GlobalBool("prefixOutput") {
+		outPrefix += "out| "
+		errPrefix += colorRed + "err|" + colorReset + " "
+	}
+	if outPrefix != "" || timestamps {
+		stdout = gin.NewPrefixWriter(stdout, outPrefix, timestamps)
+		stderr = gin.NewPrefixWriter(stderr, errPrefix, timestamps)
+	}
+
+	stderr = gin.NewPanicWriter(stderr, panics, wd, c.GlobalString("links"))
+
+	if path := c.GlobalPath("logFile"); path != "" {
+		maxSize, err := gin.ParseBytes(c.GlobalString("logMaxSize"))
+		if err != nil {
+			logger.Fatal(err)
+		}
+		file, err := gin.NewRotatingFile(path, maxSize, c.GlobalInt("logKeep"))
+		if err != nil {
+			logger.Fatal(err)
+		}
+		// the file always tells the streams and times apart
+		stdout = io.MultiWriter(stdout, gin.NewPrefixWriter(file, "out| ", true))
+		stderr = io.MultiWriter(stderr, gin.NewPrefixWriter(file, "err| ", true))
+	}
+
+	return stdout, stderr
+}
+
+// swap stops the app and replaces its binary with the retained build id, or
+// the most recent one for "latest"
+func swap(builds *gin.BuildStore, runner gin.Runner, id string) (*gin.RetainedBuild, error) {
+	if builds == nil {
+		return nil, fmt.Errorf("builds are not retained, pass --keepBuilds")
+	}
+
+	var (
+		build *gin.RetainedBuild
+		err   error
+	)
+	if id == "" || id == "latest" {
+		build, err = builds.Latest()
+	} else if n, convErr := strconv.Atoi(strings.TrimPrefix(id, "#")); convErr != nil {
+		err = fmt.Errorf("invalid build id %q", id)
+	} else {
+		build, err = builds.Get(n)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	runner.Kill()
+	if err := builds.Restore(build.ID); err != nil {
+		return nil, err
+	}
+	logger.Printf("Swapped in build #%d from %s\n", build.ID, build.Time.Format("15:04:05"))
+	if immediate {
+		runner.Run()
+	}
+	return build, nil
+}
+
+func updateStatus(state string) {
+	if dashboard != nil {
+		dashboard.SetState(state)
+	}
+	if err := status.Update(state); err != nil {
+		logger.Println(err)
+	}
+}
+
+// remoteHostname returns the host name of an ssh destination such as
+// user@host or ssh://user@host:22
+func remoteHostname(destination string) string {
+	host := strings.TrimPrefix(destination, "ssh://")
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host
+}
+
+// childEnv loads the env files and adds the PORT the app should bind to
+func childEnv(envFiles []string, appPort string) gin.Env {
+	env, err := gin.BootstrapEnv(gin.Env{"PORT": appPort}, envFiles...)
+	if err != nil && !os.IsNotExist(err) {
+		logger.Println(err)
+	}
+	// the env section of the config file only sets variables missing from
+	// gin's environment and the .env files
+	for name, value := range configEnv {
+		if _, ok := env[name]; ok {
+			continue
+		}
+		if _, ok := os.LookupEnv(name); !ok {
+			env[name] = value
+		}
+	}
+	env["PORT"] = appPort
+	// the app writes to a pipe, tell it that its output ends up in a
+	// terminal showing colors
+	if gin.ColorTerminal(os.Stdout) {
+		for _, name := range []string{"FORCE_COLOR", "CLICOLOR_FORCE"} {
+			if _, ok := os.LookupEnv(name); !ok && env[name] == "" {
+				env[name] = "1"
 			}
-			for _, x := range excludeDirs {
-				if x == path {
-					return filepath.SkipDir
-				}
+		}
+	}
+	return env
+}
+
+// restart starts the already built binary again with a refreshed
+// environment, cause describes why
+func restart(ctx context.Context, runner gin.Runner, env gin.Env, cause string) {
+	gin.Infof("Restarting (%s)...\n", cause)
+
+	runner.SetEnv(env)
+	if immediate {
+		runApp(ctx, runner)
+	}
+}
+
+// signalApp sends sig to the running app instead of restarting it, cause
+// describes why. It reports whether the app got the signal.
+func signalApp(ctx context.Context, runner gin.Runner, sig os.Signal, cause string) bool {
+	r, ok := runner.(interface{ Signal(os.Signal) error })
+	if !ok {
+		return false
+	}
+	gin.Infof("Sending %s (%s)\n", gin.SignalName(sig), cause)
+
+	span := gin.SpanFromContext(ctx).Child("signal", gin.SpanInternal)
+	span.SetAttribute("gin.signal", gin.SignalName(sig))
+	err := r.Signal(sig)
+	span.SetError(err)
+	span.End()
+	if err != nil {
+		gin.Verbosef("Restarting instead, can't signal the app: %v\n", err)
+		return false
+	}
+	return true
+}
+
+// traceChanges starts the trace of a reload cycle, its detect span lasts
+// from the last change to files until now
+func traceChanges(files []string) *gin.Span {
+	if tracer == nil {
+		return nil
+	}
+	now := time.Now()
+	var changed time.Time
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(changed) && !info.ModTime().After(now) {
+			changed = info.ModTime()
+		}
+	}
+	if changed.IsZero() {
+		changed = now
+	}
+	cycle := tracer.Start("reload", gin.SpanInternal, changed)
+	cycle.SetAttribute("gin.files", len(files))
+	cycle.ChildAt("detect", gin.SpanInternal, changed).EndAt(now)
+	return cycle
+}
+
+// killApp stops the app, tracing it below the span of ctx
+func killApp(ctx context.Context, runner gin.Runner) {
+	span := gin.SpanFromContext(ctx).Child("kill", gin.SpanInternal)
+	span.SetError(runner.Kill())
+	span.End()
+}
+
+// runApp starts the app and waits until it is ready, tracing both below
+// the span of ctx. Runners which support it stop waiting once ctx is
+// canceled.
+func runApp(ctx context.Context, runner gin.Runner) {
+	parent := gin.SpanFromContext(ctx)
+	begun := time.Now()
+	start := parent.ChildAt("start", gin.SpanInternal, begun)
+	var err error
+	if r, ok := runner.(interface {
+		RunContext(context.Context) (*exec.Cmd, error)
+	}); ok {
+		_, err = r.RunContext(ctx)
+	} else {
+		_, err = runner.Run()
+	}
+	if r, ok := runner.(interface{ ProcessInfo() gin.ProcessInfo }); ok && err == nil {
+		// the process started before Run waited for it to be ready
+		info := r.ProcessInfo()
+		if !info.Started.Before(begun) {
+			start.SetAttribute("process.pid", info.Pid)
+			start.EndAt(info.Started)
+			parent.ChildAt("ready", gin.SpanInternal, info.Started).End()
+			return
+		}
+	}
+	start.SetError(err)
+	start.End()
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+// drain collects events that arrive in quick succession, so a burst of
+// changes results in a single rebuild.
+func drain(events <-chan gin.Event) []gin.Event {
+	var drained []gin.Event
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return drained
 			}
+			drained = append(drained, ev)
+		case <-time.After(100 * time.Millisecond):
+			return drained
+		}
+	}
+}
 
-			// ignore hidden files
-			if filepath.Base(path)[0] == '.' {
-				return nil
+// openTunnel exposes the proxy listening at proxyURL through the named
+// tunnel provider
+func openTunnel(name, proxyURL string) {
+	target, err := url.Parse(proxyURL)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if host, port, err := net.SplitHostPort(target.Host); err == nil && (host == "" || net.ParseIP(host) == nil || net.ParseIP(host).IsUnspecified()) {
+		target.Host = net.JoinHostPort("localhost", port)
+	}
+
+	gin.Verbosef("Opening a tunnel to %s with %s\n", target, name)
+	t, err := gin.NewTunnel(name, target.String())
+	if err != nil {
+		logger.Printf("Can't open the tunnel: %s\n", err)
+		return
+	}
+	tunnel = t
+	gin.Infof("Public URL %s\n", t.URL())
+}
+
+// printReachable lists the URLs to open when the proxy listens on all
+// addresses, localhost and the ones of this machine on its networks
+func printReachable(proxyURLs []string) {
+	urls := gin.ReachableURLs(proxyURLs)
+	if strings.Join(urls, " ") == strings.Join(proxyURLs, " ") {
+		return
+	}
+	for _, u := range urls {
+		label := "Network:"
+		if parsed, err := url.Parse(u); err == nil && (parsed.Hostname() == "localhost" || isLoopback(parsed.Host)) {
+			label = "Local:"
+		}
+		gin.Infof("  %-8s %s\n", label, u)
+	}
+}
+
+// showQRCodes prints QR codes of the proxyURLs other devices on the local
+// network can open, or shows them on the dashboard, and again whenever the
+// address of this machine changes. It reports whether there were any.
+func showQRCodes(proxyURLs []string) bool {
+	show := func(urls []string) {
+		if dashboard != nil {
+			if err := dashboard.ShowQRCodes(urls); err != nil {
+				logger.Printf("Can't show the QR code: %s\n", err)
 			}
+			return
+		}
+		lines, err := gin.QRCodeLines(urls)
+		if err != nil {
+			logger.Printf("Can't print the QR code: %s\n", err)
+			return
+		}
+		fmt.Fprintln(logger.Writer(), strings.Join(lines, "\n"))
+	}
 
-			if (allFiles || filepath.Ext(path) == ".go") && info.ModTime().After(startTime) {
-				cb(path)
-				startTime = time.Now()
-				return errors.New("done")
+	urls := gin.LANURLs(proxyURLs)
+	if len(urls) == 0 {
+		return false
+	}
+	show(urls)
+
+	go func() {
+		for range time.Tick(gin.AddressInterval) {
+			current := gin.LANURLs(proxyURLs)
+			if len(current) == 0 || strings.Join(current, " ") == strings.Join(urls, " ") {
+				continue
 			}
+			urls = current
+			gin.Infof("The address changed, the proxy is now reachable at %s\n", strings.Join(urls, ", "))
+			show(urls)
+		}
+	}()
+	return true
+}
 
-			return nil
-		})
-		time.Sleep(500 * time.Millisecond)
+// advertiseMDNS answers mDNS queries for name with the addresses of this
+// machine, so other devices can reach the proxy listening at proxyURLs
+func advertiseMDNS(name string, proxyURLs []string) {
+	port := 0
+	scheme := "http"
+	local := true
+	for _, proxyURL := range proxyURLs {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			continue
+		}
+		if port == 0 {
+			port, _ = strconv.Atoi(u.Port())
+			scheme = u.Scheme
+		}
+		if !isLoopback(u.Host) && u.Hostname() != "localhost" {
+			local = false
+		}
+	}
+
+	m, err := gin.AdvertiseMDNS(name, port)
+	if err != nil {
+		logger.Printf("Can't advertise the proxy: %s\n", err)
+		return
+	}
+	mdns = m
+	gin.Infof("Advertising %s://%s:%d\n", scheme, m.Hostname(), port)
+	if local {
+		logger.Printf("%sWarning:%s the proxy only accepts local connections, pass --laddr 0.0.0.0 so other devices can reach %s\n", colorRed, colorReset, m.Hostname())
+	}
+}
+
+// closeServices stops the tunnel and the mDNS advertisement, and exports
+// the remaining spans
+func closeServices() {
+	if summary := buildHistory.Summary(); summary.Builds > 0 {
+		gin.Infof("%s this session\n", summary)
+	}
+	if tunnel != nil {
+		tunnel.Close()
+	}
+	if mdns != nil {
+		mdns.Close()
 	}
+	if daemon != nil {
+		daemon.Remove()
+	}
+	for _, plugin := range plugins {
+		plugin.Close(3 * time.Second)
+	}
+	tracer.Close(3 * time.Second)
+	if err := sentinel.Restore(); err != nil {
+		logger.Println(err)
+	}
+	systemd.Close()
 }
 
+// shutdown stops the app and gin on an interrupt, and forwards the other
+// signals gin receives to the app
 func shutdown(runner gin.Runner) {
 	c := make(chan os.Signal, 2)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		s := <-c
-		log.Println("Got signal: ", s)
-		err := runner.Kill()
-		if err != nil {
-			log.Print("Error killing: ", err)
+		stop(runner, <-c)
+	}()
+
+	r, ok := runner.(interface{ Signal(os.Signal) error })
+	if !ok || len(gin.ForwardedSignals) == 0 {
+		return
+	}
+	forward := make(chan os.Signal, 4)
+	signal.Notify(forward, gin.ForwardedSignals...)
+	terminal := gin.HasTerminal()
+	go func() {
+		for s := range forward {
+			// gin also gets SIGHUP when its terminal is closed
+			if s == syscall.SIGHUP && terminal && !gin.HasTerminal() {
+				stop(runner, s)
+			}
+			gin.Verbosef("Forwarding %s to the app\n", gin.SignalName(s))
+			if err := r.Signal(s); err != nil {
+				logger.Printf("Can't forward %s: %v\n", gin.SignalName(s), err)
+			}
 		}
-		os.Exit(1)
 	}()
 }
+
+// stop stops the app and gin after receiving s
+func stop(runner gin.Runner, s os.Signal) {
+	if dashboard != nil {
+		dashboard.Close()
+	}
+	log.SetOutput(os.Stderr)
+	log.Println("Got signal: ", s)
+	closeServices()
+	err := runner.Kill()
+	if err != nil {
+		log.Print("Error killing: ", err)
+	}
+	os.Exit(1)
+}